@@ -0,0 +1,130 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Matbe34/lankir/internal/config"
+	"github.com/Matbe34/lankir/internal/signature"
+	"github.com/spf13/cobra"
+)
+
+var (
+	archiveWithinDays   int
+	archiveTimestampURL string
+	archiveCertFiles    []string
+	archiveOCSPFiles    []string
+	archiveCRLFiles     []string
+	archiveOffline      bool
+	archiveDryRun       bool
+	archiveManifest     string
+)
+
+var archiveCmd = &cobra.Command{
+	Use:   "archive",
+	Short: "Maintain archives of signed documents",
+	Long:  `Keep archives of signed PDFs verifiable in the long term (PAdES-LTA).`,
+}
+
+var archiveRenewCmd = &cobra.Command{
+	Use:   "renew <directory>",
+	Short: "Renew the document timestamps of an archive",
+	Long: `Scan a directory recursively for signed PDFs and renew those whose latest document timestamp
+expires within --within days, uses a digest the algorithm policy no longer accepts, or that have
+no document timestamp yet. A renewal stores the validation data of all signatures and timestamps
+in the DSS and adds a new document timestamp, so the chain of timestamps stays verifiable after
+the previous TSA certificate expires.
+
+Files are updated in place with incremental updates. Every renewal is recorded in the manifest,
+by default lankir-archive.json in the directory. Use --dry-run to only list the documents due.
+
+The TSA is taken from the configuration unless --timestamp-url is given. The command exits with
+status 1 when a document could not be renewed.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dir := args[0]
+
+		if archiveWithinDays < 0 {
+			ExitWithError("invalid --within value", fmt.Errorf("days must not be negative"))
+		}
+
+		cfgService, err := config.NewService()
+		if err != nil {
+			ExitWithError("failed to initialize config service", err)
+		}
+		service := signature.NewSignatureService(cfgService)
+
+		GetLogger().Info("renewing archive", "dir", SanitizePath(dir), "within_days", archiveWithinDays, "dry_run", archiveDryRun)
+
+		result, err := service.RenewArchive(dir, signature.ArchiveRenewOptions{
+			Within:           time.Duration(archiveWithinDays) * 24 * time.Hour,
+			TimestampURL:     archiveTimestampURL,
+			CertificateFiles: archiveCertFiles,
+			OCSPFiles:        archiveOCSPFiles,
+			CRLFiles:         archiveCRLFiles,
+			Offline:          archiveOffline,
+			DryRun:           archiveDryRun,
+			ManifestPath:     archiveManifest,
+		})
+		if err != nil {
+			ExitWithError("failed to renew archive", err)
+		}
+
+		if jsonOutput {
+			data, err := json.MarshalIndent(result, "", "  ")
+			if err != nil {
+				ExitWithError("failed to marshal result to JSON", err)
+			}
+			fmt.Println(string(data))
+		} else {
+			for _, f := range result.Files {
+				line := fmt.Sprintf("%-9s %s", f.Status, f.File)
+				switch {
+				case f.Error != "":
+					line += " (" + f.Error + ")"
+				case f.Reason != "":
+					line += " (" + f.Reason + ")"
+				case f.ExpiresAt != nil:
+					line += " (timestamp valid until " + f.ExpiresAt.Format("2006-01-02") + ")"
+				}
+				fmt.Println(line)
+				if f.RenewedUntil != nil {
+					fmt.Printf("          new timestamp valid until %s\n", f.RenewedUntil.Format("2006-01-02"))
+				}
+				for _, w := range f.Warnings {
+					fmt.Printf("          warning: %s\n", w)
+				}
+			}
+
+			if result.DryRun {
+				fmt.Printf("\n%d file(s): %d due for renewal\n", len(result.Files), result.Due)
+			} else {
+				fmt.Printf("\n%d file(s): %d renewed, %d failed\n", len(result.Files), result.Renewed, result.Failed)
+				if result.Renewed > 0 {
+					fmt.Printf("Manifest: %s\n", result.ManifestPath)
+				}
+			}
+		}
+
+		if result.Failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(archiveCmd)
+	archiveCmd.AddCommand(archiveRenewCmd)
+
+	archiveRenewCmd.Flags().BoolVarP(&jsonOutput, "json", "j", false, "output in JSON format")
+	archiveRenewCmd.Flags().IntVar(&archiveWithinDays, "within", 90, "renew timestamps expiring within this many days")
+	archiveRenewCmd.Flags().StringVar(&archiveTimestampURL, "timestamp-url", "", "RFC 3161 TSA to use instead of the configured one")
+	archiveRenewCmd.Flags().StringSliceVar(&archiveCertFiles, "cert", nil, "certificate files (PEM or DER) to complete the chains with")
+	archiveRenewCmd.Flags().StringSliceVar(&archiveOCSPFiles, "ocsp", nil, "DER encoded OCSP response files to use")
+	archiveRenewCmd.Flags().StringSliceVar(&archiveCRLFiles, "crl", nil, "CRL files (PEM or DER) to use")
+	archiveRenewCmd.Flags().BoolVar(&archiveOffline, "offline", false, "do not fetch validation data from the network (the TSA is still contacted)")
+	archiveRenewCmd.Flags().BoolVar(&archiveDryRun, "dry-run", false, "only list the documents due for renewal")
+	archiveRenewCmd.Flags().StringVar(&archiveManifest, "manifest", "", "renewal manifest (default: lankir-archive.json in the directory)")
+}
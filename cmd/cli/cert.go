@@ -3,8 +3,13 @@ package cli
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Matbe34/lankir/internal/config"
 	"github.com/Matbe34/lankir/internal/signature"
@@ -23,6 +28,19 @@ var (
 	certSearch    string
 	certValidOnly bool
 	certShowAll   bool
+	certStatus    bool
+	certWithin    string
+
+	certKeyUsage      string
+	certEKU           string
+	certIssuer        string
+	certKeyAlgorithm  string
+	certMinKeySize    int
+	certPrivateKey    bool
+	certExpiresBefore string
+	certQualified     bool
+	certSortBy        string
+	certSortDesc      bool
 )
 
 var certListCmd = &cobra.Command{
@@ -40,9 +58,26 @@ var certListCmd = &cobra.Command{
 		GetLogger().Info("listing certificates", "source", certSource, "valid_only", certValidOnly)
 
 		filter := signature.CertificateFilter{
-			Source:    certSource,
-			Search:    certSearch,
-			ValidOnly: certValidOnly,
+			Source:           certSource,
+			Search:           certSearch,
+			ValidOnly:        certValidOnly,
+			RequiredKeyUsage: certKeyUsage,
+			ExtendedKeyUsage: certEKU,
+			Issuer:           certIssuer,
+			KeyAlgorithm:     certKeyAlgorithm,
+			MinKeySize:       certMinKeySize,
+			PrivateKeyOnly:   certPrivateKey,
+			QualifiedOnly:    certQualified,
+			SortBy:           certSortBy,
+			SortDescending:   certSortDesc,
+		}
+
+		if certExpiresBefore != "" {
+			expiresBefore, err := parseExpiresBefore(certExpiresBefore)
+			if err != nil {
+				ExitWithError("invalid --expires-before value", err)
+			}
+			filter.ExpiresBefore = expiresBefore
 		}
 
 		certs, err := service.ListCertificatesFiltered(filter)
@@ -52,13 +87,27 @@ var certListCmd = &cobra.Command{
 
 		GetLogger().Debug("certificates retrieved", "count", len(certs))
 
+		inventory, err := service.ListCertificatesWithStatus()
+		if err != nil {
+			ExitWithError("failed to get certificate source status", err)
+		}
+
 		if jsonOutput {
-			data, err := json.MarshalIndent(certs, "", "  ")
+			var output interface{} = certs
+			if certStatus {
+				output = signature.CertificateListResult{
+					Certificates: certs,
+					Sources:      inventory.Sources,
+				}
+			}
+			data, err := json.MarshalIndent(output, "", "  ")
 			if err != nil {
 				ExitWithError("failed to marshal certificates to JSON", err)
 			}
 			fmt.Println(string(data))
 		} else {
+			printSourceStatus(inventory.Sources, certStatus)
+
 			fmt.Printf("Found %d certificate(s):\n\n", len(certs))
 
 			for i, cert := range certs {
@@ -92,6 +141,19 @@ var certListCmd = &cobra.Command{
 				if len(cert.KeyUsage) > 0 {
 					fmt.Printf("  Key Usage:     %s\n", strings.Join(cert.KeyUsage, ", "))
 				}
+				if len(cert.ExtendedKeyUsage) > 0 {
+					fmt.Printf("  Ext Key Usage: %s\n", strings.Join(cert.ExtendedKeyUsage, ", "))
+				}
+				if cert.KeyAlgorithm != "" {
+					fmt.Printf("  Key:           %s %d bits\n", cert.KeyAlgorithm, cert.KeySize)
+				}
+				fmt.Printf("  Private Key:   %v\n", cert.HasPrivateKey)
+				if len(cert.Locations) > 1 {
+					printCertificateLocations(cert.Locations)
+				}
+				if cert.IsQualified {
+					fmt.Printf("  Qualified:     yes\n")
+				}
 				fmt.Println()
 			}
 		}
@@ -166,80 +228,335 @@ var certInfoCmd = &cobra.Command{
 
 		GetLogger().Info("retrieving certificate info", "fingerprint", fingerprint)
 
-		certs, err := service.ListCertificates()
-		if err != nil {
-			ExitWithError("failed to list certificates", err)
+		targetCert, err := service.GetCertificateByFingerprint(fingerprint)
+		if errors.Is(err, signature.ErrCertificateNotFound) {
+			ExitWithError(fmt.Sprintf("certificate not found: %s", fingerprint), nil)
 		}
-
-		var targetCert *types.Certificate
-		for _, cert := range certs {
-			if cert.Fingerprint == fingerprint {
-				targetCert = &cert
-				break
-			}
+		if err != nil {
+			ExitWithError("failed to look up certificate", err)
 		}
 
-		if targetCert == nil {
-			ExitWithError(fmt.Sprintf("certificate not found: %s", fingerprint), nil)
+		details, err := service.GetCertificateDetails(fingerprint)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
 		}
 
 		if jsonOutput {
-			data, err := json.MarshalIndent(targetCert, "", "  ")
+			var output interface{} = targetCert
+			if details != nil {
+				output = details
+			}
+			data, err := json.MarshalIndent(output, "", "  ")
 			if err != nil {
 				ExitWithError("failed to marshal certificate to JSON", err)
 			}
 			fmt.Println(string(data))
-		} else {
-			fmt.Printf("Certificate Information:\n\n")
-			fmt.Printf("  Name:          %s\n", targetCert.Name)
-			fmt.Printf("  Subject:       %s\n", targetCert.Subject)
-			fmt.Printf("  Issuer:        %s\n", targetCert.Issuer)
-			fmt.Printf("  Serial Number: %s\n", targetCert.SerialNumber)
-			fmt.Printf("  Valid From:    %s\n", targetCert.ValidFrom)
-			fmt.Printf("  Valid To:      %s\n", targetCert.ValidTo)
-			fmt.Printf("  Fingerprint:   %s\n", targetCert.Fingerprint)
-			fmt.Printf("  Source:        %s\n", targetCert.Source)
-			fmt.Printf("  Is Valid:      %v\n", targetCert.IsValid)
-			fmt.Printf("  Can Sign:      %v\n", targetCert.CanSign)
-			fmt.Printf("  Requires PIN:  %v\n", targetCert.RequiresPin)
-			fmt.Printf("  PIN Optional:  %v\n", targetCert.PinOptional)
-
-			if targetCert.FilePath != "" {
-				fmt.Printf("  File Path:     %s\n", targetCert.FilePath)
-			}
-			if targetCert.PKCS11Module != "" {
-				fmt.Printf("  PKCS11 Module: %s\n", targetCert.PKCS11Module)
-			}
-			if targetCert.PKCS11URL != "" {
-				fmt.Printf("  PKCS11 URL:    %s\n", targetCert.PKCS11URL)
-			}
-			if targetCert.NSSNickname != "" {
-				fmt.Printf("  NSS Nickname:  %s\n", targetCert.NSSNickname)
-			}
+			return
+		}
 
+		fmt.Printf("Certificate Information:\n\n")
+		fmt.Printf("  Name:          %s\n", targetCert.Name)
+		fmt.Printf("  Subject:       %s\n", targetCert.Subject)
+		fmt.Printf("  Issuer:        %s\n", targetCert.Issuer)
+		fmt.Printf("  Serial Number: %s\n", targetCert.SerialNumber)
+		fmt.Printf("  Valid From:    %s\n", targetCert.ValidFrom)
+		fmt.Printf("  Valid To:      %s\n", targetCert.ValidTo)
+		fmt.Printf("  Fingerprint:   %s\n", targetCert.Fingerprint)
+		fmt.Printf("  Source:        %s\n", targetCert.Source)
+		fmt.Printf("  Is Valid:      %v\n", targetCert.IsValid)
+		fmt.Printf("  Can Sign:      %v\n", targetCert.CanSign)
+		fmt.Printf("  Private Key:   %v\n", targetCert.HasPrivateKey)
+		fmt.Printf("  Requires PIN:  %v\n", targetCert.RequiresPin)
+		fmt.Printf("  PIN Optional:  %v\n", targetCert.PinOptional)
+
+		if targetCert.FilePath != "" {
+			fmt.Printf("  File Path:     %s\n", targetCert.FilePath)
+		}
+		if targetCert.PKCS11Module != "" {
+			fmt.Printf("  PKCS11 Module: %s\n", targetCert.PKCS11Module)
+		}
+		if targetCert.PKCS11URL != "" {
+			fmt.Printf("  PKCS11 URL:    %s\n", targetCert.PKCS11URL)
+		}
+		if targetCert.NSSNickname != "" {
+			fmt.Printf("  NSS Nickname:  %s\n", targetCert.NSSNickname)
+		}
+
+		if len(targetCert.Locations) > 1 {
+			printCertificateLocations(targetCert.Locations)
+		}
+
+		if details == nil {
 			if len(targetCert.KeyUsage) > 0 {
 				fmt.Printf("\n  Key Usage:\n")
 				for _, usage := range targetCert.KeyUsage {
 					fmt.Printf("    - %s\n", usage)
 				}
 			}
+			return
 		}
+
+		printCertificateDetails(details)
 	},
 }
 
+// printCertificateLocations lists every backend holding a certificate and whether it has the key.
+func printCertificateLocations(locations []types.CertificateLocation) {
+	fmt.Printf("  Locations:\n")
+	for _, loc := range locations {
+		where := loc.FilePath
+		switch {
+		case loc.PKCS11URL != "":
+			where = loc.PKCS11URL
+		case loc.NSSNickname != "":
+			where = loc.NSSNickname
+		}
+
+		key := "no private key"
+		if loc.HasPrivateKey {
+			key = "private key"
+		}
+		fmt.Printf("    - %s: %s (%s)\n", loc.Source, where, key)
+	}
+}
+
+// printCertificateDetails prints the decoded fields, extensions and chain of a certificate.
+func printCertificateDetails(d *types.CertificateDetails) {
+	fmt.Printf("\n  Subject DN:    %s\n", d.SubjectDN)
+	fmt.Printf("  Issuer DN:     %s\n", d.IssuerDN)
+	fmt.Printf("  Serial (hex):  %s\n", d.SerialNumberHex)
+	fmt.Printf("  Version:       %d\n", d.Version)
+	fmt.Printf("  Signature:     %s\n", d.SignatureAlgorithm)
+	if d.KeyCurve != "" {
+		fmt.Printf("  Public Key:    %s %d bits (%s)\n", d.KeyAlgorithm, d.KeySize, d.KeyCurve)
+	} else {
+		fmt.Printf("  Public Key:    %s %d bits\n", d.KeyAlgorithm, d.KeySize)
+	}
+	fmt.Printf("  SHA-1:         %s\n", d.FingerprintSHA1)
+	fmt.Printf("  SHA-256:       %s\n", d.FingerprintSHA256)
+	fmt.Printf("  CA:            %v\n", d.IsCA)
+	fmt.Printf("  Self-Signed:   %v\n", d.SelfSigned)
+
+	printList := func(title string, values []string) {
+		if len(values) == 0 {
+			return
+		}
+		fmt.Printf("\n  %s:\n", title)
+		for _, v := range values {
+			fmt.Printf("    - %s\n", v)
+		}
+	}
+
+	printList("Key Usage", d.KeyUsage)
+	printList("Extended Key Usage", d.ExtendedKeyUsage)
+
+	var sans []string
+	for _, v := range d.SubjectAltNames.DNSNames {
+		sans = append(sans, "DNS: "+v)
+	}
+	for _, v := range d.SubjectAltNames.EmailAddresses {
+		sans = append(sans, "Email: "+v)
+	}
+	for _, v := range d.SubjectAltNames.IPAddresses {
+		sans = append(sans, "IP: "+v)
+	}
+	for _, v := range d.SubjectAltNames.URIs {
+		sans = append(sans, "URI: "+v)
+	}
+	printList("Subject Alternative Names", sans)
+
+	printList("Certificate Policies", d.CertificatePolicies)
+	printList("OCSP Servers", d.OCSPServers)
+	printList("CA Issuers", d.IssuingCertificateURL)
+	printList("CRL Distribution Points", d.CRLDistributionPoints)
+
+	var qc []string
+	for _, st := range d.QCStatements {
+		if len(st.Types) > 0 {
+			qc = append(qc, fmt.Sprintf("%s (%s): %s", st.Name, st.OID, strings.Join(st.Types, ", ")))
+		} else {
+			qc = append(qc, fmt.Sprintf("%s (%s)", st.Name, st.OID))
+		}
+	}
+	printList("QC Statements", qc)
+
+	fmt.Printf("\n  Chain:\n")
+	for i, c := range d.Chain {
+		fmt.Printf("    %d. %s\n", i, c.SubjectDN)
+		fmt.Printf("       SHA-256: %s\n", c.FingerprintSHA256)
+	}
+	if d.ChainTrusted && d.TrustAnchor != nil {
+		fmt.Printf("    Trusted: yes (anchor: %s)\n", d.TrustAnchor.Source)
+	} else if d.ChainTrusted {
+		fmt.Printf("    Trusted: yes\n")
+	} else {
+		fmt.Printf("    Trusted: no (%s)\n", d.ChainError)
+	}
+}
+
+var certExpiringCmd = &cobra.Command{
+	Use:   "expiring",
+	Short: "List certificates nearing expiry",
+	Long: `List certificates that have expired or expire within the given period, soonest first.
+The period accepts days (30d), weeks (4w), a plain number of days, or a Go duration (720h).
+Without --within, the configured expiryWarningDays threshold is used.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		withinDays := 0
+		if certWithin != "" {
+			days, err := parseDays(certWithin)
+			if err != nil {
+				ExitWithError("invalid --within value", err)
+			}
+			withinDays = days
+		}
+
+		cfgService, err := config.NewService()
+		if err != nil {
+			ExitWithError("failed to initialize config service", err)
+		}
+		service := signature.NewSignatureService(cfgService)
+		service.Startup(context.Background())
+
+		GetLogger().Info("checking certificate expiry", "within_days", withinDays)
+
+		expiring, err := service.GetExpiringCertificates(withinDays)
+		if err != nil {
+			ExitWithError("failed to check certificate expiry", err)
+		}
+
+		if jsonOutput {
+			data, err := json.MarshalIndent(expiring, "", "  ")
+			if err != nil {
+				ExitWithError("failed to marshal certificates to JSON", err)
+			}
+			fmt.Println(string(data))
+			return
+		}
+
+		if len(expiring) == 0 {
+			fmt.Println("No certificates are expiring.")
+			return
+		}
+
+		fmt.Printf("Found %d expiring certificate(s):\n\n", len(expiring))
+		for _, entry := range expiring {
+			fmt.Printf("  %s\n", entry.Certificate.Name)
+			fmt.Printf("    Status:      %s\n", entry.FormatExpiry())
+			fmt.Printf("    Fingerprint: %s\n", entry.Certificate.Fingerprint)
+			fmt.Printf("    Source:      %s\n", entry.Certificate.Source)
+			fmt.Println()
+		}
+	},
+}
+
+// parseDays converts a period such as "30d", "4w", "30" or "720h" to whole days.
+func parseDays(value string) (int, error) {
+	value = strings.TrimSpace(strings.ToLower(value))
+	if value == "" {
+		return 0, fmt.Errorf("empty period")
+	}
+
+	multiplier := 1
+	number := value
+	switch {
+	case strings.HasSuffix(value, "d"):
+		number = strings.TrimSuffix(value, "d")
+	case strings.HasSuffix(value, "w"):
+		number = strings.TrimSuffix(value, "w")
+		multiplier = 7
+	}
+
+	if days, err := strconv.Atoi(number); err == nil {
+		if days <= 0 {
+			return 0, fmt.Errorf("period must be positive: %s", value)
+		}
+		return days * multiplier, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("unrecognized period %q (use e.g. 30d, 4w or 720h)", value)
+	}
+	days := int(math.Ceil(d.Hours() / 24))
+	if days <= 0 {
+		return 0, fmt.Errorf("period must be positive: %s", value)
+	}
+	return days, nil
+}
+
+// parseExpiresBefore accepts a date (2006-01-02), an RFC 3339 time, or a period from now such as 90d.
+func parseExpiresBefore(value string) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	days, err := parseDays(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected a date (YYYY-MM-DD) or a period such as 90d: %w", err)
+	}
+	return time.Now().AddDate(0, 0, days), nil
+}
+
+// printSourceStatus prints the load status of certificate sources. Unless showAll is set,
+// only sources that failed for a reason other than not being present are printed.
+func printSourceStatus(sources []types.CertificateSourceStatus, showAll bool) {
+	printed := false
+	for _, src := range sources {
+		if !showAll && (src.Status == signature.SourceStatusOK || src.Status == signature.SourceStatusNotFound) {
+			continue
+		}
+
+		if !printed {
+			if showAll {
+				fmt.Println("Certificate sources:")
+			} else {
+				fmt.Println("Warning: some certificate sources could not be loaded:")
+			}
+			printed = true
+		}
+
+		if src.Status == signature.SourceStatusOK {
+			fmt.Printf("  [%s] %s %s: %d certificate(s) in %dms\n", src.Status, src.Kind, src.Source, src.Count, src.DurationMs)
+		} else {
+			fmt.Printf("  [%s] %s %s: %s\n", src.Status, src.Kind, src.Source, src.Message)
+		}
+	}
+	if printed {
+		fmt.Println()
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(certCmd)
 	certCmd.AddCommand(certListCmd)
 	certCmd.AddCommand(certSearchCmd)
 	certCmd.AddCommand(certInfoCmd)
+	certCmd.AddCommand(certExpiringCmd)
 
 	certListCmd.Flags().StringVarP(&certSource, "source", "s", "", "filter by source (system, user, pkcs11)")
 	certListCmd.Flags().StringVar(&certSearch, "search", "", "search query")
 	certListCmd.Flags().BoolVar(&certValidOnly, "valid-only", false, "only show valid certificates")
 	certListCmd.Flags().BoolVarP(&jsonOutput, "json", "j", false, "output in JSON format")
 	certListCmd.Flags().BoolVar(&certShowAll, "all", false, "show all certificates (default: limit to 20)")
+	certListCmd.Flags().BoolVar(&certStatus, "status", false, "show the load status of every certificate source")
+	certListCmd.Flags().StringVar(&certKeyUsage, "key-usage", "", "require a key usage (e.g. \"Digital Signature\", \"Non Repudiation\")")
+	certListCmd.Flags().StringVar(&certEKU, "eku", "", "require an extended key usage (documentSigning, emailProtection, clientAuth, or an OID)")
+	certListCmd.Flags().StringVar(&certIssuer, "issuer", "", "match issuer name or DN (substring)")
+	certListCmd.Flags().StringVar(&certKeyAlgorithm, "key-algorithm", "", "require key algorithm (RSA, ECDSA, Ed25519)")
+	certListCmd.Flags().IntVar(&certMinKeySize, "min-key-size", 0, "require a key of at least this many bits")
+	certListCmd.Flags().BoolVar(&certPrivateKey, "has-private-key", false, "only show certificates with a private key")
+	certListCmd.Flags().StringVar(&certExpiresBefore, "expires-before", "", "only show certificates expiring before a date (YYYY-MM-DD) or within a period (e.g. 90d)")
+	certListCmd.Flags().BoolVar(&certQualified, "qualified", false, "only show qualified certificates (QC statements)")
+	certListCmd.Flags().StringVar(&certSortBy, "sort", "", "sort by name, issuer, expiry, validfrom, source or keysize")
+	certListCmd.Flags().BoolVar(&certSortDesc, "desc", false, "sort in descending order")
 
 	certSearchCmd.Flags().BoolVarP(&jsonOutput, "json", "j", false, "output in JSON format")
 
 	certInfoCmd.Flags().BoolVarP(&jsonOutput, "json", "j", false, "output in JSON format")
+
+	certExpiringCmd.Flags().StringVar(&certWithin, "within", "", "expiry period, e.g. 30d, 4w or 720h (default: configured threshold)")
+	certExpiringCmd.Flags().BoolVarP(&jsonOutput, "json", "j", false, "output in JSON format")
 }
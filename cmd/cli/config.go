@@ -7,6 +7,8 @@ import (
 	"strings"
 
 	"github.com/Matbe34/lankir/internal/config"
+	"github.com/Matbe34/lankir/internal/signature/pkcs12"
+	"github.com/Matbe34/lankir/internal/signature/revocation"
 	"github.com/spf13/cobra"
 )
 
@@ -55,6 +57,27 @@ var configGetCmd = &cobra.Command{
 				fmt.Printf("\nCertificates:\n")
 				fmt.Printf("  Certificate Stores:  %v\n", cfg.CertificateStores)
 				fmt.Printf("  Token Libraries:     %v\n", cfg.TokenLibraries)
+				fmt.Printf("  Source Timeout:      %d seconds\n", cfg.CertificateSourceTimeout)
+				fmt.Printf("  Revocation Policy:   %s\n", cfg.RevocationPolicy)
+				fmt.Printf("  Expiry Warning:      %d days\n", cfg.ExpiryWarningDays)
+				prefs := cfg.CertificatePreferences
+				fmt.Printf("  Preferred Sources:   %v\n", prefs.PreferredSources)
+				fmt.Printf("  Preferred Issuers:   %v\n", prefs.PreferredIssuers)
+				fmt.Printf("  Excluded Certs:      %v\n", prefs.ExcludedCertificates)
+				fmt.Printf("  Require Qualified:   %v\n", prefs.RequireQualified)
+				policy := cfg.AlgorithmPolicy
+				fmt.Printf("  Forbidden Digests:   %v\n", policy.ForbiddenDigests)
+				fmt.Printf("  Deprecated Digests:  %v\n", policy.DeprecatedDigests)
+				fmt.Printf("  Min RSA Key Size:    %d bits\n", policy.MinRSAKeySize)
+				fmt.Printf("  Min EC Key Size:     %d bits\n", policy.MinECKeySize)
+				fmt.Printf("  Timestamp URL:       %s\n", cfg.TimestampAuthority.URL)
+				for _, anchor := range cfg.TrustAnchors {
+					fmt.Printf("  Trust Anchor:        %s (%s)\n", anchor.Path, anchor.Purpose)
+				}
+				for path, opts := range cfg.CertificateStoreOptions {
+					fmt.Printf("  Store Options:       %s (recursive: %v, max depth: %d, include: %v, exclude: %v)\n",
+						path, opts.Recursive, opts.MaxDepth, opts.Include, opts.Exclude)
+				}
 
 				fmt.Printf("\nAdvanced:\n")
 				fmt.Printf("  Debug Mode:        %v\n", cfg.DebugMode)
@@ -162,6 +185,46 @@ func getConfigValue(cfg *config.Config, key string) interface{} {
 		return cfg.CertificateStores
 	case "tokenlibraries":
 		return cfg.TokenLibraries
+	case "certificatesourcetimeout":
+		return cfg.CertificateSourceTimeout
+	case "revocationpolicy":
+		return cfg.RevocationPolicy
+	case "expirywarningdays":
+		return cfg.ExpiryWarningDays
+	case "certificatepreferences":
+		return cfg.CertificatePreferences
+	case "preferredsources":
+		return cfg.CertificatePreferences.PreferredSources
+	case "preferredissuers":
+		return cfg.CertificatePreferences.PreferredIssuers
+	case "excludedcertificates":
+		return cfg.CertificatePreferences.ExcludedCertificates
+	case "requirequalified":
+		return cfg.CertificatePreferences.RequireQualified
+	case "algorithmpolicy":
+		return cfg.AlgorithmPolicy
+	case "forbiddendigests":
+		return cfg.AlgorithmPolicy.ForbiddenDigests
+	case "deprecateddigests":
+		return cfg.AlgorithmPolicy.DeprecatedDigests
+	case "minrsakeysize":
+		return cfg.AlgorithmPolicy.MinRSAKeySize
+	case "mineckeysize":
+		return cfg.AlgorithmPolicy.MinECKeySize
+	case "timestampurl":
+		return cfg.TimestampAuthority.URL
+	case "timestampusername":
+		return cfg.TimestampAuthority.Username
+	case "trustanchors":
+		if cfg.TrustAnchors == nil {
+			return []config.TrustAnchor{}
+		}
+		return cfg.TrustAnchors
+	case "certificatestoreoptions":
+		if cfg.CertificateStoreOptions == nil {
+			return map[string]config.StoreScanOptions{}
+		}
+		return cfg.CertificateStoreOptions
 	case "debugmode":
 		return cfg.DebugMode
 	case "hardwareaccel":
@@ -209,6 +272,64 @@ func setConfigValue(cfg *config.Config, key, value string) error {
 			return fmt.Errorf("invalid integer value: %s", value)
 		}
 		cfg.AutosaveInterval = v
+	case "certificatesourcetimeout":
+		v, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid integer value: %s", value)
+		}
+		cfg.CertificateSourceTimeout = v
+	case "revocationpolicy":
+		policy, err := revocation.ParsePolicy(value)
+		if err != nil {
+			return err
+		}
+		cfg.RevocationPolicy = string(policy)
+	case "expirywarningdays":
+		v, err := strconv.Atoi(value)
+		if err != nil || v <= 0 {
+			return fmt.Errorf("invalid number of days: %s", value)
+		}
+		cfg.ExpiryWarningDays = v
+	case "preferredsources":
+		cfg.CertificatePreferences.PreferredSources = splitList(value)
+	case "preferredissuers":
+		cfg.CertificatePreferences.PreferredIssuers = splitList(value)
+	case "excludedcertificates":
+		cfg.CertificatePreferences.ExcludedCertificates = splitList(value)
+	case "requirequalified":
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean value: %s", value)
+		}
+		cfg.CertificatePreferences.RequireQualified = v
+	case "forbiddendigests":
+		cfg.AlgorithmPolicy.ForbiddenDigests = splitList(value)
+	case "deprecateddigests":
+		cfg.AlgorithmPolicy.DeprecatedDigests = splitList(value)
+	case "minrsakeysize":
+		v, err := strconv.Atoi(value)
+		if err != nil || v < 0 {
+			return fmt.Errorf("invalid key size: %s", value)
+		}
+		cfg.AlgorithmPolicy.MinRSAKeySize = v
+	case "mineckeysize":
+		v, err := strconv.Atoi(value)
+		if err != nil || v < 0 {
+			return fmt.Errorf("invalid key size: %s", value)
+		}
+		cfg.AlgorithmPolicy.MinECKeySize = v
+	case "timestampurl":
+		cfg.TimestampAuthority.URL = strings.TrimSpace(value)
+	case "timestampusername":
+		cfg.TimestampAuthority.Username = value
+	case "timestamppassword":
+		cfg.TimestampAuthority.Password = value
+	case "certificatestoreoptions":
+		opts, err := parseStoreOptions(value)
+		if err != nil {
+			return err
+		}
+		cfg.CertificateStoreOptions = opts
 	case "debugmode":
 		v, err := strconv.ParseBool(value)
 		if err != nil {
@@ -226,3 +347,39 @@ func setConfigValue(cfg *config.Config, key, value string) error {
 	}
 	return nil
 }
+
+// parseStoreOptions parses per-store scan settings given as a JSON object keyed by store path,
+// rejecting negative depths and malformed patterns so they are not saved.
+func parseStoreOptions(value string) (map[string]config.StoreScanOptions, error) {
+	var opts map[string]config.StoreScanOptions
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&opts); err != nil {
+		return nil, fmt.Errorf("invalid store options (expected a JSON object keyed by store path): %w", err)
+	}
+
+	for path, o := range opts {
+		if strings.TrimSpace(path) == "" {
+			return nil, fmt.Errorf("invalid store options: empty store path")
+		}
+		if o.MaxDepth < 0 {
+			return nil, fmt.Errorf("invalid max depth for %s: %d", path, o.MaxDepth)
+		}
+		scan := pkcs12.ScanOptions{Recursive: o.Recursive, MaxDepth: o.MaxDepth, Include: o.Include, Exclude: o.Exclude}
+		if err := scan.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid store options for %s: %w", path, err)
+		}
+	}
+	return opts, nil
+}
+
+// splitList parses a comma-separated list, dropping empty entries.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
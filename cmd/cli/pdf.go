@@ -2,12 +2,15 @@ package cli
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 
+	"github.com/Matbe34/lankir/internal/config"
 	"github.com/Matbe34/lankir/internal/pdf"
+	"github.com/Matbe34/lankir/internal/signature"
 	"github.com/spf13/cobra"
 )
 
@@ -122,6 +125,7 @@ var (
 	renderPage    int
 	renderDPI     float64
 	renderOutput  string
+	renderOverlay bool
 	jsonOutput    bool
 	thumbnailSize int
 )
@@ -129,8 +133,12 @@ var (
 var pdfRenderCmd = &cobra.Command{
 	Use:   "render <pdf-file>",
 	Short: "Render a PDF page to PNG",
-	Long:  `Render a specific page of a PDF file to a PNG image.`,
-	Args:  cobra.ExactArgs(1),
+	Long: `Render a specific page of a PDF file to a PNG image.
+
+With --signature-overlay, the signatures are verified first and every signature widget
+on the page is framed in green (valid), red (invalid) or amber (unknown, for example an
+untrusted certificate), with a matching badge in its top-right corner.`,
+	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		pdfPath := args[0]
 
@@ -138,6 +146,22 @@ var pdfRenderCmd = &cobra.Command{
 			ExitWithError("PDF file not found", err)
 		}
 
+		var opts pdf.RenderOptions
+		if renderOverlay {
+			cfgService, err := config.NewService()
+			if err != nil {
+				ExitWithError("failed to initialize config service", err)
+			}
+			sigService := signature.NewSignatureService(cfgService)
+			sigService.Startup(context.Background())
+
+			signatures, err := sigService.VerifySignatures(pdfPath)
+			if err != nil {
+				ExitWithError("failed to verify signatures", err)
+			}
+			opts = pdf.RenderOptions{Signatures: signatures, SignatureOverlay: true}
+		}
+
 		service := pdf.NewPDFService(nil)
 		service.Startup(context.Background())
 
@@ -154,7 +178,7 @@ var pdfRenderCmd = &cobra.Command{
 
 		GetLogger().Info("rendering page", "page", renderPage, "dpi", renderDPI)
 
-		pageInfo, err := service.RenderPage(renderPage-1, renderDPI)
+		pageInfo, err := service.RenderPageWithOptions(renderPage-1, renderDPI, opts)
 		if err != nil {
 			ExitWithError("failed to render page", err)
 		}
@@ -170,15 +194,25 @@ var pdfRenderCmd = &cobra.Command{
 			ExitWithError("invalid image data format", nil)
 		}
 
-		import64 := pageInfo.ImageData[len(prefix):]
+		pngData, err := base64.StdEncoding.DecodeString(pageInfo.ImageData[len(prefix):])
+		if err != nil {
+			ExitWithError("invalid image data format", err)
+		}
 
-		err = os.WriteFile(renderOutput, []byte(import64), 0644)
+		err = os.WriteFile(renderOutput, pngData, 0644)
 		if err != nil {
 			ExitWithError("failed to write output file", err)
 		}
 
 		GetLogger().Info("page rendered successfully", "output", renderOutput)
 		fmt.Printf("Page %d rendered to: %s\n", renderPage, renderOutput)
+		for _, w := range pageInfo.SignatureWidgets {
+			fmt.Printf("  %s: %s", w.FieldName, w.Status)
+			if w.SignerName != "" {
+				fmt.Printf(" (%s)", w.SignerName)
+			}
+			fmt.Println()
+		}
 	},
 }
 
@@ -240,6 +274,7 @@ func init() {
 	pdfRenderCmd.Flags().IntVarP(&renderPage, "page", "p", 1, "page number to render")
 	pdfRenderCmd.Flags().Float64VarP(&renderDPI, "dpi", "d", 150.0, "DPI for rendering")
 	pdfRenderCmd.Flags().StringVarP(&renderOutput, "output", "o", "", "output PNG file (default: <pdf>_page<N>.png)")
+	pdfRenderCmd.Flags().BoolVar(&renderOverlay, "signature-overlay", false, "verify the signatures and mark their widgets with their status")
 
 	pdfThumbnailCmd.Flags().IntVarP(&thumbnailSize, "width", "w", 400, "maximum width for thumbnail")
 	pdfThumbnailCmd.Flags().StringVarP(&renderOutput, "output", "o", "", "output PNG file (default: <pdf>_thumbnail.png)")
@@ -0,0 +1,580 @@
+package cli
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Matbe34/lankir/internal/config"
+	"github.com/Matbe34/lankir/internal/signature"
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var (
+	profileName             string
+	profileDescription      string
+	profileVisibility       string
+	profileDefault          bool
+	profilePage             int
+	profileX                float64
+	profileY                float64
+	profileWidth            float64
+	profileHeight           float64
+	profileShowSignerName   bool
+	profileShowSigningTime  bool
+	profileShowLocation     bool
+	profileShowLogo         bool
+	profileLogoFile         string
+	profileLogoPosition     string
+	profileCustomText       string
+	profileFontSize         int
+	profileFontFile         string
+	profileBackgroundColor  string
+	profileTextColor        string
+	profileReason           string
+	profileLocation         string
+	profileRevocationPolicy string
+	profileCertFingerprint  string
+	profileCertSelector     string
+	profileCertSource       string
+	profilePINCache         int
+	profileBundleOutput     string
+	profileOnConflict       string
+	profilePreviewCert      string
+	profilePreviewPDF       string
+	profilePreviewOutput    string
+)
+
+var signProfileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Manage signature profiles",
+	Long: `Create, update, delete and duplicate signature profiles, choose the default one, and share
+them as bundles. Use profile-list and profile-info to inspect them.`,
+}
+
+var signProfileCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create a signature profile",
+	Long: `Create a signature profile. --name is required. Visible profiles start from the settings of the
+built-in visible profile (last page, bottom right, signer name and signing time), which the
+other flags override.
+
+--logo reads a PNG, JPEG or GIF file and stores it in the profile; it also turns on
+--show-logo unless that is given. --default makes the new profile the default one.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if !cmd.Flags().Changed("name") {
+			ExitWithError("missing profile name", fmt.Errorf("--name is required"))
+		}
+
+		profile := signature.DefaultInvisibleProfile()
+		if profileVisibility == string(signature.VisibilityVisible) {
+			profile = signature.DefaultVisibleProfile()
+		}
+		profile.ID = uuid.New()
+		profile.Description = ""
+		profile.IsDefault = false
+
+		if err := applyProfileFlags(cmd.Flags(), profile); err != nil {
+			ExitWithError("invalid profile settings", err)
+		}
+
+		service := newProfileService()
+
+		GetLogger().Info("creating signature profile", "name", profile.Name)
+
+		if err := service.SaveSignatureProfile(profile); err != nil {
+			ExitWithError("failed to create profile", err)
+		}
+
+		printProfileResult("Profile created", profile)
+	},
+}
+
+var signProfileUpdateCmd = &cobra.Command{
+	Use:   "update <profile-id>",
+	Short: "Update a signature profile",
+	Long: `Change the settings of a signature profile. Only the given flags are changed; pass --logo ""
+to remove the logo. --default=false no longer makes the profile the default, in which case
+signing falls back to the built-in invisible profile until another one is set as default.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		service := newProfileService()
+
+		profile, err := service.GetSignatureProfile(args[0])
+		if err != nil {
+			ExitWithError("failed to get profile", err)
+		}
+
+		if err := applyProfileFlags(cmd.Flags(), profile); err != nil {
+			ExitWithError("invalid profile settings", err)
+		}
+
+		GetLogger().Info("updating signature profile", "id", profile.ID)
+
+		if err := service.SaveSignatureProfile(profile); err != nil {
+			ExitWithError("failed to update profile", err)
+		}
+
+		printProfileResult("Profile updated", profile)
+	},
+}
+
+var signProfileDeleteCmd = &cobra.Command{
+	Use:   "delete <profile-id>",
+	Short: "Delete a signature profile",
+	Long: `Delete a signature profile. When the default profile is deleted, signing falls back to the
+built-in invisible profile until another one is set as default.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		service := newProfileService()
+
+		GetLogger().Info("deleting signature profile", "id", args[0])
+
+		if err := service.DeleteSignatureProfile(args[0]); err != nil {
+			ExitWithError("failed to delete profile", err)
+		}
+
+		fmt.Printf("Profile deleted: %s\n", args[0])
+	},
+}
+
+var signProfileSetDefaultCmd = &cobra.Command{
+	Use:   "set-default <profile-id>",
+	Short: "Make a signature profile the default",
+	Long:  `Make a signature profile the default used for signing. All other profiles stop being the default.`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		service := newProfileService()
+
+		GetLogger().Info("setting default signature profile", "id", args[0])
+
+		profile, err := service.SetDefaultSignatureProfile(args[0])
+		if err != nil {
+			ExitWithError("failed to set default profile", err)
+		}
+
+		printProfileResult("Default profile", profile)
+	},
+}
+
+var signProfileDuplicateCmd = &cobra.Command{
+	Use:   "duplicate <profile-id>",
+	Short: "Duplicate a signature profile",
+	Long: `Copy a signature profile under a new ID, named --name or "<original> (copy)". The copy is
+never the default.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		service := newProfileService()
+
+		GetLogger().Info("duplicating signature profile", "id", args[0])
+
+		profile, err := service.DuplicateSignatureProfile(args[0], profileName)
+		if err != nil {
+			ExitWithError("failed to duplicate profile", err)
+		}
+
+		printProfileResult("Profile created", profile)
+	},
+}
+
+var signProfileExportCmd = &cobra.Command{
+	Use:   "export [profile-id|name...] --output <bundle>",
+	Short: "Export signature profiles to a bundle",
+	Long: `Export signature profiles, or all of them when none are given, to a self-contained bundle
+(a zip file) to share them. The bundle includes the logo and font of each profile and records
+its format version. Exported profiles are never marked as default.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if profileBundleOutput == "" {
+			ExitWithError("missing output file", fmt.Errorf("--output is required"))
+		}
+
+		service := newProfileService()
+
+		ids := make([]string, 0, len(args))
+		for _, arg := range args {
+			profile, err := service.FindSignatureProfile(arg)
+			if err != nil {
+				ExitWithError("failed to get profile", err)
+			}
+			ids = append(ids, profile.ID.String())
+		}
+
+		GetLogger().Info("exporting signature profiles", "count", len(ids), "output", SanitizePath(profileBundleOutput))
+
+		manifest, err := service.ExportSignatureProfiles(ids, profileBundleOutput)
+		if err != nil {
+			ExitWithError("failed to export profiles", err)
+		}
+
+		if jsonOutput {
+			data, err := json.MarshalIndent(manifest, "", "  ")
+			if err != nil {
+				ExitWithError("failed to marshal manifest to JSON", err)
+			}
+			fmt.Println(string(data))
+			return
+		}
+
+		for _, entry := range manifest.Profiles {
+			fmt.Printf("  %s (%s)\n", entry.Name, entry.ID)
+		}
+		fmt.Printf("Exported %d profile(s) to %s\n", len(manifest.Profiles), profileBundleOutput)
+	},
+}
+
+var signProfileImportCmd = &cobra.Command{
+	Use:   "import <bundle>",
+	Short: "Import signature profiles from a bundle",
+	Long: `Import the signature profiles of a bundle written by profile export, with their logos and
+fonts. --on-conflict decides what happens to a profile whose ID already exists, which the
+built-in profile IDs always do:
+
+  keep     keep the existing profile and skip the imported one (default)
+  replace  overwrite the existing profile
+  rename   import it under a new ID, adding " (imported)" to its name until it is unique
+
+Imported profiles never become the default; use set-default for that. Nothing is imported when
+any profile in the bundle is invalid or an ID appears twice.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		service := newProfileService()
+
+		GetLogger().Info("importing signature profiles", "bundle", SanitizePath(args[0]), "on_conflict", profileOnConflict)
+
+		result, err := service.ImportSignatureProfiles(args[0], profileOnConflict)
+		if err != nil {
+			ExitWithError("failed to import profiles", err)
+		}
+
+		if jsonOutput {
+			data, err := json.MarshalIndent(result, "", "  ")
+			if err != nil {
+				ExitWithError("failed to marshal result to JSON", err)
+			}
+			fmt.Println(string(data))
+			return
+		}
+
+		for _, entry := range result.Profiles {
+			line := fmt.Sprintf("%-9s %s (%s)", entry.Action, entry.Name, entry.ID)
+			if entry.ID != entry.OriginalID {
+				line += ", was " + entry.OriginalID
+			}
+			fmt.Println(line)
+		}
+		fmt.Printf("\n%d profile(s) in bundle (format version %d)\n", len(result.Profiles), result.Version)
+	},
+}
+
+var signProfilePreviewCmd = &cobra.Command{
+	Use:   "preview [profile-id|name]",
+	Short: "Preview the appearance of a signature profile",
+	Long: `Render the text of a signature profile, or of the built-in visible profile when none is given,
+without signing. The appearance flags change the profile for the preview only, so templates can
+be tried before they are saved.
+
+Placeholders in --custom-text are filled from the certificate given by --fingerprint and the PDF
+given by --pdf, or from sample values. --output writes the signature image as PNG.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		service := newProfileService()
+
+		profile := signature.DefaultVisibleProfile()
+		if len(args) == 1 {
+			var err error
+			if profile, err = service.FindSignatureProfile(args[0]); err != nil {
+				ExitWithError("failed to get profile", err)
+			}
+		}
+		if err := applySignatureFlags(cmd.Flags(), profile); err != nil {
+			ExitWithError("invalid profile settings", err)
+		}
+
+		preview, err := service.PreviewSignatureAppearance(profile, profilePreviewCert, profilePreviewPDF)
+		if err != nil {
+			ExitWithError("failed to preview appearance", err)
+		}
+
+		if profilePreviewOutput != "" {
+			if preview.Image == "" {
+				ExitWithError("failed to write image", fmt.Errorf("profile %q is invisible", profile.Name))
+			}
+			_, encoded, _ := strings.Cut(preview.Image, ";base64,")
+			data, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				ExitWithError("failed to decode image", err)
+			}
+			if err := os.WriteFile(profilePreviewOutput, data, 0644); err != nil {
+				ExitWithError("failed to write image", err)
+			}
+		}
+
+		if jsonOutput {
+			data, err := json.MarshalIndent(preview, "", "  ")
+			if err != nil {
+				ExitWithError("failed to marshal preview to JSON", err)
+			}
+			fmt.Println(string(data))
+			return
+		}
+
+		fmt.Printf("Appearance of %s:\n\n", profile.Name)
+		for _, line := range preview.Lines {
+			fmt.Printf("  %s\n", line)
+		}
+		if profilePreviewOutput != "" {
+			fmt.Printf("\nImage written to %s\n", profilePreviewOutput)
+		}
+	},
+}
+
+func newProfileService() *signature.SignatureService {
+	cfgService, err := config.NewService()
+	if err != nil {
+		ExitWithError("failed to initialize config service", err)
+	}
+	service := signature.NewSignatureService(cfgService)
+	service.Startup(context.Background())
+	return service
+}
+
+// applyProfileFlags copies the profile flags given on the command line into profile.
+func applyProfileFlags(flags *pflag.FlagSet, profile *signature.SignatureProfile) error {
+	if flags.Changed("name") {
+		profile.Name = profileName
+	}
+	if flags.Changed("description") {
+		profile.Description = profileDescription
+	}
+	if flags.Changed("visibility") {
+		profile.Visibility = signature.SignatureVisibility(profileVisibility)
+	}
+	if flags.Changed("default") {
+		profile.IsDefault = profileDefault
+	}
+
+	if flags.Changed("page") {
+		profile.Position.Page = profilePage
+	}
+	if flags.Changed("x") {
+		profile.Position.X = profileX
+	}
+	if flags.Changed("y") {
+		profile.Position.Y = profileY
+	}
+	if flags.Changed("width") {
+		profile.Position.Width = profileWidth
+	}
+	if flags.Changed("height") {
+		profile.Position.Height = profileHeight
+	}
+
+	if flags.Changed("cert-fingerprint") {
+		profile.CertificateFingerprint = strings.ToLower(profileCertFingerprint)
+		if profileCertFingerprint != "" {
+			profile.CertificateSelector = ""
+		}
+	}
+	if flags.Changed("cert-selector") {
+		profile.CertificateSelector = profileCertSelector
+		if profileCertSelector != "" {
+			profile.CertificateFingerprint = ""
+		}
+	}
+	if flags.Changed("cert-source") {
+		profile.CertificateSource = profileCertSource
+	}
+	if flags.Changed("pin-cache") {
+		profile.PINPolicy = signature.PINPolicyPrompt
+		profile.PINCacheMinutes = 0
+		if profilePINCache > 0 {
+			profile.PINPolicy = signature.PINPolicyCache
+			profile.PINCacheMinutes = profilePINCache
+		}
+	}
+
+	return applySignatureFlags(flags, profile)
+}
+
+// applySignatureFlags copies the appearance and signing option flags given on the command line
+// into profile.
+func applySignatureFlags(flags *pflag.FlagSet, profile *signature.SignatureProfile) error {
+	appearance := &profile.Appearance
+	if flags.Changed("logo") {
+		appearance.LogoPath = ""
+		appearance.ShowLogo = false
+		if profileLogoFile != "" {
+			logo, err := signature.LoadLogoFile(profileLogoFile)
+			if err != nil {
+				return err
+			}
+			appearance.LogoPath = logo
+			appearance.ShowLogo = true
+		}
+	}
+	if flags.Changed("show-signer-name") {
+		appearance.ShowSignerName = profileShowSignerName
+	}
+	if flags.Changed("show-signing-time") {
+		appearance.ShowSigningTime = profileShowSigningTime
+	}
+	if flags.Changed("show-location") {
+		appearance.ShowLocation = profileShowLocation
+	}
+	if flags.Changed("show-logo") {
+		appearance.ShowLogo = profileShowLogo
+	}
+	if flags.Changed("logo-position") {
+		appearance.LogoPosition = profileLogoPosition
+	}
+	if flags.Changed("custom-text") {
+		appearance.CustomText = profileCustomText
+	}
+	if flags.Changed("font-size") {
+		appearance.FontSize = profileFontSize
+	}
+	if flags.Changed("font") {
+		appearance.FontPath = ""
+		if profileFontFile != "" {
+			font, err := signature.LoadFontFile(profileFontFile)
+			if err != nil {
+				return err
+			}
+			appearance.FontPath = font
+		}
+	}
+	if flags.Changed("background-color") {
+		appearance.BackgroundColor = profileBackgroundColor
+	}
+	if flags.Changed("text-color") {
+		appearance.TextColor = profileTextColor
+	}
+	if appearance.ShowLogo && appearance.LogoPath == "" {
+		return fmt.Errorf("--show-logo requires a logo (--logo <file>)")
+	}
+
+	if flags.Changed("reason") {
+		profile.Reason = profileReason
+	}
+	if flags.Changed("location") {
+		profile.Location = profileLocation
+	}
+	if flags.Changed("revocation-policy") {
+		profile.RevocationPolicy = profileRevocationPolicy
+	}
+	return nil
+}
+
+// printProfileResult prints a profile after it was changed, as JSON with --json.
+func printProfileResult(message string, profile *signature.SignatureProfile) {
+	if jsonOutput {
+		data, err := json.MarshalIndent(profile, "", "  ")
+		if err != nil {
+			ExitWithError("failed to marshal profile to JSON", err)
+		}
+		fmt.Println(string(data))
+		return
+	}
+
+	fmt.Printf("%s: %s\n", message, profile.Name)
+	fmt.Printf("  ID:          %s\n", profile.ID)
+	fmt.Printf("  Visibility:  %s\n", profile.Visibility)
+	fmt.Printf("  Default:     %v\n", profile.IsDefault)
+	if profile.BindsCertificate() {
+		fmt.Printf("  Certificate: %s\n", describeCertificateBinding(profile))
+	}
+}
+
+// describeCertificateBinding summarizes how a profile selects its certificate.
+func describeCertificateBinding(profile *signature.SignatureProfile) string {
+	var parts []string
+	switch {
+	case profile.CertificateFingerprint != "":
+		parts = append(parts, "fingerprint "+profile.CertificateFingerprint)
+	case profile.CertificateSelector != "":
+		parts = append(parts, fmt.Sprintf("best match for %q", profile.CertificateSelector))
+	default:
+		parts = append(parts, "best available")
+	}
+	if profile.CertificateSource != "" {
+		parts = append(parts, "from "+profile.CertificateSource)
+	}
+	return strings.Join(parts, " ")
+}
+
+// addProfileFlags registers the flags setting the fields of a signature profile.
+func addProfileFlags(cmd *cobra.Command) {
+	flags := cmd.Flags()
+	flags.StringVar(&profileName, "name", "", "profile name")
+	flags.StringVar(&profileDescription, "description", "", "profile description")
+	flags.StringVar(&profileVisibility, "visibility", string(signature.VisibilityInvisible), "signature visibility: invisible or visible")
+	flags.BoolVar(&profileDefault, "default", false, "make this the default profile")
+
+	flags.IntVar(&profilePage, "page", 0, "page of a visible signature (1-based, 0 = last page, -1 = first page)")
+	flags.Float64Var(&profileX, "x", 0, "x coordinate in points, from the left")
+	flags.Float64Var(&profileY, "y", 0, "y coordinate in points, from the bottom")
+	flags.Float64Var(&profileWidth, "width", 0, "signature width in points")
+	flags.Float64Var(&profileHeight, "height", 0, "signature height in points")
+
+	flags.StringVar(&profileCertFingerprint, "cert-fingerprint", "", "bind the certificate with this SHA-256 fingerprint; empty removes it")
+	flags.StringVar(&profileCertSelector, "cert-selector", "", "bind the best certificate matching this name, subject, issuer or serial; empty removes it")
+	flags.StringVar(&profileCertSource, "cert-source", "", "take the bound certificate from this source, e.g. pkcs11; empty allows any")
+	flags.IntVar(&profilePINCache, "pin-cache", 0, fmt.Sprintf("cache the PIN for this many minutes after signing (max %d); 0 always prompts", signature.MaxPINCacheMinutes))
+
+	addSignatureFlags(cmd)
+}
+
+// addSignatureFlags registers the flags setting the appearance and signing options of a profile.
+func addSignatureFlags(cmd *cobra.Command) {
+	flags := cmd.Flags()
+	flags.BoolVar(&profileShowSignerName, "show-signer-name", false, "show the signer name")
+	flags.BoolVar(&profileShowSigningTime, "show-signing-time", false, "show the signing time")
+	flags.BoolVar(&profileShowLocation, "show-location", false, "show the location")
+	flags.BoolVar(&profileShowLogo, "show-logo", false, "show the logo")
+	flags.StringVar(&profileLogoFile, "logo", "", "logo image file (PNG, JPEG or GIF); empty removes the logo")
+	flags.StringVar(&profileLogoPosition, "logo-position", "", "logo position: left or top")
+	flags.StringVar(&profileCustomText, "custom-text", "", "additional text shown in the signature, may use {{...}} placeholders")
+	flags.IntVar(&profileFontSize, "font-size", 0, "font size (0 = automatic)")
+	flags.StringVar(&profileFontFile, "font", "", "TrueType or OpenType font file; empty uses the built-in font")
+	flags.StringVar(&profileBackgroundColor, "background-color", "", "background color (hex)")
+	flags.StringVar(&profileTextColor, "text-color", "", "text color (hex)")
+	flags.StringVar(&profileReason, "reason", "", "signing reason stored in the signature")
+	flags.StringVar(&profileLocation, "location", "", "signing location; replaces the looked-up location")
+	flags.StringVar(&profileRevocationPolicy, "revocation-policy", "", "pre-signing revocation check: off, soft-fail or hard-fail (empty uses the configuration)")
+}
+
+func init() {
+	signCmd.AddCommand(signProfileCmd)
+	signProfileCmd.AddCommand(signProfileCreateCmd)
+	signProfileCmd.AddCommand(signProfileUpdateCmd)
+	signProfileCmd.AddCommand(signProfileDeleteCmd)
+	signProfileCmd.AddCommand(signProfileSetDefaultCmd)
+	signProfileCmd.AddCommand(signProfileDuplicateCmd)
+	signProfileCmd.AddCommand(signProfileExportCmd)
+	signProfileCmd.AddCommand(signProfileImportCmd)
+	signProfileCmd.AddCommand(signProfilePreviewCmd)
+
+	addProfileFlags(signProfileCreateCmd)
+	addProfileFlags(signProfileUpdateCmd)
+	addSignatureFlags(signProfilePreviewCmd)
+
+	signProfileCreateCmd.Flags().BoolVarP(&jsonOutput, "json", "j", false, "output in JSON format")
+	signProfileUpdateCmd.Flags().BoolVarP(&jsonOutput, "json", "j", false, "output in JSON format")
+	signProfileSetDefaultCmd.Flags().BoolVarP(&jsonOutput, "json", "j", false, "output in JSON format")
+	signProfileDuplicateCmd.Flags().BoolVarP(&jsonOutput, "json", "j", false, "output in JSON format")
+	signProfileDuplicateCmd.Flags().StringVar(&profileName, "name", "", "name of the copy (default: \"<original> (copy)\")")
+	signProfileExportCmd.Flags().BoolVarP(&jsonOutput, "json", "j", false, "output in JSON format")
+	signProfileExportCmd.Flags().StringVarP(&profileBundleOutput, "output", "o", "", "bundle file to write")
+	signProfileImportCmd.Flags().BoolVarP(&jsonOutput, "json", "j", false, "output in JSON format")
+	signProfilePreviewCmd.Flags().BoolVarP(&jsonOutput, "json", "j", false, "output in JSON format")
+	signProfilePreviewCmd.Flags().StringVar(&profilePreviewCert, "fingerprint", "", "certificate to fill the signer placeholders from (default: sample values)")
+	signProfilePreviewCmd.Flags().StringVar(&profilePreviewPDF, "pdf", "", "PDF to fill the document placeholders from (default: sample values)")
+	signProfilePreviewCmd.Flags().StringVarP(&profilePreviewOutput, "output", "o", "", "write the signature image to this PNG file")
+	signProfileImportCmd.Flags().StringVar(&profileOnConflict, "on-conflict", signature.ProfileConflictKeep, "existing profile IDs: keep, replace or rename")
+}
@@ -1,16 +1,23 @@
 package cli
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
 
 	"github.com/Matbe34/lankir/internal/config"
 	"github.com/Matbe34/lankir/internal/signature"
 	"github.com/Matbe34/lankir/internal/signature/types"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 var signCmd = &cobra.Command{
@@ -22,8 +29,18 @@ var signCmd = &cobra.Command{
 var signPDFCmd = &cobra.Command{
 	Use:   "pdf <input-pdf> <output-pdf>",
 	Short: "Sign a PDF file",
-	Long:  `Sign a PDF file using a digital certificate. You can specify the certificate by fingerprint, name, or file path.`,
-	Args:  cobra.ExactArgs(2),
+	Long: `Sign a PDF file using a digital certificate. You can specify the certificate by fingerprint, name, or file path.
+
+--profile signs with a saved signature profile, given by ID or name, with the same appearance,
+position and options as in the GUI. Position, visibility and appearance flags given explicitly
+override the matching profile fields for this signature only. Without --profile, the built-in
+visible profile is used with the position flags, or the invisible one with --visible=false.
+
+Without --cert-file, --fingerprint, --name or --auto, the certificate bound to the profile is
+used, and signing fails when it is not available.
+
+--dry-run prints the effective profile, after the overrides, without signing.`,
+	Args: cobra.ExactArgs(2),
 	Run: func(cmd *cobra.Command, args []string) {
 		inputPath := args[0]
 		outputPath := args[1]
@@ -39,6 +56,24 @@ var signPDFCmd = &cobra.Command{
 		service := signature.NewSignatureService(cfgService)
 		service.Startup(context.Background())
 
+		profile, err := signingProfile(cmd.Flags(), service)
+		if err != nil {
+			ExitWithError("failed to prepare signature profile", err)
+		}
+
+		if signDryRun {
+			if jsonOutput {
+				data, err := json.MarshalIndent(profile, "", "  ")
+				if err != nil {
+					ExitWithError("failed to marshal profile to JSON", err)
+				}
+				fmt.Println(string(data))
+				return
+			}
+			printSignatureProfile("Effective Signature Profile", profile)
+			return
+		}
+
 		var cert *types.Certificate
 
 		if signCertFile != "" {
@@ -51,7 +86,7 @@ var signPDFCmd = &cobra.Command{
 
 			absPath, _ := filepath.Abs(signCertFile)
 			for _, c := range certs {
-				if c.FilePath == signCertFile || c.FilePath == absPath {
+				if c.HasFilePath(signCertFile) || c.HasFilePath(absPath) {
 					cert = &c
 					break
 				}
@@ -62,21 +97,21 @@ var signPDFCmd = &cobra.Command{
 			}
 		} else if signCertFingerprint != "" {
 			GetLogger().Info("finding certificate by fingerprint", "fingerprint", signCertFingerprint)
-			certs, err := service.ListCertificates()
-			if err != nil {
-				ExitWithError("failed to list certificates", err)
+			cert, err = service.GetCertificateByFingerprint(signCertFingerprint)
+			if errors.Is(err, signature.ErrCertificateNotFound) {
+				ExitWithError(fmt.Sprintf("certificate with fingerprint %s not found", signCertFingerprint), nil)
 			}
-
-			for _, c := range certs {
-				if c.Fingerprint == signCertFingerprint {
-					cert = &c
-					break
-				}
+			if err != nil {
+				ExitWithError("failed to look up certificate", err)
 			}
-
-			if cert == nil {
-				ExitWithError(fmt.Sprintf("certificate with fingerprint %s not found", signCertFingerprint), nil)
+		} else if signAuto {
+			GetLogger().Info("selecting certificate automatically", "name", signCertName)
+			selection, err := service.SelectSigningCertificate(signCertName)
+			if err != nil {
+				ExitWithError("failed to select a signing certificate", err)
 			}
+			printCertificateSelection(selection)
+			cert = &selection.Certificate
 		} else if signCertName != "" {
 			GetLogger().Info("finding certificate by name", "name", signCertName)
 			results, err := service.SearchCertificates(signCertName)
@@ -87,7 +122,7 @@ var signPDFCmd = &cobra.Command{
 			if len(results) == 0 {
 				ExitWithError(fmt.Sprintf("no certificate found matching name: %s", signCertName), nil)
 			} else if len(results) > 1 {
-				fmt.Printf("Found %d certificates matching '%s'. Please specify fingerprint:\n", len(results), signCertName)
+				fmt.Printf("Found %d certificates matching '%s'. Please specify fingerprint or use --auto:\n", len(results), signCertName)
 				for _, c := range results {
 					fmt.Printf("  - %s (Fingerprint: %s)\n", c.Name, c.Fingerprint)
 				}
@@ -95,12 +130,22 @@ var signPDFCmd = &cobra.Command{
 			}
 
 			cert = &results[0]
+		} else if profile.BindsCertificate() {
+			GetLogger().Info("using the certificate bound to the profile", "profile", profile.Name)
+			cert, err = service.ResolveProfileCertificate(profile)
+			if err != nil {
+				ExitWithError("failed to find the certificate bound to the profile", err)
+			}
 		} else {
-			ExitWithError("please specify a certificate using --file, --fingerprint, or --name", nil)
+			ExitWithError("please specify a certificate using --cert-file, --fingerprint, --name, or --auto, or a --profile with a bound certificate", nil)
 		}
 
 		GetLogger().Info("using certificate", "name", cert.Name, "fingerprint", cert.Fingerprint)
 
+		if expiry, err := service.CheckCertificateExpiry(cert.Fingerprint); err == nil && expiry != nil && !expiry.Expired {
+			fmt.Fprintf(os.Stderr, "Warning: certificate '%s' %s\n", cert.Name, expiry.FormatExpiry())
+		}
+
 		if cert.RequiresPin || (cert.PinOptional && signPin != "") {
 			if signPin == "" {
 				fmt.Print("Enter PIN: ")
@@ -110,28 +155,9 @@ var signPDFCmd = &cobra.Command{
 			}
 		}
 
-		var profileID string
-		var position *signature.SignaturePosition
+		GetLogger().Info("signing PDF", "input", inputPath, "profile", profile.Name)
 
-		if signVisible {
-			defProfile := signature.DefaultVisibleProfile()
-			profileID = defProfile.ID.String()
-
-			position = &signature.SignaturePosition{
-				Page:   signPage,
-				X:      signX,
-				Y:      signY,
-				Width:  signWidth,
-				Height: signHeight,
-			}
-		} else {
-			defProfile := signature.DefaultInvisibleProfile()
-			profileID = defProfile.ID.String()
-		}
-
-		GetLogger().Info("signing PDF", "input", inputPath)
-
-		generatedPath, err := service.SignPDFWithProfileAndPosition(inputPath, cert.Fingerprint, signPin, profileID, position)
+		generatedPath, err := service.SignPDFWithCertificate(inputPath, cert, signPin, profile)
 		if err != nil {
 			ExitWithError("failed to sign PDF", err)
 		}
@@ -147,17 +173,62 @@ var signPDFCmd = &cobra.Command{
 }
 
 var signVerifyCmd = &cobra.Command{
-	Use:   "verify <pdf-file>",
+	Use:   "verify <pdf-file|directory|pattern>...",
 	Short: "Verify PDF signatures",
-	Long:  `Verify digital signatures in a PDF document.`,
-	Args:  cobra.ExactArgs(1),
+	Long: `Verify digital signatures in a PDF document.
+
+With --report json or --report html, a detailed report is written instead, covering
+byte range coverage, digest and signature algorithms, the signer chain with
+per-certificate status, revocation sources, timestamps, DocMDP permissions and
+whether the document was modified after each signature.
+
+Several files, directories (searched recursively for PDFs) or glob patterns are
+verified in parallel. A summary table is printed, or one JSON object per line with
+--json, and the exit code reports the outcome:
+
+  0  all files carry valid, trusted signatures
+  2  some files are invalid or unsigned
+  3  some signatures are valid but not trusted
+  4  some files could not be verified
+
+By default certificates are checked as of the signature timestamp, or now when there is
+none. --at checks them as of another time: a date, an RFC 3339 time, or "signing" for
+the signing time each untimestamped signature claims. Revocation status comes from the
+data embedded in the signatures, the files given with --cert, --ocsp and --crl and the
+document security store (DSS). With --online, or when the configured revocation policy
+is not off, missing issuers and revocation data are fetched from the network; --offline
+never uses the network. The output says which evidence was used for each certificate.`,
+	Args: cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
+		opts := verifyOptions()
+
+		if isBatchVerify(args) {
+			if verifyReportFormat != "" {
+				ExitWithError("--report can only be used with a single file", nil)
+			}
+
+			cfgService, err := config.NewService()
+			if err != nil {
+				ExitWithError("failed to initialize config service", err)
+			}
+			service := signature.NewSignatureService(cfgService)
+			service.Startup(context.Background())
+
+			runBatchVerify(service, args, verifyWorkers, opts)
+			return
+		}
+
 		pdfPath := args[0]
 
 		if _, err := os.Stat(pdfPath); os.IsNotExist(err) {
 			ExitWithError("PDF file not found", err)
 		}
 
+		verifyReportFormat = strings.ToLower(verifyReportFormat)
+		if verifyReportFormat != "" && verifyReportFormat != "json" && verifyReportFormat != "html" {
+			ExitWithError(fmt.Sprintf("invalid report format '%s' (use json or html)", verifyReportFormat), nil)
+		}
+
 		cfgService, err := config.NewService()
 		if err != nil {
 			ExitWithError("failed to initialize config service", err)
@@ -167,7 +238,12 @@ var signVerifyCmd = &cobra.Command{
 
 		GetLogger().Info("verifying signatures", "file", SanitizePath(pdfPath))
 
-		signatures, err := service.VerifySignatures(pdfPath)
+		if verifyReportFormat != "" {
+			writeVerificationReport(service, pdfPath, opts)
+			return
+		}
+
+		signatures, err := service.VerifySignaturesWithOptions(pdfPath, opts)
 		if err != nil {
 			ExitWithError("failed to verify signatures", err)
 		}
@@ -187,10 +263,15 @@ var signVerifyCmd = &cobra.Command{
 			fmt.Printf("Found %d signature(s):\n\n", len(signatures))
 
 			for i, sig := range signatures {
-				fmt.Printf("Signature %d:\n", i+1)
+				if sig.DocumentTimestamp {
+					fmt.Printf("Signature %d (document timestamp):\n", i+1)
+				} else {
+					fmt.Printf("Signature %d:\n", i+1)
+				}
 				fmt.Printf("  Signer Name:    %s\n", sig.SignerName)
 				fmt.Printf("  Signer DN:      %s\n", sig.SignerDN)
 				fmt.Printf("  Signing Time:   %s\n", sig.SigningTime)
+				fmt.Printf("  Validated At:   %s\n", sig.ValidationTime)
 				fmt.Printf("  Signature Type: %s\n", sig.SignatureType)
 				fmt.Printf("  Hash Algorithm: %s\n", sig.SigningHashAlgorithm)
 				fmt.Printf("  Valid:          %v\n", sig.IsValid)
@@ -199,6 +280,9 @@ var signVerifyCmd = &cobra.Command{
 				if sig.CertificateValidationMessage != "" {
 					fmt.Printf("  Cert Status:    %s\n", sig.CertificateValidationMessage)
 				}
+				if sig.TrustAnchor != "" {
+					fmt.Printf("  Trust Anchor:   %s\n", sig.TrustAnchor)
+				}
 				if sig.Reason != "" {
 					fmt.Printf("  Reason:         %s\n", sig.Reason)
 				}
@@ -208,12 +292,302 @@ var signVerifyCmd = &cobra.Command{
 				if sig.ContactInfo != "" {
 					fmt.Printf("  Contact:        %s\n", sig.ContactInfo)
 				}
+				for _, v := range sig.PolicyViolations {
+					fmt.Printf("  Policy:         %s (%s)\n", v.Message, v.Severity)
+				}
+				for _, e := range sig.Evidence {
+					fmt.Printf("  Revocation:     %s\n", evidenceSummary(e))
+				}
+				fmt.Printf("  Later Changes:  %s\n", sig.ModificationStatus)
+				for _, m := range sig.Modifications {
+					if m.Allowed {
+						fmt.Printf("    - %s (allowed)\n", m.Description)
+					} else {
+						fmt.Printf("    - %s (disallowed: %s)\n", m.Description, m.Reason)
+					}
+				}
 				fmt.Println()
 			}
 		}
 	},
 }
 
+// verifyOptions builds the verification options from the --at, --online, --offline, --cert,
+// --ocsp and --crl flags.
+func verifyOptions() signature.VerifyOptions {
+	opts := signature.VerifyOptions{
+		Online:           verifyOnline,
+		Offline:          verifyOffline,
+		CertificateFiles: verifyCertFiles,
+		OCSPFiles:        verifyOCSPFiles,
+		CRLFiles:         verifyCRLFiles,
+	}
+
+	switch verifyAt {
+	case "":
+	case "signing":
+		opts.AtSigningTime = true
+	default:
+		at, err := time.Parse(time.RFC3339, verifyAt)
+		if err != nil {
+			if at, err = time.ParseInLocation("2006-01-02", verifyAt, time.Local); err != nil {
+				ExitWithError(fmt.Sprintf("invalid --at value '%s' (use YYYY-MM-DD, RFC 3339 or signing)", verifyAt), nil)
+			}
+		}
+		opts.ValidationTime = at
+	}
+
+	return opts
+}
+
+// evidenceSummary describes the revocation evidence of one certificate on a single line.
+func evidenceSummary(e types.RevocationEvidence) string {
+	line := fmt.Sprintf("%s: %s", e.Certificate, e.Status)
+	if e.Source != signature.EvidenceSourceNone {
+		line += fmt.Sprintf(" (%s from %s", strings.ToUpper(e.Method), e.Source)
+		if e.Location != "" {
+			line += " " + e.Location
+		}
+		line += ")"
+	}
+	if e.Message != "" {
+		line += "; " + e.Message
+	}
+	return line
+}
+
+// writeVerificationReport writes the detailed verification report to --output or stdout.
+func writeVerificationReport(service *signature.SignatureService, pdfPath string, opts signature.VerifyOptions) {
+	report, err := service.GetVerificationReportWithOptions(pdfPath, opts)
+	if err != nil {
+		ExitWithError("failed to verify signatures", err)
+	}
+
+	var buf bytes.Buffer
+	if verifyReportFormat == "html" {
+		if err := signature.WriteVerificationReportHTML(&buf, report); err != nil {
+			ExitWithError("failed to render report", err)
+		}
+	} else {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			ExitWithError("failed to marshal report to JSON", err)
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+
+	if verifyReportOutput == "" {
+		os.Stdout.Write(buf.Bytes())
+		return
+	}
+
+	if err := os.WriteFile(verifyReportOutput, buf.Bytes(), 0644); err != nil {
+		ExitWithError("failed to write report", err)
+	}
+	fmt.Printf("Verification report written to: %s\n", verifyReportOutput)
+}
+
+var signExtractCmd = &cobra.Command{
+	Use:   "extract <pdf-file>",
+	Short: "Extract signed revisions, CMS signatures and certificates",
+	Long: `Extract what each signature of a PDF covers: the revision of the document as it was
+signed, the raw CMS/PKCS#7 signature (.p7s) and the embedded certificates (PEM, signer first).
+
+Files are written to --output, by default a <name>_signatures directory next to the PDF.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		pdfPath := args[0]
+
+		if _, err := os.Stat(pdfPath); os.IsNotExist(err) {
+			ExitWithError("PDF file not found", err)
+		}
+
+		outputDir := extractOutput
+		if outputDir == "" {
+			outputDir = strings.TrimSuffix(pdfPath, filepath.Ext(pdfPath)) + "_signatures"
+		}
+
+		cfgService, err := config.NewService()
+		if err != nil {
+			ExitWithError("failed to initialize config service", err)
+		}
+		service := signature.NewSignatureService(cfgService)
+
+		GetLogger().Info("extracting signatures", "file", SanitizePath(pdfPath), "output", SanitizePath(outputDir))
+
+		extracted, err := service.ExtractSignatures(pdfPath, outputDir)
+		if err != nil {
+			ExitWithError("failed to extract signatures", err)
+		}
+
+		if jsonOutput {
+			data, err := json.MarshalIndent(extracted, "", "  ")
+			if err != nil {
+				ExitWithError("failed to marshal result to JSON", err)
+			}
+			fmt.Println(string(data))
+			return
+		}
+
+		fmt.Printf("Extracted %d signature(s) to %s:\n\n", len(extracted), outputDir)
+		for _, sig := range extracted {
+			fmt.Printf("Signature %d:\n", sig.Index)
+			if sig.FieldName != "" {
+				fmt.Printf("  Field:        %s\n", sig.FieldName)
+			}
+			if sig.SignerName != "" {
+				fmt.Printf("  Signer:       %s\n", sig.SignerName)
+			}
+			if sig.RevisionPath != "" {
+				fmt.Printf("  Revision:     %s (%d bytes)\n", sig.RevisionPath, sig.RevisionSize)
+			}
+			fmt.Printf("  CMS:          %s\n", sig.CMSPath)
+			if sig.CertificatesPath != "" {
+				fmt.Printf("  Certificates: %s (%d)\n", sig.CertificatesPath, sig.CertificateCount)
+			}
+			if sig.Error != "" {
+				fmt.Printf("  Error:        %s\n", sig.Error)
+			}
+			fmt.Println()
+		}
+	},
+}
+
+var signExtendCmd = &cobra.Command{
+	Use:   "extend <pdf-file>",
+	Short: "Add long-term validation data to existing signatures",
+	Long: `Make the signatures of a PDF verifiable in the long term (LTV). For every signature the
+certificate chain and an OCSP response or CRL for each certificate are collected and stored in
+the document security store (DSS), with a VRI entry per signature. Use --timestamp-url to also
+append a document timestamp.
+
+Validation data is fetched from the issuers, OCSP responders and CRL distribution points named
+in the certificates. Use --cert, --ocsp and --crl to supply it from files instead, and --offline
+to never use the network.
+
+The data is added as an incremental update, so the existing signatures stay valid. The result is
+written to --output, by default <name>_ltv.pdf next to the PDF.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		pdfPath := args[0]
+
+		if _, err := os.Stat(pdfPath); os.IsNotExist(err) {
+			ExitWithError("PDF file not found", err)
+		}
+
+		cfgService, err := config.NewService()
+		if err != nil {
+			ExitWithError("failed to initialize config service", err)
+		}
+		service := signature.NewSignatureService(cfgService)
+
+		opts := signature.ExtendOptions{
+			OutputPath:       extendOutput,
+			CertificateFiles: extendCertFiles,
+			OCSPFiles:        extendOCSPFiles,
+			CRLFiles:         extendCRLFiles,
+			Offline:          extendOffline,
+			TimestampURL:     extendTimestampURL,
+		}
+
+		GetLogger().Info("extending signatures", "file", SanitizePath(pdfPath), "offline", extendOffline)
+
+		result, err := service.ExtendSignatures(pdfPath, opts)
+		if err != nil {
+			ExitWithError("failed to extend signatures", err)
+		}
+
+		if jsonOutput {
+			data, err := json.MarshalIndent(result, "", "  ")
+			if err != nil {
+				ExitWithError("failed to marshal result to JSON", err)
+			}
+			fmt.Println(string(data))
+			return
+		}
+
+		fmt.Printf("Extended %d signature(s): added %d certificate(s), %d OCSP response(s), %d CRL(s)\n",
+			len(result.Signatures), result.Certificates, result.OCSPResponses, result.CRLs)
+		if result.DocumentTimestamp {
+			fmt.Println("Document timestamp added")
+		}
+		fmt.Printf("Output: %s\n\n", result.OutputPath)
+
+		for _, sig := range result.Signatures {
+			fmt.Printf("Signature %d:\n", sig.Index)
+			if sig.SignerName != "" {
+				fmt.Printf("  Signer:       %s\n", sig.SignerName)
+			}
+			fmt.Printf("  VRI:          %s\n", sig.VRIKey)
+			fmt.Printf("  Certificates: %d\n", sig.Certificates)
+			fmt.Printf("  OCSP:         %d\n", sig.OCSPResponses)
+			fmt.Printf("  CRLs:         %d\n", sig.CRLs)
+			for _, warning := range sig.Warnings {
+				fmt.Printf("  Warning:      %s\n", warning)
+			}
+			fmt.Println()
+		}
+	},
+}
+
+var signTimestampCmd = &cobra.Command{
+	Use:   "timestamp <pdf-file>",
+	Short: "Add a document timestamp to a PDF",
+	Long: `Add an RFC 3161 document timestamp (SubFilter ETSI.RFC3161) to a PDF. It proves the document
+existed in its current form at the time the time-stamping authority (TSA) issued it, without a
+personal signature or certificate.
+
+The TSA is taken from the configuration (lankir config set timestampUrl <url>, with optional
+timestampUsername and timestampPassword) unless --timestamp-url is given.
+
+The timestamp is added as an incremental update, so existing signatures stay valid. The result
+is written to --output, by default <name>_timestamped.pdf next to the PDF.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		pdfPath := args[0]
+
+		if _, err := os.Stat(pdfPath); os.IsNotExist(err) {
+			ExitWithError("PDF file not found", err)
+		}
+
+		cfgService, err := config.NewService()
+		if err != nil {
+			ExitWithError("failed to initialize config service", err)
+		}
+		service := signature.NewSignatureService(cfgService)
+
+		GetLogger().Info("adding document timestamp", "file", SanitizePath(pdfPath))
+
+		result, err := service.TimestampPDF(pdfPath, signature.TimestampOptions{
+			OutputPath: timestampOutput,
+			URL:        timestampURL,
+		})
+		if err != nil {
+			ExitWithError("failed to timestamp PDF", err)
+		}
+
+		if jsonOutput {
+			data, err := json.MarshalIndent(result, "", "  ")
+			if err != nil {
+				ExitWithError("failed to marshal result to JSON", err)
+			}
+			fmt.Println(string(data))
+			return
+		}
+
+		fmt.Println("Document timestamp added")
+		if ts := result.Timestamp; ts != nil {
+			fmt.Printf("  Time:      %s\n", ts.Time.Format(time.RFC3339))
+			fmt.Printf("  Authority: %s\n", ts.Authority)
+			if ts.SerialNumber != "" {
+				fmt.Printf("  Serial:    %s\n", ts.SerialNumber)
+			}
+		}
+		fmt.Printf("Output: %s\n", result.OutputPath)
+	},
+}
+
 var signProfileListCmd = &cobra.Command{
 	Use:   "profile-list",
 	Short: "List signature profiles",
@@ -299,38 +673,156 @@ var signProfileInfoCmd = &cobra.Command{
 			}
 			fmt.Println(string(data))
 		} else {
-			fmt.Printf("Signature Profile:\n\n")
-			fmt.Printf("  ID:          %s\n", profile.ID)
-			fmt.Printf("  Name:        %s\n", profile.Name)
-			fmt.Printf("  Description: %s\n", profile.Description)
-			fmt.Printf("  Visibility:  %s\n", profile.Visibility)
-			fmt.Printf("  Default:     %v\n", profile.IsDefault)
-
-			if profile.Visibility == signature.VisibilityVisible {
-				fmt.Printf("\n  Position:\n")
-				fmt.Printf("    Page:   %d\n", profile.Position.Page)
-				fmt.Printf("    X:      %.2f\n", profile.Position.X)
-				fmt.Printf("    Y:      %.2f\n", profile.Position.Y)
-				fmt.Printf("    Width:  %.2f\n", profile.Position.Width)
-				fmt.Printf("    Height: %.2f\n", profile.Position.Height)
-
-				fmt.Printf("\n  Appearance:\n")
-				fmt.Printf("    Show Signer Name:  %v\n", profile.Appearance.ShowSignerName)
-				fmt.Printf("    Show Signing Time: %v\n", profile.Appearance.ShowSigningTime)
-				fmt.Printf("    Show Location:     %v\n", profile.Appearance.ShowLocation)
-				fmt.Printf("    Show Logo:         %v\n", profile.Appearance.ShowLogo)
-				if profile.Appearance.LogoPath != "" {
-					fmt.Printf("    Logo Path:         %s\n", profile.Appearance.LogoPath)
-				}
-				if profile.Appearance.CustomText != "" {
-					fmt.Printf("    Custom Text:       %s\n", profile.Appearance.CustomText)
-				}
-				fmt.Printf("    Font Size:         %d\n", profile.Appearance.FontSize)
-			}
+			printSignatureProfile("Signature Profile", profile)
 		}
 	},
 }
 
+// signingProfile returns the profile sign pdf signs with: the --profile one, or a built-in one
+// without it, with the position, visibility and appearance flags given applied.
+func signingProfile(flags *pflag.FlagSet, service *signature.SignatureService) (*signature.SignatureProfile, error) {
+	position := signature.SignaturePosition{
+		Page:   signPage,
+		X:      signX,
+		Y:      signY,
+		Width:  signWidth,
+		Height: signHeight,
+	}
+
+	var profile *signature.SignatureProfile
+	if signProfile == "" {
+		builtIn := signature.DefaultInvisibleProfile()
+		if signVisible {
+			builtIn = signature.DefaultVisibleProfile()
+		}
+
+		var err error
+		profile, err = service.GetSignatureProfile(builtIn.ID.String())
+		if err != nil {
+			return nil, err
+		}
+		if signVisible {
+			profile.Position = position
+		}
+		return profile, applySignatureFlags(flags, profile)
+	}
+
+	profile, err := service.FindSignatureProfile(signProfile)
+	if err != nil {
+		return nil, err
+	}
+
+	if flags.Changed("visible") {
+		wasVisible := profile.Visibility == signature.VisibilityVisible
+		profile.Visibility = signature.VisibilityInvisible
+		if signVisible {
+			profile.Visibility = signature.VisibilityVisible
+			// A profile made visible here has no position of its own yet
+			if !wasVisible {
+				profile.Position = position
+			}
+		}
+	}
+
+	if flags.Changed("page") {
+		profile.Position.Page = signPage
+	}
+	if flags.Changed("x") {
+		profile.Position.X = signX
+	}
+	if flags.Changed("y") {
+		profile.Position.Y = signY
+	}
+	if flags.Changed("width") {
+		profile.Position.Width = signWidth
+	}
+	if flags.Changed("height") {
+		profile.Position.Height = signHeight
+	}
+
+	return profile, applySignatureFlags(flags, profile)
+}
+
+// printSignatureProfile prints the settings of a signature profile.
+func printSignatureProfile(title string, profile *signature.SignatureProfile) {
+	fmt.Printf("%s:\n\n", title)
+	fmt.Printf("  ID:          %s\n", profile.ID)
+	fmt.Printf("  Name:        %s\n", profile.Name)
+	fmt.Printf("  Description: %s\n", profile.Description)
+	fmt.Printf("  Visibility:  %s\n", profile.Visibility)
+	fmt.Printf("  Default:     %v\n", profile.IsDefault)
+
+	if profile.Visibility == signature.VisibilityVisible {
+		fmt.Printf("\n  Position:\n")
+		fmt.Printf("    Page:   %d\n", profile.Position.Page)
+		fmt.Printf("    X:      %.2f\n", profile.Position.X)
+		fmt.Printf("    Y:      %.2f\n", profile.Position.Y)
+		fmt.Printf("    Width:  %.2f\n", profile.Position.Width)
+		fmt.Printf("    Height: %.2f\n", profile.Position.Height)
+
+		fmt.Printf("\n  Appearance:\n")
+		fmt.Printf("    Show Signer Name:  %v\n", profile.Appearance.ShowSignerName)
+		fmt.Printf("    Show Signing Time: %v\n", profile.Appearance.ShowSigningTime)
+		fmt.Printf("    Show Location:     %v\n", profile.Appearance.ShowLocation)
+		fmt.Printf("    Show Logo:         %v\n", profile.Appearance.ShowLogo)
+		if profile.Appearance.LogoPath != "" {
+			fmt.Printf("    Logo:              %s\n", describeEmbeddedFile(profile.Appearance.LogoPath))
+		}
+		if profile.Appearance.LogoPosition != "" {
+			fmt.Printf("    Logo Position:     %s\n", profile.Appearance.LogoPosition)
+		}
+		if profile.Appearance.CustomText != "" {
+			fmt.Printf("    Custom Text:       %s\n", profile.Appearance.CustomText)
+		}
+		fmt.Printf("    Font Size:         %d\n", profile.Appearance.FontSize)
+		if profile.Appearance.FontPath != "" {
+			fmt.Printf("    Font:              %s\n", describeEmbeddedFile(profile.Appearance.FontPath))
+		}
+	}
+	if profile.Reason != "" || profile.Location != "" {
+		fmt.Printf("\n  Reason:   %s\n", profile.Reason)
+		fmt.Printf("  Location: %s\n", profile.Location)
+	}
+	if profile.RevocationPolicy != "" {
+		fmt.Printf("\n  Revocation Policy: %s\n", profile.RevocationPolicy)
+	}
+	if profile.BindsCertificate() {
+		fmt.Printf("\n  Certificate: %s\n", describeCertificateBinding(profile))
+	}
+	if profile.PINPolicy == signature.PINPolicyCache {
+		fmt.Printf("  PIN Policy:  cache for %d minutes\n", profile.PINCacheMinutes)
+	}
+}
+
+// describeEmbeddedFile summarizes an embedded logo or font instead of printing its data URL.
+func describeEmbeddedFile(dataURL string) string {
+	mediaType, data, ok := strings.Cut(strings.TrimPrefix(dataURL, "data:"), ";base64,")
+	if !ok || !strings.HasPrefix(dataURL, "data:") {
+		return dataURL
+	}
+	return fmt.Sprintf("%s, %d bytes", mediaType, base64.StdEncoding.DecodedLen(len(data)))
+}
+
+// printCertificateSelection reports the automatically selected certificate and why it won.
+func printCertificateSelection(selection *signature.CertificateSelection) {
+	fmt.Printf("Selected certificate: %s (Fingerprint: %s)\n", selection.Certificate.Name, selection.Certificate.Fingerprint)
+	for _, reason := range selection.Reasons {
+		fmt.Printf("  - %s\n", reason)
+	}
+
+	if len(selection.Candidates) > 1 {
+		fmt.Println("Candidates:")
+		for _, c := range selection.Candidates {
+			if c.Eligible {
+				fmt.Printf("  %d. %s [%s]\n", c.Rank, c.Name, c.Source)
+			} else {
+				fmt.Printf("  -  %s [%s]: %s\n", c.Name, c.Source, strings.Join(c.Reasons, ", "))
+			}
+		}
+	}
+	fmt.Println()
+}
+
 var (
 	signCertFile        string
 	signCertFingerprint string
@@ -342,12 +834,36 @@ var (
 	signWidth           float64
 	signHeight          float64
 	signVisible         bool
+	signAuto            bool
+	signProfile         string
+	signDryRun          bool
+	verifyReportFormat  string
+	verifyReportOutput  string
+	extractOutput       string
+	verifyWorkers       int
+	verifyAt            string
+	verifyOnline        bool
+	verifyOffline       bool
+	verifyCertFiles     []string
+	verifyOCSPFiles     []string
+	verifyCRLFiles      []string
+	extendOutput        string
+	extendCertFiles     []string
+	extendOCSPFiles     []string
+	extendCRLFiles      []string
+	extendOffline       bool
+	extendTimestampURL  string
+	timestampOutput     string
+	timestampURL        string
 )
 
 func init() {
 	rootCmd.AddCommand(signCmd)
 	signCmd.AddCommand(signPDFCmd)
 	signCmd.AddCommand(signVerifyCmd)
+	signCmd.AddCommand(signExtractCmd)
+	signCmd.AddCommand(signExtendCmd)
+	signCmd.AddCommand(signTimestampCmd)
 	signCmd.AddCommand(signProfileListCmd)
 	signCmd.AddCommand(signProfileInfoCmd)
 
@@ -355,6 +871,7 @@ func init() {
 	signPDFCmd.Flags().StringVar(&signCertFingerprint, "fingerprint", "", "certificate fingerprint")
 	signPDFCmd.Flags().StringVarP(&signCertName, "name", "n", "", "certificate name (partial match)")
 	signPDFCmd.Flags().StringVar(&signPin, "pin", "", "PIN/password for the certificate")
+	signPDFCmd.Flags().BoolVar(&signAuto, "auto", false, "automatically select the best signing certificate (narrowed by --name if given)")
 
 	signPDFCmd.Flags().IntVar(&signPage, "page", 1, "page number to sign (1-based)")
 	signPDFCmd.Flags().Float64Var(&signX, "x", 100, "x coordinate")
@@ -364,7 +881,33 @@ func init() {
 
 	signPDFCmd.Flags().BoolVar(&signVisible, "visible", true, "create a visible signature")
 
+	signPDFCmd.Flags().StringVar(&signProfile, "profile", "", "signature profile ID or name (default: built-in profiles)")
+	signPDFCmd.Flags().BoolVar(&signDryRun, "dry-run", false, "print the effective signature profile without signing")
+	signPDFCmd.Flags().BoolVarP(&jsonOutput, "json", "j", false, "output the --dry-run profile in JSON format")
+	addSignatureFlags(signPDFCmd)
+
 	signVerifyCmd.Flags().BoolVarP(&jsonOutput, "json", "j", false, "output in JSON format")
+	signVerifyCmd.Flags().StringVar(&verifyReportFormat, "report", "", "write a detailed verification report (json or html)")
+	signVerifyCmd.Flags().StringVarP(&verifyReportOutput, "output", "o", "", "file to write the report to (default: stdout)")
+	signVerifyCmd.Flags().IntVar(&verifyWorkers, "workers", runtime.NumCPU(), "number of files verified in parallel")
+	signVerifyCmd.Flags().StringVar(&verifyAt, "at", "", "validation time: YYYY-MM-DD, RFC 3339 or \"signing\" (default: timestamp or now)")
+	signVerifyCmd.Flags().BoolVar(&verifyOnline, "online", false, "fetch missing issuers and revocation data from the network")
+	signVerifyCmd.Flags().BoolVar(&verifyOffline, "offline", false, "never use the network, even when the revocation policy is on")
+	signVerifyCmd.Flags().StringSliceVar(&verifyCertFiles, "cert", nil, "issuer certificate files (PEM or DER) to complete the chains with")
+	signVerifyCmd.Flags().StringSliceVar(&verifyOCSPFiles, "ocsp", nil, "DER encoded OCSP response files to use")
+	signVerifyCmd.Flags().StringSliceVar(&verifyCRLFiles, "crl", nil, "CRL files (PEM or DER) to use")
+	signExtractCmd.Flags().BoolVarP(&jsonOutput, "json", "j", false, "output in JSON format")
+	signExtractCmd.Flags().StringVarP(&extractOutput, "output", "o", "", "directory to write the files to (default: <name>_signatures next to the PDF)")
+	signExtendCmd.Flags().BoolVarP(&jsonOutput, "json", "j", false, "output in JSON format")
+	signExtendCmd.Flags().StringVarP(&extendOutput, "output", "o", "", "file to write the extended PDF to (default: <name>_ltv.pdf next to the PDF)")
+	signExtendCmd.Flags().StringSliceVar(&extendCertFiles, "cert", nil, "certificate files (PEM or DER) to complete the chains with")
+	signExtendCmd.Flags().StringSliceVar(&extendOCSPFiles, "ocsp", nil, "DER encoded OCSP response files to use")
+	signExtendCmd.Flags().StringSliceVar(&extendCRLFiles, "crl", nil, "CRL files (PEM or DER) to use")
+	signExtendCmd.Flags().BoolVar(&extendOffline, "offline", false, "only use the supplied files and the data embedded in the signatures")
+	signExtendCmd.Flags().StringVar(&extendTimestampURL, "timestamp-url", "", "append a document timestamp from this RFC 3161 TSA")
+	signTimestampCmd.Flags().BoolVarP(&jsonOutput, "json", "j", false, "output in JSON format")
+	signTimestampCmd.Flags().StringVarP(&timestampOutput, "output", "o", "", "file to write the timestamped PDF to (default: <name>_timestamped.pdf next to the PDF)")
+	signTimestampCmd.Flags().StringVar(&timestampURL, "timestamp-url", "", "RFC 3161 TSA to use instead of the configured one")
 	signProfileListCmd.Flags().BoolVarP(&jsonOutput, "json", "j", false, "output in JSON format")
 	signProfileInfoCmd.Flags().BoolVarP(&jsonOutput, "json", "j", false, "output in JSON format")
 }
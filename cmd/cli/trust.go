@@ -0,0 +1,118 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/Matbe34/lankir/internal/config"
+	"github.com/Matbe34/lankir/internal/signature"
+	"github.com/spf13/cobra"
+)
+
+var trustPurpose string
+
+var trustCmd = &cobra.Command{
+	Use:   "trust",
+	Short: "Manage trust anchors",
+	Long: `Manage the certificates trusted as roots when verifying signatures, in addition to the
+system trust store. An anchor is a PEM/DER bundle or a directory of certificate files, trusted
+for signing, timestamping or all purposes.`,
+}
+
+var trustAddCmd = &cobra.Command{
+	Use:   "add <path>",
+	Short: "Add a trust anchor",
+	Long:  `Trust the certificates in a PEM/DER bundle or in the .pem, .crt, .cer and .der files of a directory.`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		service := newTrustService()
+
+		GetLogger().Info("adding trust anchor", "path", SanitizePath(args[0]), "purpose", trustPurpose)
+
+		if err := service.AddTrustAnchor(args[0], trustPurpose); err != nil {
+			ExitWithError("failed to add trust anchor", err)
+		}
+
+		fmt.Printf("Trust anchor added: %s\n", args[0])
+	},
+}
+
+var trustListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List trust anchors",
+	Long:  `List the configured trust anchors and the certificates each provides.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		service := newTrustService()
+
+		anchors, err := service.ListTrustAnchors()
+		if err != nil {
+			ExitWithError("failed to list trust anchors", err)
+		}
+
+		if jsonOutput {
+			data, err := json.MarshalIndent(anchors, "", "  ")
+			if err != nil {
+				ExitWithError("failed to marshal trust anchors to JSON", err)
+			}
+			fmt.Println(string(data))
+			return
+		}
+
+		if len(anchors) == 0 {
+			fmt.Println("No trust anchors configured. Only the system trust store is used.")
+			return
+		}
+
+		fmt.Printf("Found %d trust anchor(s):\n\n", len(anchors))
+		for _, anchor := range anchors {
+			fmt.Printf("  %s\n", anchor.Path)
+			fmt.Printf("    Purpose: %s\n", anchor.Purpose)
+			if anchor.Error != "" {
+				fmt.Printf("    Error:   %s\n", anchor.Error)
+			}
+			for _, cert := range anchor.Certificates {
+				fmt.Printf("    - %s\n", cert.SubjectDN)
+				fmt.Printf("      SHA-256: %s\n", cert.FingerprintSHA256)
+				fmt.Printf("      Expires: %s\n", cert.NotAfter.Format("2006-01-02"))
+			}
+			fmt.Println()
+		}
+	},
+}
+
+var trustRemoveCmd = &cobra.Command{
+	Use:   "remove <path>",
+	Short: "Remove a trust anchor",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		service := newTrustService()
+
+		GetLogger().Info("removing trust anchor", "path", SanitizePath(args[0]))
+
+		if err := service.RemoveTrustAnchor(args[0]); err != nil {
+			ExitWithError("failed to remove trust anchor", err)
+		}
+
+		fmt.Printf("Trust anchor removed: %s\n", args[0])
+	},
+}
+
+// newTrustService creates a signature service for editing trust anchors; certificate
+// sources are not watched since no certificates are listed.
+func newTrustService() *signature.SignatureService {
+	cfgService, err := config.NewService()
+	if err != nil {
+		ExitWithError("failed to initialize config service", err)
+	}
+	return signature.NewSignatureService(cfgService)
+}
+
+func init() {
+	rootCmd.AddCommand(trustCmd)
+	trustCmd.AddCommand(trustAddCmd)
+	trustCmd.AddCommand(trustListCmd)
+	trustCmd.AddCommand(trustRemoveCmd)
+
+	trustAddCmd.Flags().StringVar(&trustPurpose, "purpose", config.TrustPurposeAll, "what the anchor is trusted for: all, signing or timestamping")
+	trustListCmd.Flags().BoolVarP(&jsonOutput, "json", "j", false, "output in JSON format")
+}
@@ -0,0 +1,255 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/Matbe34/lankir/internal/signature"
+	"github.com/Matbe34/lankir/internal/signature/types"
+)
+
+// Exit codes of a batch verification. When several apply, errors take precedence over
+// invalid files, and invalid files over untrusted ones.
+const (
+	exitBatchValid     = 0
+	exitBatchInvalid   = 2
+	exitBatchUntrusted = 3
+	exitBatchError     = 4
+)
+
+// Per-file statuses of a batch verification
+const (
+	batchStatusValid     = "valid"
+	batchStatusUntrusted = "untrusted"
+	batchStatusInvalid   = "invalid"
+	batchStatusUnsigned  = "unsigned"
+	batchStatusError     = "error"
+)
+
+// batchResult is the outcome of verifying one file, printed as one JSON line with --json.
+type batchResult struct {
+	File           string                `json:"file"`
+	Status         string                `json:"status"`
+	SignatureCount int                   `json:"signatureCount"`
+	Message        string                `json:"message,omitempty"`
+	Signatures     []types.SignatureInfo `json:"signatures,omitempty"`
+}
+
+// isBatchVerify reports whether the verify arguments name more than a single PDF file.
+func isBatchVerify(args []string) bool {
+	if len(args) != 1 {
+		return true
+	}
+	info, err := os.Stat(args[0])
+	if err != nil {
+		return isGlobPattern(args[0])
+	}
+	return info.IsDir()
+}
+
+// isGlobPattern reports whether arg is to be expanded as a glob. Existing files are taken
+// literally, so names such as contract[1].pdf are not mistaken for patterns.
+func isGlobPattern(arg string) bool {
+	if !strings.ContainsAny(arg, "*?[") {
+		return false
+	}
+	_, err := os.Stat(arg)
+	return err != nil
+}
+
+// expandVerifyTargets resolves files, directories (searched recursively for PDFs) and glob
+// patterns into a list of files, in argument order and without duplicates.
+func expandVerifyTargets(args []string) ([]string, error) {
+	var files []string
+	add := func(path string) {
+		if !slices.Contains(files, path) {
+			files = append(files, path)
+		}
+	}
+
+	for _, arg := range args {
+		paths := []string{arg}
+		if isGlobPattern(arg) {
+			matches, err := filepath.Glob(arg)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern '%s': %w", arg, err)
+			}
+			if len(matches) == 0 {
+				GetLogger().Warn("pattern matched no files", "pattern", arg)
+			}
+			paths = matches
+		}
+
+		for _, path := range paths {
+			info, err := os.Stat(path)
+			if err != nil || !info.IsDir() {
+				// Missing files are reported as errors by the verification itself
+				add(path)
+				continue
+			}
+
+			err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if !d.IsDir() && strings.EqualFold(filepath.Ext(p), ".pdf") {
+					add(p)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to read directory %s: %w", path, err)
+			}
+		}
+	}
+
+	return files, nil
+}
+
+// verifyFile verifies one file and classifies the outcome.
+func verifyFile(service *signature.SignatureService, path string, opts signature.VerifyOptions) batchResult {
+	result := batchResult{File: path}
+
+	signatures, err := service.VerifySignaturesWithOptions(path, opts)
+	if err != nil {
+		result.Status = batchStatusError
+		result.Message = err.Error()
+		return result
+	}
+
+	result.Signatures = signatures
+	result.SignatureCount = len(signatures)
+	result.Status = batchStatusValid
+	if len(signatures) == 0 {
+		result.Status = batchStatusUnsigned
+		result.Message = "no signatures found"
+		return result
+	}
+
+	for i, sig := range signatures {
+		switch {
+		case !sig.IsValid:
+			result.Status = batchStatusInvalid
+			result.Message = fmt.Sprintf("signature %d: %s", i+1, sig.ValidationMessage)
+			return result
+		case sig.ModificationStatus == signature.ModificationStatusDisallowed:
+			result.Status = batchStatusInvalid
+			result.Message = fmt.Sprintf("signature %d: disallowed changes after signing", i+1)
+			return result
+		case !sig.CertificateValid && result.Status == batchStatusValid:
+			result.Status = batchStatusUntrusted
+			result.Message = fmt.Sprintf("signature %d: %s", i+1, sig.CertificateValidationMessage)
+		}
+	}
+	return result
+}
+
+// runBatchVerify verifies the files named by args and exits with the batch exit code.
+func runBatchVerify(service *signature.SignatureService, args []string, workers int, opts signature.VerifyOptions) {
+	files, err := expandVerifyTargets(args)
+	if err != nil {
+		ExitWithError("failed to resolve files", err)
+	}
+	if len(files) == 0 {
+		ExitWithError("no PDF files found", nil)
+	}
+
+	os.Exit(verifyBatch(os.Stdout, service, files, workers, opts))
+}
+
+// verifyBatch verifies files with a bounded worker pool, writes each result in input order as
+// soon as it and all results before it are known, and returns the batch exit code.
+func verifyBatch(out io.Writer, service *signature.SignatureService, files []string, workers int, opts signature.VerifyOptions) int {
+	workers = max(1, min(workers, len(files)))
+	GetLogger().Info("verifying files", "count", len(files), "workers", workers)
+
+	type indexedResult struct {
+		index  int
+		result batchResult
+	}
+
+	jobs := make(chan int)
+	results := make(chan indexedResult)
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results <- indexedResult{index: i, result: verifyFile(service, files[i], opts)}
+			}
+		}()
+	}
+
+	go func() {
+		for i := range files {
+			jobs <- i
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	if !jsonOutput {
+		fmt.Fprintf(out, "%-10s %-5s %s\n", "STATUS", "SIGS", "FILE")
+	}
+
+	counts := map[string]int{}
+	pending := map[int]batchResult{}
+	next := 0
+	for r := range results {
+		pending[r.index] = r.result
+		for {
+			result, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+
+			counts[result.Status]++
+			printBatchResult(out, result)
+		}
+	}
+
+	if !jsonOutput {
+		fmt.Fprintf(out, "\n%d file(s): %d valid, %d untrusted, %d invalid, %d unsigned, %d error(s)\n",
+			len(files), counts[batchStatusValid], counts[batchStatusUntrusted], counts[batchStatusInvalid],
+			counts[batchStatusUnsigned], counts[batchStatusError])
+	}
+
+	switch {
+	case counts[batchStatusError] > 0:
+		return exitBatchError
+	case counts[batchStatusInvalid] > 0 || counts[batchStatusUnsigned] > 0:
+		return exitBatchInvalid
+	case counts[batchStatusUntrusted] > 0:
+		return exitBatchUntrusted
+	}
+	return exitBatchValid
+}
+
+func printBatchResult(out io.Writer, result batchResult) {
+	if jsonOutput {
+		data, err := json.Marshal(result)
+		if err != nil {
+			ExitWithError("failed to marshal result to JSON", err)
+		}
+		fmt.Fprintln(out, string(data))
+		return
+	}
+
+	line := fmt.Sprintf("%-10s %-5d %s", result.Status, result.SignatureCount, result.File)
+	if result.Message != "" && result.Status != batchStatusValid {
+		line += " (" + result.Message + ")"
+	}
+	fmt.Fprintln(out, line)
+}
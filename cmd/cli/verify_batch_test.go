@@ -0,0 +1,276 @@
+package cli
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Matbe34/lankir/internal/config"
+	"github.com/Matbe34/lankir/internal/signature"
+	goPkcs12 "software.sslmate.com/src/go-pkcs12"
+)
+
+// batchFixture is a signature service with a signing certificate in its store
+type batchFixture struct {
+	service *signature.SignatureService
+	cert    *x509.Certificate
+	dir     string
+}
+
+func newBatchFixture(t *testing.T) *batchFixture {
+	t.Helper()
+
+	storeDir := t.TempDir()
+	cfgService, err := config.NewServiceWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create config service: %v", err)
+	}
+	cfg := cfgService.Get()
+	cfg.CertificateStores = []string{storeDir}
+	cfg.TokenLibraries = []string{}
+	if err := cfgService.Update(cfg); err != nil {
+		t.Fatalf("Failed to update config: %v", err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "Batch Signer"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageContentCommitment,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+
+	p12, err := goPkcs12.Modern.Encode(key, cert, nil, "")
+	if err != nil {
+		t.Fatalf("Failed to encode PKCS#12: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(storeDir, "signer.p12"), p12, 0600); err != nil {
+		t.Fatalf("Failed to write PKCS#12: %v", err)
+	}
+
+	return &batchFixture{service: signature.NewSignatureService(cfgService), cert: cert, dir: t.TempDir()}
+}
+
+// unsigned writes a minimal one-page PDF named name
+func (f *batchFixture) unsigned(t *testing.T, name string) string {
+	t.Helper()
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R /Resources << /Font << /F1 << /Type /Font /Subtype /Type1 /BaseFont /Helvetica >> >> >> >>",
+		"<< /Length 44 >>\nstream\nBT /F1 24 Tf 100 700 Td (Test PDF) Tj ET\nendstream",
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.7\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	path := filepath.Join(f.dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write PDF: %v", err)
+	}
+	return path
+}
+
+// signed writes a PDF named name signed with the fixture certificate
+func (f *batchFixture) signed(t *testing.T, name string) string {
+	t.Helper()
+
+	source := f.unsigned(t, "source-"+name)
+	sum := sha256.Sum256(f.cert.Raw)
+	signedPath, err := f.service.SignPDF(source, hex.EncodeToString(sum[:]), "")
+	if err != nil {
+		t.Fatalf("SignPDF failed: %v", err)
+	}
+
+	path := filepath.Join(f.dir, name)
+	if err := os.Rename(signedPath, path); err != nil {
+		t.Fatalf("Failed to rename signed PDF: %v", err)
+	}
+	os.Remove(source)
+	return path
+}
+
+// tampered writes a signed PDF named name whose signed content was changed afterwards
+func (f *batchFixture) tampered(t *testing.T, name string) string {
+	t.Helper()
+
+	path := f.signed(t, name)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read PDF: %v", err)
+	}
+	data = bytes.Replace(data, []byte("(Test PDF)"), []byte("(Test PDX)"), 1)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("Failed to write PDF: %v", err)
+	}
+	return path
+}
+
+// trust makes the fixture certificate a signing trust anchor
+func (f *batchFixture) trust(t *testing.T) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "anchor.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: f.cert.Raw}), 0644); err != nil {
+		t.Fatalf("Failed to write anchor: %v", err)
+	}
+	if err := f.service.AddTrustAnchor(path, config.TrustPurposeSigning); err != nil {
+		t.Fatalf("AddTrustAnchor failed: %v", err)
+	}
+}
+
+// TestExpandVerifyTargets tests files, directories and globs, and that existing files with glob
+// characters in their names are taken literally
+func TestExpandVerifyTargets(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"contract[1].pdf", "contract1.pdf", "a.pdf", "notes.txt", "sub/b.PDF"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("%PDF-1.7\n"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+	join := func(name string) string { return filepath.Join(dir, name) }
+
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{"literal file with brackets", []string{join("contract[1].pdf")}, []string{join("contract[1].pdf")}},
+		{"glob pattern", []string{join("contract?.pdf")}, []string{join("contract1.pdf")}},
+		{"bracket pattern without such a file", []string{join("contract[0-9].pdf")}, []string{join("contract1.pdf")}},
+		{"directory", []string{join("sub")}, []string{join("sub/b.PDF")}},
+		{"order and duplicates", []string{join("contract1.pdf"), join("a.pdf"), join("*1.pdf")}, []string{join("contract1.pdf"), join("a.pdf")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := expandVerifyTargets(tt.args)
+			if err != nil {
+				t.Fatalf("expandVerifyTargets failed: %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	if isBatchVerify([]string{join("contract[1].pdf")}) {
+		t.Error("Existing file with brackets treated as a batch")
+	}
+	if !isBatchVerify([]string{join("*.pdf")}) {
+		t.Error("Glob pattern not treated as a batch")
+	}
+	if !isBatchVerify([]string{join("sub")}) {
+		t.Error("Directory not treated as a batch")
+	}
+}
+
+// TestVerifyBatch_ExitCodes tests the exit code for each outcome and that errors take precedence
+// over invalid files, and invalid files over untrusted ones
+func TestVerifyBatch_ExitCodes(t *testing.T) {
+	f := newBatchFixture(t)
+	signed := f.signed(t, "signed.pdf")
+	unsigned := f.unsigned(t, "unsigned.pdf")
+	tampered := f.tampered(t, "tampered.pdf")
+	missing := filepath.Join(f.dir, "missing.pdf")
+
+	tests := []struct {
+		name  string
+		files []string
+		want  int
+	}{
+		{"untrusted", []string{signed}, exitBatchUntrusted},
+		{"unsigned", []string{signed, unsigned}, exitBatchInvalid},
+		{"tampered", []string{tampered, signed}, exitBatchInvalid},
+		{"error", []string{signed, unsigned, missing}, exitBatchError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if got := verifyBatch(&out, f.service, tt.files, 2, signature.VerifyOptions{}); got != tt.want {
+				t.Errorf("Expected exit code %d, got %d\n%s", tt.want, got, out.String())
+			}
+		})
+	}
+
+	f.trust(t)
+	var out bytes.Buffer
+	if got := verifyBatch(&out, f.service, []string{signed}, 1, signature.VerifyOptions{}); got != exitBatchValid {
+		t.Errorf("Expected exit code %d for a trusted signature, got %d\n%s", exitBatchValid, got, out.String())
+	}
+}
+
+// TestVerifyBatch_OrderedOutput tests that results are written in input order whatever order
+// the workers finish in
+func TestVerifyBatch_OrderedOutput(t *testing.T) {
+	f := newBatchFixture(t)
+
+	var files []string
+	for i := range 6 {
+		if i%2 == 0 {
+			files = append(files, f.signed(t, fmt.Sprintf("doc%d.pdf", i)))
+		} else {
+			files = append(files, f.unsigned(t, fmt.Sprintf("doc%d.pdf", i)))
+		}
+	}
+
+	var out bytes.Buffer
+	verifyBatch(&out, f.service, files, 4, signature.VerifyOptions{})
+
+	var listed []string
+	for _, line := range strings.Split(out.String(), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 3 && strings.HasSuffix(fields[2], ".pdf") {
+			listed = append(listed, fields[2])
+		}
+	}
+	if !slices.Equal(listed, files) {
+		t.Errorf("Expected results in input order %v, got %v", files, listed)
+	}
+	if !strings.Contains(out.String(), "6 file(s): 0 valid, 3 untrusted, 0 invalid, 3 unsigned, 0 error(s)") {
+		t.Errorf("Unexpected summary:\n%s", out.String())
+	}
+}
@@ -10,6 +10,7 @@ import { initMessageDialog } from './messageDialog.js';
 import { initSettings, getSetting } from './settings.js';
 import { themeManager } from './themeManager.js';
 import { initLoadingIndicator } from './loadingIndicator.js';
+import { initCertificateExpiryNotifications } from './certificates.js';
 import { state } from './state.js';
 
 
@@ -30,6 +31,7 @@ document.addEventListener('DOMContentLoaded', async () => {
     initializeUI();
     initMessageDialog();
     initLoadingIndicator();
+    initCertificateExpiryNotifications();
     await initSettings();
     await themeManager.init();
     updateStatus('Ready');
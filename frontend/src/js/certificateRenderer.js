@@ -100,6 +100,12 @@ export function renderCertificateItem(cert, options = {}) {
                     <span class="cert-detail-label">Valid Until:</span>
                     <span>${formatDate(cert.validTo)}</span>
                 </div>
+                ${cert.locations && cert.locations.length > 1 ? `
+                    <div class="cert-detail-row">
+                        <span class="cert-detail-label">Found In:</span>
+                        <span>${cert.locations.map(loc => `${escapeHtml(getCertTypeName(loc.source))}${loc.hasPrivateKey ? ' (key)' : ''}`).join(', ')}</span>
+                    </div>
+                ` : ''}
                 ${includeCapabilities && cert.keyUsage && cert.keyUsage.length > 0 ? `
                     <div class="cert-capabilities">
                         ${cert.keyUsage.map(usage => `<span class="cert-capability">${escapeHtml(usage)}</span>`).join('')}
@@ -12,6 +12,34 @@ export function initCertificatesSettings() {
     renderLists();
 }
 
+/** Subscribes to backend certificate expiry notifications. */
+export function initCertificateExpiryNotifications() {
+    if (!window.runtime || !window.runtime.EventsOn) return;
+
+    window.runtime.EventsOn('certificates:expiring', (expiring) => {
+        if (!expiring || expiring.length === 0) return;
+
+        const lines = expiring.map(entry => {
+            const name = entry.certificate.name;
+            if (entry.expired) {
+                return `${name}: expired`;
+            }
+            return `${name}: expires in ${entry.daysRemaining} day(s)`;
+        });
+        showMessage(lines.join('\n'), 'Certificates Expiring', 'warning');
+    });
+
+    window.runtime.EventsOn('signing:certificateExpiring', (entry) => {
+        if (!entry) return;
+        const name = entry.certificate.name;
+        showMessage(
+            `The signing certificate "${name}" expires in ${entry.daysRemaining} day(s). Consider renewing it soon.`,
+            'Certificate Expiring',
+            'warning'
+        );
+    });
+}
+
 /** Sets up restore defaults buttons for stores and libraries. */
 function setupRestoreDefaults() {
     const restoreStoresBtn = document.getElementById('restoreDefaultStoresBtn');
@@ -218,11 +246,11 @@ async function renderLibrariesList() {
 function setupCertificateDetection() {
     const refreshBtn = document.getElementById('refreshCertsBtn');
     if (refreshBtn) {
-        refreshBtn.addEventListener('click', loadCertificates);
+        refreshBtn.addEventListener('click', () => loadCertificates(true));
     }
 }
 
-async function loadCertificates() {
+async function loadCertificates(refresh = false) {
     const container = document.getElementById('certificatesList');
     const spinner = document.getElementById('certsLoadingSpinner');
     const text = document.getElementById('certsLoadingText');
@@ -234,8 +262,14 @@ async function loadCertificates() {
 
     try {
         if (window.go && window.go.signature && window.go.signature.SignatureService) {
-            const certs = await window.go.signature.SignatureService.ListCertificates();
-            renderCertificates(certs);
+            const service = window.go.signature.SignatureService;
+            // A refresh rescans all sources; otherwise the cached inventory is shown
+            if (refresh) {
+                await service.RefreshCertificates();
+            }
+            const result = await service.ListCertificatesWithStatus();
+            renderSourceStatus(result.sources);
+            renderCertificates(result.certificates);
         } else {
             console.error("SignatureService not available");
             if (text) text.textContent = 'Backend service not available';
@@ -248,6 +282,33 @@ async function loadCertificates() {
     }
 }
 
+const SOURCE_STATUS_LABELS = {
+    timeout: 'timed out',
+    permission_denied: 'permission denied',
+    module_failed: 'module failed to load',
+    error: 'failed to load'
+};
+
+function renderSourceStatus(sources) {
+    const container = document.getElementById('certSourceStatus');
+    if (!container) return;
+
+    const failed = (sources || []).filter(src => src.status !== 'ok' && src.status !== 'not_found');
+    if (failed.length === 0) {
+        container.classList.add('hidden');
+        container.innerHTML = '';
+        return;
+    }
+
+    container.innerHTML = '<strong>Some certificate sources could not be loaded:</strong>' + failed.map(src => `
+        <div title="${escapeHtml(src.message || '')}">
+            ${escapeHtml(SOURCE_STATUS_LABELS[src.status] || src.status)}:
+            <span class="cert-source-path">${escapeHtml(src.source)}</span>
+        </div>
+    `).join('');
+    container.classList.remove('hidden');
+}
+
 function renderCertificates(certs) {
     const container = document.getElementById('certificatesList');
     if (!container) return;
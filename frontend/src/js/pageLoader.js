@@ -1,6 +1,7 @@
 import { state, getActivePDF } from './state.js';
 import { updateStatus, updatePageIndicator, updateScrollProgress } from './utils.js';
 import { PERFORMANCE, DPI } from './constants.js';
+import { highlightSignatures } from './signatureOverlay.js';
 
 const LAZY_LOAD_BUFFER = PERFORMANCE.LAZY_LOAD_BUFFER;
 const LAZY_LOAD_DEBOUNCE_MS = PERFORMANCE.LAZY_LOAD_DEBOUNCE_MS;
@@ -61,6 +62,7 @@ export async function loadPage(pageNum, pageDiv, activePDF) {
         pageDiv.innerHTML = `
             <img src="${pageInfo.imageData}" class="pdf-page" alt="Page ${pageNum + 1}" data-page="${pageNum}" style="width: 100%; height: 100%;"/>
         `;
+        highlightSignatures(pageDiv, pageNum, activePDF);
 
         // Update actual height
         pageDiv.style.minHeight = '';
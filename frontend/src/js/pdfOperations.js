@@ -3,6 +3,7 @@ import { updateStatus, escapeHtml, formatDate, updatePageIndicator, updateScroll
 import { createPDFTab, switchToTab } from './pdfManager.js';
 import { renderPage, renderScrollMode } from './renderer.js';
 import { showLoading, hideLoading } from './loadingIndicator.js';
+import { loadSignatureWidgets } from './signatureOverlay.js';
 
 /** Returns tab ID if PDF is already open, null otherwise. */
 function findOpenPDFTab(filePath) {
@@ -135,6 +136,7 @@ export async function loadSignatureInfo(pdfPath) {
         
         // Call backend to verify signatures
         const signatures = await window.go.signature.SignatureService.VerifySignatures(pdfPath);
+        loadSignatureWidgets(pdfPath, signatures || []);
         
         if (!signatures || signatures.length === 0) {
             signatureInfoContainer.innerHTML = `
@@ -207,10 +209,30 @@ export async function loadSignatureInfo(pdfPath) {
                 `;
             }
             
+            if (sig.modificationStatus === 'disallowed') {
+                const changes = (sig.modifications || [])
+                    .filter(m => !m.allowed)
+                    .map(m => `<li>${escapeHtml(m.description)} (${escapeHtml(m.reason)})</li>`)
+                    .join('');
+                html += `
+                    <div class="signature-detail" style="color: #ef4444; margin-bottom: 0.75rem; padding: 0.75rem; background-color: rgba(239, 68, 68, 0.1); border-radius: 0.25rem; border-left: 3px solid #ef4444;">
+                        <div style="font-weight: 600; margin-bottom: 0.25rem;">✗ Disallowed Changes After Signing</div>
+                        <ul style="font-size: 0.8125rem; margin: 0; padding-left: 1rem;">${changes}</ul>
+                    </div>
+                `;
+            } else if (sig.modificationStatus === 'allowed') {
+                html += `
+                    <div class="signature-detail">
+                        <span class="signature-detail-label">Changes:</span>
+                        <span class="signature-detail-value">${(sig.modifications || []).length} allowed change(s) after signing</span>
+                    </div>
+                `;
+            }
+
             if (sig.signerName) {
                 html += `
                     <div class="signature-detail">
-                        <span class="signature-detail-label">Signer:</span>
+                        <span class="signature-detail-label">${sig.documentTimestamp ? 'Timestamp Authority:' : 'Signer:'}</span>
                         <span class="signature-detail-value">${escapeHtml(sig.signerName)}</span>
                     </div>
                 `;
@@ -228,7 +250,7 @@ export async function loadSignatureInfo(pdfPath) {
             if (sig.signingHashAlgorithm) {
                 html += `
                     <div class="signature-detail">
-                        <span class="signature-detail-label">Algorithm:</span>
+                        <span class="signature-detail-label">Hash:</span>
                         <span class="signature-detail-value">${escapeHtml(sig.signingHashAlgorithm)}</span>
                     </div>
                 `;
@@ -237,7 +259,7 @@ export async function loadSignatureInfo(pdfPath) {
             if (sig.signatureType) {
                 html += `
                     <div class="signature-detail">
-                        <span class="signature-detail-label">Type:</span>
+                        <span class="signature-detail-label">Algorithm:</span>
                         <span class="signature-detail-value">${escapeHtml(sig.signatureType)}</span>
                     </div>
                 `;
@@ -261,11 +283,21 @@ export async function loadSignatureInfo(pdfPath) {
                 `;
             }
             
+            html += `
+                <button class="btn btn-secondary signature-revision-btn" data-signature-index="${index}" style="margin-top: 0.5rem;">
+                    View signed version
+                </button>
+            `;
+            
             html += `</div>`;
         });
         
         signatureInfoContainer.innerHTML = html;
         
+        signatureInfoContainer.querySelectorAll('.signature-revision-btn').forEach(btn => {
+            btn.addEventListener('click', () => openSignedRevision(pdfPath, Number(btn.dataset.signatureIndex)));
+        });
+        
     } catch (error) {
         console.error('Error loading signature info:', error);
         signatureInfoContainer.innerHTML = `
@@ -276,6 +308,25 @@ export async function loadSignatureInfo(pdfPath) {
     }
 }
 
+/** Opens the revision of a PDF as it was when the given signature was applied. */
+async function openSignedRevision(pdfPath, index) {
+    try {
+        showLoading('Extracting signed version...');
+        const extracted = await window.go.signature.SignatureService.ExtractSignatures(pdfPath, '');
+        const sig = extracted && extracted[index];
+        if (!sig || !sig.revisionPath) {
+            updateStatus('Signed version not available' + (sig && sig.error ? ': ' + sig.error : ''));
+            return;
+        }
+        await openRecentFile(sig.revisionPath);
+    } catch (error) {
+        console.error('Error extracting signed version:', error);
+        updateStatus('Error extracting signed version: ' + sanitizeError(error));
+    } finally {
+        hideLoading();
+    }
+}
+
 /** Load page thumbnails using optimized lazy loading */
 export async function loadPageThumbnails(pageCount) {
     try {
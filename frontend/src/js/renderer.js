@@ -2,6 +2,7 @@ import { state, getActivePDF } from './state.js';
 import { updateStatus, sanitizeError } from './utils.js';
 import { updateCurrentPageFromScroll, lazyLoadVisiblePages } from './pageLoader.js';
 import { DPI } from './constants.js';
+import { highlightSignatures } from './signatureOverlay.js';
 
 const DPI_SCALE = DPI.SCREEN / DPI.RENDER;
 
@@ -31,13 +32,14 @@ export async function renderPage(pageNum) {
             const height = baseHeight * state.zoomLevel;
 
             viewer.innerHTML = `
-                <div class="pdf-page-container" 
+                <div class="pdf-page-container" data-page-number="${pageNum}" 
                      data-width="${baseWidth}" 
                      data-height="${baseHeight}"
                      style="width: ${width}px; height: ${height}px;">
                     <img src="${pageInfo.imageData}" class="pdf-page" alt="Page ${pageNum + 1}" style="width: 100%; height: 100%;"/>
                 </div>
             `;
+            highlightSignatures(viewer.firstElementChild, pageNum, activePDF);
 
             updatePageControls();
             updateStatus(`Page ${pageNum + 1} of ${activePDF.totalPages}`);
@@ -132,13 +134,14 @@ export function renderPageFromCache(pageNum, pdfData) {
         const height = baseHeight * state.zoomLevel;
 
         viewer.innerHTML = `
-            <div class="pdf-page-container" 
+            <div class="pdf-page-container" data-page-number="${pageNum}" 
                  data-width="${baseWidth}" 
                  data-height="${baseHeight}"
                  style="width: ${width}px; height: ${height}px;">
                 <img src="${pageInfo.imageData}" class="pdf-page" alt="Page ${pageNum + 1}" style="width: 100%; height: 100%;"/>
             </div>
         `;
+        highlightSignatures(viewer.firstElementChild, pageNum, pdfData);
 
         // Update controls
         updatePageControls();
@@ -187,6 +190,11 @@ export function renderScrollModeFromCache(pdfData) {
     }
 
     viewer.innerHTML = html;
+    viewer.querySelectorAll('.pdf-page-container').forEach(pageDiv => {
+        if (pageDiv.querySelector('img.pdf-page')) {
+            highlightSignatures(pageDiv, Number(pageDiv.dataset.pageNumber), pdfData);
+        }
+    });
 
     cleanupScrollListeners(pdfData.id);
 
@@ -370,6 +370,7 @@ export async function showCertificateDialog(pdfPath) {
 
     // Reset certificate state (keep profile from previous step)
     state.selectedCertificate = null;
+    state.profileCertificate = null;
     signBtn.disabled = true;
 
     // Show loading state
@@ -383,6 +384,25 @@ export async function showCertificateDialog(pdfPath) {
     // Show dialog
     dialog.classList.remove('hidden');
 
+    const profile = state.selectedProfile;
+    if (profile && (profile.certificateFingerprint || profile.certificateSelector || profile.certificateSource)) {
+        try {
+            // Profiles bound to a certificate sign with it and nothing else
+            state.profileCertificate = await window.go.signature.SignatureService.GetProfileCertificate(profile.id);
+            renderCertificateList([state.profileCertificate.certificate], pdfPath);
+        } catch (error) {
+            listContainer.innerHTML = `
+                <div class="empty-state">
+                    <p>Certificate of profile "${escapeHtml(profile.name)}" not available</p>
+                    <p style="font-size: 0.875rem; color: var(--text-secondary); margin-top: 0.5rem;">
+                        ${escapeHtml(String(error))}
+                    </p>
+                </div>
+            `;
+        }
+        return;
+    }
+
     try {
         // Load certificates from backend
         const certificates = await window.go.signature.SignatureService.ListCertificates();
@@ -490,6 +510,7 @@ export function closeCertificateDialog() {
     const dialog = document.getElementById('certDialog');
     dialog.classList.add('hidden');
     state.selectedCertificate = null;
+    state.profileCertificate = null;
     // Keep selectedProfile and signaturePosition for the signing workflow
 }
 
@@ -579,24 +600,12 @@ export async function performSigning() {
         let pin = '';
         let signedPath;
 
-        if (state.selectedCertificate.pinOptional && !state.selectedCertificate.requiresPin) {
+        if (state.profileCertificate?.pinCached) {
+            // The PIN policy of the profile remembers the PIN
+            signedPath = await signWithPIN(pdfPath, '');
+        } else if (state.selectedCertificate.pinOptional && !state.selectedCertificate.requiresPin) {
             try {
-                if (state.signaturePosition && state.selectedProfile.visibility === 'visible') {
-                    signedPath = await window.go.signature.SignatureService.SignPDFWithProfileAndPosition(
-                        pdfPath,
-                        state.selectedCertificate.fingerprint,
-                        '',
-                        state.selectedProfile.id,
-                        state.signaturePosition
-                    );
-                } else {
-                    signedPath = await window.go.signature.SignatureService.SignPDFWithProfile(
-                        pdfPath,
-                        state.selectedCertificate.fingerprint,
-                        '',
-                        state.selectedProfile.id
-                    );
-                }
+                signedPath = await signWithPIN(pdfPath, '');
             } catch (error) {
                 try {
                     pin = await showPINDialog(state.selectedCertificate.name, true);
@@ -621,22 +630,7 @@ export async function performSigning() {
         }
 
         if (!signedPath) {
-            if (state.signaturePosition && state.selectedProfile.visibility === 'visible') {
-                signedPath = await window.go.signature.SignatureService.SignPDFWithProfileAndPosition(
-                    pdfPath,
-                    state.selectedCertificate.fingerprint,
-                    pin,
-                    state.selectedProfile.id,
-                    state.signaturePosition
-                );
-            } else {
-                signedPath = await window.go.signature.SignatureService.SignPDFWithProfile(
-                    pdfPath,
-                    state.selectedCertificate.fingerprint,
-                    pin,
-                    state.selectedProfile.id
-                );
-            }
+            signedPath = await signWithPIN(pdfPath, pin);
         }
 
         signBtn.disabled = false;
@@ -673,6 +667,30 @@ export async function performSigning() {
     }
 }
 
+/**
+ * Sign with the selected profile, position and certificate. Profiles bound to a certificate
+ * pass no fingerprint so the backend resolves the certificate and applies the PIN policy.
+ */
+async function signWithPIN(pdfPath, pin) {
+    const fingerprint = state.profileCertificate ? '' : state.selectedCertificate.fingerprint;
+
+    if (state.signaturePosition && state.selectedProfile.visibility === 'visible') {
+        return window.go.signature.SignatureService.SignPDFWithProfileAndPosition(
+            pdfPath,
+            fingerprint,
+            pin,
+            state.selectedProfile.id,
+            state.signaturePosition
+        );
+    }
+    return window.go.signature.SignatureService.SignPDFWithProfile(
+        pdfPath,
+        fingerprint,
+        pin,
+        state.selectedProfile.id
+    );
+}
+
 /**
  * Open a signed PDF file
  */
@@ -0,0 +1,51 @@
+import { state, getActivePDF } from './state.js';
+
+const STATUS_LABELS = {
+    valid: '✓ Valid signature',
+    invalid: '✗ Invalid signature',
+    unknown: '⚠ Signature not fully verified'
+};
+
+/** Fetches the signature widget positions of a PDF and highlights them with their status. */
+export async function loadSignatureWidgets(pdfPath, signatures) {
+    let widgets = [];
+    try {
+        widgets = await window.go.pdf.PDFService.GetSignatureWidgets(pdfPath, signatures) || [];
+    } catch (error) {
+        console.error('Error loading signature widgets:', error);
+    }
+
+    for (const pdfData of state.openPDFs.values()) {
+        if (pdfData.filePath === pdfPath) {
+            pdfData.signatureWidgets = widgets;
+        }
+    }
+
+    const activePDF = getActivePDF();
+    if (activePDF && activePDF.filePath === pdfPath) {
+        document.querySelectorAll('#pdfViewer .pdf-page-container').forEach(pageDiv => {
+            if (pageDiv.querySelector('img.pdf-page')) {
+                highlightSignatures(pageDiv, Number(pageDiv.dataset.pageNumber), activePDF);
+            }
+        });
+    }
+}
+
+/** Draws the signature highlights of a page over its rendered image. */
+export function highlightSignatures(pageDiv, pageNum, pdfData) {
+    pageDiv.querySelectorAll('.signature-highlight').forEach(el => el.remove());
+
+    const widgets = (pdfData?.signatureWidgets || []).filter(w => w.page === pageNum);
+    for (const widget of widgets) {
+        const highlight = document.createElement('div');
+        highlight.className = `signature-highlight ${widget.status}`;
+        highlight.style.left = `${(widget.x / widget.pageWidth) * 100}%`;
+        highlight.style.top = `${(widget.y / widget.pageHeight) * 100}%`;
+        highlight.style.width = `${(widget.width / widget.pageWidth) * 100}%`;
+        highlight.style.height = `${(widget.height / widget.pageHeight) * 100}%`;
+        highlight.title = [STATUS_LABELS[widget.status], widget.signerName, widget.message]
+            .filter(Boolean)
+            .join('\n');
+        pageDiv.appendChild(highlight);
+    }
+}
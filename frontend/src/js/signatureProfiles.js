@@ -206,6 +206,11 @@ function setupProfileEditor() {
         iconPosition.addEventListener('change', updatePreview);
     }
 
+    const pinPolicy = document.getElementById('profilePinPolicy');
+    pinPolicy.addEventListener('change', () => {
+        document.getElementById('profilePinCacheMinutes').disabled = pinPolicy.value !== 'cache';
+    });
+
     saveBtn.addEventListener('click', async () => {
         await saveProfile();
     });
@@ -247,6 +252,12 @@ function openProfileEditor(profile) {
     visibilitySelect.value = profile.visibility || 'invisible';
     document.getElementById('profileIsDefault').checked = profile.isDefault || false;
 
+    document.getElementById('profileCertificate').value = profile.certificateFingerprint || profile.certificateSelector || '';
+    document.getElementById('profileCertificateSource').value = profile.certificateSource || '';
+    document.getElementById('profilePinPolicy').value = profile.pinPolicy || 'prompt';
+    document.getElementById('profilePinCacheMinutes').value = profile.pinCacheMinutes || 15;
+    document.getElementById('profilePinCacheMinutes').disabled = profile.pinPolicy !== 'cache';
+
     document.getElementById('profileShowSignerName').checked = profile.appearance?.showSignerName ?? true;
     document.getElementById('profileShowSigningTime').checked = profile.appearance?.showSigningTime ?? true;
     document.getElementById('profileShowLocation').checked = profile.appearance?.showLocation ?? false;
@@ -340,7 +351,8 @@ async function updatePreview() {
     }
 
     if (customText) {
-        lines.push(`<div class="preview-line" style="margin-top: 0.5rem;">${escapeHtml(customText)}</div>`);
+        const rendered = await renderCustomText(customText);
+        lines.push(`<div class="preview-line" style="margin-top: 0.5rem; white-space: pre-line;">${escapeHtml(rendered)}</div>`);
     }
 
     if (lines.length === 0 && !showIcon) {
@@ -377,6 +389,38 @@ async function updatePreview() {
     previewContainer.innerHTML = finalHtml;
 }
 
+/** Reads the certificate binding and PIN policy fields of the profile editor. */
+function certificateBinding() {
+    const certificate = document.getElementById('profileCertificate').value.trim();
+    const isFingerprint = /^[0-9a-fA-F]{64}$/.test(certificate);
+    const pinPolicy = document.getElementById('profilePinPolicy').value;
+
+    return {
+        certificateFingerprint: isFingerprint ? certificate.toLowerCase() : '',
+        certificateSelector: isFingerprint ? '' : certificate,
+        certificateSource: document.getElementById('profileCertificateSource').value.trim(),
+        pinPolicy: pinPolicy,
+        pinCacheMinutes: pinPolicy === 'cache'
+            ? parseInt(document.getElementById('profilePinCacheMinutes').value) || 15
+            : 0
+    };
+}
+
+/** Fills the placeholders of custom text with sample values, or returns the template error. */
+async function renderCustomText(customText) {
+    if (!customText.includes('{{')) {
+        return customText;
+    }
+
+    try {
+        const preview = await window.go.signature.SignatureService.PreviewSignatureAppearance(
+            { visibility: 'invisible', appearance: { customText } }, '', '');
+        return preview.customText;
+    } catch (error) {
+        return `Template error: ${error}`;
+    }
+}
+
 async function saveProfile() {
     const modal = document.getElementById('profileEditorModal');
     const saveBtn = document.getElementById('profileEditorSave');
@@ -393,25 +437,13 @@ async function saveProfile() {
             return;
         }
 
-        if (isDefault) {
-            const profiles = await window.go.signature.SignatureService.ListSignatureProfiles();
-            for (const p of profiles) {
-                if (p.isDefault && p.id !== currentEditingProfileId) {
-                    p.isDefault = false;
-                    await window.go.signature.SignatureService.SaveSignatureProfile(p);
-                }
-            }
-        }
-
         const profile = {
             id: currentEditingProfileId || generateProfileId(name),
             name: name,
             description: description,
             visibility: visibility,
-            reason: 'Document digitally signed',
-            location: 'Digital Signature',
-            contactInfo: '',
             isDefault: isDefault,
+            ...certificateBinding(),
             position: {
                 page: 0,
                 x: 0,
@@ -7,6 +7,7 @@ export const state = {
     viewMode: 'scroll', // 'single' or 'scroll'
     loadingPages: new Set(),
     selectedCertificate: null,
+    profileCertificate: null, // certificate bound to the selected profile, with pinCached
     selectedProfile: null,
     availableProfiles: null,
     signaturePosition: null,
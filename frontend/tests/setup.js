@@ -9,6 +9,7 @@ global.window.go = {
       OpenPDF: vi.fn(),
       OpenPDFByPath: vi.fn(),
       RenderPage: vi.fn(),
+      GetSignatureWidgets: vi.fn(),
       ClosePDF: vi.fn()
     },
     RecentFilesService: {
@@ -20,6 +21,8 @@ global.window.go = {
   signature: {
     SignatureService: {
       ListCertificates: vi.fn(),
+      ListCertificatesWithStatus: vi.fn(),
+      RefreshCertificates: vi.fn(),
       SignPDFWithProfile: vi.fn(),
       SignPDFWithProfileAndPosition: vi.fn(),
       VerifySignatures: vi.fn(),
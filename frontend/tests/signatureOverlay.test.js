@@ -0,0 +1,93 @@
+import { describe, it, expect, beforeEach } from 'vitest';
+import { state, createPDFData, addOpenPDF } from '../src/js/state.js';
+import { highlightSignatures, loadSignatureWidgets } from '../src/js/signatureOverlay.js';
+
+const widget = {
+  page: 0,
+  fieldName: 'Signature1',
+  x: 100,
+  y: 92,
+  width: 200,
+  height: 100,
+  pageWidth: 612,
+  pageHeight: 792,
+  status: 'invalid',
+  signerName: 'John Doe',
+  message: 'Signature validation failed'
+};
+
+describe('Signature Overlay', () => {
+  beforeEach(() => {
+    state.openPDFs.clear();
+    state.activeTabId = null;
+  });
+
+  describe('highlightSignatures', () => {
+    it('should position highlights relative to the page', () => {
+      const pageDiv = document.createElement('div');
+      highlightSignatures(pageDiv, 0, { signatureWidgets: [widget] });
+
+      const highlight = pageDiv.querySelector('.signature-highlight');
+      expect(highlight).not.toBeNull();
+      expect(highlight.classList.contains('invalid')).toBe(true);
+      expect(parseFloat(highlight.style.left)).toBeCloseTo((100 / 612) * 100);
+      expect(parseFloat(highlight.style.height)).toBeCloseTo((100 / 792) * 100);
+      expect(highlight.title).toContain('John Doe');
+    });
+
+    it('should only highlight widgets of the given page', () => {
+      const pageDiv = document.createElement('div');
+      highlightSignatures(pageDiv, 1, { signatureWidgets: [widget] });
+
+      expect(pageDiv.querySelectorAll('.signature-highlight').length).toBe(0);
+    });
+
+    it('should replace existing highlights', () => {
+      const pageDiv = document.createElement('div');
+      const pdfData = { signatureWidgets: [widget] };
+      highlightSignatures(pageDiv, 0, pdfData);
+      highlightSignatures(pageDiv, 0, pdfData);
+
+      expect(pageDiv.querySelectorAll('.signature-highlight').length).toBe(1);
+    });
+
+    it('should not inject markup from signer names', () => {
+      const pageDiv = document.createElement('div');
+      const hostile = { ...widget, signerName: '<img src=x onerror=alert(1)>' };
+      highlightSignatures(pageDiv, 0, { signatureWidgets: [hostile] });
+
+      expect(pageDiv.querySelector('img')).toBeNull();
+    });
+  });
+
+  describe('loadSignatureWidgets', () => {
+    it('should store widgets on the open PDF and highlight rendered pages', async () => {
+      const pdfData = createPDFData(1, '/docs/signed.pdf', { pageCount: 1 });
+      addOpenPDF(1, pdfData);
+      state.activeTabId = 1;
+
+      document.body.innerHTML = `
+        <div id="pdfViewer">
+          <div class="pdf-page-container" data-page-number="0"><img class="pdf-page"/></div>
+        </div>
+      `;
+      window.go.pdf.PDFService.GetSignatureWidgets.mockResolvedValue([widget]);
+
+      await loadSignatureWidgets('/docs/signed.pdf', [{ fieldName: 'Signature1' }]);
+
+      expect(window.go.pdf.PDFService.GetSignatureWidgets).toHaveBeenCalledWith('/docs/signed.pdf', [{ fieldName: 'Signature1' }]);
+      expect(pdfData.signatureWidgets).toEqual([widget]);
+      expect(document.querySelectorAll('.signature-highlight').length).toBe(1);
+    });
+
+    it('should fall back to no widgets when the backend fails', async () => {
+      const pdfData = createPDFData(1, '/docs/signed.pdf', { pageCount: 1 });
+      addOpenPDF(1, pdfData);
+      window.go.pdf.PDFService.GetSignatureWidgets.mockRejectedValue(new Error('failed'));
+
+      await loadSignatureWidgets('/docs/signed.pdf', []);
+
+      expect(pdfData.signatureWidgets).toEqual([]);
+    });
+  });
+});
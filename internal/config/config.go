@@ -29,19 +29,108 @@ type Config struct {
 	AutosaveInterval  int `json:"autosaveInterval"`
 
 	// Certificate settings
-	CertificateStores []string `json:"certificateStores"`
-	TokenLibraries    []string `json:"tokenLibraries"`
+	CertificateStores        []string `json:"certificateStores"`
+	TokenLibraries           []string `json:"tokenLibraries"`
+	CertificateSourceTimeout int      `json:"certificateSourceTimeout"` // seconds per source
+
+	// RevocationPolicy is the pre-signing revocation check: "off", "soft-fail" or "hard-fail"
+	RevocationPolicy string `json:"revocationPolicy"`
+
+	// ExpiryWarningDays is how many days before expiry a certificate is reported as expiring
+	ExpiryWarningDays int `json:"expiryWarningDays"`
+
+	// CertificatePreferences tunes automatic signing certificate selection
+	CertificatePreferences CertificatePreferences `json:"certificatePreferences"`
+
+	// AlgorithmPolicy sets the digest algorithms and key sizes accepted when signing and verifying
+	AlgorithmPolicy AlgorithmPolicy `json:"algorithmPolicy"`
+
+	// TimestampAuthority is the RFC 3161 time-stamping service used for document timestamps
+	TimestampAuthority TimestampAuthority `json:"timestampAuthority"`
+
+	// TrustAnchors are certificates trusted as roots during verification in addition to the system roots
+	TrustAnchors []TrustAnchor `json:"trustAnchors,omitempty"`
+
+	// CertificateStoreOptions holds per-store scan settings keyed by store path
+	CertificateStoreOptions map[string]StoreScanOptions `json:"certificateStoreOptions,omitempty"`
 
 	// Advanced settings
 	DebugMode     bool `json:"debugMode"`
 	HardwareAccel bool `json:"hardwareAccel"`
 }
 
+// StoreScanOptions controls how a certificate store directory is scanned.
+type StoreScanOptions struct {
+	Recursive bool     `json:"recursive"`
+	MaxDepth  int      `json:"maxDepth,omitempty"` // 0 = default depth limit
+	Include   []string `json:"include,omitempty"`  // glob patterns files must match
+	Exclude   []string `json:"exclude,omitempty"`  // glob patterns for files and directories to skip
+}
+
+// CertificatePreferences are per-user rules for automatic signing certificate selection.
+type CertificatePreferences struct {
+	PreferredSources     []string `json:"preferredSources,omitempty"`     // sources in order of preference, e.g. "pkcs11"
+	PreferredIssuers     []string `json:"preferredIssuers,omitempty"`     // issuer name substrings in order of preference
+	ExcludedCertificates []string `json:"excludedCertificates,omitempty"` // fingerprints never selected automatically
+	RequireQualified     bool     `json:"requireQualified"`               // only select qualified certificates
+}
+
+// AlgorithmPolicy lists the digest algorithms and key sizes accepted for signatures. Digests are
+// named as in verification results, e.g. "SHA-1". Signing refuses forbidden and deprecated
+// digests and keys below the minimum sizes; verification reports forbidden digests and small
+// keys as errors and deprecated digests as warnings.
+type AlgorithmPolicy struct {
+	ForbiddenDigests  []string `json:"forbiddenDigests"`
+	DeprecatedDigests []string `json:"deprecatedDigests"`
+	MinRSAKeySize     int      `json:"minRSAKeySize"` // bits
+	MinECKeySize      int      `json:"minECKeySize"`  // bits
+}
+
+// DefaultAlgorithmPolicy returns the policy based on ETSI TS 119 312: MD5 is broken, SHA-1 is no
+// longer suitable for signatures, and RSA and elliptic curve keys need 2048 and 256 bits.
+func DefaultAlgorithmPolicy() AlgorithmPolicy {
+	return AlgorithmPolicy{
+		ForbiddenDigests:  []string{"MD5"},
+		DeprecatedDigests: []string{"SHA-1"},
+		MinRSAKeySize:     2048,
+		MinECKeySize:      256,
+	}
+}
+
+// TimestampAuthority is an RFC 3161 time-stamping service. Username and Password are sent as
+// HTTP basic authentication when both are set.
+type TimestampAuthority struct {
+	URL      string `json:"url"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// Trust anchor purposes
+const (
+	TrustPurposeAll          = "all"
+	TrustPurposeSigning      = "signing"
+	TrustPurposeTimestamping = "timestamping"
+)
+
+// TrustAnchor is a PEM/DER certificate bundle or a directory of certificates trusted as roots.
+type TrustAnchor struct {
+	Path    string `json:"path"`
+	Purpose string `json:"purpose"` // "all", "signing" or "timestamping"
+}
+
+// AppliesTo reports whether the anchor is trusted for purpose.
+func (a TrustAnchor) AppliesTo(purpose string) bool {
+	return a.Purpose == "" || a.Purpose == TrustPurposeAll || a.Purpose == purpose
+}
+
 // Service provides thread-safe access to application configuration.
 type Service struct {
 	mu         sync.RWMutex
 	config     *Config
 	configPath string
+
+	listenersMu sync.RWMutex
+	listeners   []func(*Config)
 }
 
 // NewService creates a config service using the default config directory.
@@ -99,18 +188,22 @@ func NewServiceWithDir(configDir string) (*Service, error) {
 // getDefaultConfig returns default configuration
 func getDefaultConfig() *Config {
 	return &Config{
-		Theme:             "dark",
-		AccentColor:       "#007acc",
-		DefaultZoom:       100,
-		ShowLeftSidebar:   true,
-		ShowRightSidebar:  false,
-		DefaultViewMode:   "scroll",
-		RecentFilesLength: 5,
-		AutosaveInterval:  0,
-		CertificateStores: []string{},
-		TokenLibraries:    []string{},
-		DebugMode:         false,
-		HardwareAccel:     true,
+		Theme:                    "dark",
+		AccentColor:              "#007acc",
+		DefaultZoom:              100,
+		ShowLeftSidebar:          true,
+		ShowRightSidebar:         false,
+		DefaultViewMode:          "scroll",
+		RecentFilesLength:        5,
+		AutosaveInterval:         0,
+		CertificateStores:        []string{},
+		TokenLibraries:           []string{},
+		CertificateSourceTimeout: 10,
+		RevocationPolicy:         "off",
+		ExpiryWarningDays:        30,
+		AlgorithmPolicy:          DefaultAlgorithmPolicy(),
+		DebugMode:                false,
+		HardwareAccel:            true,
 	}
 }
 
@@ -170,25 +263,76 @@ func (s *Service) Get() *Config {
 	configCopy := *s.config
 	configCopy.CertificateStores = append([]string(nil), s.config.CertificateStores...)
 	configCopy.TokenLibraries = append([]string(nil), s.config.TokenLibraries...)
+	configCopy.CertificatePreferences.PreferredSources = append([]string(nil), s.config.CertificatePreferences.PreferredSources...)
+	configCopy.CertificatePreferences.PreferredIssuers = append([]string(nil), s.config.CertificatePreferences.PreferredIssuers...)
+	configCopy.CertificatePreferences.ExcludedCertificates = append([]string(nil), s.config.CertificatePreferences.ExcludedCertificates...)
+	configCopy.AlgorithmPolicy.ForbiddenDigests = append([]string(nil), s.config.AlgorithmPolicy.ForbiddenDigests...)
+	configCopy.AlgorithmPolicy.DeprecatedDigests = append([]string(nil), s.config.AlgorithmPolicy.DeprecatedDigests...)
+	configCopy.TrustAnchors = append([]TrustAnchor(nil), s.config.TrustAnchors...)
+	if s.config.CertificateStoreOptions != nil {
+		configCopy.CertificateStoreOptions = make(map[string]StoreScanOptions, len(s.config.CertificateStoreOptions))
+		for path, opts := range s.config.CertificateStoreOptions {
+			opts.Include = append([]string(nil), opts.Include...)
+			opts.Exclude = append([]string(nil), opts.Exclude...)
+			configCopy.CertificateStoreOptions[path] = opts
+		}
+	}
 	return &configCopy
 }
 
 // Update replaces the configuration and saves it to disk.
 func (s *Service) Update(config *Config) error {
 	s.mu.Lock()
-	defer s.mu.Unlock()
 
 	s.config = config
-	return s.saveUnlocked()
+	if err := s.saveUnlocked(); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	s.mu.Unlock()
+
+	s.notifyChange()
+	return nil
 }
 
 // Reset restores default settings and saves to disk.
 func (s *Service) Reset() error {
 	s.mu.Lock()
-	defer s.mu.Unlock()
 
 	s.config = getDefaultConfig()
-	return s.saveUnlocked()
+	if err := s.saveUnlocked(); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	s.mu.Unlock()
+
+	s.notifyChange()
+	return nil
+}
+
+// OnChange registers a callback invoked with a copy of the configuration
+// after every successful Update or Reset.
+func (s *Service) OnChange(fn func(*Config)) {
+	s.listenersMu.Lock()
+	defer s.listenersMu.Unlock()
+
+	s.listeners = append(s.listeners, fn)
+}
+
+// notifyChange calls the registered change listeners outside of the config lock
+func (s *Service) notifyChange() {
+	s.listenersMu.RLock()
+	listeners := append([]func(*Config){}, s.listeners...)
+	s.listenersMu.RUnlock()
+
+	if len(listeners) == 0 {
+		return
+	}
+
+	cfg := s.Get()
+	for _, fn := range listeners {
+		fn(cfg)
+	}
 }
 
 // getCertificatesDefaults returns default certificate stores and token libraries paths
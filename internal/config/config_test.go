@@ -516,3 +516,61 @@ func TestSlicesCopiedInGet(t *testing.T) {
 		t.Error("TokenLibraries slice not properly copied")
 	}
 }
+
+// TestOnChange tests that listeners are notified after Update and Reset
+func TestOnChange(t *testing.T) {
+	tmpDir := t.TempDir()
+	service, _ := NewServiceWithDir(tmpDir)
+
+	var notified []string
+	service.OnChange(func(cfg *Config) {
+		notified = append(notified, cfg.Theme)
+	})
+
+	cfg := service.Get()
+	cfg.Theme = "light"
+	if err := service.Update(cfg); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	if err := service.Reset(); err != nil {
+		t.Fatalf("Reset failed: %v", err)
+	}
+
+	if len(notified) != 2 {
+		t.Fatalf("Expected 2 notifications, got %d", len(notified))
+	}
+	if notified[0] != "light" {
+		t.Errorf("Expected first notification with theme 'light', got '%s'", notified[0])
+	}
+	if notified[1] != "dark" {
+		t.Errorf("Expected second notification with default theme, got '%s'", notified[1])
+	}
+}
+
+// TestStoreOptionsCopiedInGet tests that per-store scan options are deep copied
+func TestStoreOptionsCopiedInGet(t *testing.T) {
+	tmpDir := t.TempDir()
+	service, _ := NewServiceWithDir(tmpDir)
+
+	cfg := service.Get()
+	cfg.CertificateStoreOptions = map[string]StoreScanOptions{
+		"/certs": {Recursive: true, Include: []string{"*.p12"}},
+	}
+	if err := service.Update(cfg); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	cfg1 := service.Get()
+	opts := cfg1.CertificateStoreOptions["/certs"]
+	opts.Include[0] = "*.pem"
+	cfg1.CertificateStoreOptions["/other"] = StoreScanOptions{}
+
+	cfg2 := service.Get()
+	if _, ok := cfg2.CertificateStoreOptions["/other"]; ok {
+		t.Error("CertificateStoreOptions map not properly copied")
+	}
+	if got := cfg2.CertificateStoreOptions["/certs"].Include[0]; got != "*.p12" {
+		t.Errorf("Include patterns not properly copied, got %s", got)
+	}
+}
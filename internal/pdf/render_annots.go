@@ -182,11 +182,8 @@ void free_render_result(RenderResult *result) {
 import "C"
 
 import (
-	"bytes"
-	"encoding/base64"
 	"fmt"
 	"image"
-	"image/png"
 	"unsafe"
 )
 
@@ -202,7 +199,7 @@ func RestoreMuPDFWarnings() {
 
 // renderPageWithAnnotations renders a PDF page including all annotations and signature widgets
 // This uses a completely separate MuPDF context for safety
-func (s *PDFService) renderPageWithAnnotations(pageNum int, dpi float64) (*PageInfo, error) {
+func (s *PDFService) renderPageWithAnnotations(pageNum int, dpi float64) (image.Image, error) {
 	s.mu.RLock()
 	filePath := s.currentFile
 	totalPages := s.pageCount
@@ -234,24 +231,7 @@ func (s *PDFService) renderPageWithAnnotations(pageNum int, dpi float64) (*PageI
 	}
 
 	// Convert C data to Go image
-	img := cPixmapToImage(cResult)
-
-	// Encode to PNG
-	var buf bytes.Buffer
-	if err := png.Encode(&buf, img); err != nil {
-		return nil, fmt.Errorf("failed to encode PNG: %w", err)
-	}
-
-	// Convert to base64
-	base64Data := base64.StdEncoding.EncodeToString(buf.Bytes())
-
-	bounds := img.Bounds()
-	return &PageInfo{
-		PageNumber: pageNum,
-		Width:      bounds.Dx(),
-		Height:     bounds.Dy(),
-		ImageData:  "data:image/png;base64," + base64Data,
-	}, nil
+	return cPixmapToImage(cResult), nil
 }
 
 // cPixmapToImage converts C pixmap data to Go image
@@ -292,7 +272,7 @@ func cPixmapToImage(cResult *C.RenderResult) image.Image {
 }
 
 // renderPageStandard is a fallback that uses the standard go-fitz rendering
-func (s *PDFService) renderPageStandard(pageNum int, dpi float64) (*PageInfo, error) {
+func (s *PDFService) renderPageStandard(pageNum int, dpi float64) (image.Image, error) {
 	s.mu.RLock()
 	doc := s.doc
 	s.mu.RUnlock()
@@ -301,19 +281,5 @@ func (s *PDFService) renderPageStandard(pageNum int, dpi float64) (*PageInfo, er
 	if err != nil {
 		return nil, fmt.Errorf("failed to render page: %w", err)
 	}
-
-	var buf bytes.Buffer
-	if err := png.Encode(&buf, img); err != nil {
-		return nil, fmt.Errorf("failed to encode PNG: %w", err)
-	}
-
-	base64Data := base64.StdEncoding.EncodeToString(buf.Bytes())
-
-	bounds := img.Bounds()
-	return &PageInfo{
-		PageNumber: pageNum,
-		Width:      bounds.Dx(),
-		Height:     bounds.Dy(),
-		ImageData:  "data:image/png;base64," + base64Data,
-	}, nil
+	return img, nil
 }
@@ -34,6 +34,9 @@ type PageInfo struct {
 	Width      int    `json:"width"`
 	Height     int    `json:"height"`
 	ImageData  string `json:"imageData"` // Base64 encoded PNG
+	// SignatureWidgets lists the signature widgets on the page when verification results were
+	// passed to RenderPageWithOptions
+	SignatureWidgets []SignatureWidget `json:"signatureWidgets,omitempty"`
 }
 
 // PDFMetadata contains metadata extracted from a PDF document.
@@ -183,6 +186,56 @@ const (
 
 // RenderPage renders the specified page at the given DPI and returns base64-encoded PNG data.
 func (s *PDFService) RenderPage(pageNum int, dpi float64) (*PageInfo, error) {
+	return s.RenderPageWithOptions(pageNum, dpi, RenderOptions{})
+}
+
+// RenderPageWithOptions renders a page like RenderPage. Given verification results, it also
+// returns the signature widgets on the page with their status and can draw them into the image.
+func (s *PDFService) RenderPageWithOptions(pageNum int, dpi float64, opts RenderOptions) (*PageInfo, error) {
+	img, err := s.renderImage(pageNum, dpi)
+	if err != nil {
+		return nil, err
+	}
+
+	var widgets []SignatureWidget
+	if opts.Signatures != nil {
+		s.mu.RLock()
+		filePath := s.currentFile
+		s.mu.RUnlock()
+
+		all, err := s.GetSignatureWidgets(filePath, opts.Signatures)
+		if err != nil {
+			return nil, err
+		}
+		widgets = []SignatureWidget{}
+		for _, w := range all {
+			if w.Page == pageNum {
+				widgets = append(widgets, w)
+			}
+		}
+		if opts.SignatureOverlay && len(widgets) > 0 {
+			img = drawSignatureOverlay(img, widgets)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode PNG: %w", err)
+	}
+
+	bounds := img.Bounds()
+	return &PageInfo{
+		PageNumber:       pageNum,
+		Width:            bounds.Dx(),
+		Height:           bounds.Dy(),
+		ImageData:        "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()),
+		SignatureWidgets: widgets,
+	}, nil
+}
+
+// renderImage renders a page with its annotations, falling back to plain rendering for the
+// rest of the document once MuPDF fails to render annotations.
+func (s *PDFService) renderImage(pageNum int, dpi float64) (image.Image, error) {
 	if dpi < MinDPI || dpi > MaxDPI {
 		return nil, fmt.Errorf("DPI %.2f out of valid range [%.2f, %.2f]", dpi, MinDPI, MaxDPI)
 	}
@@ -202,9 +255,9 @@ func (s *PDFService) RenderPage(pageNum int, dpi float64) (*PageInfo, error) {
 	}
 
 	if !annotFailed {
-		result, err := s.renderPageWithAnnotations(pageNum, dpi)
+		img, err := s.renderPageWithAnnotations(pageNum, dpi)
 		if err == nil {
-			return result, nil
+			return img, nil
 		}
 		s.mu.Lock()
 		s.annotationRenderingFailed = true
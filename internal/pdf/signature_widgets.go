@@ -0,0 +1,310 @@
+package pdf
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/Matbe34/lankir/internal/signature/types"
+	pdfreader "github.com/digitorus/pdf"
+)
+
+// Statuses of a signature widget
+const (
+	SignatureStatusValid   = "valid"
+	SignatureStatusInvalid = "invalid"
+	SignatureStatusUnknown = "unknown"
+)
+
+// maxFieldDepth bounds the walk up the field hierarchy of a widget
+const maxFieldDepth = 32
+
+// RenderOptions selects what RenderPageWithOptions adds to a rendered page.
+type RenderOptions struct {
+	// Signatures are the results of VerifySignatures for the open document; when set, the
+	// signature widgets on the page are returned with their status
+	Signatures []types.SignatureInfo `json:"signatures"`
+	// SignatureOverlay draws a colored frame and a status badge over each signature widget
+	SignatureOverlay bool `json:"signatureOverlay"`
+}
+
+// SignatureWidget is the visible widget of a signed signature field with the status of its
+// signature. The rectangle is in PDF points from the top-left corner of the page as rendered,
+// rotation included, so it scales with PageWidth and PageHeight to any DPI or zoom level.
+type SignatureWidget struct {
+	Page       int     `json:"page"` // 0-based, as in RenderPage
+	FieldName  string  `json:"fieldName"`
+	X          float64 `json:"x"`
+	Y          float64 `json:"y"`
+	Width      float64 `json:"width"`
+	Height     float64 `json:"height"`
+	PageWidth  float64 `json:"pageWidth"`
+	PageHeight float64 `json:"pageHeight"`
+	Status     string  `json:"status"` // "valid", "invalid" or "unknown"
+	SignerName string  `json:"signerName,omitempty"`
+	Message    string  `json:"message,omitempty"`
+}
+
+// GetSignatureWidgets returns the widgets of the signed signature fields of a PDF, matched by
+// field name to the results of VerifySignatures. Invisible signatures have no widget.
+func (s *PDFService) GetSignatureWidgets(filePath string, signatures []types.SignatureInfo) ([]SignatureWidget, error) {
+	widgets, err := readSignatureWidgets(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	byField := make(map[string]types.SignatureInfo, len(signatures))
+	for _, sig := range signatures {
+		if sig.FieldName != "" {
+			byField[sig.FieldName] = sig
+		}
+	}
+
+	for i := range widgets {
+		sig, ok := byField[widgets[i].FieldName]
+		if !ok {
+			widgets[i].Status = SignatureStatusUnknown
+			widgets[i].Message = "Signature was not verified"
+			continue
+		}
+		widgets[i].SignerName = sig.SignerName
+		widgets[i].Status, widgets[i].Message = signatureWidgetStatus(sig)
+	}
+
+	return widgets, nil
+}
+
+// signatureWidgetStatus condenses a verification result: invalid when the signature is broken or
+// the document was changed in a disallowed way, unknown when it is intact but the certificate
+// or the later changes could not be confirmed.
+func signatureWidgetStatus(sig types.SignatureInfo) (string, string) {
+	switch {
+	case !sig.IsValid:
+		return SignatureStatusInvalid, sig.ValidationMessage
+	case sig.ModificationStatus == "disallowed":
+		return SignatureStatusInvalid, "Disallowed changes after signing"
+	case !sig.CertificateValid:
+		return SignatureStatusUnknown, sig.CertificateValidationMessage
+	case sig.ModificationStatus == "unknown":
+		return SignatureStatusUnknown, "Changes after signing could not be analysed"
+	}
+	return SignatureStatusValid, sig.ValidationMessage
+}
+
+// recoverPDFPanic reports a panic of the PDF reader on malformed input as an error. Defer it
+// directly from the function that reads the document.
+func recoverPDFPanic(err *error, action string) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("failed to %s (%v)", action, r)
+	}
+}
+
+// readSignatureWidgets lists the widgets of signed signature fields with a visible area.
+func readSignatureWidgets(filePath string) (widgets []SignatureWidget, err error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open PDF: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	defer recoverPDFPanic(&err, "read signature widgets")
+
+	rdr, err := pdfreader.NewReader(f, info.Size())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PDF: %w", err)
+	}
+
+	widgets = []SignatureWidget{}
+	for num := 1; num <= rdr.NumPage(); num++ {
+		page := rdr.Page(num).V
+		if page.IsNull() {
+			continue
+		}
+
+		box := inheritedValue(page, "CropBox")
+		if box.Len() != 4 {
+			box = inheritedValue(page, "MediaBox")
+		}
+		bx0, by0, bx1, by1 := 0.0, 0.0, 612.0, 792.0
+		if box.Len() == 4 {
+			bx0, by0, bx1, by1 = normalizeRect(box)
+		}
+		rotate := ((inheritedValue(page, "Rotate").Int64() % 360) + 360) % 360
+
+		annots := page.Key("Annots")
+		for i := 0; i < annots.Len(); i++ {
+			annot := annots.Index(i)
+			if annot.Key("Subtype").Name() != "Widget" || inheritedValue(annot, "FT").Name() != "Sig" ||
+				inheritedValue(annot, "V").IsNull() || annot.Key("Rect").Len() != 4 {
+				continue
+			}
+
+			x0, y0, x1, y1 := normalizeRect(annot.Key("Rect"))
+			if x1-x0 <= 0 || y1-y0 <= 0 {
+				continue
+			}
+
+			widget := SignatureWidget{
+				Page:       num - 1,
+				FieldName:  fieldName(annot),
+				X:          x0 - bx0,
+				Y:          by1 - y1,
+				Width:      x1 - x0,
+				Height:     y1 - y0,
+				PageWidth:  bx1 - bx0,
+				PageHeight: by1 - by0,
+			}
+			widgets = append(widgets, rotateWidget(widget, rotate))
+		}
+	}
+
+	return widgets, nil
+}
+
+// rotateWidget maps a widget rectangle onto a page displayed with a /Rotate angle, clockwise.
+func rotateWidget(w SignatureWidget, rotate int64) SignatureWidget {
+	switch rotate {
+	case 90:
+		w.X, w.Y = w.PageHeight-w.Y-w.Height, w.X
+	case 180:
+		w.X, w.Y = w.PageWidth-w.X-w.Width, w.PageHeight-w.Y-w.Height
+	case 270:
+		w.X, w.Y = w.Y, w.PageWidth-w.X-w.Width
+	default:
+		return w
+	}
+	if rotate != 180 {
+		w.Width, w.Height = w.Height, w.Width
+		w.PageWidth, w.PageHeight = w.PageHeight, w.PageWidth
+	}
+	return w
+}
+
+// inheritedValue looks a key up in a dictionary and its parents.
+func inheritedValue(v pdfreader.Value, key string) pdfreader.Value {
+	for depth := 0; depth < maxFieldDepth && !v.IsNull(); depth++ {
+		if value := v.Key(key); !value.IsNull() {
+			return value
+		}
+		v = v.Key("Parent")
+	}
+	return pdfreader.Value{}
+}
+
+// fieldName returns the fully qualified name of the field a widget belongs to.
+func fieldName(widget pdfreader.Value) string {
+	var parts []string
+	for depth, v := 0, widget; depth < maxFieldDepth && !v.IsNull(); depth, v = depth+1, v.Key("Parent") {
+		if t := v.Key("T").Text(); t != "" {
+			parts = append([]string{t}, parts...)
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// normalizeRect returns the lower-left and upper-right corners of a rectangle array.
+func normalizeRect(rect pdfreader.Value) (x0, y0, x1, y1 float64) {
+	ax, ay := rect.Index(0).Float64(), rect.Index(1).Float64()
+	bx, by := rect.Index(2).Float64(), rect.Index(3).Float64()
+	return math.Min(ax, bx), math.Min(ay, by), math.Max(ax, bx), math.Max(ay, by)
+}
+
+// signatureStatusColors match the status colors of the signature panel in the frontend
+var signatureStatusColors = map[string]color.NRGBA{
+	SignatureStatusValid:   {R: 16, G: 185, B: 129, A: 255},
+	SignatureStatusInvalid: {R: 239, G: 68, B: 68, A: 255},
+	SignatureStatusUnknown: {R: 245, G: 158, B: 11, A: 255},
+}
+
+// signatureBadgeStrokes are the glyphs drawn in the status badges: a check mark, a cross and an
+// exclamation mark, as line segments in badge-relative coordinates
+var signatureBadgeStrokes = map[string][][4]float64{
+	SignatureStatusValid:   {{0.26, 0.52, 0.43, 0.69}, {0.43, 0.69, 0.74, 0.33}},
+	SignatureStatusInvalid: {{0.32, 0.32, 0.68, 0.68}, {0.68, 0.32, 0.32, 0.68}},
+	SignatureStatusUnknown: {{0.5, 0.24, 0.5, 0.58}, {0.5, 0.74, 0.5, 0.76}},
+}
+
+// drawSignatureOverlay frames every widget in the color of its status, tints its area and puts
+// a status badge in its top-right corner.
+func drawSignatureOverlay(src image.Image, widgets []SignatureWidget) image.Image {
+	bounds := src.Bounds()
+	img := image.NewRGBA(bounds)
+	draw.Draw(img, bounds, src, bounds.Min, draw.Src)
+
+	for _, w := range widgets {
+		if w.PageWidth <= 0 {
+			continue
+		}
+		scale := float64(bounds.Dx()) / w.PageWidth
+		rect := image.Rect(
+			int(w.X*scale), int(w.Y*scale),
+			int(math.Ceil((w.X+w.Width)*scale)), int(math.Ceil((w.Y+w.Height)*scale)),
+		).Add(bounds.Min).Intersect(bounds)
+		if rect.Empty() {
+			continue
+		}
+
+		c, ok := signatureStatusColors[w.Status]
+		if !ok {
+			c = signatureStatusColors[SignatureStatusUnknown]
+		}
+		tint := c
+		tint.A = 48
+		draw.Draw(img, rect, image.NewUniform(tint), image.Point{}, draw.Over)
+
+		border := max(2, int(1.5*scale))
+		solid := image.NewUniform(c)
+		for _, edge := range []image.Rectangle{
+			image.Rect(rect.Min.X, rect.Min.Y, rect.Max.X, rect.Min.Y+border),
+			image.Rect(rect.Min.X, rect.Max.Y-border, rect.Max.X, rect.Max.Y),
+			image.Rect(rect.Min.X, rect.Min.Y, rect.Min.X+border, rect.Max.Y),
+			image.Rect(rect.Max.X-border, rect.Min.Y, rect.Max.X, rect.Max.Y),
+		} {
+			draw.Draw(img, edge.Intersect(rect), solid, image.Point{}, draw.Src)
+		}
+
+		size := min(int(18*scale), rect.Dx(), rect.Dy())
+		if size < 8 {
+			continue
+		}
+		badge := image.Rect(rect.Max.X-size, rect.Min.Y, rect.Max.X, rect.Min.Y+size)
+		drawBadge(img, badge, c, signatureBadgeStrokes[w.Status])
+	}
+
+	return img
+}
+
+// drawBadge fills a disc inscribed in r and draws the glyph strokes over it in white.
+func drawBadge(img *image.RGBA, r image.Rectangle, c color.NRGBA, strokes [][4]float64) {
+	size := float64(r.Dx())
+	cx, cy, radius := float64(r.Min.X)+size/2, float64(r.Min.Y)+size/2, size/2
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if math.Hypot(float64(x)+0.5-cx, float64(y)+0.5-cy) <= radius {
+				img.Set(x, y, c)
+			}
+		}
+	}
+
+	width := math.Max(1, size/8)
+	for _, s := range strokes {
+		x0, y0 := float64(r.Min.X)+s[0]*size, float64(r.Min.Y)+s[1]*size
+		x1, y1 := float64(r.Min.X)+s[2]*size, float64(r.Min.Y)+s[3]*size
+		steps := int(math.Max(math.Abs(x1-x0), math.Abs(y1-y0))) + 1
+		for i := 0; i <= steps; i++ {
+			t := float64(i) / float64(steps)
+			px, py := x0+(x1-x0)*t, y0+(y1-y0)*t
+			dot := image.Rect(int(px-width/2), int(py-width/2), int(math.Ceil(px+width/2)), int(math.Ceil(py+width/2)))
+			draw.Draw(img, dot.Intersect(r), image.White, image.Point{}, draw.Src)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package pdf
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Matbe34/lankir/internal/signature/types"
+)
+
+// createSignedFieldPDF writes a one-page PDF with a signed signature field whose widget spans
+// [100 600 300 700], a second widget without a value, and the given extra page entries
+func createSignedFieldPDF(t *testing.T, path, pageEntries string) {
+	t.Helper()
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R /AcroForm << /Fields [4 0 R 6 0 R] /SigFlags 3 >> >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Annots [4 0 R 6 0 R] " + pageEntries + ">>",
+		"<< /Type /Annot /Subtype /Widget /FT /Sig /T (Signature1) /Rect [300 700 100 600] /P 3 0 R /V 5 0 R >>",
+		"<< /Type /Sig /Filter /Adobe.PPKLite /SubFilter /adbe.pkcs7.detached >>",
+		"<< /Type /Annot /Subtype /Widget /FT /Sig /T (Empty) /Rect [100 100 200 150] /P 3 0 R >>",
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.7\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write test PDF: %v", err)
+	}
+}
+
+// TestGetSignatureWidgets tests that signed widgets are located, rotated and matched to results
+func TestGetSignatureWidgets(t *testing.T) {
+	tests := []struct {
+		name        string
+		pageEntries string
+		signatures  []types.SignatureInfo
+		expected    SignatureWidget
+	}{
+		{
+			name:       "valid",
+			signatures: []types.SignatureInfo{{FieldName: "Signature1", SignerName: "Jane", IsValid: true, CertificateValid: true, ModificationStatus: "none"}},
+			expected:   SignatureWidget{X: 100, Y: 92, Width: 200, Height: 100, PageWidth: 612, PageHeight: 792, Status: SignatureStatusValid, SignerName: "Jane"},
+		},
+		{
+			name:       "untrusted",
+			signatures: []types.SignatureInfo{{FieldName: "Signature1", IsValid: true, ModificationStatus: "none"}},
+			expected:   SignatureWidget{X: 100, Y: 92, Width: 200, Height: 100, PageWidth: 612, PageHeight: 792, Status: SignatureStatusUnknown},
+		},
+		{
+			name:       "disallowed changes",
+			signatures: []types.SignatureInfo{{FieldName: "Signature1", IsValid: true, CertificateValid: true, ModificationStatus: "disallowed"}},
+			expected:   SignatureWidget{X: 100, Y: 92, Width: 200, Height: 100, PageWidth: 612, PageHeight: 792, Status: SignatureStatusInvalid},
+		},
+		{
+			name:     "not verified",
+			expected: SignatureWidget{X: 100, Y: 92, Width: 200, Height: 100, PageWidth: 612, PageHeight: 792, Status: SignatureStatusUnknown},
+		},
+		{
+			name:        "rotated page",
+			pageEntries: "/Rotate 90 ",
+			signatures:  []types.SignatureInfo{{FieldName: "Signature1", IsValid: false}},
+			expected:    SignatureWidget{X: 600, Y: 100, Width: 100, Height: 200, PageWidth: 792, PageHeight: 612, Status: SignatureStatusInvalid},
+		},
+	}
+
+	service := NewPDFService(nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "signed.pdf")
+			createSignedFieldPDF(t, path, tt.pageEntries)
+
+			widgets, err := service.GetSignatureWidgets(path, tt.signatures)
+			if err != nil {
+				t.Fatalf("GetSignatureWidgets failed: %v", err)
+			}
+			if len(widgets) != 1 {
+				t.Fatalf("Expected only the signed widget, got %+v", widgets)
+			}
+
+			got := widgets[0]
+			if got.FieldName != "Signature1" || got.Page != 0 {
+				t.Errorf("Unexpected widget %+v", got)
+			}
+			got.FieldName, got.Message = "", ""
+			if got != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
+
+// TestRenderPageWithOptions_SignatureOverlay tests that widgets are returned and drawn
+func TestRenderPageWithOptions_SignatureOverlay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "signed.pdf")
+	createSignedFieldPDF(t, path, "")
+
+	service := NewPDFService(nil)
+	service.Startup(context.Background())
+	if _, err := service.OpenPDFByPath(path); err != nil {
+		t.Fatalf("Failed to open PDF: %v", err)
+	}
+	defer service.ClosePDF()
+
+	plain, err := service.RenderPage(0, 72)
+	if err != nil {
+		t.Fatalf("RenderPage failed: %v", err)
+	}
+	if plain.SignatureWidgets != nil {
+		t.Errorf("Expected no widgets without verification results, got %+v", plain.SignatureWidgets)
+	}
+
+	signatures := []types.SignatureInfo{{FieldName: "Signature1", IsValid: false}}
+	info, err := service.RenderPageWithOptions(0, 72, RenderOptions{Signatures: signatures, SignatureOverlay: true})
+	if err != nil {
+		t.Fatalf("RenderPageWithOptions failed: %v", err)
+	}
+	if len(info.SignatureWidgets) != 1 || info.SignatureWidgets[0].Status != SignatureStatusInvalid {
+		t.Fatalf("Expected an invalid widget, got %+v", info.SignatureWidgets)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(info.ImageData, "data:image/png;base64,"))
+	if err != nil {
+		t.Fatalf("Failed to decode image data: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to decode PNG: %v", err)
+	}
+
+	// The frame of the widget at 72 DPI starts at (100, 92) and is drawn in the invalid color
+	r, g, b, _ := img.At(101, 150).RGBA()
+	expected := signatureStatusColors[SignatureStatusInvalid]
+	if uint8(r>>8) != expected.R || uint8(g>>8) != expected.G || uint8(b>>8) != expected.B {
+		t.Errorf("Expected the invalid frame color at the widget edge, got %d,%d,%d", r>>8, g>>8, b>>8)
+	}
+}
@@ -15,7 +15,6 @@ import (
 	"sync"
 	"time"
 
-	"github.com/Matbe34/lankir/internal/signature/types"
 	"github.com/digitorus/pdfsign/sign"
 	"golang.org/x/image/font"
 	"golang.org/x/image/font/gofont/goregular"
@@ -36,21 +35,22 @@ const (
 	LocationAPIEndpoint      = "https://ipinfo.io/json"
 )
 
-// CreateSignatureAppearance builds a sign.Appearance from profile settings for PDF signing.
-func CreateSignatureAppearance(profile *SignatureProfile, cert *types.Certificate, signingTime time.Time) *sign.Appearance {
+// CreateSignatureAppearance builds a sign.Appearance from profile settings for PDF signing. The
+// text of a visible signature is built from data, see AppearanceData.
+func CreateSignatureAppearance(profile *SignatureProfile, data *AppearanceData) (*sign.Appearance, error) {
 	appearance := &sign.Appearance{
 		Visible: profile.Visibility == VisibilityVisible,
 	}
 
 	if !appearance.Visible {
-		return appearance
+		return appearance, nil
 	}
 
-	page := profile.Position.Page
-	if page <= 0 {
-		page = 1
+	page, err := signaturePage(profile.Position.Page, data.Document.Pages)
+	if err != nil {
+		return nil, err
 	}
-	appearance.Page = uint32(page)
+	appearance.Page = page
 
 	appearance.LowerLeftX = profile.Position.X
 	appearance.LowerLeftY = profile.Position.Y
@@ -64,36 +64,34 @@ func CreateSignatureAppearance(profile *SignatureProfile, cert *types.Certificat
 		appearance.UpperRightY = appearance.LowerLeftY + DefaultSignatureHeight
 	}
 
-	var textLines []string
-
-	if profile.Appearance.ShowSignerName {
-		signerName := cert.Name
-		if signerName == "" {
-			signerName = cert.Subject
-		}
-		textLines = append(textLines, fmt.Sprintf("Signed by: %s", signerName))
-	}
-
-	if profile.Appearance.ShowSigningTime {
-		timeStr := signingTime.Format("2006-01-02 15:04:05 MST")
-		textLines = append(textLines, fmt.Sprintf("Date: %s", timeStr))
-	}
-
-	if profile.Appearance.ShowLocation {
-		location, err := getLocationString()
-		if err == nil && location != "" {
-			textLines = append(textLines, fmt.Sprintf("Location: %s", location))
-		}
-	}
-
-	if profile.Appearance.CustomText != "" {
-		textLines = append(textLines, profile.Appearance.CustomText)
+	textLines, err := appearanceTextLines(profile, data)
+	if err != nil {
+		return nil, err
 	}
 
 	appearance.Image = generateSignatureImage(textLines, profile)
 	appearance.ImageAsWatermark = false
 
-	return appearance
+	return appearance, nil
+}
+
+// signaturePage resolves the page of a visible signature in a document of the given page count:
+// 0 is the last page and -1 the first. When the page count is unknown the last page falls back
+// to the first.
+func signaturePage(page, pageCount int) (uint32, error) {
+	switch {
+	case page == -1:
+		return 1, nil
+	case page == 0 && pageCount > 0:
+		return uint32(pageCount), nil
+	case page == 0:
+		return 1, nil
+	case page < -1:
+		return 0, fmt.Errorf("invalid page: %d", page)
+	case pageCount > 0 && page > pageCount:
+		return 0, fmt.Errorf("page %d is out of range, the document has %d pages", page, pageCount)
+	}
+	return uint32(page), nil
 }
 
 // generateSignatureImage creates an image for signature appearance
@@ -179,6 +177,9 @@ func (g *SignatureImageGenerator) Generate() []byte {
 	if err != nil {
 		return g.encodeImage(img)
 	}
+	if custom := decodeFont(g.profile.Appearance.FontPath); custom != nil {
+		ttf = custom
+	}
 
 	g.drawContent(img, ttf)
 
@@ -430,6 +431,26 @@ func decodeLogoImage(dataURL string) image.Image {
 	return img
 }
 
+// decodeFont decodes a font from a base64 data URL, or returns nil if it is empty or invalid
+func decodeFont(dataURL string) *opentype.Font {
+	_, encoded, ok := strings.Cut(dataURL, ";base64,")
+	if !ok {
+		return nil
+	}
+
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil
+	}
+
+	ttf, err := opentype.Parse(data)
+	if err != nil {
+		return nil
+	}
+
+	return ttf
+}
+
 // splitIntoWords splits text into words preserving punctuation
 func splitIntoWords(text string) []string {
 	var words []string
@@ -0,0 +1,355 @@
+package signature
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/Matbe34/lankir/internal/signature/types"
+	"github.com/digitorus/pdf"
+)
+
+// DefaultAppearanceDateFormat is the layout of the "Date:" line and of {{date}} without arguments
+const DefaultAppearanceDateFormat = "2006-01-02 15:04:05 MST"
+
+// maxAppearanceTextLength limits the text an appearance template may produce
+const maxAppearanceTextLength = 4096
+
+// AppearanceData is what the text of a visible signature is built from. The custom text of a
+// profile is a Go text/template executed with it, for example
+//
+//	Signed by {{.Signer.CN}} ({{.Signer.O}}) on {{date "02.01.2006" "Europe/Berlin"}}
+//	{{.Document.FileName}}, {{.Document.Pages}} pages
+//
+// The date function formats the signing time with an optional Go layout and IANA time zone.
+type AppearanceData struct {
+	Signer      AppearanceSigner   `json:"signer"`
+	Reason      string             `json:"reason"`
+	Location    string             `json:"location"`
+	Document    AppearanceDocument `json:"document"`
+	SigningTime time.Time          `json:"signingTime"`
+}
+
+// AppearanceSigner holds the signing certificate fields available to appearance templates.
+// Name is the display name used by the "Signed by:" line.
+type AppearanceSigner struct {
+	Name   string `json:"name"`
+	CN     string `json:"cn"`
+	O      string `json:"o"`
+	OU     string `json:"ou"`
+	Email  string `json:"email"`
+	Serial string `json:"serial"`
+	Issuer string `json:"issuer"`
+}
+
+// AppearanceDocument holds the document fields available to appearance templates.
+type AppearanceDocument struct {
+	FileName string `json:"fileName"`
+	Pages    int    `json:"pages"`
+	Title    string `json:"title"`
+}
+
+// sampleAppearanceData returns the data templates are checked and previewed with when no
+// certificate or document is given.
+func sampleAppearanceData(profile *SignatureProfile) *AppearanceData {
+	location := profile.Location
+	if location == "" {
+		location = "Sample City, XX"
+	}
+	return &AppearanceData{
+		Signer: AppearanceSigner{
+			Name:   "Jane Doe",
+			CN:     "Jane Doe",
+			O:      "Example Corp",
+			OU:     "Legal",
+			Email:  "jane.doe@example.com",
+			Serial: "1234567890",
+			Issuer: "Example CA",
+		},
+		Reason:      profile.Reason,
+		Location:    location,
+		Document:    AppearanceDocument{FileName: "document.pdf", Pages: 1, Title: "Sample Document"},
+		SigningTime: time.Now().Local(),
+	}
+}
+
+// newAppearanceData collects the data of a signature: the certificate fields, from x509Cert if
+// given, the reason and location of the profile, and the file name, page count and title of the
+// PDF at pdfPath. Without a location in the profile, the looked-up location is used when a
+// visible profile shows it.
+func newAppearanceData(profile *SignatureProfile, cert *types.Certificate, x509Cert *x509.Certificate, signingTime time.Time, pdfPath string) *AppearanceData {
+	data := &AppearanceData{
+		Reason:      profile.Reason,
+		Location:    profile.Location,
+		SigningTime: signingTime,
+	}
+
+	if x509Cert == nil && cert != nil && len(cert.Raw) > 0 {
+		x509Cert, _ = x509.ParseCertificate(cert.Raw)
+	}
+	if x509Cert != nil {
+		data.Signer = AppearanceSigner{
+			CN:     x509Cert.Subject.CommonName,
+			O:      strings.Join(x509Cert.Subject.Organization, ", "),
+			OU:     strings.Join(x509Cert.Subject.OrganizationalUnit, ", "),
+			Email:  strings.Join(x509Cert.EmailAddresses, ", "),
+			Serial: x509Cert.SerialNumber.String(),
+			Issuer: x509Cert.Issuer.CommonName,
+		}
+		if data.Signer.Issuer == "" {
+			data.Signer.Issuer = x509Cert.Issuer.String()
+		}
+	}
+	if cert != nil {
+		data.Signer.Name = cert.Name
+		if data.Signer.Name == "" {
+			data.Signer.Name = cert.Subject
+		}
+		if data.Signer.CN == "" {
+			data.Signer.CN = cert.Name
+		}
+		if data.Signer.Serial == "" {
+			data.Signer.Serial = cert.SerialNumber
+		}
+		if data.Signer.Issuer == "" {
+			data.Signer.Issuer = cert.Issuer
+		}
+	}
+	if data.Signer.Name == "" {
+		data.Signer.Name = data.Signer.CN
+	}
+
+	if data.Location == "" && profile.Visibility == VisibilityVisible && profile.Appearance.ShowLocation {
+		if location, err := getLocationString(); err == nil {
+			data.Location = location
+		}
+	}
+
+	if pdfPath != "" {
+		data.Document = readAppearanceDocument(pdfPath)
+	}
+
+	return data
+}
+
+// readAppearanceDocument reads the page count and title of a PDF. Fields that cannot be read are
+// left empty.
+func readAppearanceDocument(pdfPath string) AppearanceDocument {
+	doc := AppearanceDocument{FileName: filepath.Base(pdfPath)}
+	if pages, title, err := readDocumentInfo(pdfPath); err == nil {
+		doc.Pages, doc.Title = pages, title
+	}
+	return doc
+}
+
+// readDocumentInfo returns the page count and title of a PDF.
+func readDocumentInfo(pdfPath string) (pages int, title string, err error) {
+	defer recoverPDFPanic(&err, "read document")
+
+	content, err := os.ReadFile(pdfPath)
+	if err != nil {
+		return 0, "", err
+	}
+	rdr, err := pdf.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return 0, "", err
+	}
+	return rdr.NumPage(), rdr.Trailer().Key("Info").Key("Title").Text(), nil
+}
+
+// appearanceTextLines returns the text lines of a visible signature: the signer, date and
+// location lines the profile shows, followed by its rendered custom text.
+func appearanceTextLines(profile *SignatureProfile, data *AppearanceData) ([]string, error) {
+	var lines []string
+
+	if profile.Appearance.ShowSignerName {
+		lines = append(lines, fmt.Sprintf("Signed by: %s", data.Signer.Name))
+	}
+	if profile.Appearance.ShowSigningTime {
+		lines = append(lines, fmt.Sprintf("Date: %s", data.SigningTime.Format(DefaultAppearanceDateFormat)))
+	}
+	if profile.Appearance.ShowLocation && data.Location != "" {
+		lines = append(lines, fmt.Sprintf("Location: %s", data.Location))
+	}
+
+	text, err := renderAppearanceText(profile.Appearance.CustomText, data)
+	if err != nil {
+		return nil, err
+	}
+	if text != "" {
+		lines = append(lines, strings.Split(text, "\n")...)
+	}
+
+	return lines, nil
+}
+
+// renderAppearanceText executes an appearance text template. Text without template actions is
+// returned as is.
+func renderAppearanceText(text string, data *AppearanceData) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+
+	tmpl, err := parseAppearanceTemplate(text)
+	if err != nil {
+		return "", err
+	}
+	tmpl.Funcs(template.FuncMap{"date": appearanceDateFunc(data.SigningTime)})
+
+	out := &limitedBuffer{limit: maxAppearanceTextLength}
+	if err := tmpl.Execute(out, data); err != nil {
+		return "", fmt.Errorf("invalid custom text template: %w", err)
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+// validateAppearanceText checks that a custom text template parses and runs with sample data.
+func validateAppearanceText(profile *SignatureProfile) error {
+	_, err := renderAppearanceText(profile.Appearance.CustomText, sampleAppearanceData(profile))
+	return err
+}
+
+// parseAppearanceTemplate parses an appearance text template. Loops and nested templates are
+// rejected, so executing a template takes time proportional to its length.
+func parseAppearanceTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("customText").
+		Option("missingkey=error").
+		Funcs(template.FuncMap{"date": appearanceDateFunc(time.Time{})}).
+		Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid custom text template: %w", err)
+	}
+	if len(tmpl.Templates()) > 1 {
+		return nil, fmt.Errorf("invalid custom text template: define and block are not supported")
+	}
+	if tmpl.Tree != nil {
+		if err := checkAppearanceTemplateNode(tmpl.Tree.Root); err != nil {
+			return nil, fmt.Errorf("invalid custom text template: %w", err)
+		}
+	}
+	return tmpl, nil
+}
+
+// checkAppearanceTemplateNode rejects range and template actions anywhere below node.
+func checkAppearanceTemplateNode(node parse.Node) error {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			if err := checkAppearanceTemplateNode(child); err != nil {
+				return err
+			}
+		}
+	case *parse.IfNode:
+		return checkAppearanceTemplateBranch(&n.BranchNode)
+	case *parse.WithNode:
+		return checkAppearanceTemplateBranch(&n.BranchNode)
+	case *parse.RangeNode:
+		return fmt.Errorf("range is not supported")
+	case *parse.TemplateNode:
+		return fmt.Errorf("template and block are not supported")
+	}
+	return nil
+}
+
+func checkAppearanceTemplateBranch(branch *parse.BranchNode) error {
+	if err := checkAppearanceTemplateNode(branch.List); err != nil {
+		return err
+	}
+	return checkAppearanceTemplateNode(branch.ElseList)
+}
+
+// appearanceDateFunc returns the date template function: the signing time formatted with an
+// optional Go layout, in an optional IANA time zone.
+func appearanceDateFunc(signingTime time.Time) func(args ...string) (string, error) {
+	return func(args ...string) (string, error) {
+		layout := DefaultAppearanceDateFormat
+		t := signingTime
+		switch len(args) {
+		case 2:
+			loc, err := time.LoadLocation(args[1])
+			if err != nil {
+				return "", fmt.Errorf("unknown time zone %q", args[1])
+			}
+			t = t.In(loc)
+			fallthrough
+		case 1:
+			if args[0] != "" {
+				layout = args[0]
+			}
+		case 0:
+		default:
+			return "", fmt.Errorf("date takes at most a layout and a time zone")
+		}
+		return t.Format(layout), nil
+	}
+}
+
+// limitedBuffer is a buffer that fails once more than limit bytes are written to it.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > b.limit {
+		return 0, errors.New("text is too long")
+	}
+	return b.Buffer.Write(p)
+}
+
+// PreviewSignatureAppearance renders the text and, for visible profiles, the image of a
+// signature without signing. The certificate and document fields come from the certificate and
+// PDF given, or from sample data when they are empty. The profile need not be saved.
+func (s *SignatureService) PreviewSignatureAppearance(profile *SignatureProfile, certFingerprint string, pdfPath string) (*types.AppearancePreview, error) {
+	if profile == nil {
+		return nil, fmt.Errorf("signature profile is required")
+	}
+
+	data := sampleAppearanceData(profile)
+	if certFingerprint != "" || pdfPath != "" {
+		var cert *types.Certificate
+		if certFingerprint != "" {
+			var err error
+			if cert, err = s.GetCertificateByFingerprint(certFingerprint); err != nil {
+				return nil, err
+			}
+		}
+		actual := newAppearanceData(profile, cert, nil, data.SigningTime, pdfPath)
+		if cert != nil {
+			data.Signer = actual.Signer
+		}
+		if pdfPath != "" {
+			if _, err := os.Stat(pdfPath); err != nil {
+				return nil, fmt.Errorf("failed to open PDF: %w", err)
+			}
+			data.Document = actual.Document
+		}
+	}
+
+	lines, err := appearanceTextLines(profile, data)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &types.AppearancePreview{Lines: lines}
+	if lines == nil {
+		preview.Lines = []string{}
+	}
+	if text, err := renderAppearanceText(profile.Appearance.CustomText, data); err == nil {
+		preview.CustomText = text
+	}
+	if profile.Visibility == VisibilityVisible {
+		preview.Image = encodeDataURL(generateSignatureImage(lines, profile))
+	}
+	return preview, nil
+}
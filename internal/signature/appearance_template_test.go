@@ -0,0 +1,228 @@
+package signature
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TestRenderAppearanceText tests the placeholders of appearance text templates
+func TestRenderAppearanceText(t *testing.T) {
+	data := &AppearanceData{
+		Signer: AppearanceSigner{
+			Name:   "Jane Doe",
+			CN:     "Jane Doe",
+			O:      "Example Corp",
+			OU:     "Legal",
+			Email:  "jane@example.com",
+			Serial: "42",
+			Issuer: "Example CA",
+		},
+		Reason:      "Approval",
+		Location:    "Berlin",
+		Document:    AppearanceDocument{FileName: "contract.pdf", Pages: 3, Title: "Contract"},
+		SigningTime: time.Date(2026, 3, 1, 23, 30, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		name    string
+		text    string
+		want    string
+		wantErr string
+	}{
+		{name: "literal", text: "Approved by {legal}", want: "Approved by {legal}"},
+		{name: "certificate", text: "{{.Signer.CN}}, {{.Signer.O}}/{{.Signer.OU}} <{{.Signer.Email}}> #{{.Signer.Serial}} by {{.Signer.Issuer}}",
+			want: "Jane Doe, Example Corp/Legal <jane@example.com> #42 by Example CA"},
+		{name: "signing context", text: "{{.Reason}} in {{.Location}}", want: "Approval in Berlin"},
+		{name: "document", text: "{{.Document.FileName}} ({{.Document.Title}}), {{.Document.Pages}} pages", want: "contract.pdf (Contract), 3 pages"},
+		{name: "default date", text: "{{date}}", want: "2026-03-01 23:30:00 UTC"},
+		{name: "date format and time zone", text: `{{date "02.01.2006 15:04" "Europe/Berlin"}}`, want: "02.03.2026 00:30"},
+		{name: "conditional", text: "Signed{{with .Reason}} for {{.}}{{end}}", want: "Signed for Approval"},
+		{name: "multiple lines", text: "{{.Signer.O}}\n{{.Signer.OU}}", want: "Example Corp\nLegal"},
+		{name: "syntax error", text: "{{.Signer.CN", wantErr: "unclosed action"},
+		{name: "unknown field", text: "{{.Signer.Phone}}", wantErr: "can't evaluate field Phone"},
+		{name: "unknown function", text: "{{upper .Signer.CN}}", wantErr: `function "upper" not defined`},
+		{name: "unknown time zone", text: `{{date "2006" "Mars/Olympus"}}`, wantErr: "unknown time zone"},
+		{name: "too many date arguments", text: `{{date "2006" "UTC" "x"}}`, wantErr: "at most"},
+		{name: "too long", text: strings.Repeat("{{.Signer.Name}}", maxAppearanceTextLength/8+1), wantErr: "too long"},
+		{name: "large range", text: "{{range 2000000000}}{{end}}", wantErr: "range is not supported"},
+		{name: "nested range", text: "{{if .Signer.CN}}{{else}}{{range 10}}x{{end}}{{end}}", wantErr: "range is not supported"},
+		{name: "recursive template", text: `{{define "loop"}}{{template "loop"}}{{end}}{{template "loop"}}`, wantErr: "not supported"},
+		{name: "block", text: `{{block "b" .}}x{{end}}`, wantErr: "not supported"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := renderAppearanceText(tt.text, data)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("renderAppearanceText failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+// TestNewAppearanceData tests that template data is read from the certificate and the document
+func TestNewAppearanceData(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	now := time.Now()
+	cert := CreateTestCertificateFromTemplate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(4711),
+		Subject: pkix.Name{
+			CommonName:         "Jane Doe",
+			Organization:       []string{"Example Corp"},
+			OrganizationalUnit: []string{"Legal"},
+		},
+		EmailAddresses: []string{"jane@example.com"},
+		NotBefore:      now.Add(-time.Hour),
+		NotAfter:       now.Add(time.Hour),
+	}, key)
+
+	pdfPath := filepath.Join(t.TempDir(), "contract.pdf")
+	CreateTestPDF(t, pdfPath)
+
+	profile := DefaultVisibleProfile()
+	profile.Reason = "Approval"
+	profile.Location = "Berlin"
+
+	data := newAppearanceData(profile, nil, cert, now, pdfPath)
+	want := AppearanceSigner{Name: "Jane Doe", CN: "Jane Doe", O: "Example Corp", OU: "Legal", Email: "jane@example.com", Serial: "4711", Issuer: "Jane Doe"}
+	if data.Signer != want {
+		t.Errorf("Expected signer %+v, got %+v", want, data.Signer)
+	}
+	if data.Reason != "Approval" || data.Location != "Berlin" {
+		t.Errorf("Expected reason and location of the profile, got %q and %q", data.Reason, data.Location)
+	}
+	if data.Document.FileName != "contract.pdf" || data.Document.Pages != 1 {
+		t.Errorf("Unexpected document data: %+v", data.Document)
+	}
+
+	lines, err := appearanceTextLines(profile, data)
+	if err != nil {
+		t.Fatalf("appearanceTextLines failed: %v", err)
+	}
+	if len(lines) != 2 || lines[0] != "Signed by: Jane Doe" || !strings.HasPrefix(lines[1], "Date: ") {
+		t.Errorf("Unexpected appearance lines: %q", lines)
+	}
+}
+
+// TestSaveProfile_AppearanceTemplate tests that templates are validated when a profile is saved
+func TestSaveProfile_AppearanceTemplate(t *testing.T) {
+	pm := NewProfileManagerWithDir(t.TempDir())
+
+	profile := DefaultVisibleProfile()
+	profile.ID = uuid.New()
+	profile.Name = "Template"
+	profile.Appearance.CustomText = "{{.Signer.Phone}}"
+	if err := pm.SaveProfile(profile); err == nil {
+		t.Fatal("Expected error for an invalid template")
+	}
+	if _, err := pm.GetProfile(profile.ID); err == nil {
+		t.Error("Profile with an invalid template should not be saved")
+	}
+
+	profile.Appearance.CustomText = `{{.Signer.O}} - {{date "02.01.2006" "Europe/Berlin"}}`
+	if err := pm.SaveProfile(profile); err != nil {
+		t.Fatalf("SaveProfile failed: %v", err)
+	}
+}
+
+// TestPreviewSignatureAppearance tests previewing a template without signing
+func TestPreviewSignatureAppearance(t *testing.T) {
+	storeDir := t.TempDir()
+	service, _ := NewTestServiceWithStore(t, storeDir)
+
+	now := time.Now()
+	cert, key := CreateTestCertificate(t, "Preview Signer", now.Add(-time.Hour), now.Add(24*time.Hour))
+	WriteTestPKCS12(t, storeDir, "signer.p12", cert, key)
+
+	pdfPath := filepath.Join(t.TempDir(), "report.pdf")
+	CreateTestPDF(t, pdfPath)
+
+	profile := DefaultVisibleProfile()
+	profile.Appearance.ShowSigningTime = false
+	profile.Appearance.CustomText = "{{.Signer.CN}} signed {{.Document.FileName}}"
+
+	preview, err := service.PreviewSignatureAppearance(profile, "", "")
+	if err != nil {
+		t.Fatalf("PreviewSignatureAppearance failed: %v", err)
+	}
+	if preview.CustomText != "Jane Doe signed document.pdf" {
+		t.Errorf("Expected sample values, got %q", preview.CustomText)
+	}
+	if !strings.HasPrefix(preview.Image, "data:image/png;base64,") {
+		t.Error("Expected a PNG image for a visible profile")
+	}
+
+	preview, err = service.PreviewSignatureAppearance(profile, CertificateFingerprint(cert), pdfPath)
+	if err != nil {
+		t.Fatalf("PreviewSignatureAppearance failed: %v", err)
+	}
+	want := []string{"Signed by: Preview Signer", "Preview Signer signed report.pdf"}
+	if strings.Join(preview.Lines, "|") != strings.Join(want, "|") {
+		t.Errorf("Expected lines %q, got %q", want, preview.Lines)
+	}
+
+	if _, err := service.PreviewSignatureAppearance(profile, "unknown", ""); err == nil {
+		t.Error("Expected error for an unknown certificate")
+	}
+	profile.Appearance.CustomText = "{{.Nope}}"
+	if _, err := service.PreviewSignatureAppearance(profile, "", ""); err == nil {
+		t.Error("Expected error for an invalid template")
+	}
+}
+
+// TestSignPDF_AppearanceTemplate tests signing with a template, a reason and a location
+func TestSignPDF_AppearanceTemplate(t *testing.T) {
+	storeDir := t.TempDir()
+	service, _ := NewTestServiceWithStore(t, storeDir)
+
+	now := time.Now()
+	cert, key := CreateTestCertificate(t, "Template Signer", now.Add(-time.Hour), now.Add(24*time.Hour))
+	WriteTestPKCS12(t, storeDir, "signer.p12", cert, key)
+
+	pdfPath := filepath.Join(t.TempDir(), "doc.pdf")
+	CreateTestPDF(t, pdfPath)
+
+	profile := DefaultVisibleProfile()
+	profile.Position.Page = 1
+	profile.Reason = "Approval"
+	profile.Location = "Berlin"
+	profile.Appearance.CustomText = `{{.Signer.CN}}, {{date "2006-01-02"}}`
+
+	signedPath, err := service.SignPDFWithCustomProfile(pdfPath, CertificateFingerprint(cert), "", profile)
+	if err != nil {
+		t.Fatalf("SignPDFWithCustomProfile failed: %v", err)
+	}
+
+	signatures, err := service.VerifySignatures(signedPath)
+	if err != nil {
+		t.Fatalf("VerifySignatures failed: %v", err)
+	}
+	if len(signatures) != 1 || !signatures[0].IsValid {
+		t.Fatalf("Expected 1 valid signature, got %+v", signatures)
+	}
+	if signatures[0].Reason != "Approval" || signatures[0].Location != "Berlin" {
+		t.Errorf("Expected reason and location in the signature, got %q and %q", signatures[0].Reason, signatures[0].Location)
+	}
+}
@@ -0,0 +1,313 @@
+package signature
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Matbe34/lankir/internal/signature/types"
+	"github.com/digitorus/timestamp"
+)
+
+// ArchiveManifestName is the file RenewArchive records renewals in, inside the archive directory
+const ArchiveManifestName = "lankir-archive.json"
+
+// archiveManifestVersion is the version of the manifest format
+const archiveManifestVersion = 1
+
+// Statuses of an archived document
+const (
+	ArchiveStatusRenewed  = "renewed"
+	ArchiveStatusDue      = "due"
+	ArchiveStatusCurrent  = "current"
+	ArchiveStatusUnsigned = "unsigned"
+	ArchiveStatusFailed   = "failed"
+)
+
+// ArchiveRenewOptions selects which documents RenewArchive renews and where the new validation
+// data and timestamps come from.
+type ArchiveRenewOptions struct {
+	// Within renews documents whose latest document timestamp expires within this period
+	Within time.Duration `json:"within"`
+	// TimestampURL overrides the configured time-stamping authority
+	TimestampURL string `json:"timestampURL"`
+	// CertificateFiles, OCSPFiles and CRLFiles supply validation data from local files
+	CertificateFiles []string `json:"certificateFiles"`
+	OCSPFiles        []string `json:"ocspFiles"`
+	CRLFiles         []string `json:"crlFiles"`
+	// Offline disables fetching validation data from the network; the TSA is still contacted
+	Offline bool `json:"offline"`
+	// DryRun only reports the documents due for renewal
+	DryRun bool `json:"dryRun"`
+	// ManifestPath is the renewal manifest; empty uses lankir-archive.json in the directory
+	ManifestPath string `json:"manifestPath"`
+}
+
+// latestTimestamp is the newest document timestamp of a document and when it stops being verifiable.
+type latestTimestamp struct {
+	time      time.Time
+	expiresAt time.Time
+	authority string
+	digest    string
+}
+
+// RenewArchive keeps the signed PDFs of a directory verifiable in the long term (PAdES-LTA). Every
+// signed PDF whose latest document timestamp expires within opts.Within, uses a digest the
+// algorithm policy no longer accepts, or that has no document timestamp yet, gets the validation
+// data of its signatures and timestamps stored in the DSS and a new document timestamp. Files are
+// updated in place with incremental updates, and each renewal is recorded in the manifest.
+func (s *SignatureService) RenewArchive(dir string, opts ArchiveRenewOptions) (*types.ArchiveRenewResult, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive directory: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+
+	tsaURL := s.timestampAuthority(opts.TimestampURL).URL
+	if tsaURL == "" && !opts.DryRun {
+		return nil, fmt.Errorf("no timestamp authority configured (set timestampUrl in the configuration)")
+	}
+
+	result := &types.ArchiveRenewResult{
+		Directory:    dir,
+		ManifestPath: opts.ManifestPath,
+		DryRun:       opts.DryRun,
+		Files:        []types.ArchiveFileResult{},
+	}
+	if result.ManifestPath == "" {
+		result.ManifestPath = filepath.Join(dir, ArchiveManifestName)
+	}
+
+	manifest, err := loadArchiveManifest(result.ManifestPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && strings.EqualFold(filepath.Ext(path), ".pdf") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan archive directory: %w", err)
+	}
+
+	now := time.Now()
+	for _, path := range files {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			rel = path
+		}
+		file := types.ArchiveFileResult{File: rel}
+
+		previous, signed, reason, err := s.archiveRenewalReason(path, now.Add(opts.Within))
+		if previous != nil {
+			file.TimestampTime = &previous.time
+			file.ExpiresAt = &previous.expiresAt
+		}
+		switch {
+		case err != nil:
+			file.Status = ArchiveStatusFailed
+			file.Error = err.Error()
+		case !signed:
+			file.Status = ArchiveStatusUnsigned
+		case reason == "":
+			file.Status = ArchiveStatusCurrent
+		case opts.DryRun:
+			file.Status = ArchiveStatusDue
+			file.Reason = reason
+		default:
+			file.Reason = reason
+			renewal, warnings, err := s.renewArchivedDocument(path, tsaURL, opts)
+			file.Warnings = warnings
+			if err != nil {
+				file.Status = ArchiveStatusFailed
+				file.Error = err.Error()
+				break
+			}
+
+			file.Status = ArchiveStatusRenewed
+			file.RenewedUntil = &renewal.ExpiresAt
+			renewal.File = filepath.ToSlash(rel)
+			renewal.Reason = reason
+			renewal.PreviousExpiry = file.ExpiresAt
+			manifest.Renewals = append(manifest.Renewals, *renewal)
+
+			// Saved after every renewal so the manifest matches the files if the run is interrupted
+			if err := saveArchiveManifest(result.ManifestPath, manifest); err != nil {
+				return nil, err
+			}
+		}
+
+		switch file.Status {
+		case ArchiveStatusRenewed:
+			result.Renewed++
+		case ArchiveStatusDue:
+			result.Due++
+		case ArchiveStatusFailed:
+			result.Failed++
+		}
+		result.Files = append(result.Files, file)
+	}
+
+	return result, nil
+}
+
+// archiveRenewalReason returns the latest document timestamp of a PDF, whether it is signed at
+// all, and why it needs renewal before deadline, or an empty reason if it does not.
+func (s *SignatureService) archiveRenewalReason(path string, deadline time.Time) (*latestTimestamp, bool, string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false, "", fmt.Errorf("failed to open PDF: %w", err)
+	}
+
+	dictionaries, err := readSignatureDictionaries(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, false, "", err
+	}
+	if len(dictionaries) == 0 {
+		return nil, false, "", nil
+	}
+
+	latest := latestDocumentTimestamp(dictionaries)
+	policy := s.algorithmPolicy()
+	switch {
+	case latest == nil:
+		return nil, true, "no document timestamp", nil
+	case listsDigest(policy.ForbiddenDigests, latest.digest) || listsDigest(policy.DeprecatedDigests, latest.digest):
+		return latest, true, fmt.Sprintf("timestamp digest %s is no longer accepted by the algorithm policy", latest.digest), nil
+	case latest.expiresAt.IsZero():
+		return latest, true, "timestamp has no TSA certificate", nil
+	case !latest.expiresAt.After(deadline):
+		return latest, true, fmt.Sprintf("TSA certificate expires on %s", latest.expiresAt.Format("2006-01-02")), nil
+	}
+	return latest, true, "", nil
+}
+
+// latestDocumentTimestamp returns the newest parsable document timestamp, or nil if there is none.
+func latestDocumentTimestamp(dictionaries []signatureDictionary) *latestTimestamp {
+	var latest *latestTimestamp
+	for _, dict := range dictionaries {
+		if dict.subFilter != SubFilterDocTimeStamp {
+			continue
+		}
+		ts, err := timestamp.Parse(dict.contents)
+		if err != nil || (latest != nil && !ts.Time.After(latest.time)) {
+			continue
+		}
+
+		latest = &latestTimestamp{time: ts.Time, digest: ts.HashAlgorithm.String()}
+		if cert := dict.p7.GetOnlySigner(); cert != nil {
+			latest.expiresAt = cert.NotAfter
+			latest.authority = cert.Subject.String()
+		}
+	}
+	return latest
+}
+
+// renewArchivedDocument adds validation data and a new document timestamp to an archived PDF.
+// The update is written next to it and moved over the original once complete.
+func (s *SignatureService) renewArchivedDocument(path, tsaURL string, opts ArchiveRenewOptions) (*types.ArchiveRenewal, []string, error) {
+	tmpPath := path + ".renew.tmp"
+	defer os.Remove(tmpPath)
+
+	extended, err := s.ExtendSignatures(path, ExtendOptions{
+		OutputPath:       tmpPath,
+		CertificateFiles: opts.CertificateFiles,
+		OCSPFiles:        opts.OCSPFiles,
+		CRLFiles:         opts.CRLFiles,
+		Offline:          opts.Offline,
+		TimestampURL:     tsaURL,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var warnings []string
+	for _, sig := range extended.Signatures {
+		for _, w := range sig.Warnings {
+			warnings = append(warnings, fmt.Sprintf("signature %d: %s", sig.Index, w))
+		}
+	}
+
+	data, err := os.ReadFile(tmpPath)
+	if err != nil {
+		return nil, warnings, fmt.Errorf("failed to read renewed PDF: %w", err)
+	}
+	dictionaries, err := readSignatureDictionaries(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, warnings, err
+	}
+	latest := latestDocumentTimestamp(dictionaries)
+	if latest == nil {
+		return nil, warnings, fmt.Errorf("renewed PDF has no readable document timestamp")
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return nil, warnings, fmt.Errorf("failed to replace archived PDF: %w", err)
+	}
+
+	digest := sha256.Sum256(data)
+	return &types.ArchiveRenewal{
+		RenewedAt:     time.Now(),
+		TimestampTime: latest.time,
+		ExpiresAt:     latest.expiresAt,
+		Authority:     latest.authority,
+		SHA256:        hex.EncodeToString(digest[:]),
+	}, warnings, nil
+}
+
+// loadArchiveManifest reads a renewal manifest, or returns an empty one if it does not exist yet.
+func loadArchiveManifest(path string) (*types.ArchiveManifest, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &types.ArchiveManifest{Version: archiveManifestVersion, Renewals: []types.ArchiveRenewal{}}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive manifest: %w", err)
+	}
+
+	var manifest types.ArchiveManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse archive manifest: %w", err)
+	}
+	if manifest.Version > archiveManifestVersion {
+		return nil, fmt.Errorf("archive manifest version %d is newer than supported (%d)", manifest.Version, archiveManifestVersion)
+	}
+	manifest.Version = archiveManifestVersion
+	return &manifest, nil
+}
+
+// saveArchiveManifest writes a renewal manifest atomically.
+func saveArchiveManifest(path string, manifest *types.ArchiveManifest) error {
+	manifest.UpdatedAt = time.Now()
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal archive manifest: %w", err)
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write archive manifest: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to save archive manifest: %w", err)
+	}
+	return nil
+}
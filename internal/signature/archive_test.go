@@ -0,0 +1,129 @@
+package signature
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Matbe34/lankir/internal/signature/revocation"
+	"github.com/Matbe34/lankir/internal/signature/types"
+)
+
+// TestRenewArchive tests that documents are renewed when their timestamp expires within the
+// threshold and that renewals are recorded in the manifest
+func TestRenewArchive(t *testing.T) {
+	storeDir := t.TempDir()
+	service, _ := NewTestServiceWithStore(t, storeDir)
+	service.revocationChecker = revocation.NewChecker(t.TempDir(), nil)
+	tsaURL, _ := NewTestTSA(t)
+
+	archive := t.TempDir()
+	signedPath, _ := SignTestPDF(t, service, storeDir)
+	CopyTestFile(t, signedPath, filepath.Join(archive, "signed.pdf"))
+	CreateTestPDF(t, filepath.Join(archive, "unsigned.pdf"))
+	if err := os.MkdirAll(filepath.Join(archive, "2024"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	timestamped := filepath.Join(archive, "2024", "timestamped.pdf")
+	if _, err := service.TimestampPDF(signedPath, TimestampOptions{OutputPath: timestamped, URL: tsaURL}); err != nil {
+		t.Fatalf("TimestampPDF failed: %v", err)
+	}
+
+	statuses := func(result *types.ArchiveRenewResult) map[string]string {
+		m := map[string]string{}
+		for _, f := range result.Files {
+			m[filepath.ToSlash(f.File)] = f.Status
+		}
+		return m
+	}
+
+	// The test TSA certificate expires in a day
+	result, err := service.RenewArchive(archive, ArchiveRenewOptions{Within: time.Hour, DryRun: true})
+	if err != nil {
+		t.Fatalf("RenewArchive failed: %v", err)
+	}
+	expected := map[string]string{
+		"signed.pdf":           ArchiveStatusDue,
+		"unsigned.pdf":         ArchiveStatusUnsigned,
+		"2024/timestamped.pdf": ArchiveStatusCurrent,
+	}
+	for file, status := range expected {
+		if statuses(result)[file] != status {
+			t.Errorf("Expected %s to be %s, got %+v", file, status, result.Files)
+		}
+	}
+	if result.Due != 1 {
+		t.Errorf("Expected 1 document due, got %d", result.Due)
+	}
+	if _, err := os.Stat(result.ManifestPath); !os.IsNotExist(err) {
+		t.Error("Expected a dry run not to write the manifest")
+	}
+
+	before, _ := os.ReadFile(timestamped)
+	result, err = service.RenewArchive(archive, ArchiveRenewOptions{Within: 48 * time.Hour, TimestampURL: tsaURL, Offline: true})
+	if err != nil {
+		t.Fatalf("RenewArchive failed: %v", err)
+	}
+	if result.Renewed != 2 || result.Failed != 0 {
+		t.Fatalf("Expected 2 renewed documents, got %+v", result.Files)
+	}
+	if statuses(result)["2024/timestamped.pdf"] != ArchiveStatusRenewed {
+		t.Errorf("Expected the timestamped document to be renewed, got %+v", result.Files)
+	}
+
+	after, _ := os.ReadFile(timestamped)
+	if len(after) <= len(before) || string(after[:len(before)]) != string(before) {
+		t.Error("Expected the renewal to be an incremental update")
+	}
+	signatures, err := service.VerifySignatures(timestamped)
+	if err != nil {
+		t.Fatalf("VerifySignatures failed: %v", err)
+	}
+	if len(signatures) != 3 {
+		t.Fatalf("Expected the signature and 2 document timestamps, got %d", len(signatures))
+	}
+	for _, sig := range signatures {
+		if !sig.IsValid || sig.ModificationStatus == "disallowed" {
+			t.Errorf("Expected signature to stay valid, got %+v", sig)
+		}
+	}
+
+	data, err := os.ReadFile(result.ManifestPath)
+	if err != nil {
+		t.Fatalf("Expected a manifest: %v", err)
+	}
+	var manifest types.ArchiveManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("Failed to parse manifest: %v", err)
+	}
+	if manifest.Version != archiveManifestVersion || len(manifest.Renewals) != 2 {
+		t.Fatalf("Expected 2 renewals in the manifest, got %+v", manifest)
+	}
+	for _, renewal := range manifest.Renewals {
+		if renewal.File == "2024/timestamped.pdf" && (renewal.PreviousExpiry == nil || renewal.SHA256 == "" || renewal.Authority == "") {
+			t.Errorf("Incomplete renewal record %+v", renewal)
+		}
+		if renewal.File == "signed.pdf" && renewal.Reason != "no document timestamp" {
+			t.Errorf("Unexpected reason %q", renewal.Reason)
+		}
+	}
+
+	// Renewals are appended to the existing manifest
+	if _, err := service.RenewArchive(archive, ArchiveRenewOptions{Within: 48 * time.Hour, TimestampURL: tsaURL, Offline: true}); err != nil {
+		t.Fatalf("RenewArchive failed: %v", err)
+	}
+	manifestData, _ := os.ReadFile(result.ManifestPath)
+	if err := json.Unmarshal(manifestData, &manifest); err != nil || len(manifest.Renewals) != 4 {
+		t.Errorf("Expected 4 renewals after the second run, got %d (%v)", len(manifest.Renewals), err)
+	}
+}
+
+// TestRenewArchive_NoTimestampAuthority tests that renewing requires a TSA
+func TestRenewArchive_NoTimestampAuthority(t *testing.T) {
+	service, _ := NewTestServiceWithStore(t, t.TempDir())
+	if _, err := service.RenewArchive(t.TempDir(), ArchiveRenewOptions{}); err == nil {
+		t.Error("Expected an error without a timestamp authority")
+	}
+}
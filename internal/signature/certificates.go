@@ -1,105 +1,163 @@
 package signature
 
 import (
-	"log/slog"
-	"os"
-	"path/filepath"
+	"fmt"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/Matbe34/lankir/internal/signature/certutil"
 	"github.com/Matbe34/lankir/internal/signature/nss"
-	"github.com/Matbe34/lankir/internal/signature/pkcs11"
-	"github.com/Matbe34/lankir/internal/signature/pkcs12"
 	"github.com/Matbe34/lankir/internal/signature/types"
 )
 
 // CertificateFilter defines criteria for filtering certificates
 type CertificateFilter struct {
-	Source           string // Filter by source (system, user, pkcs11)
-	Search           string // Search in name, subject, issuer
-	ValidOnly        bool   // Only return valid (non-expired) certificates
-	RequiredKeyUsage string // Require specific key usage (e.g., "digitalSignature")
+	Source           string    // Filter by source (system, user, pkcs11)
+	Search           string    // Search in name, subject, issuer
+	ValidOnly        bool      // Only return valid (non-expired) certificates
+	RequiredKeyUsage string    // Require specific key usage (e.g., "Digital Signature")
+	ExtendedKeyUsage string    // Require an extended key usage (e.g., "documentSigning", "emailProtection", "clientAuth")
+	Issuer           string    // Match issuer common name or distinguished name (case-insensitive substring)
+	KeyAlgorithm     string    // Require key algorithm ("RSA", "ECDSA", "Ed25519")
+	MinKeySize       int       // Require a key of at least this many bits
+	PrivateKeyOnly   bool      // Only return certificates with an associated private key
+	ExpiresBefore    time.Time // Only return certificates expiring before this time (zero = no limit)
+	QualifiedOnly    bool      // Only return qualified certificates (QcCompliance statement)
+	SortBy           string    // Sort field: "name", "issuer", "expiry", "validfrom", "source", "keysize" (empty = source order)
+	SortDescending   bool      // Reverse the sort order
 }
 
+// Certificate sort fields accepted by CertificateFilter.SortBy
+const (
+	SortByName      = "name"
+	SortByIssuer    = "issuer"
+	SortByExpiry    = "expiry"
+	SortByValidFrom = "validfrom"
+	SortBySource    = "source"
+	SortByKeySize   = "keysize"
+)
+
 // ListCertificates returns all available certificates from all configured sources.
 func (s *SignatureService) ListCertificates() ([]types.Certificate, error) {
 	return s.ListCertificatesFiltered(CertificateFilter{})
 }
 
 // ListCertificatesFiltered returns certificates matching the given filter criteria.
+// Results are served from the certificate index; sources are only rescanned when it is stale.
 func (s *SignatureService) ListCertificatesFiltered(filter CertificateFilter) ([]types.Certificate, error) {
-	var allCerts []types.Certificate
-	seenFingerprints := make(map[string]bool)
-
-	// Load certificates from configured stores only
-	if s.configService != nil {
-		cfg := s.configService.Get()
-
-		// Load from certificate stores
-		for _, storePath := range cfg.CertificateStores {
-			storeCerts, err := pkcs12.LoadCertificatesFromPath(storePath)
-			if err != nil {
-				// Log different error types with appropriate severity
-				if os.IsNotExist(err) {
-					slog.Debug("certificate store path does not exist",
-						"path", storePath,
-						"error", err)
-				} else if os.IsPermission(err) {
-					slog.Warn("permission denied accessing certificate store",
-						"path", storePath,
-						"error", err)
-				} else {
-					slog.Warn("failed to load certificates from store",
-						"path", storePath,
-						"error", err)
-				}
-				continue
-			}
+	certs, err := s.cachedCertificates()
+	if err != nil {
+		return nil, err
+	}
 
-			for _, sc := range storeCerts {
-				if sc.FilePath != "" {
-					ext := strings.ToLower(filepath.Ext(sc.FilePath))
-					inNSSDB := strings.Contains(sc.FilePath, ".pki/nssdb")
-
-					if ext == ".p12" || ext == ".pfx" {
-						allCerts = append(allCerts, sc)
-					} else if !inNSSDB {
-						allCerts = append(allCerts, sc)
-					}
-				} else {
-					allCerts = append(allCerts, sc)
-				}
-			}
+	filtered := make([]types.Certificate, 0, len(certs))
+	for _, cert := range certs {
+		if s.matchesFilter(cert, filter) {
+			filtered = append(filtered, cert)
 		}
+	}
 
-		// Load from token libraries (PKCS#11)
-		pkcs11Certs, err := pkcs11.LoadCertificatesFromModules(cfg.TokenLibraries)
-		if err != nil {
-			slog.Warn("failed to load PKCS#11 certificates", "error", err)
-		} else {
-			allCerts = append(allCerts, pkcs11Certs...)
-		}
+	if err := sortCertificates(filtered, filter.SortBy, filter.SortDescending); err != nil {
+		return nil, err
 	}
 
-	nssCerts, err := LoadNSSCertificates()
+	return filtered, nil
+}
+
+// sortCertificates sorts certs in place by the given field. Ties keep source order.
+func sortCertificates(certs []types.Certificate, sortBy string, descending bool) error {
+	var less func(a, b types.Certificate) bool
+
+	switch strings.ToLower(sortBy) {
+	case "":
+		return nil
+	case SortByName:
+		less = func(a, b types.Certificate) bool { return strings.ToLower(a.Name) < strings.ToLower(b.Name) }
+	case SortByIssuer:
+		less = func(a, b types.Certificate) bool { return strings.ToLower(a.Issuer) < strings.ToLower(b.Issuer) }
+	case SortByExpiry:
+		// ValidTo and ValidFrom use a fixed-width layout, so they sort lexically
+		less = func(a, b types.Certificate) bool { return a.ValidTo < b.ValidTo }
+	case SortByValidFrom:
+		less = func(a, b types.Certificate) bool { return a.ValidFrom < b.ValidFrom }
+	case SortBySource:
+		less = func(a, b types.Certificate) bool { return a.Source < b.Source }
+	case SortByKeySize:
+		less = func(a, b types.Certificate) bool { return a.KeySize < b.KeySize }
+	default:
+		return fmt.Errorf("unsupported sort field: %s", sortBy)
+	}
+
+	sort.SliceStable(certs, func(i, j int) bool {
+		if descending {
+			return less(certs[j], certs[i])
+		}
+		return less(certs[i], certs[j])
+	})
+	return nil
+}
+
+// ListCertificatesWithStatus returns all certificates together with the load status of every source,
+// so callers can show which store, module or NSS database failed instead of silently omitting it.
+func (s *SignatureService) ListCertificatesWithStatus() (*CertificateListResult, error) {
+	certs, sources, err := s.cachedInventory()
 	if err != nil {
-		slog.Warn("failed to load NSS certificates", "error", err)
-	} else {
-		allCerts = append(allCerts, nssCerts...)
+		return nil, err
+	}
+
+	if certs == nil {
+		certs = []types.Certificate{}
+	}
+	if sources == nil {
+		sources = []types.CertificateSourceStatus{}
 	}
 
-	uniqueCerts := make([]types.Certificate, 0, len(allCerts))
-	for _, cert := range allCerts {
-		if !seenFingerprints[cert.Fingerprint] {
-			seenFingerprints[cert.Fingerprint] = true
+	return &CertificateListResult{
+		Certificates: certs,
+		Sources:      sources,
+	}, nil
+}
+
+// loadAllCertificates loads every configured source in parallel, each bounded by the
+// source timeout, and returns certificates merged by fingerprint with per-source statuses.
+func (s *SignatureService) loadAllCertificates() ([]types.Certificate, []types.CertificateSourceStatus) {
+	results, statuses := s.sourceLoader.loadAll(s.certificateSources(), s.sourceTimeout())
+	return mergeCertificates(results), statuses
+}
+
+// mergeCertificates combines entries with the same fingerprint into one certificate that
+// lists every location, in source order. The first location with a private key becomes
+// the certificate's preferred backend, so a signable token entry is not hidden behind a
+// plain certificate file. Entries without a fingerprint (password-protected files) are
+// kept separate.
+func mergeCertificates(results [][]types.Certificate) []types.Certificate {
+	var merged []types.Certificate
+	byFingerprint := make(map[string]int)
+
+	for _, certs := range results {
+		for _, cert := range certs {
+			loc := cert.Location()
+
+			i, seen := byFingerprint[cert.Fingerprint]
+			if !seen || cert.Fingerprint == "" {
+				cert.Locations = []types.CertificateLocation{loc}
+				if cert.Fingerprint != "" {
+					byFingerprint[cert.Fingerprint] = len(merged)
+				}
+				merged = append(merged, cert)
+				continue
+			}
 
-			if s.matchesFilter(cert, filter) {
-				uniqueCerts = append(uniqueCerts, cert)
+			existing := &merged[i]
+			existing.Locations = append(existing.Locations, loc)
+			if loc.HasPrivateKey && !existing.HasPrivateKey {
+				existing.UseLocation(loc)
 			}
 		}
 	}
 
-	return uniqueCerts, nil
+	return merged
 }
 
 // SearchCertificates finds certificates matching the query in name, subject, or issuer.
@@ -115,7 +173,7 @@ func (s *SignatureService) matchesFilter(cert types.Certificate, filter Certific
 		return false
 	}
 
-	if filter.Source != "" && !strings.EqualFold(cert.Source, filter.Source) {
+	if filter.Source != "" && !cert.HasSource(filter.Source) {
 		return false
 	}
 
@@ -147,6 +205,41 @@ func (s *SignatureService) matchesFilter(cert types.Certificate, filter Certific
 		}
 	}
 
+	if filter.ExtendedKeyUsage != "" && !cert.HasExtendedKeyUsage(certutil.CanonicalExtKeyUsage(filter.ExtendedKeyUsage)) {
+		return false
+	}
+
+	if filter.Issuer != "" {
+		issuerLower := strings.ToLower(filter.Issuer)
+		if !strings.Contains(strings.ToLower(cert.Issuer), issuerLower) &&
+			!strings.Contains(strings.ToLower(cert.IssuerDN), issuerLower) {
+			return false
+		}
+	}
+
+	if filter.KeyAlgorithm != "" && !strings.EqualFold(cert.KeyAlgorithm, filter.KeyAlgorithm) {
+		return false
+	}
+
+	if filter.MinKeySize > 0 && cert.KeySize < filter.MinKeySize {
+		return false
+	}
+
+	if filter.PrivateKeyOnly && !cert.HasPrivateKey {
+		return false
+	}
+
+	if !filter.ExpiresBefore.IsZero() {
+		expiresAt, ok := certificateExpiry(cert)
+		if !ok || !expiresAt.Before(filter.ExpiresBefore) {
+			return false
+		}
+	}
+
+	if filter.QualifiedOnly && !cert.IsQualified {
+		return false
+	}
+
 	return true
 }
 
@@ -165,6 +258,7 @@ func LoadNSSCertificates() ([]types.Certificate, error) {
 
 		c := certutil.ConvertX509Certificate(nc.X509Cert, "NSS Database", nc.X509Cert.Subject.CommonName)
 		c.NSSNickname = nc.Nickname
+		c.HasPrivateKey = true
 		c.RequiresPin = false
 		c.PinOptional = true
 
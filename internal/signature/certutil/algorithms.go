@@ -0,0 +1,52 @@
+package certutil
+
+import (
+	"encoding/asn1"
+)
+
+// digestAlgorithmNames names the CMS digest algorithm identifiers
+var digestAlgorithmNames = map[string]string{
+	"1.2.840.113549.2.5":      "MD5",
+	"1.3.14.3.2.26":           "SHA-1",
+	"2.16.840.1.101.3.4.2.4":  "SHA-224",
+	"2.16.840.1.101.3.4.2.1":  "SHA-256",
+	"2.16.840.1.101.3.4.2.2":  "SHA-384",
+	"2.16.840.1.101.3.4.2.3":  "SHA-512",
+	"2.16.840.1.101.3.4.2.8":  "SHA3-256",
+	"2.16.840.1.101.3.4.2.9":  "SHA3-384",
+	"2.16.840.1.101.3.4.2.10": "SHA3-512",
+}
+
+// signatureAlgorithmNames names the CMS signature algorithm identifiers. Signers may give
+// either the bare key algorithm or one combined with the digest.
+var signatureAlgorithmNames = map[string]string{
+	"1.2.840.113549.1.1.1":  "RSA",
+	"1.2.840.113549.1.1.4":  "MD5-RSA",
+	"1.2.840.113549.1.1.5":  "SHA1-RSA",
+	"1.2.840.113549.1.1.10": "RSA-PSS",
+	"1.2.840.113549.1.1.11": "SHA256-RSA",
+	"1.2.840.113549.1.1.12": "SHA384-RSA",
+	"1.2.840.113549.1.1.13": "SHA512-RSA",
+	"1.2.840.10045.2.1":     "ECDSA",
+	"1.2.840.10045.4.1":     "ECDSA-SHA1",
+	"1.2.840.10045.4.3.2":   "ECDSA-SHA256",
+	"1.2.840.10045.4.3.3":   "ECDSA-SHA384",
+	"1.2.840.10045.4.3.4":   "ECDSA-SHA512",
+	"1.3.101.112":           "Ed25519",
+}
+
+// DigestAlgorithmName returns the name of a CMS digest algorithm, or its dotted OID if unknown.
+func DigestAlgorithmName(oid asn1.ObjectIdentifier) string {
+	if name, ok := digestAlgorithmNames[oid.String()]; ok {
+		return name
+	}
+	return oid.String()
+}
+
+// SignatureAlgorithmName returns the name of a CMS signature algorithm, or its dotted OID if unknown.
+func SignatureAlgorithmName(oid asn1.ObjectIdentifier) string {
+	if name, ok := signatureAlgorithmNames[oid.String()]; ok {
+		return name
+	}
+	return oid.String()
+}
@@ -54,17 +54,25 @@ func ConvertX509Certificate(cert *x509.Certificate, source string, filename stri
 	// Check if certificate has digital signature capability
 	canSign := cert.KeyUsage&x509.KeyUsageDigitalSignature != 0
 
+	keyAlgorithm, keySize := PublicKeyInfo(cert)
+
 	return types.Certificate{
-		Name:         name,
-		Issuer:       cert.Issuer.CommonName,
-		Subject:      cert.Subject.CommonName,
-		SerialNumber: cert.SerialNumber.String(),
-		ValidFrom:    cert.NotBefore.Format("2006-01-02 15:04:05"),
-		ValidTo:      cert.NotAfter.Format("2006-01-02 15:04:05"),
-		Fingerprint:  fingerprint,
-		Source:       source,
-		KeyUsage:     keyUsage,
-		IsValid:      isValid,
-		CanSign:      canSign,
+		Name:             name,
+		Issuer:           cert.Issuer.CommonName,
+		Subject:          cert.Subject.CommonName,
+		SerialNumber:     cert.SerialNumber.String(),
+		ValidFrom:        cert.NotBefore.Format("2006-01-02 15:04:05"),
+		ValidTo:          cert.NotAfter.Format("2006-01-02 15:04:05"),
+		Fingerprint:      fingerprint,
+		Source:           source,
+		KeyUsage:         keyUsage,
+		IsValid:          isValid,
+		CanSign:          canSign,
+		IssuerDN:         cert.Issuer.String(),
+		ExtendedKeyUsage: ExtendedKeyUsageNames(cert),
+		KeyAlgorithm:     keyAlgorithm,
+		KeySize:          keySize,
+		IsQualified:      IsQualified(cert),
+		Raw:              cert.Raw,
 	}
 }
@@ -0,0 +1,226 @@
+package certutil
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"strings"
+
+	"github.com/Matbe34/lankir/internal/signature/types"
+)
+
+var keyUsageNames = []struct {
+	usage x509.KeyUsage
+	name  string
+}{
+	{x509.KeyUsageDigitalSignature, "Digital Signature"},
+	{x509.KeyUsageContentCommitment, "Non Repudiation"},
+	{x509.KeyUsageKeyEncipherment, "Key Encipherment"},
+	{x509.KeyUsageDataEncipherment, "Data Encipherment"},
+	{x509.KeyUsageKeyAgreement, "Key Agreement"},
+	{x509.KeyUsageCertSign, "Certificate Sign"},
+	{x509.KeyUsageCRLSign, "CRL Sign"},
+	{x509.KeyUsageEncipherOnly, "Encipher Only"},
+	{x509.KeyUsageDecipherOnly, "Decipher Only"},
+}
+
+// qcStatementNames names the ETSI EN 319 412-5 and RFC 3739 statements
+var qcStatementNames = map[string]string{
+	"0.4.0.1862.1.1":     "QcCompliance",
+	"0.4.0.1862.1.2":     "QcLimitValue",
+	"0.4.0.1862.1.3":     "QcRetentionPeriod",
+	"0.4.0.1862.1.4":     "QcSSCD",
+	"0.4.0.1862.1.5":     "QcPDS",
+	"0.4.0.1862.1.6":     "QcType",
+	"0.4.0.1862.1.7":     "QcCClegislation",
+	"0.4.0.194121.1.1":   "Semantics (natural person)",
+	"0.4.0.194121.1.2":   "Semantics (legal person)",
+	"1.3.6.1.5.5.7.11.2": "PKIX QC syntax v2",
+}
+
+var qcTypeNames = map[string]string{
+	"0.4.0.1862.1.6.1": "esign",
+	"0.4.0.1862.1.6.2": "eseal",
+	"0.4.0.1862.1.6.3": "web",
+}
+
+var extensionNames = map[string]string{
+	"2.5.29.14":               "Subject Key Identifier",
+	"2.5.29.15":               "Key Usage",
+	"2.5.29.17":               "Subject Alternative Name",
+	"2.5.29.19":               "Basic Constraints",
+	"2.5.29.31":               "CRL Distribution Points",
+	"2.5.29.32":               "Certificate Policies",
+	"2.5.29.35":               "Authority Key Identifier",
+	"2.5.29.37":               "Extended Key Usage",
+	"1.3.6.1.5.5.7.1.1":       "Authority Information Access",
+	"1.3.6.1.5.5.7.1.3":       "QC Statements",
+	"1.3.6.1.5.5.7.48.1.5":    "OCSP No Check",
+	"1.2.840.113583.1.1.9.1":  "Adobe Timestamp",
+	"1.2.840.113583.1.1.9.2":  "Adobe Archive Revocation Info",
+	"2.16.840.1.113730.1.1":   "Netscape Certificate Type",
+	"1.3.6.1.4.1.311.20.2":    "Microsoft Certificate Template Name",
+	"1.3.6.1.4.1.311.21.7":    "Microsoft Certificate Template",
+	"1.3.6.1.4.1.11129.2.4.2": "Certificate Transparency SCTs",
+}
+
+// KeyUsageNames returns the names of all key usage bits set on the certificate.
+func KeyUsageNames(cert *x509.Certificate) []string {
+	names := []string{}
+	for _, ku := range keyUsageNames {
+		if cert.KeyUsage&ku.usage != 0 {
+			names = append(names, ku.name)
+		}
+	}
+	return names
+}
+
+// Fingerprints returns the hex SHA-1 and SHA-256 fingerprints of the certificate.
+func Fingerprints(cert *x509.Certificate) (string, string) {
+	sha1Sum := sha1.Sum(cert.Raw)
+	sha256Sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sha1Sum[:]), hex.EncodeToString(sha256Sum[:])
+}
+
+// IsSelfSigned reports whether the certificate is issued and signed by its own key.
+func IsSelfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawIssuer, cert.RawSubject) && cert.CheckSignatureFrom(cert) == nil
+}
+
+// NewCertificateDetails decodes the certificate's fields and extensions. The summary
+// Certificate and the chain are filled in by the caller.
+func NewCertificateDetails(cert *x509.Certificate) types.CertificateDetails {
+	sha1Hex, sha256Hex := Fingerprints(cert)
+	keyAlgorithm, keySize := PublicKeyInfo(cert)
+
+	details := types.CertificateDetails{
+		Version:               cert.Version,
+		SubjectDN:             cert.Subject.String(),
+		IssuerDN:              cert.Issuer.String(),
+		SerialNumberHex:       strings.ToUpper(cert.SerialNumber.Text(16)),
+		NotBefore:             cert.NotBefore,
+		NotAfter:              cert.NotAfter,
+		SignatureAlgorithm:    cert.SignatureAlgorithm.String(),
+		KeyAlgorithm:          keyAlgorithm,
+		KeySize:               keySize,
+		FingerprintSHA1:       sha1Hex,
+		FingerprintSHA256:     sha256Hex,
+		SubjectKeyID:          hex.EncodeToString(cert.SubjectKeyId),
+		AuthorityKeyID:        hex.EncodeToString(cert.AuthorityKeyId),
+		IsCA:                  cert.IsCA,
+		SelfSigned:            IsSelfSigned(cert),
+		KeyUsage:              KeyUsageNames(cert),
+		ExtendedKeyUsage:      nonNil(ExtendedKeyUsageNames(cert)),
+		CertificatePolicies:   []string{},
+		OCSPServers:           nonNil(cert.OCSPServer),
+		IssuingCertificateURL: nonNil(cert.IssuingCertificateURL),
+		CRLDistributionPoints: nonNil(cert.CRLDistributionPoints),
+		QCStatements:          qcStatementDetails(cert),
+		Extensions:            []types.ExtensionInfo{},
+		Chain:                 []types.ChainCertificate{},
+	}
+
+	if key, ok := cert.PublicKey.(*ecdsa.PublicKey); ok {
+		details.KeyCurve = key.Curve.Params().Name
+	}
+
+	details.SubjectAltNames = types.SubjectAltNames{
+		DNSNames:       cert.DNSNames,
+		EmailAddresses: cert.EmailAddresses,
+	}
+	for _, ip := range cert.IPAddresses {
+		details.SubjectAltNames.IPAddresses = append(details.SubjectAltNames.IPAddresses, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		details.SubjectAltNames.URIs = append(details.SubjectAltNames.URIs, uri.String())
+	}
+
+	for _, policy := range cert.Policies {
+		details.CertificatePolicies = append(details.CertificatePolicies, policy.String())
+	}
+
+	for _, ext := range cert.Extensions {
+		oid := ext.Id.String()
+		name := extensionNames[oid]
+		if name == "" {
+			name = oid
+		}
+		details.Extensions = append(details.Extensions, types.ExtensionInfo{
+			OID:      oid,
+			Name:     name,
+			Critical: ext.Critical,
+		})
+	}
+
+	return details
+}
+
+// NewChainCertificate summarizes a certificate for a chain listing.
+func NewChainCertificate(cert *x509.Certificate) types.ChainCertificate {
+	_, sha256Hex := Fingerprints(cert)
+	return types.ChainCertificate{
+		SubjectDN:         cert.Subject.String(),
+		IssuerDN:          cert.Issuer.String(),
+		FingerprintSHA256: sha256Hex,
+		NotAfter:          cert.NotAfter,
+		IsCA:              cert.IsCA,
+		SelfSigned:        IsSelfSigned(cert),
+	}
+}
+
+// qcStatementDetails decodes the qcStatements extension including QcType values.
+func qcStatementDetails(cert *x509.Certificate) []types.QCStatement {
+	statements := []types.QCStatement{}
+
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oidQCStatements) {
+			continue
+		}
+
+		var raw []asn1.RawValue
+		if _, err := asn1.Unmarshal(ext.Value, &raw); err != nil {
+			return statements
+		}
+
+		for _, entry := range raw {
+			var id asn1.ObjectIdentifier
+			rest, err := asn1.Unmarshal(entry.Bytes, &id)
+			if err != nil {
+				continue
+			}
+
+			statement := types.QCStatement{OID: id.String(), Name: qcStatementNames[id.String()]}
+			if statement.Name == "" {
+				statement.Name = statement.OID
+			}
+
+			if statement.Name == "QcType" && len(rest) > 0 {
+				var qcTypes []asn1.ObjectIdentifier
+				if _, err := asn1.Unmarshal(rest, &qcTypes); err == nil {
+					for _, t := range qcTypes {
+						name := qcTypeNames[t.String()]
+						if name == "" {
+							name = t.String()
+						}
+						statement.Types = append(statement.Types, name)
+					}
+				}
+			}
+
+			statements = append(statements, statement)
+		}
+	}
+
+	return statements
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
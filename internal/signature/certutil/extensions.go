@@ -0,0 +1,170 @@
+package certutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"strings"
+)
+
+// Extended key usage names as reported in types.Certificate.ExtendedKeyUsage
+const (
+	EKUServerAuth      = "Server Authentication"
+	EKUClientAuth      = "Client Authentication"
+	EKUCodeSigning     = "Code Signing"
+	EKUEmailProtection = "Email Protection"
+	EKUTimeStamping    = "Time Stamping"
+	EKUOCSPSigning     = "OCSP Signing"
+	EKUDocumentSigning = "Document Signing"
+	EKUAny             = "Any"
+)
+
+var extKeyUsageNames = map[x509.ExtKeyUsage]string{
+	x509.ExtKeyUsageAny:             EKUAny,
+	x509.ExtKeyUsageServerAuth:      EKUServerAuth,
+	x509.ExtKeyUsageClientAuth:      EKUClientAuth,
+	x509.ExtKeyUsageCodeSigning:     EKUCodeSigning,
+	x509.ExtKeyUsageEmailProtection: EKUEmailProtection,
+	x509.ExtKeyUsageTimeStamping:    EKUTimeStamping,
+	x509.ExtKeyUsageOCSPSigning:     EKUOCSPSigning,
+}
+
+// documentSigningOIDs are the extended key usages issued for PDF and document signing
+var documentSigningOIDs = []asn1.ObjectIdentifier{
+	{1, 3, 6, 1, 5, 5, 7, 3, 36},       // id-kp-documentSigning (RFC 9336)
+	{1, 2, 840, 113583, 1, 1, 5},       // Adobe Authentic Documents Trust
+	{1, 3, 6, 1, 4, 1, 311, 10, 3, 12}, // Microsoft Document Signing
+}
+
+// extKeyUsageAliases maps short spellings to extended key usage names
+var extKeyUsageAliases = map[string]string{
+	"serverauth":      EKUServerAuth,
+	"clientauth":      EKUClientAuth,
+	"codesigning":     EKUCodeSigning,
+	"email":           EKUEmailProtection,
+	"emailprotection": EKUEmailProtection,
+	"smime":           EKUEmailProtection,
+	"timestamping":    EKUTimeStamping,
+	"ocspsigning":     EKUOCSPSigning,
+	"documentsigning": EKUDocumentSigning,
+	"docsigning":      EKUDocumentSigning,
+	"any":             EKUAny,
+}
+
+var (
+	// oidQCStatements is the id-pe-qcStatements certificate extension (RFC 3739)
+	oidQCStatements = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 3}
+	// OIDQcCompliance marks an EU qualified certificate (ETSI EN 319 412-5)
+	OIDQcCompliance = asn1.ObjectIdentifier{0, 4, 0, 1862, 1, 1}
+	// OIDQcSSCD marks a private key held in a qualified signature creation device
+	OIDQcSSCD = asn1.ObjectIdentifier{0, 4, 0, 1862, 1, 4}
+)
+
+// ExtendedKeyUsageNames returns readable names for the certificate's extended key usages.
+// Unrecognized usages are reported by their dotted OID.
+func ExtendedKeyUsageNames(cert *x509.Certificate) []string {
+	var names []string
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	for _, usage := range cert.ExtKeyUsage {
+		if name, ok := extKeyUsageNames[usage]; ok {
+			add(name)
+		}
+	}
+
+	for _, oid := range cert.UnknownExtKeyUsage {
+		if isDocumentSigningOID(oid) {
+			add(EKUDocumentSigning)
+		} else {
+			add(oid.String())
+		}
+	}
+
+	return names
+}
+
+func isDocumentSigningOID(oid asn1.ObjectIdentifier) bool {
+	for _, known := range documentSigningOIDs {
+		if oid.Equal(known) {
+			return true
+		}
+	}
+	return false
+}
+
+// CanonicalExtKeyUsage maps user input such as "clientAuth" or "document-signing" to the
+// name used in types.Certificate.ExtendedKeyUsage. Unknown input is returned unchanged.
+func CanonicalExtKeyUsage(usage string) string {
+	key := strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' || r == '_' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(strings.TrimSpace(usage)))
+
+	if name, ok := extKeyUsageAliases[key]; ok {
+		return name
+	}
+	for _, name := range extKeyUsageNames {
+		if strings.EqualFold(strings.ReplaceAll(name, " ", ""), key) {
+			return name
+		}
+	}
+	return usage
+}
+
+// PublicKeyInfo returns the public key algorithm name and key size in bits.
+func PublicKeyInfo(cert *x509.Certificate) (string, int) {
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return "RSA", key.N.BitLen()
+	case *ecdsa.PublicKey:
+		return "ECDSA", key.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		return "Ed25519", 256
+	default:
+		return cert.PublicKeyAlgorithm.String(), 0
+	}
+}
+
+// QCStatements returns the statement IDs of the certificate's qcStatements extension.
+func QCStatements(cert *x509.Certificate) []asn1.ObjectIdentifier {
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oidQCStatements) {
+			continue
+		}
+
+		var statements []asn1.RawValue
+		if rest, err := asn1.Unmarshal(ext.Value, &statements); err != nil || len(rest) > 0 {
+			return nil
+		}
+
+		var ids []asn1.ObjectIdentifier
+		for _, raw := range statements {
+			var id asn1.ObjectIdentifier
+			if _, err := asn1.Unmarshal(raw.Bytes, &id); err == nil {
+				ids = append(ids, id)
+			}
+		}
+		return ids
+	}
+	return nil
+}
+
+// IsQualified reports whether the certificate declares EU qualified status via QcCompliance.
+func IsQualified(cert *x509.Certificate) bool {
+	for _, id := range QCStatements(cert) {
+		if id.Equal(OIDQcCompliance) {
+			return true
+		}
+	}
+	return false
+}
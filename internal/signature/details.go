@@ -0,0 +1,129 @@
+package signature
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+
+	"github.com/Matbe34/lankir/internal/config"
+	"github.com/Matbe34/lankir/internal/signature/certutil"
+	"github.com/Matbe34/lankir/internal/signature/types"
+)
+
+// maxChainLength bounds issuer lookups when a chain cannot be verified.
+const maxChainLength = 10
+
+// GetCertificateDetails returns the decoded certificate with the chain resolved up to a trust anchor.
+// Issuers are looked up among the available certificates, the system roots and the configured
+// signing trust anchors, then downloaded from the AIA URL when missing.
+func (s *SignatureService) GetCertificateDetails(fingerprint string) (*types.CertificateDetails, error) {
+	cert, err := s.GetCertificateByFingerprint(fingerprint)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cert.Raw) == 0 {
+		return nil, fmt.Errorf("details of certificate '%s' are unavailable: the file is password-protected", cert.Name)
+	}
+
+	leaf, err := x509.ParseCertificate(cert.Raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+
+	details := certutil.NewCertificateDetails(leaf)
+	details.Certificate = *cert
+
+	chain, trusted, err := s.resolveChain(leaf)
+	for _, c := range chain {
+		details.Chain = append(details.Chain, certutil.NewChainCertificate(c))
+	}
+	details.ChainTrusted = trusted
+	if trusted {
+		details.TrustAnchor = trustAnchorMatch(chain[len(chain)-1], s.trustAnchors(config.TrustPurposeSigning))
+	}
+	if err != nil {
+		details.ChainError = err.Error()
+	}
+
+	return &details, nil
+}
+
+// resolveChain builds the chain of leaf up to a trust anchor. When no trusted chain exists it
+// returns the longest chain that could be assembled, false, and the verification error.
+// Chains are verified at the middle of the leaf validity period, since expiry is reported separately.
+func (s *SignatureService) resolveChain(leaf *x509.Certificate) ([]*x509.Certificate, bool, error) {
+	candidates := s.issuerCandidates()
+
+	roots := trustRoots(s.trustAnchors(config.TrustPurposeSigning))
+
+	chain, err := verifyChain(leaf, roots, candidates)
+	if err == nil {
+		return chain, true, nil
+	}
+
+	// Walk issuers one by one, downloading missing ones from the AIA URL
+	ctx := s.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	partial := []*x509.Certificate{leaf}
+	current := leaf
+	for len(partial) < maxChainLength && !certutil.IsSelfSigned(current) {
+		issuer, resolveErr := s.revocationChecker.ResolveIssuer(ctx, current, candidates)
+		if resolveErr != nil {
+			slog.Debug("issuer not found", "subject", current.Subject.String(), "error", resolveErr)
+			break
+		}
+		partial = append(partial, issuer)
+		candidates = append(candidates, issuer)
+		current = issuer
+	}
+
+	if chain, verifyErr := verifyChain(leaf, roots, candidates); verifyErr == nil {
+		return chain, true, nil
+	}
+
+	return partial, false, err
+}
+
+// verifyChain verifies leaf against roots using candidates as intermediates.
+func verifyChain(leaf *x509.Certificate, roots *x509.CertPool, candidates []*x509.Certificate) ([]*x509.Certificate, error) {
+	intermediates := x509.NewCertPool()
+	for _, c := range candidates {
+		intermediates.AddCert(c)
+	}
+
+	chains, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   leaf.NotBefore.Add(leaf.NotAfter.Sub(leaf.NotBefore) / 2),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return chains[0], nil
+}
+
+// issuerCandidates returns the parsed certificates of the inventory, for use as intermediates.
+func (s *SignatureService) issuerCandidates() []*x509.Certificate {
+	certs, err := s.cachedCertificates()
+	if err != nil {
+		return nil
+	}
+
+	var candidates []*x509.Certificate
+	for _, c := range certs {
+		if len(c.Raw) == 0 {
+			continue
+		}
+		parsed, err := x509.ParseCertificate(c.Raw)
+		if err == nil {
+			candidates = append(candidates, parsed)
+		}
+	}
+	return candidates
+}
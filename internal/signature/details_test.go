@@ -0,0 +1,116 @@
+package signature
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Matbe34/lankir/internal/signature/revocation"
+)
+
+// TestGetCertificateDetails tests decoded fields and chain resolution through the AIA URL
+func TestGetCertificateDetails(t *testing.T) {
+	now := time.Now()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate CA key: %v", err)
+	}
+	ca := CreateTestCertificateFromTemplate(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Details Test CA", Organization: []string{"Example"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(48 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, caKey)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(ca.Raw)
+	}))
+	defer server.Close()
+
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	policy, err := x509.OIDFromInts([]uint64{1, 3, 6, 1, 4, 1, 99999, 1})
+	if err != nil {
+		t.Fatalf("Failed to build policy OID: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(0xBEEF),
+		Subject:               pkix.Name{CommonName: "Erin Signer", Country: []string{"ES"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageContentCommitment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection},
+		EmailAddresses:        []string{"erin@example.com"},
+		Policies:              []x509.OID{policy},
+		CRLDistributionPoints: []string{server.URL + "/ca.crl"},
+		IssuingCertificateURL: []string{server.URL + "/ca.crt"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &leafKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	leaf, _ := x509.ParseCertificate(der)
+
+	storeDir := t.TempDir()
+	WriteTestCertificatePEM(t, storeDir, "leaf.pem", leaf)
+	service, _ := NewTestServiceWithStore(t, storeDir)
+	service.revocationChecker = revocation.NewChecker(t.TempDir(), nil)
+
+	details, err := service.GetCertificateDetails(CertificateFingerprint(leaf))
+	if err != nil {
+		t.Fatalf("GetCertificateDetails failed: %v", err)
+	}
+
+	sha1Sum := sha1.Sum(leaf.Raw)
+	if details.FingerprintSHA1 != hex.EncodeToString(sha1Sum[:]) {
+		t.Errorf("Unexpected SHA-1 fingerprint %s", details.FingerprintSHA1)
+	}
+	if details.FingerprintSHA256 != CertificateFingerprint(leaf) {
+		t.Errorf("Unexpected SHA-256 fingerprint %s", details.FingerprintSHA256)
+	}
+	if details.SubjectDN != "CN=Erin Signer,C=ES" || details.IssuerDN != "CN=Details Test CA,O=Example" {
+		t.Errorf("Unexpected DNs: %q / %q", details.SubjectDN, details.IssuerDN)
+	}
+	if details.SerialNumberHex != "BEEF" {
+		t.Errorf("Expected serial BEEF, got %s", details.SerialNumberHex)
+	}
+	if details.KeyAlgorithm != "ECDSA" || details.KeySize != 256 || details.KeyCurve != "P-256" {
+		t.Errorf("Unexpected key info: %s %d %s", details.KeyAlgorithm, details.KeySize, details.KeyCurve)
+	}
+	if len(details.SubjectAltNames.EmailAddresses) != 1 || details.SubjectAltNames.EmailAddresses[0] != "erin@example.com" {
+		t.Errorf("Unexpected SANs: %+v", details.SubjectAltNames)
+	}
+	if len(details.CertificatePolicies) != 1 || details.CertificatePolicies[0] != "1.3.6.1.4.1.99999.1" {
+		t.Errorf("Unexpected policies: %v", details.CertificatePolicies)
+	}
+	if len(details.ExtendedKeyUsage) != 1 || details.ExtendedKeyUsage[0] != "Email Protection" {
+		t.Errorf("Unexpected EKUs: %v", details.ExtendedKeyUsage)
+	}
+	if len(details.CRLDistributionPoints) != 1 || len(details.IssuingCertificateURL) != 1 {
+		t.Errorf("Expected CRL and AIA URLs, got %v / %v", details.CRLDistributionPoints, details.IssuingCertificateURL)
+	}
+
+	if len(details.Chain) != 2 || details.Chain[1].FingerprintSHA256 != CertificateFingerprint(ca) {
+		t.Fatalf("Expected chain leaf -> CA, got %+v", details.Chain)
+	}
+	if !details.Chain[1].SelfSigned {
+		t.Error("Expected the CA to be reported as self-signed")
+	}
+	if details.ChainTrusted || details.ChainError == "" {
+		t.Error("Expected a chain to an unknown CA to be reported as untrusted")
+	}
+}
@@ -0,0 +1,22 @@
+package signature
+
+import (
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// Events emitted to the frontend.
+const (
+	// EventCertificatesExpiring carries the []ExpiringCertificate found at startup.
+	EventCertificatesExpiring = "certificates:expiring"
+	// EventSigningCertificateExpiring carries the ExpiringCertificate used for a signature.
+	EventSigningCertificateExpiring = "signing:certificateExpiring"
+)
+
+// emitEvent sends an event to the frontend. It is a no-op outside the GUI, since the
+// Wails runtime aborts the process when the context carries no event emitter.
+func (s *SignatureService) emitEvent(name string, data ...interface{}) {
+	if s.ctx == nil || s.ctx.Value("events") == nil {
+		return
+	}
+	runtime.EventsEmit(s.ctx, name, data...)
+}
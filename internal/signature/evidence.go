@@ -0,0 +1,418 @@
+package signature
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/Matbe34/lankir/internal/config"
+	"github.com/Matbe34/lankir/internal/signature/certutil"
+	"github.com/Matbe34/lankir/internal/signature/revocation"
+	"github.com/Matbe34/lankir/internal/signature/types"
+	"github.com/digitorus/pdf"
+	pdfrevocation "github.com/digitorus/pdfsign/revocation"
+	"github.com/digitorus/pdfsign/verify"
+	"github.com/digitorus/pkcs7"
+	"golang.org/x/crypto/ocsp"
+)
+
+// Validation modes reported in types.VerificationReport
+const (
+	ValidationModeOnline  = "online"
+	ValidationModeOffline = "offline"
+)
+
+// Sources of the revocation evidence reported in types.RevocationEvidence
+const (
+	EvidenceSourceEmbedded = "embedded"
+	EvidenceSourceDSS      = "dss"
+	EvidenceSourceFile     = "file"
+	EvidenceSourceNetwork  = "network"
+	EvidenceSourceNone     = "none"
+)
+
+// Time sources set when a verification is requested as of a given time
+const (
+	TimeSourceValidationTime = "validation_time"
+	TimeSourceSignatureTime  = "signature_time"
+)
+
+// oidRevocationInfoArchival is the signed attribute holding revocation data embedded at signing
+var oidRevocationInfoArchival = asn1.ObjectIdentifier{1, 2, 840, 113583, 1, 1, 8}
+
+// VerifyOptions selects the time a verification is made at and the evidence it may use.
+type VerifyOptions struct {
+	// ValidationTime checks certificates and revocation status as of this time instead of the
+	// timestamp or the current time
+	ValidationTime time.Time `json:"validationTime"`
+	// AtSigningTime checks signatures without a timestamp as of the signing time they claim
+	AtSigningTime bool `json:"atSigningTime"`
+	// Online fetches missing issuer certificates and revocation data from the network. Without
+	// it, only the data embedded in the signatures, the DSS and the supplied files is used,
+	// unless the configured revocation policy is not off
+	Online bool `json:"online"`
+	// Offline never uses the network, whatever Online and the revocation policy say
+	Offline bool `json:"offline"`
+	// CertificateFiles supply issuer certificates, OCSPFiles and CRLFiles revocation data from
+	// local files
+	CertificateFiles []string `json:"certificateFiles"`
+	OCSPFiles        []string `json:"ocspFiles"`
+	CRLFiles         []string `json:"crlFiles"`
+}
+
+// revocationItem is an OCSP response or CRL available for validation, and where it came from.
+type revocationItem struct {
+	source   string
+	location string
+	ocsp     []byte
+	crl      *x509.RevocationList
+}
+
+// validationEvidence is the validation data available without the network: certificates to
+// complete chains with, and OCSP responses and CRLs.
+type validationEvidence struct {
+	certs []*x509.Certificate
+	items []revocationItem
+}
+
+// loadValidationFiles reads certificates, OCSP responses and CRLs supplied as files.
+func loadValidationFiles(certFiles, ocspFiles, crlFiles []string) (*validationEvidence, error) {
+	evidence := &validationEvidence{}
+
+	for _, path := range certFiles {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read certificate file: %w", err)
+		}
+		certs, err := certutil.ParseCertificates(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificates in %s: %w", path, err)
+		}
+		evidence.certs = append(evidence.certs, certs...)
+	}
+
+	for _, path := range ocspFiles {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read OCSP response: %w", err)
+		}
+		if _, err := ocsp.ParseResponse(data, nil); err != nil {
+			return nil, fmt.Errorf("failed to parse OCSP response %s: %w", path, err)
+		}
+		evidence.items = append(evidence.items, revocationItem{source: EvidenceSourceFile, location: path, ocsp: data})
+	}
+
+	for _, path := range crlFiles {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CRL: %w", err)
+		}
+		crl, err := revocation.ParseCRL(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse CRL %s: %w", path, err)
+		}
+		evidence.items = append(evidence.items, revocationItem{source: EvidenceSourceFile, location: path, crl: crl})
+	}
+
+	return evidence, nil
+}
+
+// readDSS returns the certificates, OCSP responses and CRLs of the document security store.
+// Entries that cannot be parsed are skipped.
+func readDSS(data []byte) (evidence *validationEvidence, err error) {
+	defer recoverPDFPanic(&err, "read DSS")
+
+	rdr, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read document: %w", err)
+	}
+
+	evidence = &validationEvidence{}
+	dss := rdr.Trailer().Key("Root").Key("DSS")
+	streams := func(key string) [][]byte {
+		var contents [][]byte
+		items := dss.Key(key)
+		for i := 0; i < items.Len(); i++ {
+			if content, err := io.ReadAll(items.Index(i).Reader()); err == nil {
+				contents = append(contents, content)
+			}
+		}
+		return contents
+	}
+
+	for _, content := range streams("Certs") {
+		if cert, err := x509.ParseCertificate(content); err == nil {
+			evidence.certs = append(evidence.certs, cert)
+		}
+	}
+	for _, content := range streams("OCSPs") {
+		evidence.items = append(evidence.items, revocationItem{source: EvidenceSourceDSS, ocsp: content})
+	}
+	for _, content := range streams("CRLs") {
+		if crl, err := x509.ParseRevocationList(content); err == nil {
+			evidence.items = append(evidence.items, revocationItem{source: EvidenceSourceDSS, crl: crl})
+		}
+	}
+
+	return evidence, nil
+}
+
+// embeddedRevocation returns the revocation data embedded in a signature when it was created.
+func embeddedRevocation(p7 *pkcs7.PKCS7) []revocationItem {
+	var archival pdfrevocation.InfoArchival
+	if err := p7.UnmarshalSignedAttribute(oidRevocationInfoArchival, &archival); err != nil {
+		return nil
+	}
+
+	var items []revocationItem
+	for _, raw := range archival.OCSP {
+		items = append(items, revocationItem{source: EvidenceSourceEmbedded, ocsp: raw.FullBytes})
+	}
+	for _, raw := range archival.CRL {
+		if crl, err := x509.ParseRevocationList(raw.FullBytes); err == nil {
+			items = append(items, revocationItem{source: EvidenceSourceEmbedded, crl: crl})
+		}
+	}
+	return items
+}
+
+// check returns the status of cert according to the item as of at, or nil if the item does not
+// cover cert or cannot be relied on. OCSP responses must be signed by the issuer or a responder
+// it authorized, and a good status must be current at that time: an OCSP response must have
+// been produced by then and a CRL must not have expired. A revocation counts whenever it was
+// published, since it is never undone.
+func (item revocationItem) check(cert, issuer *x509.Certificate, at time.Time) *revocation.Result {
+	if item.ocsp != nil {
+		resp, err := ocsp.ParseResponseForCert(item.ocsp, cert, issuer)
+		if err != nil || resp.Status == ocsp.Unknown || revocation.CheckOCSPResponder(resp, issuer) != nil {
+			return nil
+		}
+		if resp.Status == ocsp.Good && revocation.CheckOCSPFreshness(resp, at) != nil {
+			return nil
+		}
+		result := &revocation.Result{
+			Status:     revocation.StatusGood,
+			Method:     revocation.MethodOCSP,
+			URL:        item.location,
+			ThisUpdate: resp.ThisUpdate,
+			NextUpdate: resp.NextUpdate,
+			Raw:        item.ocsp,
+		}
+		if resp.Status == ocsp.Revoked {
+			result.Status = revocation.StatusRevoked
+			result.RevokedAt = resp.RevokedAt
+			result.RevocationReason = resp.RevocationReason
+		}
+		return result
+	}
+
+	if item.crl == nil || !bytes.Equal(item.crl.RawIssuer, cert.RawIssuer) || item.crl.CheckSignatureFrom(issuer) != nil {
+		return nil
+	}
+	result := &revocation.Result{
+		Status:     revocation.StatusGood,
+		Method:     revocation.MethodCRL,
+		URL:        item.location,
+		ThisUpdate: item.crl.ThisUpdate,
+		NextUpdate: item.crl.NextUpdate,
+		Raw:        item.crl.Raw,
+	}
+	for _, entry := range item.crl.RevokedCertificateEntries {
+		if entry.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+			result.Status = revocation.StatusRevoked
+			result.RevokedAt = entry.RevocationTime
+			result.RevocationReason = entry.ReasonCode
+			break
+		}
+	}
+	if result.Status == revocation.StatusGood && !item.crl.NextUpdate.IsZero() && !at.Before(item.crl.NextUpdate) {
+		return nil
+	}
+	return result
+}
+
+// evidenceSourceRank orders evidence of the same status: data signed into the signature first,
+// then files the user supplied, then the DSS, which anyone can append to.
+var evidenceSourceRank = map[string]int{
+	EvidenceSourceEmbedded: 0,
+	EvidenceSourceFile:     1,
+	EvidenceSourceDSS:      2,
+}
+
+// findRevocation returns the item that best establishes the status of cert as of at: a
+// revocation over a good status, and otherwise the most trustworthy source.
+func findRevocation(items []revocationItem, cert, issuer *x509.Certificate, at time.Time) (*revocationItem, *revocation.Result) {
+	var best *revocationItem
+	var bestResult *revocation.Result
+	for i := range items {
+		result := items[i].check(cert, issuer, at)
+		if result == nil {
+			continue
+		}
+
+		revoked, bestRevoked := result.Status == revocation.StatusRevoked, bestResult != nil && bestResult.Status == revocation.StatusRevoked
+		switch {
+		case best == nil,
+			revoked && !bestRevoked,
+			revoked == bestRevoked && evidenceSourceRank[items[i].source] < evidenceSourceRank[best.source]:
+			best, bestResult = &items[i], result
+		}
+	}
+	return best, bestResult
+}
+
+// fetchRevocation queries the OCSP responders and CRL distribution points of cert. CRLs are
+// returned DER encoded, whatever encoding the distribution point served.
+func (s *SignatureService) fetchRevocation(ctx context.Context, cert, issuer *x509.Certificate) (*revocation.Result, error) {
+	result, err := s.revocationChecker.Check(ctx, cert, issuer)
+	if err != nil {
+		return nil, err
+	}
+	if result.Method == revocation.MethodCRL {
+		crl, err := revocation.ParseCRL(result.Raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CRL: %w", err)
+		}
+		result.Raw = crl.Raw
+	}
+	return result, nil
+}
+
+// issuerIn returns the certificate among candidates that issued cert, or nil.
+func issuerIn(cert *x509.Certificate, candidates []*x509.Certificate) *x509.Certificate {
+	for _, candidate := range candidates {
+		if !candidate.Equal(cert) && cert.CheckSignatureFrom(candidate) == nil {
+			return candidate
+		}
+	}
+	return nil
+}
+
+// validationTime returns the time a signature is checked at, and whether it was requested
+// through the options rather than taken from the verifier.
+func validationTime(signer verify.Signer, opts VerifyOptions) (time.Time, string, bool) {
+	switch {
+	case !opts.ValidationTime.IsZero():
+		return opts.ValidationTime, TimeSourceValidationTime, true
+	case opts.AtSigningTime && signer.TimeStamp == nil && signer.SignatureTime != nil:
+		return *signer.SignatureTime, TimeSourceSignatureTime, true
+	case signer.VerificationTime != nil:
+		return *signer.VerificationTime, signer.TimeSource, false
+	}
+	return time.Now(), signer.TimeSource, false
+}
+
+// fetchesValidationData reports whether a verification may look up issuer certificates and
+// revocation data on the network: when asked to, or when the revocation policy is not off.
+func (s *SignatureService) fetchesValidationData(opts VerifyOptions) bool {
+	if opts.Offline {
+		return false
+	}
+	if opts.Online {
+		return true
+	}
+	policy, err := s.revocationPolicy(nil)
+	return err == nil && policy != revocation.PolicyOff
+}
+
+// checkRevocationEvidence determines the revocation status of every certificate in the chain
+// of a signer as of the validation time. Evidence is taken from the signature itself, the
+// supplied files and the DSS and, when fetching is enabled and none of them covers a
+// certificate, the network. Revocations found are recorded on the signer so they show in its
+// status.
+func (s *SignatureService) checkRevocationEvidence(signer *verify.Signer, dict *signatureDictionary, evidence *validationEvidence, opts VerifyOptions, at time.Time) []types.RevocationEvidence {
+	ctx := s.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	var chain []*x509.Certificate
+	for _, c := range signer.Certificates {
+		if c.Certificate != nil {
+			chain = append(chain, c.Certificate)
+		}
+	}
+	candidates := append(append([]*x509.Certificate{}, chain...), evidence.certs...)
+	for _, anchor := range s.trustAnchors(config.TrustPurposeSigning) {
+		candidates = append(candidates, anchor.cert)
+	}
+
+	var items []revocationItem
+	if dict != nil {
+		items = embeddedRevocation(dict.p7)
+	}
+	items = append(items, evidence.items...)
+
+	fetch := s.fetchesValidationData(opts)
+	results := []types.RevocationEvidence{}
+	for i := range signer.Certificates {
+		c := &signer.Certificates[i]
+		if c.Certificate == nil || certutil.IsSelfSigned(c.Certificate) {
+			continue
+		}
+
+		cert := c.Certificate
+		entry := types.RevocationEvidence{
+			Certificate: cert.Subject.CommonName,
+			Source:      EvidenceSourceNone,
+			Status:      string(revocation.StatusUnknown),
+		}
+		if entry.Certificate == "" {
+			entry.Certificate = cert.Subject.String()
+		}
+
+		issuer := issuerIn(cert, candidates)
+		if issuer == nil && fetch {
+			issuer, _ = s.revocationChecker.ResolveIssuer(ctx, cert, candidates)
+		}
+
+		var result *revocation.Result
+		switch {
+		case issuer == nil:
+			entry.Message = "issuer certificate not available"
+		default:
+			var item *revocationItem
+			if item, result = findRevocation(items, cert, issuer, at); item != nil {
+				entry.Source = item.source
+				entry.Location = item.location
+			} else if !fetch {
+				entry.Message = "no usable revocation data in the signature, the DSS or the supplied files"
+			} else if fetched, err := s.fetchRevocation(ctx, cert, issuer); err != nil {
+				entry.Message = err.Error()
+			} else {
+				result = fetched
+				entry.Source = EvidenceSourceNetwork
+				entry.Location = fetched.URL
+			}
+		}
+
+		if result != nil {
+			entry.Method = result.Method
+			entry.Status = string(result.Status)
+			entry.ThisUpdate = &result.ThisUpdate
+			if !result.NextUpdate.IsZero() {
+				entry.NextUpdate = &result.NextUpdate
+			}
+			if result.Status == revocation.StatusRevoked {
+				revokedAt := result.RevokedAt
+				entry.RevokedAt = &revokedAt
+				if revokedAt.After(at) {
+					entry.Status = string(revocation.StatusGood)
+					entry.Message = fmt.Sprintf("revoked on %s, after the validation time", revokedAt.Format("2006-01-02 15:04:05"))
+				} else {
+					signer.RevokedCertificate = true
+					c.RevocationTime = &revokedAt
+				}
+			}
+		}
+
+		results = append(results, entry)
+	}
+
+	return results
+}
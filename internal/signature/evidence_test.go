@@ -0,0 +1,386 @@
+package signature
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Matbe34/lankir/internal/signature/revocation"
+	"github.com/digitorus/pdfsign/verify"
+	"golang.org/x/crypto/ocsp"
+)
+
+// TestVerifySignaturesOffline tests that offline verification reports where the revocation data
+// of each certificate came from
+func TestVerifySignaturesOffline(t *testing.T) {
+	storeDir := t.TempDir()
+	service, _ := NewTestServiceWithStore(t, storeDir)
+	service.revocationChecker = revocation.NewChecker(t.TempDir(), nil)
+
+	signedPath, caPath, crlPath := signWithIssuedCertificate(t, service, storeDir)
+
+	extended, err := service.ExtendSignatures(signedPath, ExtendOptions{
+		CertificateFiles: []string{caPath},
+		CRLFiles:         []string{crlPath},
+		Offline:          true,
+	})
+	if err != nil {
+		t.Fatalf("ExtendSignatures failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		opts     VerifyOptions
+		source   string
+		location string
+		status   string
+	}{
+		{"no evidence", signedPath, VerifyOptions{Offline: true}, EvidenceSourceNone, "", string(revocation.StatusUnknown)},
+		{"supplied CRL", signedPath, VerifyOptions{Offline: true, CertificateFiles: []string{caPath}, CRLFiles: []string{crlPath}}, EvidenceSourceFile, crlPath, string(revocation.StatusGood)},
+		{"DSS", extended.OutputPath, VerifyOptions{Offline: true}, EvidenceSourceDSS, "", string(revocation.StatusGood)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := service.GetVerificationReportWithOptions(tt.path, tt.opts)
+			if err != nil {
+				t.Fatalf("GetVerificationReportWithOptions failed: %v", err)
+			}
+			if report.ValidationMode != ValidationModeOffline {
+				t.Errorf("Expected offline mode, got %s", report.ValidationMode)
+			}
+			if len(report.Signatures) != 1 {
+				t.Fatalf("Expected 1 signature, got %d", len(report.Signatures))
+			}
+
+			summary := report.Signatures[0].Summary
+			if !summary.IsValid {
+				t.Errorf("Expected a valid signature: %s", summary.ValidationMessage)
+			}
+			if len(summary.Evidence) != 1 {
+				t.Fatalf("Expected evidence for the signer certificate, got %+v", summary.Evidence)
+			}
+			evidence := summary.Evidence[0]
+			if evidence.Certificate != "Issued Signer" || evidence.Source != tt.source ||
+				evidence.Location != tt.location || evidence.Status != tt.status {
+				t.Errorf("Unexpected evidence %+v", evidence)
+			}
+		})
+	}
+}
+
+// TestVerifySignatures_ValidationTime tests that certificates are checked as of the requested time
+func TestVerifySignatures_ValidationTime(t *testing.T) {
+	storeDir := t.TempDir()
+	service, _ := NewTestServiceWithStore(t, storeDir)
+	service.revocationChecker = revocation.NewChecker(t.TempDir(), nil)
+
+	signedPath, _, crlPath := signWithIssuedCertificate(t, service, storeDir)
+
+	at := time.Now().Add(72 * time.Hour).Truncate(time.Second)
+	report, err := service.GetVerificationReportWithOptions(signedPath, VerifyOptions{
+		ValidationTime: at,
+		Offline:        true,
+		CRLFiles:       []string{crlPath},
+	})
+	if err != nil {
+		t.Fatalf("GetVerificationReportWithOptions failed: %v", err)
+	}
+
+	if report.ValidationTime == nil || !report.ValidationTime.Equal(at) {
+		t.Errorf("Expected validation time %s, got %v", at, report.ValidationTime)
+	}
+	sig := report.Signatures[0]
+	if sig.TimeSource != TimeSourceValidationTime {
+		t.Errorf("Expected time source %s, got %s", TimeSourceValidationTime, sig.TimeSource)
+	}
+	if sig.Summary.ValidationTime != at.Format(time.RFC3339) {
+		t.Errorf("Unexpected summary validation time %s", sig.Summary.ValidationTime)
+	}
+	if sig.Summary.CertificateValid {
+		t.Error("Expected the certificate to be invalid after it expired")
+	}
+	if len(sig.Chain) == 0 || sig.Chain[0].Status != CertificateStatusInvalid ||
+		!strings.Contains(strings.Join(sig.Chain[0].Errors, "; "), "expired") {
+		t.Errorf("Expected an expired signer certificate, got %+v", sig.Chain)
+	}
+}
+
+// TestCheckRevocationEvidence tests that a revocation only counts from its revocation time on
+func TestCheckRevocationEvidence(t *testing.T) {
+	service, _ := NewTestServiceWithStore(t, t.TempDir())
+
+	now := time.Now()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	ca := CreateTestCertificateFromTemplate(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             now.Add(-24 * time.Hour),
+		NotAfter:              now.Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, caKey)
+
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(7),
+		Subject:      pkix.Name{CommonName: "Revoked Signer"},
+		NotBefore:    now.Add(-24 * time.Hour),
+		NotAfter:     now.Add(24 * time.Hour),
+	}, ca, &leafKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	leaf, _ := x509.ParseCertificate(der)
+
+	revokedAt := now.Add(-time.Hour).Truncate(time.Second)
+	crlDER, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: now.Add(-time.Minute),
+		NextUpdate: now.Add(time.Hour),
+		RevokedCertificateEntries: []x509.RevocationListEntry{
+			{SerialNumber: leaf.SerialNumber, RevocationTime: revokedAt},
+		},
+	}, ca, caKey)
+	if err != nil {
+		t.Fatalf("Failed to create CRL: %v", err)
+	}
+	crl, _ := x509.ParseRevocationList(crlDER)
+
+	evidence := &validationEvidence{
+		certs: []*x509.Certificate{ca},
+		items: []revocationItem{{source: EvidenceSourceFile, location: "ca.crl", crl: crl}},
+	}
+	opts := VerifyOptions{Offline: true}
+
+	tests := []struct {
+		name    string
+		at      time.Time
+		revoked bool
+	}{
+		{"before revocation", revokedAt.Add(-time.Minute), false},
+		{"after revocation", revokedAt.Add(time.Minute), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer := verify.Signer{Certificates: []verify.Certificate{{Certificate: leaf}}}
+			results := service.checkRevocationEvidence(&signer, nil, evidence, opts, tt.at)
+			if len(results) != 1 {
+				t.Fatalf("Expected 1 evidence entry, got %+v", results)
+			}
+			if results[0].RevokedAt == nil || !results[0].RevokedAt.Equal(revokedAt) {
+				t.Errorf("Expected revocation time %s, got %v", revokedAt, results[0].RevokedAt)
+			}
+			if signer.RevokedCertificate != tt.revoked {
+				t.Errorf("Expected revoked %v, got %+v", tt.revoked, results[0])
+			}
+			expected := string(revocation.StatusGood)
+			if tt.revoked {
+				expected = string(revocation.StatusRevoked)
+			}
+			if results[0].Status != expected {
+				t.Errorf("Expected status %s, got %s", expected, results[0].Status)
+			}
+		})
+	}
+}
+
+// testOCSPResponse creates an OCSP response for leaf signed by responder
+func testOCSPResponse(t *testing.T, issuer, responder *x509.Certificate, key crypto.Signer, leaf *x509.Certificate, status int, thisUpdate, nextUpdate time.Time) []byte {
+	t.Helper()
+
+	template := ocsp.Response{
+		Status:       status,
+		SerialNumber: leaf.SerialNumber,
+		ThisUpdate:   thisUpdate,
+		NextUpdate:   nextUpdate,
+	}
+	if status == ocsp.Revoked {
+		template.RevokedAt = thisUpdate.Add(-time.Hour)
+	}
+	if !responder.Equal(issuer) {
+		template.Certificate = responder
+	}
+	resp, err := ocsp.CreateResponse(issuer, responder, template, key)
+	if err != nil {
+		t.Fatalf("Failed to create OCSP response: %v", err)
+	}
+	return resp
+}
+
+// TestFindRevocation tests that OCSP responses need an authorized responder and a good status
+// must be current, that revocations win over good statuses and that signed evidence wins over
+// the DSS
+func TestFindRevocation(t *testing.T) {
+	now := time.Now()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	ca := CreateTestCertificateFromTemplate(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             now.Add(-48 * time.Hour),
+		NotAfter:              now.Add(48 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, caKey)
+
+	issue := func(serial int64, extKeyUsage []x509.ExtKeyUsage) (*x509.Certificate, crypto.Signer) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("Failed to generate key: %v", err)
+		}
+		der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: fmt.Sprintf("Certificate %d", serial)},
+			NotBefore:    now.Add(-48 * time.Hour),
+			NotAfter:     now.Add(48 * time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  extKeyUsage,
+		}, ca, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatalf("Failed to create certificate: %v", err)
+		}
+		cert, _ := x509.ParseCertificate(der)
+		return cert, key
+	}
+	leaf, leafKey := issue(10, nil)
+	responder, responderKey := issue(11, []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning})
+
+	current := func(status int) []byte {
+		return testOCSPResponse(t, ca, ca, caKey, leaf, status, now.Add(-time.Minute), now.Add(time.Hour))
+	}
+	item := func(source string, resp []byte) revocationItem {
+		return revocationItem{source: source, ocsp: resp}
+	}
+
+	tests := []struct {
+		name   string
+		items  []revocationItem
+		source string
+		status revocation.Status
+	}{
+		{"issuer signed", []revocationItem{item(EvidenceSourceDSS, current(ocsp.Good))}, EvidenceSourceDSS, revocation.StatusGood},
+		{"authorized responder", []revocationItem{item(EvidenceSourceDSS, testOCSPResponse(t, ca, responder, responderKey, leaf, ocsp.Good, now.Add(-time.Minute), now.Add(time.Hour)))}, EvidenceSourceDSS, revocation.StatusGood},
+		{"signed by the certificate itself", []revocationItem{item(EvidenceSourceDSS, testOCSPResponse(t, ca, leaf, leafKey, leaf, ocsp.Good, now.Add(-time.Minute), now.Add(time.Hour)))}, "", ""},
+		{"expired", []revocationItem{item(EvidenceSourceDSS, testOCSPResponse(t, ca, ca, caKey, leaf, ocsp.Good, now.Add(-3*time.Hour), now.Add(-2*time.Hour)))}, "", ""},
+		{"produced later", []revocationItem{item(EvidenceSourceDSS, testOCSPResponse(t, ca, ca, caKey, leaf, ocsp.Good, now.Add(time.Hour), now.Add(2*time.Hour)))}, "", ""},
+		{"revocation over good", []revocationItem{item(EvidenceSourceEmbedded, current(ocsp.Good)), item(EvidenceSourceDSS, current(ocsp.Revoked))}, EvidenceSourceDSS, revocation.StatusRevoked},
+		{"embedded over DSS", []revocationItem{item(EvidenceSourceDSS, current(ocsp.Good)), item(EvidenceSourceEmbedded, current(ocsp.Good))}, EvidenceSourceEmbedded, revocation.StatusGood},
+		{"file over DSS", []revocationItem{item(EvidenceSourceDSS, current(ocsp.Good)), item(EvidenceSourceFile, current(ocsp.Good))}, EvidenceSourceFile, revocation.StatusGood},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found, result := findRevocation(tt.items, leaf, ca, now)
+			if tt.source == "" {
+				if found != nil {
+					t.Fatalf("Expected no usable evidence, got %s from %s", result.Status, found.source)
+				}
+				return
+			}
+			if found == nil {
+				t.Fatal("Expected usable evidence, got none")
+			}
+			if found.source != tt.source || result.Status != tt.status {
+				t.Errorf("Expected %s from %s, got %s from %s", tt.status, tt.source, result.Status, found.source)
+			}
+		})
+	}
+}
+
+// TestVerifySignatures_NetworkOptIn tests that verification only fetches validation data when
+// asked to or when the revocation policy is on
+func TestVerifySignatures_NetworkOptIn(t *testing.T) {
+	var hits int32
+	var ca *x509.Certificate
+	var crl []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		if r.URL.Path == "/ca.crt" {
+			w.Write(ca.Raw)
+			return
+		}
+		w.Write(crl)
+	}))
+	defer server.Close()
+
+	storeDir := t.TempDir()
+	service, cfgService := NewTestServiceWithStore(t, storeDir)
+	service.revocationChecker = revocation.NewChecker(t.TempDir(), nil)
+
+	ca, leaf, key, crl := createTestCAWithURLs(t, server.URL+"/ca.crl", server.URL+"/ca.crt")
+	WriteTestPKCS12(t, storeDir, "issued.p12", leaf, key)
+	pdfPath := filepath.Join(t.TempDir(), "doc.pdf")
+	CreateTestPDF(t, pdfPath)
+	signedPath, err := service.SignPDF(pdfPath, CertificateFingerprint(leaf), "")
+	if err != nil {
+		t.Fatalf("SignPDF failed: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		policy revocation.Policy
+		opts   VerifyOptions
+		fetch  bool
+	}{
+		{"default", revocation.PolicyOff, VerifyOptions{}, false},
+		{"online", revocation.PolicyOff, VerifyOptions{Online: true}, true},
+		{"revocation policy on", revocation.PolicySoftFail, VerifyOptions{}, true},
+		{"offline with revocation policy on", revocation.PolicySoftFail, VerifyOptions{Offline: true}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := cfgService.Get()
+			cfg.RevocationPolicy = string(tt.policy)
+			if err := cfgService.Update(cfg); err != nil {
+				t.Fatalf("Update failed: %v", err)
+			}
+			service.revocationChecker = revocation.NewChecker(t.TempDir(), nil)
+			atomic.StoreInt32(&hits, 0)
+
+			report, err := service.GetVerificationReportWithOptions(signedPath, tt.opts)
+			if err != nil {
+				t.Fatalf("GetVerificationReportWithOptions failed: %v", err)
+			}
+
+			fetched := atomic.LoadInt32(&hits) > 0
+			if fetched != tt.fetch {
+				t.Errorf("Expected network use %v, got %d request(s)", tt.fetch, hits)
+			}
+			mode := ValidationModeOffline
+			if tt.fetch {
+				mode = ValidationModeOnline
+			}
+			if report.ValidationMode != mode {
+				t.Errorf("Expected validation mode %s, got %s", mode, report.ValidationMode)
+			}
+			evidence := report.Signatures[0].Summary.Evidence
+			if tt.fetch && (len(evidence) != 1 || evidence[0].Source != EvidenceSourceNetwork) {
+				t.Errorf("Expected evidence from the network, got %+v", evidence)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package signature
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/Matbe34/lankir/internal/signature/types"
+)
+
+// DefaultExpiryWarningDays is used when the config does not set an expiry warning threshold.
+const DefaultExpiryWarningDays = 30
+
+// ExpiringCertificate is a certificate that has expired or expires within the warning threshold.
+type ExpiringCertificate struct {
+	Certificate   types.Certificate `json:"certificate"`
+	ExpiresAt     time.Time         `json:"expiresAt"`
+	DaysRemaining int               `json:"daysRemaining"`
+	Expired       bool              `json:"expired"`
+}
+
+// expiryWarningDays returns the configured expiry warning threshold in days.
+func (s *SignatureService) expiryWarningDays() int {
+	if s.configService != nil {
+		if days := s.configService.Get().ExpiryWarningDays; days > 0 {
+			return days
+		}
+	}
+	return DefaultExpiryWarningDays
+}
+
+// GetExpiringCertificates returns certificates that have expired or expire within the given
+// number of days, soonest first. A non-positive value uses the configured warning threshold.
+func (s *SignatureService) GetExpiringCertificates(withinDays int) ([]ExpiringCertificate, error) {
+	if withinDays <= 0 {
+		withinDays = s.expiryWarningDays()
+	}
+
+	certs, err := s.cachedCertificates()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	expiring := []ExpiringCertificate{}
+	for _, cert := range certs {
+		entry, ok := checkExpiry(cert, now, withinDays)
+		if ok {
+			expiring = append(expiring, entry)
+		}
+	}
+
+	sort.SliceStable(expiring, func(i, j int) bool {
+		return expiring[i].ExpiresAt.Before(expiring[j].ExpiresAt)
+	})
+
+	return expiring, nil
+}
+
+// CheckCertificateExpiry returns expiry details for a certificate if it has expired or
+// expires within the configured warning threshold, and nil otherwise.
+func (s *SignatureService) CheckCertificateExpiry(fingerprint string) (*ExpiringCertificate, error) {
+	cert, err := s.GetCertificateByFingerprint(fingerprint)
+	if err != nil {
+		return nil, err
+	}
+
+	entry, ok := checkExpiry(*cert, time.Now(), s.expiryWarningDays())
+	if !ok {
+		return nil, nil
+	}
+	return &entry, nil
+}
+
+// warnIfExpiring logs and notifies the frontend when the signing certificate is close to expiry.
+func (s *SignatureService) warnIfExpiring(cert *types.Certificate) {
+	entry, ok := checkExpiry(*cert, time.Now(), s.expiryWarningDays())
+	if !ok || entry.Expired {
+		return
+	}
+
+	slog.Warn("signing certificate expires soon",
+		"certificate", cert.Name,
+		"expires", cert.ValidTo,
+		"days_remaining", entry.DaysRemaining)
+
+	s.emitEvent(EventSigningCertificateExpiring, entry)
+}
+
+// checkExpiry reports whether cert has expired or expires within withinDays of now.
+func checkExpiry(cert types.Certificate, now time.Time, withinDays int) (ExpiringCertificate, bool) {
+	expiresAt, ok := certificateExpiry(cert)
+	if !ok {
+		return ExpiringCertificate{}, false
+	}
+
+	remaining := expiresAt.Sub(now)
+	if remaining > time.Duration(withinDays)*24*time.Hour {
+		return ExpiringCertificate{}, false
+	}
+
+	return ExpiringCertificate{
+		Certificate:   cert,
+		ExpiresAt:     expiresAt,
+		DaysRemaining: int(remaining / (24 * time.Hour)),
+		Expired:       remaining <= 0,
+	}, true
+}
+
+// certificateExpiry parses the certificate's ValidTo time, which is recorded in UTC.
+func certificateExpiry(cert types.Certificate) (time.Time, bool) {
+	if cert.ValidTo == "" {
+		return time.Time{}, false
+	}
+
+	expiresAt, err := time.Parse("2006-01-02 15:04:05", cert.ValidTo)
+	if err != nil {
+		slog.Debug("failed to parse certificate expiry", "certificate", cert.Name, "validTo", cert.ValidTo, "error", err)
+		return time.Time{}, false
+	}
+	return expiresAt, true
+}
+
+// FormatExpiry describes how long until a certificate expires, or since it expired.
+func (e ExpiringCertificate) FormatExpiry() string {
+	switch {
+	case e.Expired:
+		return fmt.Sprintf("expired on %s", e.ExpiresAt.Format("2006-01-02"))
+	case e.DaysRemaining == 0:
+		return fmt.Sprintf("expires today (%s)", e.ExpiresAt.Format("2006-01-02 15:04"))
+	case e.DaysRemaining == 1:
+		return fmt.Sprintf("expires in 1 day (%s)", e.ExpiresAt.Format("2006-01-02"))
+	default:
+		return fmt.Sprintf("expires in %d days (%s)", e.DaysRemaining, e.ExpiresAt.Format("2006-01-02"))
+	}
+}
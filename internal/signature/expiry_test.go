@@ -0,0 +1,60 @@
+package signature
+
+import (
+	"testing"
+	"time"
+)
+
+// TestGetExpiringCertificates tests the expiry window, ordering and expired certificates
+func TestGetExpiringCertificates(t *testing.T) {
+	storeDir := t.TempDir()
+	service, cfgService := NewTestServiceWithStore(t, storeDir)
+
+	now := time.Now()
+	soon, _ := CreateTestCertificate(t, "Soon", now.Add(-time.Hour), now.Add(10*24*time.Hour))
+	later, _ := CreateTestCertificate(t, "Later", now.Add(-time.Hour), now.Add(60*24*time.Hour))
+	expired, _ := CreateTestCertificate(t, "Expired", now.Add(-48*time.Hour), now.Add(-24*time.Hour))
+	WriteTestCertificatePEM(t, storeDir, "soon.pem", soon)
+	WriteTestCertificatePEM(t, storeDir, "later.pem", later)
+	WriteTestCertificatePEM(t, storeDir, "expired.pem", expired)
+
+	expiring, err := service.GetExpiringCertificates(30)
+	if err != nil {
+		t.Fatalf("GetExpiringCertificates failed: %v", err)
+	}
+
+	var order []string
+	for _, entry := range expiring {
+		switch entry.Certificate.Fingerprint {
+		case CertificateFingerprint(soon):
+			order = append(order, "soon")
+			if entry.Expired || entry.DaysRemaining < 9 || entry.DaysRemaining > 10 {
+				t.Errorf("Unexpected expiry for soon certificate: %+v", entry)
+			}
+		case CertificateFingerprint(expired):
+			order = append(order, "expired")
+			if !entry.Expired {
+				t.Error("Expired certificate not marked as expired")
+			}
+		case CertificateFingerprint(later):
+			t.Error("Certificate expiring in 60 days reported within 30 days")
+		}
+	}
+	if len(order) != 2 || order[0] != "expired" || order[1] != "soon" {
+		t.Errorf("Expected [expired soon], got %v", order)
+	}
+
+	cfg := cfgService.Get()
+	cfg.ExpiryWarningDays = 90
+	if err := cfgService.Update(cfg); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	entry, err := service.CheckCertificateExpiry(CertificateFingerprint(later))
+	if err != nil {
+		t.Fatalf("CheckCertificateExpiry failed: %v", err)
+	}
+	if entry == nil {
+		t.Error("Expected configured 90-day threshold to include the 60-day certificate")
+	}
+}
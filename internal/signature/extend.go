@@ -0,0 +1,380 @@
+package signature
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Matbe34/lankir/internal/signature/certutil"
+	"github.com/Matbe34/lankir/internal/signature/revocation"
+	"github.com/Matbe34/lankir/internal/signature/types"
+	"github.com/digitorus/pdf"
+	"golang.org/x/crypto/ocsp"
+)
+
+// ExtendOptions selects the validation data ExtendSignatures adds to a document.
+type ExtendOptions struct {
+	// OutputPath is the extended document; empty writes <name>_ltv.pdf next to the input
+	OutputPath string `json:"outputPath"`
+	// CertificateFiles, OCSPFiles and CRLFiles supply validation data from local files
+	CertificateFiles []string `json:"certificateFiles"`
+	OCSPFiles        []string `json:"ocspFiles"`
+	CRLFiles         []string `json:"crlFiles"`
+	// Offline disables fetching issuers, OCSP responses and CRLs from the network
+	Offline bool `json:"offline"`
+	// TimestampURL appends a document timestamp from this TSA when set
+	TimestampURL string `json:"timestampURL"`
+}
+
+// signatureEvidence is the validation data collected for one signature.
+type signatureEvidence struct {
+	certs    []*x509.Certificate
+	ocsps    [][]byte
+	crls     [][]byte
+	warnings []string
+}
+
+// ExtendSignatures makes the signatures of a PDF verifiable in the long term (LTV). It collects
+// the certificate chain and OCSP responses or CRLs of every signature, records them in the
+// document security store (DSS) with one VRI entry per signature, and optionally appends a
+// document timestamp. Everything is added as incremental updates, so the existing signatures
+// stay valid.
+func (s *SignatureService) ExtendSignatures(pdfPath string, opts ExtendOptions) (*types.ExtendResult, error) {
+	data, err := os.ReadFile(pdfPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open PDF: %w", err)
+	}
+
+	dictionaries, err := readSignatureDictionaries(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	if len(dictionaries) == 0 {
+		return nil, fmt.Errorf("no signatures found in PDF")
+	}
+
+	evidence, err := loadValidationFiles(opts.CertificateFiles, opts.OCSPFiles, opts.CRLFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &types.ExtendResult{OutputPath: opts.OutputPath}
+	if result.OutputPath == "" {
+		result.OutputPath = generateExtendedPDFPath(pdfPath)
+	}
+
+	collected := make([]signatureEvidence, len(dictionaries))
+	for i, dict := range dictionaries {
+		collected[i] = s.collectSignatureEvidence(dict, evidence, opts.Offline)
+
+		extended := types.ExtendedSignature{
+			Index:         i + 1,
+			FieldName:     dict.fieldName,
+			VRIKey:        vriKey(dict.contents),
+			Certificates:  len(collected[i].certs),
+			OCSPResponses: len(collected[i].ocsps),
+			CRLs:          len(collected[i].crls),
+			Warnings:      collected[i].warnings,
+		}
+		if signer := dict.p7.GetOnlySigner(); signer != nil {
+			extended.SignerName = signer.Subject.CommonName
+		}
+		result.Signatures = append(result.Signatures, extended)
+	}
+
+	updated, err := addValidationData(data, dictionaries, collected, result)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(result.OutputPath, updated, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write extended PDF: %w", err)
+	}
+
+	if opts.TimestampURL != "" {
+		if err := writeDocumentTimestamp(result.OutputPath, result.OutputPath, s.timestampAuthority(opts.TimestampURL)); err != nil {
+			return nil, err
+		}
+		result.DocumentTimestamp = true
+	}
+
+	return result, nil
+}
+
+// generateExtendedPDFPath returns the default output path of ExtendSignatures.
+func generateExtendedPDFPath(pdfPath string) string {
+	ext := filepath.Ext(pdfPath)
+	return strings.TrimSuffix(pdfPath, ext) + "_ltv" + ext
+}
+
+// collectSignatureEvidence builds the chain of a signer certificate and finds revocation data for
+// every certificate in it, from the supplied files, the data embedded in the signature or, unless
+// offline, the network.
+func (s *SignatureService) collectSignatureEvidence(dict signatureDictionary, supplied *validationEvidence, offline bool) signatureEvidence {
+	var collected signatureEvidence
+
+	signer := dict.p7.GetOnlySigner()
+	if signer == nil {
+		collected.warnings = append(collected.warnings, "signature has no single signer certificate")
+		return collected
+	}
+
+	candidates := append(append([]*x509.Certificate{}, dict.p7.Certificates...), supplied.certs...)
+	items := append(append([]revocationItem{}, supplied.items...), embeddedRevocation(dict.p7)...)
+
+	ctx := s.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	cert := signer
+	for range maxChainLength {
+		collected.certs = append(collected.certs, cert)
+		if certutil.IsSelfSigned(cert) {
+			break
+		}
+
+		name := cert.Subject.CommonName
+		issuer := s.findIssuer(ctx, cert, candidates, offline)
+		if issuer == nil {
+			collected.warnings = append(collected.warnings, fmt.Sprintf("issuer of certificate '%s' not found", name))
+			break
+		}
+
+		_, result := findRevocation(items, cert, issuer, time.Now())
+		if result == nil {
+			if offline {
+				collected.warnings = append(collected.warnings, fmt.Sprintf("no revocation data for certificate '%s'", name))
+			} else if fetched, err := s.fetchRevocation(ctx, cert, issuer); err != nil {
+				collected.warnings = append(collected.warnings, fmt.Sprintf("no revocation data for certificate '%s': %v", name, err))
+			} else {
+				result = fetched
+			}
+		}
+		if result != nil {
+			collected.addRevocation(cert, issuer, result)
+		}
+
+		cert = issuer
+	}
+
+	return collected
+}
+
+// findIssuer looks up the issuer of cert among candidates and, unless offline, through the
+// Authority Information Access URLs of the certificate.
+func (s *SignatureService) findIssuer(ctx context.Context, cert *x509.Certificate, candidates []*x509.Certificate, offline bool) *x509.Certificate {
+	if !offline {
+		issuer, err := s.revocationChecker.ResolveIssuer(ctx, cert, candidates)
+		if err != nil {
+			return nil
+		}
+		return issuer
+	}
+
+	return issuerIn(cert, candidates)
+}
+
+// addRevocation records an OCSP response or CRL covering cert, with the certificate of a
+// delegated OCSP responder.
+func (e *signatureEvidence) addRevocation(cert, issuer *x509.Certificate, result *revocation.Result) {
+	switch result.Method {
+	case revocation.MethodOCSP:
+		e.ocsps = append(e.ocsps, result.Raw)
+		if resp, err := ocsp.ParseResponseForCert(result.Raw, cert, issuer); err == nil && resp.Certificate != nil {
+			e.certs = append(e.certs, resp.Certificate)
+		}
+	case revocation.MethodCRL:
+		e.crls = append(e.crls, result.Raw)
+	}
+
+	if result.Status == revocation.StatusRevoked {
+		e.warnings = append(e.warnings, fmt.Sprintf("certificate '%s' was revoked on %s",
+			cert.Subject.CommonName, result.RevokedAt.Format("2006-01-02 15:04:05")))
+	}
+}
+
+// vriKey returns the key of a signature in the VRI dictionary: the upper-case hex SHA-1 digest
+// of its /Contents string.
+func vriKey(contents []byte) string {
+	digest := sha1.Sum(contents)
+	return strings.ToUpper(hex.EncodeToString(digest[:]))
+}
+
+// dssBuilder merges new validation data into the document security store, storing every
+// certificate, OCSP response and CRL once.
+type dssBuilder struct {
+	update *incrementalUpdate
+	refs   map[[32]byte]string
+	certs  []string
+	ocsps  []string
+	crls   []string
+	vri    map[string]string
+	keys   []string
+}
+
+// addValidationData appends a DSS holding the collected evidence to data, keeping the contents
+// of an existing DSS, and counts the newly stored items in result.
+func addValidationData(data []byte, dictionaries []signatureDictionary, collected []signatureEvidence, result *types.ExtendResult) (updated []byte, err error) {
+	defer recoverPDFPanic(&err, "read document")
+
+	rdr, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read document: %w", err)
+	}
+
+	update, err := newIncrementalUpdate(data, rdr)
+	if err != nil {
+		return nil, fmt.Errorf("cannot update document: %w", err)
+	}
+
+	root := rdr.Trailer().Key("Root")
+	b := &dssBuilder{update: update, refs: map[[32]byte]string{}, vri: map[string]string{}}
+	if err := b.load(root.Key("DSS")); err != nil {
+		return nil, err
+	}
+
+	updatedAt := pdfDate(time.Now())
+	for i, dict := range dictionaries {
+		evidence := collected[i]
+
+		var certs, ocsps, crls []string
+		for _, cert := range evidence.certs {
+			certs = appendUnique(certs, b.store(&b.certs, cert.Raw, &result.Certificates))
+		}
+		for _, raw := range evidence.ocsps {
+			ocsps = appendUnique(ocsps, b.store(&b.ocsps, raw, &result.OCSPResponses))
+		}
+		for _, raw := range evidence.crls {
+			crls = appendUnique(crls, b.store(&b.crls, raw, &result.CRLs))
+		}
+
+		var entry strings.Builder
+		entry.WriteString("<<")
+		for _, list := range []struct {
+			key  string
+			refs []string
+		}{{"Cert", certs}, {"OCSP", ocsps}, {"CRL", crls}} {
+			if len(list.refs) > 0 {
+				fmt.Fprintf(&entry, " /%s [%s]", list.key, strings.Join(list.refs, " "))
+			}
+		}
+		fmt.Fprintf(&entry, " /TU (%s) >>", updatedAt)
+		b.setVRI(vriKey(dict.contents), entry.String())
+	}
+
+	dssID := update.add(b.dictionary())
+
+	var catalog bytes.Buffer
+	catalog.WriteString("<<")
+	for _, key := range root.Keys() {
+		if key == "DSS" {
+			continue
+		}
+		catalog.WriteString(" " + pdfName(key) + " ")
+		writeValue(&catalog, root.Key(key), root)
+	}
+	fmt.Fprintf(&catalog, " /DSS %d 0 R >>", dssID)
+	update.replace(root, catalog.Bytes())
+
+	return update.bytes(), nil
+}
+
+// load keeps the entries of an existing DSS.
+func (b *dssBuilder) load(dss pdf.Value) error {
+	if dss.IsNull() {
+		return nil
+	}
+
+	for _, list := range []struct {
+		key  string
+		refs *[]string
+	}{{"Certs", &b.certs}, {"OCSPs", &b.ocsps}, {"CRLs", &b.crls}} {
+		items := dss.Key(list.key)
+		for i := 0; i < items.Len(); i++ {
+			item := items.Index(i)
+			if item.Kind() != pdf.Stream {
+				continue
+			}
+			content, err := io.ReadAll(item.Reader())
+			if err != nil {
+				return fmt.Errorf("failed to read DSS %s: %w", list.key, err)
+			}
+			ptr := item.GetPtr()
+			ref := fmt.Sprintf("%d %d R", ptr.GetID(), ptr.GetGen())
+			b.refs[sha256.Sum256(content)] = ref
+			*list.refs = append(*list.refs, ref)
+		}
+	}
+
+	vri := dss.Key("VRI")
+	for _, key := range vri.Keys() {
+		var entry bytes.Buffer
+		writeValue(&entry, vri.Key(key), vri)
+		b.setVRI(key, entry.String())
+	}
+
+	return nil
+}
+
+// store returns a reference to a stream holding data, adding the stream to list when the
+// DSS does not hold it yet.
+func (b *dssBuilder) store(list *[]string, data []byte, added *int) string {
+	digest := sha256.Sum256(data)
+	if ref, ok := b.refs[digest]; ok {
+		return ref
+	}
+
+	ref := fmt.Sprintf("%d 0 R", b.update.addStream(data))
+	b.refs[digest] = ref
+	*list = append(*list, ref)
+	*added++
+	return ref
+}
+
+func (b *dssBuilder) setVRI(key, entry string) {
+	if _, ok := b.vri[key]; !ok {
+		b.keys = append(b.keys, key)
+	}
+	b.vri[key] = entry
+}
+
+func (b *dssBuilder) dictionary() []byte {
+	var dss bytes.Buffer
+	dss.WriteString("<< /Type /DSS")
+	for _, list := range []struct {
+		key  string
+		refs []string
+	}{{"Certs", b.certs}, {"OCSPs", b.ocsps}, {"CRLs", b.crls}} {
+		if len(list.refs) > 0 {
+			fmt.Fprintf(&dss, " /%s [%s]", list.key, strings.Join(list.refs, " "))
+		}
+	}
+	dss.WriteString(" /VRI <<")
+	for _, key := range b.keys {
+		fmt.Fprintf(&dss, " %s %s", pdfName(key), b.vri[key])
+	}
+	dss.WriteString(" >> >>")
+	return dss.Bytes()
+}
+
+func appendUnique(refs []string, ref string) []string {
+	if slices.Contains(refs, ref) {
+		return refs
+	}
+	return append(refs, ref)
+}
+
+// pdfDate formats a time as a PDF date string in UTC.
+func pdfDate(t time.Time) string {
+	return t.UTC().Format("D:20060102150405Z")
+}
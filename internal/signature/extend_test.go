@@ -0,0 +1,219 @@
+package signature
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Matbe34/lankir/internal/signature/revocation"
+	"github.com/digitorus/pdf"
+)
+
+// createTestCA creates a CA certificate and a signing certificate issued by it, pointing to a
+// CRL distribution point that is never contacted, and a CRL of the CA
+func createTestCA(t *testing.T) (ca *x509.Certificate, leaf *x509.Certificate, leafKey *ecdsa.PrivateKey, crl []byte) {
+	t.Helper()
+
+	return createTestCAWithURLs(t, "http://crl.invalid/ca.crl", "")
+}
+
+// createTestCAWithURLs is createTestCA with the CRL distribution point and, if not empty, the
+// CA issuers URL of the signing certificate
+func createTestCAWithURLs(t *testing.T, crlURL, issuerURL string) (ca *x509.Certificate, leaf *x509.Certificate, leafKey *ecdsa.PrivateKey, crl []byte) {
+	t.Helper()
+
+	now := time.Now()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	ca = CreateTestCertificateFromTemplate(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, caKey)
+
+	leafKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(2),
+		Subject:               pkix.Name{CommonName: "Issued Signer"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageContentCommitment,
+		CRLDistributionPoints: []string{crlURL},
+	}
+	if issuerURL != "" {
+		template.IssuingCertificateURL = []string{issuerURL}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &leafKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	leaf, err = x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+
+	crl, err = x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: now.Add(-time.Hour),
+		NextUpdate: now.Add(24 * time.Hour),
+	}, ca, caKey)
+	if err != nil {
+		t.Fatalf("Failed to create CRL: %v", err)
+	}
+
+	return ca, leaf, leafKey, crl
+}
+
+// signWithIssuedCertificate signs a test PDF with a CA-issued certificate and returns the signed
+// file, the CA certificate file and a CRL file of the CA
+func signWithIssuedCertificate(t *testing.T, service *SignatureService, storeDir string) (string, string, string) {
+	t.Helper()
+
+	ca, leaf, key, crl := createTestCA(t)
+	WriteTestPKCS12(t, storeDir, "issued.p12", leaf, key)
+
+	dir := t.TempDir()
+	caPath := WriteTestCertificatePEM(t, dir, "ca.pem", ca)
+	crlPath := filepath.Join(dir, "ca.crl")
+	if err := os.WriteFile(crlPath, crl, 0644); err != nil {
+		t.Fatalf("Failed to write CRL: %v", err)
+	}
+
+	pdfPath := filepath.Join(dir, "doc.pdf")
+	CreateTestPDF(t, pdfPath)
+	signedPath, err := service.SignPDF(pdfPath, CertificateFingerprint(leaf), "")
+	if err != nil {
+		t.Fatalf("SignPDF failed: %v", err)
+	}
+	return signedPath, caPath, crlPath
+}
+
+// TestExtendSignatures tests that the chain and CRL of a signature are added to the DSS with a
+// VRI entry, without invalidating the signature
+func TestExtendSignatures(t *testing.T) {
+	storeDir := t.TempDir()
+	service, _ := NewTestServiceWithStore(t, storeDir)
+	service.revocationChecker = revocation.NewChecker(t.TempDir(), nil)
+
+	signedPath, caPath, crlPath := signWithIssuedCertificate(t, service, storeDir)
+
+	result, err := service.ExtendSignatures(signedPath, ExtendOptions{
+		CertificateFiles: []string{caPath},
+		CRLFiles:         []string{crlPath},
+		Offline:          true,
+	})
+	if err != nil {
+		t.Fatalf("ExtendSignatures failed: %v", err)
+	}
+
+	if !strings.HasSuffix(result.OutputPath, "_signed_ltv.pdf") {
+		t.Errorf("Unexpected output path %s", result.OutputPath)
+	}
+	if result.Certificates != 2 || result.CRLs != 1 || result.OCSPResponses != 0 {
+		t.Errorf("Expected 2 certificates and 1 CRL, got %+v", result)
+	}
+	if len(result.Signatures) != 1 {
+		t.Fatalf("Expected 1 signature, got %d", len(result.Signatures))
+	}
+	extended := result.Signatures[0]
+	if len(extended.Warnings) != 0 {
+		t.Errorf("Unexpected warnings: %v", extended.Warnings)
+	}
+
+	data, err := os.ReadFile(result.OutputPath)
+	if err != nil {
+		t.Fatalf("Failed to read extended PDF: %v", err)
+	}
+	original, _ := os.ReadFile(signedPath)
+	if !bytes.HasPrefix(data, original) {
+		t.Fatal("Extended PDF does not start with the original document")
+	}
+
+	rdr, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("Failed to read extended PDF: %v", err)
+	}
+	root := rdr.Trailer().Key("Root")
+	if root.Key("Pages").Key("Count").Int64() != 1 {
+		t.Error("Catalog lost its page tree")
+	}
+	vri := root.Key("DSS").Key("VRI").Key(extended.VRIKey)
+	if vri.Key("Cert").Len() != 2 || vri.Key("CRL").Len() != 1 {
+		t.Errorf("Unexpected VRI entry %v", vri)
+	}
+
+	signatures, err := service.VerifySignatures(result.OutputPath)
+	if err != nil {
+		t.Fatalf("VerifySignatures failed: %v", err)
+	}
+	if len(signatures) != 1 || !signatures[0].IsValid {
+		t.Fatalf("Expected a valid signature, got %+v", signatures)
+	}
+	if signatures[0].ModificationStatus != ModificationStatusAllowed {
+		t.Errorf("Expected allowed modifications, got %s: %+v", signatures[0].ModificationStatus, signatures[0].Modifications)
+	}
+
+	// Extending again reuses the stored data
+	again, err := service.ExtendSignatures(result.OutputPath, ExtendOptions{
+		CertificateFiles: []string{caPath},
+		CRLFiles:         []string{crlPath},
+		Offline:          true,
+	})
+	if err != nil {
+		t.Fatalf("Second ExtendSignatures failed: %v", err)
+	}
+	if again.Certificates != 0 || again.CRLs != 0 {
+		t.Errorf("Expected no new validation data, got %+v", again)
+	}
+}
+
+// TestExtendSignatures_MissingRevocationData tests that gaps in the validation data are reported
+func TestExtendSignatures_MissingRevocationData(t *testing.T) {
+	storeDir := t.TempDir()
+	service, _ := NewTestServiceWithStore(t, storeDir)
+	service.revocationChecker = revocation.NewChecker(t.TempDir(), nil)
+
+	signedPath, caPath, _ := signWithIssuedCertificate(t, service, storeDir)
+
+	result, err := service.ExtendSignatures(signedPath, ExtendOptions{
+		CertificateFiles: []string{caPath},
+		Offline:          true,
+	})
+	if err != nil {
+		t.Fatalf("ExtendSignatures failed: %v", err)
+	}
+
+	warnings := result.Signatures[0].Warnings
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "no revocation data for certificate 'Issued Signer'") {
+		t.Errorf("Unexpected warnings: %v", warnings)
+	}
+}
+
+// TestExtendSignatures_Unsigned tests that a document without signatures is rejected
+func TestExtendSignatures_Unsigned(t *testing.T) {
+	service, _ := NewTestServiceWithStore(t, t.TempDir())
+
+	pdfPath := filepath.Join(t.TempDir(), "doc.pdf")
+	CreateTestPDF(t, pdfPath)
+
+	if _, err := service.ExtendSignatures(pdfPath, ExtendOptions{Offline: true}); err == nil {
+		t.Fatal("Expected an error for an unsigned document")
+	}
+}
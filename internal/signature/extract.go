@@ -0,0 +1,163 @@
+package signature
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/Matbe34/lankir/internal/signature/types"
+)
+
+// extractionDirs hands out one temporary directory per document for extractions without an
+// output directory, so opening signed revisions repeatedly does not leave a directory behind
+// each time. The directories are removed when the service shuts down.
+type extractionDirs struct {
+	mu   sync.Mutex
+	dirs map[string]string
+}
+
+func newExtractionDirs() *extractionDirs {
+	return &extractionDirs{dirs: make(map[string]string)}
+}
+
+// get returns the directory for pdfPath, creating it on first use or if it was removed.
+func (d *extractionDirs) get(pdfPath string) (string, error) {
+	key, err := filepath.Abs(pdfPath)
+	if err != nil {
+		key = pdfPath
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if dir, ok := d.dirs[key]; ok {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir, nil
+		}
+	}
+
+	dir, err := os.MkdirTemp("", "lankir-signatures-*")
+	if err != nil {
+		return "", err
+	}
+	d.dirs[key] = dir
+	return dir, nil
+}
+
+// removeAll deletes every directory handed out.
+func (d *extractionDirs) removeAll() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for key, dir := range d.dirs {
+		if err := os.RemoveAll(dir); err != nil {
+			slog.Warn("failed to remove extraction directory", "dir", dir, "error", err)
+		}
+		delete(d.dirs, key)
+	}
+}
+
+// ExtractSignatures writes, for each signature of a PDF, the revision it signed, its raw CMS
+// signature (.p7s) and its embedded certificates (PEM, signer first) to outputDir. An empty
+// outputDir extracts to a temporary directory kept per document until shutdown, so the revisions
+// can be opened in the viewer.
+func (s *SignatureService) ExtractSignatures(pdfPath, outputDir string) ([]types.ExtractedSignature, error) {
+	data, err := os.ReadFile(pdfPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open PDF: %w", err)
+	}
+
+	dictionaries, err := readSignatureDictionaries(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	if len(dictionaries) == 0 {
+		return nil, fmt.Errorf("no signatures found in PDF")
+	}
+
+	if outputDir == "" {
+		outputDir, err = s.extractionDirs.get(pdfPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create output directory: %w", err)
+		}
+	} else if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	base := strings.TrimSuffix(filepath.Base(pdfPath), filepath.Ext(pdfPath))
+	extracted := make([]types.ExtractedSignature, 0, len(dictionaries))
+
+	for i, dict := range dictionaries {
+		prefix := filepath.Join(outputDir, fmt.Sprintf("%s_signature%d", base, i+1))
+		result := types.ExtractedSignature{
+			Index:     i + 1,
+			FieldName: dict.fieldName,
+			CMSPath:   prefix + ".p7s",
+		}
+
+		if err := os.WriteFile(result.CMSPath, cmsBlob(dict.contents), 0644); err != nil {
+			return nil, fmt.Errorf("failed to write signature %d: %w", i+1, err)
+		}
+
+		signer := dict.p7.GetOnlySigner()
+		if signer != nil {
+			result.SignerName = signer.Subject.CommonName
+		}
+		certs := dict.p7.Certificates
+		if signer != nil {
+			certs = []*x509.Certificate{signer}
+			for _, cert := range dict.p7.Certificates {
+				if !cert.Equal(signer) {
+					certs = append(certs, cert)
+				}
+			}
+		}
+		if len(certs) > 0 {
+			var buf bytes.Buffer
+			for _, cert := range certs {
+				if err := pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}); err != nil {
+					return nil, fmt.Errorf("failed to encode certificate: %w", err)
+				}
+			}
+			result.CertificatesPath = prefix + "_certificates.pem"
+			result.CertificateCount = len(certs)
+			if err := os.WriteFile(result.CertificatesPath, buf.Bytes(), 0644); err != nil {
+				return nil, fmt.Errorf("failed to write certificates of signature %d: %w", i+1, err)
+			}
+		}
+
+		coverage := byteRangeCoverage(dict.byteRange, data)
+		if coverage.Error != "" {
+			// Without a usable byte range the signed revision cannot be located
+			result.Error = "invalid byte range: " + coverage.Error
+		} else {
+			revision := data[:dict.byteRange[2]+dict.byteRange[3]]
+			result.RevisionPath = prefix + "_revision.pdf"
+			result.RevisionSize = int64(len(revision))
+			if err := os.WriteFile(result.RevisionPath, revision, 0644); err != nil {
+				return nil, fmt.Errorf("failed to write revision of signature %d: %w", i+1, err)
+			}
+		}
+
+		extracted = append(extracted, result)
+	}
+
+	return extracted, nil
+}
+
+// cmsBlob strips the zero padding that fills the reserved /Contents space after the DER
+// encoded signature. BER encodings that cannot be measured are returned padded.
+func cmsBlob(contents []byte) []byte {
+	var raw asn1.RawValue
+	if _, err := asn1.Unmarshal(contents, &raw); err != nil {
+		return contents
+	}
+	return raw.FullBytes
+}
@@ -0,0 +1,119 @@
+package signature
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Matbe34/lankir/internal/signature/certutil"
+	"github.com/Matbe34/lankir/internal/signature/revocation"
+	"github.com/digitorus/pkcs7"
+)
+
+// TestExtractSignatures tests that the signed revision, CMS blob and certificates are written
+func TestExtractSignatures(t *testing.T) {
+	storeDir := t.TempDir()
+	service, _ := NewTestServiceWithStore(t, storeDir)
+	service.revocationChecker = revocation.NewChecker(t.TempDir(), nil)
+
+	signedPath, cert := SignTestPDF(t, service, storeDir)
+	signedData, err := os.ReadFile(signedPath)
+	if err != nil {
+		t.Fatalf("Failed to read signed PDF: %v", err)
+	}
+	AppendIncrementalUpdate(t, signedPath, map[int]string{
+		20: "<< /Type /Annot /Subtype /Text /Rect [10 10 30 30] /P 3 0 R /Contents (Note) >>",
+	})
+
+	outputDir := filepath.Join(t.TempDir(), "out")
+	extracted, err := service.ExtractSignatures(signedPath, outputDir)
+	if err != nil {
+		t.Fatalf("ExtractSignatures failed: %v", err)
+	}
+	if len(extracted) != 1 {
+		t.Fatalf("Expected 1 signature, got %d", len(extracted))
+	}
+
+	sig := extracted[0]
+	if sig.SignerName != "Report Signer" {
+		t.Errorf("Expected signer 'Report Signer', got %q", sig.SignerName)
+	}
+	if filepath.Dir(sig.RevisionPath) != outputDir {
+		t.Errorf("Expected revision in %s, got %s", outputDir, sig.RevisionPath)
+	}
+
+	revision, err := os.ReadFile(sig.RevisionPath)
+	if err != nil {
+		t.Fatalf("Failed to read revision: %v", err)
+	}
+	if !bytes.Equal(revision, signedData) {
+		t.Errorf("Expected the revision to equal the document as signed (%d bytes), got %d bytes", len(signedData), len(revision))
+	}
+
+	cms, err := os.ReadFile(sig.CMSPath)
+	if err != nil {
+		t.Fatalf("Failed to read CMS blob: %v", err)
+	}
+	p7, err := pkcs7.Parse(cms)
+	if err != nil {
+		t.Fatalf("CMS blob does not parse: %v", err)
+	}
+	if !p7.GetOnlySigner().Equal(cert) {
+		t.Error("CMS signer does not match the signing certificate")
+	}
+
+	pemData, err := os.ReadFile(sig.CertificatesPath)
+	if err != nil {
+		t.Fatalf("Failed to read certificates: %v", err)
+	}
+	certs, err := certutil.ParseCertificates(pemData)
+	if err != nil {
+		t.Fatalf("Certificates do not parse: %v", err)
+	}
+	if len(certs) != sig.CertificateCount || !certs[0].Equal(cert) {
+		t.Errorf("Expected %d certificates starting with the signer, got %d", sig.CertificateCount, len(certs))
+	}
+}
+
+// TestExtractSignatures_Unsigned tests that extracting from an unsigned PDF fails
+func TestExtractSignatures_Unsigned(t *testing.T) {
+	service, _ := NewTestServiceWithStore(t, t.TempDir())
+
+	pdfPath := filepath.Join(t.TempDir(), "doc.pdf")
+	CreateTestPDF(t, pdfPath)
+
+	if _, err := service.ExtractSignatures(pdfPath, t.TempDir()); err == nil {
+		t.Error("Expected an error for an unsigned PDF")
+	}
+}
+
+// TestExtractSignatures_TemporaryDirectory tests that extractions without an output directory reuse
+// one directory per document and that it is removed on shutdown
+func TestExtractSignatures_TemporaryDirectory(t *testing.T) {
+	storeDir := t.TempDir()
+	service, _ := NewTestServiceWithStore(t, storeDir)
+	service.revocationChecker = revocation.NewChecker(t.TempDir(), nil)
+
+	signedPath, _ := SignTestPDF(t, service, storeDir)
+
+	first, err := service.ExtractSignatures(signedPath, "")
+	if err != nil {
+		t.Fatalf("ExtractSignatures failed: %v", err)
+	}
+	second, err := service.ExtractSignatures(signedPath, "")
+	if err != nil {
+		t.Fatalf("Second ExtractSignatures failed: %v", err)
+	}
+
+	dir := filepath.Dir(first[0].RevisionPath)
+	if filepath.Dir(second[0].RevisionPath) != dir {
+		t.Errorf("Expected the directory %s to be reused, got %s", dir, filepath.Dir(second[0].RevisionPath))
+	}
+
+	service.Shutdown(context.Background())
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("Expected %s to be removed on shutdown, got %v", dir, err)
+	}
+}
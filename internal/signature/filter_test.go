@@ -0,0 +1,161 @@
+package signature
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"testing"
+	"time"
+
+	"github.com/Matbe34/lankir/internal/signature/certutil"
+)
+
+// filterFixture holds the fingerprints of the certificates written by newFilterFixture
+type filterFixture struct {
+	service   *SignatureService
+	qualified string
+	rsa       string
+	soon      string
+	withKey   string
+}
+
+// newFilterFixture populates a store with certificates that differ in one filterable property each
+func newFilterFixture(t *testing.T) *filterFixture {
+	t.Helper()
+
+	storeDir := t.TempDir()
+	service, _ := NewTestServiceWithStore(t, storeDir)
+	now := time.Now()
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	qcStatements, err := asn1.Marshal([]struct{ ID asn1.ObjectIdentifier }{{certutil.OIDQcCompliance}})
+	if err != nil {
+		t.Fatalf("Failed to encode QC statements: %v", err)
+	}
+
+	qualified := CreateTestCertificateFromTemplate(t, &x509.Certificate{
+		Subject:            pkix.Name{CommonName: "Alice Qualified"},
+		NotBefore:          now.Add(-time.Hour),
+		NotAfter:           now.Add(365 * 24 * time.Hour),
+		KeyUsage:           x509.KeyUsageDigitalSignature | x509.KeyUsageContentCommitment,
+		UnknownExtKeyUsage: []asn1.ObjectIdentifier{{1, 3, 6, 1, 5, 5, 7, 3, 36}},
+		ExtraExtensions:    []pkix.Extension{{Id: asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 3}, Value: qcStatements}},
+	}, ecKey)
+	WriteTestCertificatePEM(t, storeDir, "qualified.pem", qualified)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+	rsaCert := CreateTestCertificateFromTemplate(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "Bob Email", Organization: []string{"Example Corp"}},
+		NotBefore:   now.Add(-time.Hour),
+		NotAfter:    now.Add(200 * 24 * time.Hour),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection, x509.ExtKeyUsageClientAuth},
+	}, rsaKey)
+	WriteTestCertificatePEM(t, storeDir, "rsa.pem", rsaCert)
+
+	soon, _ := CreateTestCertificate(t, "Carol Soon", now.Add(-time.Hour), now.Add(5*24*time.Hour))
+	WriteTestCertificatePEM(t, storeDir, "soon.pem", soon)
+
+	withKey, key := CreateTestCertificate(t, "Dave Bundle", now.Add(-time.Hour), now.Add(100*24*time.Hour))
+	WriteTestPKCS12(t, storeDir, "bundle.p12", withKey, key)
+
+	return &filterFixture{
+		service:   service,
+		qualified: CertificateFingerprint(qualified),
+		rsa:       CertificateFingerprint(rsaCert),
+		soon:      CertificateFingerprint(soon),
+		withKey:   CertificateFingerprint(withKey),
+	}
+}
+
+// listFingerprints returns the fingerprints matching filter, in result order
+func (f *filterFixture) listFingerprints(t *testing.T, filter CertificateFilter) []string {
+	t.Helper()
+
+	certs, err := f.service.ListCertificatesFiltered(filter)
+	if err != nil {
+		t.Fatalf("ListCertificatesFiltered failed: %v", err)
+	}
+
+	fingerprints := make([]string, len(certs))
+	for i, c := range certs {
+		fingerprints[i] = c.Fingerprint
+	}
+	return fingerprints
+}
+
+// TestListCertificatesFiltered_ExtendedCriteria tests each of the extended filter criteria
+func TestListCertificatesFiltered_ExtendedCriteria(t *testing.T) {
+	f := newFilterFixture(t)
+
+	tests := []struct {
+		name   string
+		filter CertificateFilter
+		want   []string
+	}{
+		{"document signing EKU", CertificateFilter{ExtendedKeyUsage: "documentSigning"}, []string{f.qualified}},
+		{"email EKU", CertificateFilter{ExtendedKeyUsage: "email-protection"}, []string{f.rsa}},
+		{"client auth EKU", CertificateFilter{ExtendedKeyUsage: "clientAuth"}, []string{f.rsa}},
+		{"issuer DN", CertificateFilter{Issuer: "example corp"}, []string{f.rsa}},
+		{"key algorithm", CertificateFilter{KeyAlgorithm: "rsa"}, []string{f.rsa}},
+		{"key size", CertificateFilter{MinKeySize: 1024}, []string{f.rsa}},
+		{"private key", CertificateFilter{PrivateKeyOnly: true}, []string{f.withKey}},
+		{"expires before", CertificateFilter{ExpiresBefore: time.Now().Add(30 * 24 * time.Hour)}, []string{f.soon}},
+		{"qualified", CertificateFilter{QualifiedOnly: true}, []string{f.qualified}},
+		{"key usage", CertificateFilter{RequiredKeyUsage: "non repudiation", KeyAlgorithm: "ECDSA", MinKeySize: 256}, []string{f.qualified, f.soon, f.withKey}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := f.listFingerprints(t, tt.filter)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Expected %d certificate(s), got %d", len(tt.want), len(got))
+			}
+			wanted := make(map[string]bool)
+			for _, fp := range tt.want {
+				wanted[fp] = true
+			}
+			for _, fp := range got {
+				if !wanted[fp] {
+					t.Errorf("Unexpected certificate %s", fp)
+				}
+			}
+		})
+	}
+}
+
+// TestListCertificatesFiltered_Sorting tests sorting by expiry and name
+func TestListCertificatesFiltered_Sorting(t *testing.T) {
+	f := newFilterFixture(t)
+
+	got := f.listFingerprints(t, CertificateFilter{SortBy: SortByExpiry})
+	want := []string{f.soon, f.withKey, f.rsa, f.qualified}
+	for i := range want {
+		if i >= len(got) || got[i] != want[i] {
+			t.Fatalf("Expected expiry order %v, got %v", want, got)
+		}
+	}
+
+	got = f.listFingerprints(t, CertificateFilter{SortBy: SortByName, SortDescending: true})
+	want = []string{f.withKey, f.soon, f.rsa, f.qualified}
+	for i := range want {
+		if i >= len(got) || got[i] != want[i] {
+			t.Fatalf("Expected descending name order %v, got %v", want, got)
+		}
+	}
+
+	if _, err := f.service.ListCertificatesFiltered(CertificateFilter{SortBy: "color"}); err == nil {
+		t.Error("Expected an error for an unsupported sort field")
+	}
+}
@@ -0,0 +1,154 @@
+package signature
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Matbe34/lankir/internal/signature/types"
+)
+
+// ErrCertificateNotFound is returned when no certificate source holds the requested fingerprint.
+var ErrCertificateNotFound = errors.New("certificate not found")
+
+// certificateIndex caches the merged certificate inventory of all sources.
+// It is invalidated by filesystem watches, token events and config changes.
+type certificateIndex struct {
+	mu            sync.RWMutex
+	certs         []types.Certificate
+	sources       []types.CertificateSourceStatus
+	byFingerprint map[string]int
+	loadedAt      time.Time
+	valid         bool
+	generation    uint64
+}
+
+func newCertificateIndex() *certificateIndex {
+	return &certificateIndex{
+		byFingerprint: make(map[string]int),
+	}
+}
+
+// snapshot returns a copy of the cached certificates and source statuses if the index is valid.
+func (idx *certificateIndex) snapshot() ([]types.Certificate, []types.CertificateSourceStatus, bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	if !idx.valid {
+		return nil, nil, false
+	}
+
+	certs := append([]types.Certificate{}, idx.certs...)
+	sources := append([]types.CertificateSourceStatus{}, idx.sources...)
+	return certs, sources, true
+}
+
+// lookup returns the cached certificate with the given fingerprint.
+func (idx *certificateIndex) lookup(fingerprint string) (types.Certificate, bool, bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	if !idx.valid {
+		return types.Certificate{}, false, false
+	}
+
+	i, ok := idx.byFingerprint[fingerprint]
+	if !ok {
+		return types.Certificate{}, false, true
+	}
+	return idx.certs[i], true, true
+}
+
+// currentGeneration returns a counter that changes on every invalidation.
+// Loads started before an invalidation must not be stored afterwards.
+func (idx *certificateIndex) currentGeneration() uint64 {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return idx.generation
+}
+
+// store replaces the cached inventory unless it was invalidated since generation was read.
+func (idx *certificateIndex) store(certs []types.Certificate, sources []types.CertificateSourceStatus, generation uint64) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if generation != idx.generation {
+		return
+	}
+
+	idx.certs = append([]types.Certificate{}, certs...)
+	idx.sources = append([]types.CertificateSourceStatus{}, sources...)
+	idx.byFingerprint = make(map[string]int, len(certs))
+	for i, cert := range idx.certs {
+		if _, exists := idx.byFingerprint[cert.Fingerprint]; !exists {
+			idx.byFingerprint[cert.Fingerprint] = i
+		}
+	}
+	idx.loadedAt = time.Now()
+	idx.valid = true
+}
+
+// invalidate marks the cached inventory as stale.
+func (idx *certificateIndex) invalidate() {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.valid = false
+	idx.generation++
+}
+
+// cachedCertificates returns the certificate inventory, loading all sources on a cache miss.
+func (s *SignatureService) cachedCertificates() ([]types.Certificate, error) {
+	certs, _, err := s.cachedInventory()
+	return certs, err
+}
+
+// cachedInventory returns the certificate inventory and source statuses, loading all sources on a cache miss.
+func (s *SignatureService) cachedInventory() ([]types.Certificate, []types.CertificateSourceStatus, error) {
+	if certs, sources, ok := s.certIndex.snapshot(); ok {
+		return certs, sources, nil
+	}
+
+	generation := s.certIndex.currentGeneration()
+	certs, sources := s.loadAllCertificates()
+
+	s.certIndex.store(certs, sources, generation)
+	return certs, sources, nil
+}
+
+// RefreshCertificates discards the cached certificate index and rescans all sources.
+func (s *SignatureService) RefreshCertificates() ([]types.Certificate, error) {
+	s.certIndex.invalidate()
+
+	certs, err := s.cachedCertificates()
+	if err != nil {
+		return nil, err
+	}
+	if certs == nil {
+		certs = []types.Certificate{}
+	}
+	return certs, nil
+}
+
+// GetCertificateByFingerprint returns a certificate from the index by its SHA-256 fingerprint.
+func (s *SignatureService) GetCertificateByFingerprint(fingerprint string) (*types.Certificate, error) {
+	if cert, found, valid := s.certIndex.lookup(fingerprint); valid {
+		if !found {
+			return nil, fmt.Errorf("%w: %s", ErrCertificateNotFound, fingerprint)
+		}
+		return &cert, nil
+	}
+
+	certs, err := s.cachedCertificates()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list certificates: %w", err)
+	}
+
+	for i := range certs {
+		if certs[i].Fingerprint == fingerprint {
+			return &certs[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrCertificateNotFound, fingerprint)
+}
@@ -0,0 +1,136 @@
+package signature
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// containsFingerprint reports whether any certificate in the service index has the fingerprint
+func containsFingerprint(t *testing.T, service *SignatureService, fingerprint string) bool {
+	t.Helper()
+
+	certs, err := service.ListCertificates()
+	if err != nil {
+		t.Fatalf("ListCertificates failed: %v", err)
+	}
+	for _, c := range certs {
+		if c.Fingerprint == fingerprint {
+			return true
+		}
+	}
+	return false
+}
+
+// TestCertificateIndex_ServesCachedResults tests that the index is reused until refreshed
+func TestCertificateIndex_ServesCachedResults(t *testing.T) {
+	storeDir := t.TempDir()
+	service, _ := NewTestServiceWithStore(t, storeDir)
+
+	now := time.Now()
+	first, _ := CreateTestCertificate(t, "First", now.Add(-time.Hour), now.Add(24*time.Hour))
+	WriteTestCertificatePEM(t, storeDir, "first.pem", first)
+
+	if !containsFingerprint(t, service, CertificateFingerprint(first)) {
+		t.Fatal("First certificate not listed")
+	}
+
+	second, _ := CreateTestCertificate(t, "Second", now.Add(-time.Hour), now.Add(24*time.Hour))
+	WriteTestCertificatePEM(t, storeDir, "second.pem", second)
+
+	if containsFingerprint(t, service, CertificateFingerprint(second)) {
+		t.Error("Second certificate listed before the index was refreshed")
+	}
+
+	certs, err := service.RefreshCertificates()
+	if err != nil {
+		t.Fatalf("RefreshCertificates failed: %v", err)
+	}
+
+	found := false
+	for _, c := range certs {
+		if c.Fingerprint == CertificateFingerprint(second) {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("RefreshCertificates did not pick up the new certificate")
+	}
+}
+
+// TestCertificateIndex_InvalidatedByConfigChange tests that config updates invalidate the index
+func TestCertificateIndex_InvalidatedByConfigChange(t *testing.T) {
+	storeDir := t.TempDir()
+	otherDir := t.TempDir()
+	service, cfgService := NewTestServiceWithStore(t, storeDir)
+
+	now := time.Now()
+	cert, _ := CreateTestCertificate(t, "Other Store", now.Add(-time.Hour), now.Add(24*time.Hour))
+	WriteTestCertificatePEM(t, otherDir, "other.pem", cert)
+
+	if containsFingerprint(t, service, CertificateFingerprint(cert)) {
+		t.Fatal("Certificate from unconfigured store listed")
+	}
+
+	cfg := cfgService.Get()
+	cfg.CertificateStores = append(cfg.CertificateStores, otherDir)
+	if err := cfgService.Update(cfg); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	if !containsFingerprint(t, service, CertificateFingerprint(cert)) {
+		t.Error("Config change did not invalidate the certificate index")
+	}
+}
+
+// TestCertificateIndex_InvalidatedByWatcher tests that writing to a watched store invalidates the index
+func TestCertificateIndex_InvalidatedByWatcher(t *testing.T) {
+	storeDir := t.TempDir()
+	service, _ := NewTestServiceWithStore(t, storeDir)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	service.Startup(ctx)
+	service.StartWatching(ctx)
+	defer service.Shutdown(ctx)
+
+	if _, err := service.ListCertificates(); err != nil {
+		t.Fatalf("ListCertificates failed: %v", err)
+	}
+
+	now := time.Now()
+	cert, _ := CreateTestCertificate(t, "Watched", now.Add(-time.Hour), now.Add(24*time.Hour))
+	WriteTestCertificatePEM(t, storeDir, "watched.pem", cert)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if containsFingerprint(t, service, CertificateFingerprint(cert)) {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Error("Watcher did not invalidate the index after a store change")
+}
+
+// TestGetCertificateByFingerprint tests fingerprint lookups served from the index
+func TestGetCertificateByFingerprint(t *testing.T) {
+	storeDir := t.TempDir()
+	service, _ := NewTestServiceWithStore(t, storeDir)
+
+	now := time.Now()
+	cert, _ := CreateTestCertificate(t, "Lookup", now.Add(-time.Hour), now.Add(24*time.Hour))
+	WriteTestCertificatePEM(t, storeDir, "lookup.pem", cert)
+
+	found, err := service.GetCertificateByFingerprint(CertificateFingerprint(cert))
+	if err != nil {
+		t.Fatalf("GetCertificateByFingerprint failed: %v", err)
+	}
+	if found.Name != "Lookup" {
+		t.Errorf("Expected certificate 'Lookup', got '%s'", found.Name)
+	}
+
+	if _, err := service.GetCertificateByFingerprint("0000"); !errors.Is(err, ErrCertificateNotFound) {
+		t.Errorf("Expected ErrCertificateNotFound for unknown fingerprint, got %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package signature
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Matbe34/lankir/internal/signature/types"
+)
+
+// TestMergeCertificates tests that duplicates are merged and the signable location is preferred
+func TestMergeCertificates(t *testing.T) {
+	results := [][]types.Certificate{
+		{
+			{Name: "Shared", Fingerprint: "aa", Source: "User Store", FilePath: "/store/shared.crt"},
+			{Name: "locked.p12", Fingerprint: "", Source: "User File", FilePath: "/store/locked.p12", HasPrivateKey: true},
+		},
+		{
+			{Name: "Shared", Fingerprint: "aa", Source: "pkcs11", PKCS11Module: "/lib/token.so", HasPrivateKey: true, RequiresPin: true},
+			{Name: "other.p12", Fingerprint: "", Source: "User File", FilePath: "/store/other.p12", HasPrivateKey: true},
+		},
+	}
+
+	merged := mergeCertificates(results)
+	if len(merged) != 3 {
+		t.Fatalf("Expected 3 certificates (one merged, two without fingerprint), got %d", len(merged))
+	}
+
+	shared := merged[0]
+	if len(shared.Locations) != 2 {
+		t.Fatalf("Expected 2 locations, got %d", len(shared.Locations))
+	}
+	if shared.Source != "pkcs11" || !shared.HasPrivateKey || !shared.RequiresPin || shared.FilePath != "" {
+		t.Errorf("Expected the token location to be preferred, got %+v", shared.Location())
+	}
+	if shared.Locations[0].HasPrivateKey || !shared.Locations[1].HasPrivateKey {
+		t.Error("Locations should report which backend holds the private key")
+	}
+	if !shared.HasSource("User Store") || !shared.HasFilePath("/store/shared.crt") {
+		t.Error("Merged certificate should still match its file location")
+	}
+}
+
+// TestSignPDF_UsesLocationWithPrivateKey tests signing a certificate found both as a plain
+// certificate file and in a PKCS#12 file in another store
+func TestSignPDF_UsesLocationWithPrivateKey(t *testing.T) {
+	certStore := t.TempDir()
+	keyStore := t.TempDir()
+	service, cfgService := NewTestServiceWithStore(t, certStore)
+
+	cfg := cfgService.Get()
+	cfg.CertificateStores = []string{certStore, keyStore}
+	if err := cfgService.Update(cfg); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	now := time.Now()
+	cert, key := CreateTestCertificate(t, "Merged Signer", now.Add(-time.Hour), now.Add(24*time.Hour))
+	WriteTestCertificatePEM(t, certStore, "signer.crt", cert)
+	p12Path := WriteTestPKCS12(t, keyStore, "signer.p12", cert, key)
+
+	listed, err := service.GetCertificateByFingerprint(CertificateFingerprint(cert))
+	if err != nil {
+		t.Fatalf("GetCertificateByFingerprint failed: %v", err)
+	}
+	if len(listed.Locations) != 2 || listed.FilePath != p12Path {
+		t.Fatalf("Expected merged certificate backed by %s, got %+v", p12Path, listed.Locations)
+	}
+
+	pdfPath := filepath.Join(t.TempDir(), "doc.pdf")
+	CreateTestPDF(t, pdfPath)
+
+	signedPath, err := service.SignPDF(pdfPath, listed.Fingerprint, "")
+	if err != nil {
+		t.Fatalf("SignPDF failed: %v", err)
+	}
+	if _, err := os.Stat(signedPath); err != nil {
+		t.Errorf("Signed PDF not written: %v", err)
+	}
+}
@@ -0,0 +1,663 @@
+package signature
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/Matbe34/lankir/internal/signature/types"
+	"github.com/digitorus/pdf"
+)
+
+// Kinds of change reported in types.DocumentModification
+const (
+	ModificationSignature   = "signature"
+	ModificationFormFill    = "form-fill"
+	ModificationAnnotation  = "annotation"
+	ModificationPageContent = "page-content"
+	// ModificationValidationData covers the document security store (DSS), which may always be
+	// added or updated to keep signatures verifiable in the long term
+	ModificationValidationData = "validation-data"
+	// ModificationDocument covers catalog entries such as actions and name trees, which change how
+	// the document behaves when opened
+	ModificationDocument = "document"
+	// ModificationOther covers objects supporting another change, such as appearance streams and
+	// the AcroForm dictionary
+	ModificationOther = "other"
+	// ModificationUnrecognized covers changed objects that cannot be attributed to any of the above
+	ModificationUnrecognized = "unrecognized"
+)
+
+// catalogSupportKeys are the catalog entries updated when signing, filling forms or adding
+// validation data
+var catalogSupportKeys = []string{"AcroForm", "DSS"}
+
+// Modification statuses reported in types.SignatureInfo
+const (
+	ModificationStatusNone       = "none"
+	ModificationStatusAllowed    = "allowed"
+	ModificationStatusDisallowed = "disallowed"
+	ModificationStatusUnknown    = "unknown"
+)
+
+// revisionChanges holds the changes made after one signature.
+type revisionChanges struct {
+	modifications []types.DocumentModification
+	err           error
+}
+
+// changePermissions are the DocMDP and FieldMDP restrictions in force after a signature.
+type changePermissions struct {
+	docMDP int
+	locks  []*types.FieldMDPInfo
+}
+
+// documentContext indexes the final revision so changed objects can be located.
+type documentContext struct {
+	pages       map[any]int
+	contents    map[any]int
+	resources   map[any]int
+	annotations map[any]int
+	signatures  map[any]string
+	dss         map[any]bool
+	root        any
+	// reachable holds the objects the trailer leads to; others cannot affect the document
+	reachable map[any]bool
+	// supporting maps objects such as appearance streams to the label of their summary entry
+	supporting map[any]string
+}
+
+// analyzeModifications classifies the changes made after each signature and judges them against
+// the permissions set by the signatures applied up to it. The result aligns with dictionaries.
+func analyzeModifications(data []byte, dictionaries []signatureDictionary) []revisionChanges {
+	results := make([]revisionChanges, len(dictionaries))
+
+	var final *pdf.Reader
+	var ctx *documentContext
+	for i, dict := range dictionaries {
+		coverage := byteRangeCoverage(dict.byteRange, data)
+		if coverage.Error != "" || coverage.BytesAfterSignature == 0 {
+			continue
+		}
+
+		if final == nil {
+			var err error
+			final, ctx, err = openFinalRevision(data)
+			if err != nil {
+				for j := range results {
+					results[j].err = err
+				}
+				return results
+			}
+		}
+
+		end := dict.byteRange[2] + dict.byteRange[3]
+		results[i].modifications, results[i].err = revisionModifications(data[:end], final, ctx)
+		permissionsAt(dictionaries, end).judge(results[i].modifications)
+	}
+
+	return results
+}
+
+// permissionsAt collects the restrictions of the signatures whose revision ends at or before end.
+func permissionsAt(dictionaries []signatureDictionary, end int64) changePermissions {
+	var perms changePermissions
+	for _, dict := range dictionaries {
+		if len(dict.byteRange) != 4 || dict.byteRange[2]+dict.byteRange[3] > end {
+			continue
+		}
+		if dict.docMDP > 0 && (perms.docMDP == 0 || dict.docMDP < perms.docMDP) {
+			perms.docMDP = dict.docMDP
+		}
+		if dict.fieldMDP != nil {
+			perms.locks = append(perms.locks, dict.fieldMDP)
+		}
+	}
+	return perms
+}
+
+// judge marks each modification as allowed or not. Without a certification signature, signing,
+// form filling and annotating stay allowed as for DocMDP level 3, but page content and document
+// changes are not, since they alter what was signed, and neither are changes that cannot be
+// attributed.
+func (p changePermissions) judge(modifications []types.DocumentModification) {
+	for i := range modifications {
+		m := &modifications[i]
+		m.Allowed = true
+		m.Reason = ""
+
+		switch {
+		case m.Kind == ModificationValidationData:
+		case p.docMDP == 1:
+			m.Allowed = false
+			m.Reason = "DocMDP level 1 permits no changes"
+		case m.Kind == ModificationPageContent:
+			m.Allowed = false
+			if p.docMDP > 0 {
+				m.Reason = fmt.Sprintf("DocMDP level %d does not permit page content changes", p.docMDP)
+			} else {
+				m.Reason = "Page content changes alter the signed document"
+			}
+		case m.Kind == ModificationDocument:
+			m.Allowed = false
+			m.Reason = "Document actions and catalog entries alter the signed document"
+		case m.Kind == ModificationUnrecognized:
+			m.Allowed = false
+			m.Reason = "Changes that cannot be attributed to signing, form filling or annotating are not permitted"
+		case m.Kind == ModificationAnnotation && p.docMDP == 2:
+			m.Allowed = false
+			m.Reason = "DocMDP level 2 does not permit annotations"
+		case (m.Kind == ModificationFormFill || m.Kind == ModificationSignature) && p.locked(m.Field):
+			m.Allowed = false
+			m.Reason = fmt.Sprintf("Field '%s' is locked by a FieldMDP signature", m.Field)
+		}
+	}
+}
+
+// locked reports whether a field is locked by any FieldMDP restriction.
+func (p changePermissions) locked(field string) bool {
+	if field == "" {
+		return false
+	}
+	for _, lock := range p.locks {
+		listed := slices.Contains(lock.Fields, field)
+		switch lock.Action {
+		case "All":
+			return true
+		case "Include":
+			if listed {
+				return true
+			}
+		case "Exclude":
+			if !listed {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// modificationStatus summarizes the outcome of the analysis for types.SignatureInfo.
+func modificationStatus(changes revisionChanges) string {
+	if changes.err != nil {
+		return ModificationStatusUnknown
+	}
+	if len(changes.modifications) == 0 {
+		return ModificationStatusNone
+	}
+	for _, m := range changes.modifications {
+		if !m.Allowed {
+			return ModificationStatusDisallowed
+		}
+	}
+	return ModificationStatusAllowed
+}
+
+func openFinalRevision(data []byte) (final *pdf.Reader, ctx *documentContext, err error) {
+	defer recoverPDFPanic(&err, "read document")
+
+	final, err = pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read document: %w", err)
+	}
+	return final, newDocumentContext(final), nil
+}
+
+// revisionModifications compares a signed revision with the final document.
+func revisionModifications(revisionData []byte, final *pdf.Reader, ctx *documentContext) (modifications []types.DocumentModification, err error) {
+	defer recoverPDFPanic(&err, "compare revisions")
+
+	revision, err := pdf.NewReader(bytes.NewReader(revisionData), int64(len(revisionData)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signed revision: %w", err)
+	}
+
+	var other, unrecognized []string
+	modifications = []types.DocumentModification{}
+	add := func(m types.DocumentModification) {
+		if !slices.ContainsFunc(modifications, func(existing types.DocumentModification) bool {
+			return existing.Kind == m.Kind && existing.Description == m.Description
+		}) {
+			modifications = append(modifications, m)
+		}
+	}
+
+	finalRoot := final.Trailer().Key("Root").GetPtr()
+	for _, x := range final.Xref() {
+		ptr := x.Ptr()
+		current := final.Resolve(ptr, ptr)
+		if current.IsNull() {
+			continue
+		}
+		previous := revision.Resolve(ptr, ptr)
+		if previous.IsNull() && ptr == finalRoot {
+			// Updates often write the catalog as a new object
+			previous = revision.Trailer().Key("Root")
+		}
+		if !previous.IsNull() && sameObject(previous, current) {
+			continue
+		}
+
+		m, label, supporting := ctx.classify(current, previous)
+		switch {
+		case m != nil:
+			add(*m)
+		case label == "":
+		case supporting && !slices.Contains(other, label):
+			other = append(other, label)
+		case !supporting && !slices.Contains(unrecognized, label):
+			unrecognized = append(unrecognized, label)
+		}
+	}
+
+	if len(other) > 0 {
+		add(types.DocumentModification{
+			Kind:        ModificationOther,
+			Description: "Document structure updated (" + strings.Join(other, ", ") + ")",
+		})
+	}
+	if len(unrecognized) > 0 {
+		add(types.DocumentModification{
+			Kind:        ModificationUnrecognized,
+			Description: "Unrecognized objects changed (" + strings.Join(unrecognized, ", ") + ")",
+		})
+	}
+
+	return modifications, nil
+}
+
+// classify describes a changed object. Objects that only support another change are returned as
+// a label for the summary entry instead, as are objects that cannot be attributed, with supporting
+// false. Bookkeeping objects are ignored.
+func (ctx *documentContext) classify(current, previous pdf.Value) (*types.DocumentModification, string, bool) {
+	verb := "changed"
+	if previous.IsNull() {
+		verb = "added"
+	}
+	ptr := current.GetPtr()
+	objType := current.Key("Type").Name()
+
+	switch {
+	case objType == "XRef" || objType == "ObjStm":
+		return nil, "", true
+
+	case objType == "DSS" || ctx.dss[ptr]:
+		return &types.DocumentModification{Kind: ModificationValidationData, Description: "Validation data (DSS) " + verb}, "", true
+
+	case objType == "Catalog" && ptr != ctx.root:
+		// Superseded by the catalog of a later revision
+		return nil, "", true
+
+	case objType == "Catalog":
+		return classifyCatalog(current, previous)
+
+	case objType == "Sig" || objType == "DocTimeStamp" || (!current.Key("ByteRange").IsNull() && !current.Key("Filter").IsNull()):
+		field := ctx.signatures[ptr]
+		label := "Signature"
+		if objType == "DocTimeStamp" || current.Key("SubFilter").Name() == SubFilterDocTimeStamp {
+			label = "Document timestamp"
+		}
+		description := label + " " + verb
+		if field != "" {
+			description = fmt.Sprintf("%s %s in field '%s'", label, verb, field)
+		}
+		return &types.DocumentModification{Kind: ModificationSignature, Description: description, Field: field}, "", true
+
+	case objType == "Annot" || (current.Key("Subtype").Name() != "" && !current.Key("Rect").IsNull()):
+		return ctx.classifyAnnotation(current, verb), "", true
+
+	case !current.Key("FT").IsNull() || (!current.Key("T").IsNull() && (!current.Key("Kids").IsNull() || !current.Key("Parent").IsNull())):
+		return fieldModification(current, verb, 0), "", true
+
+	case objType == "Page":
+		return ctx.classifyPage(current, previous, verb), "", true
+
+	case objType == "Pages":
+		if previous.IsNull() || current.Key("Kids").String() != previous.Key("Kids").String() {
+			return &types.DocumentModification{Kind: ModificationPageContent, Description: "Pages added or removed"}, "", true
+		}
+		return nil, "Pages", true
+	}
+
+	if page, ok := ctx.contents[ptr]; ok {
+		return &types.DocumentModification{
+			Kind:        ModificationPageContent,
+			Description: fmt.Sprintf("Content of page %d %s", page, verb),
+			Page:        page,
+		}, "", true
+	}
+	if page, ok := ctx.resources[ptr]; ok {
+		return &types.DocumentModification{
+			Kind:        ModificationPageContent,
+			Description: fmt.Sprintf("Resources of page %d %s", page, verb),
+			Page:        page,
+		}, "", true
+	}
+	if label, ok := ctx.supporting[ptr]; ok {
+		return nil, label, true
+	}
+	if !ctx.reachable[ptr] {
+		return nil, "", true
+	}
+
+	switch {
+	case objType != "":
+		return nil, objType, false
+	case current.Kind() == pdf.Stream:
+		return nil, "Stream", false
+	}
+	return nil, "Object", false
+}
+
+func (ctx *documentContext) classifyAnnotation(annot pdf.Value, verb string) *types.DocumentModification {
+	page := ctx.annotations[annot.GetPtr()]
+	if page == 0 {
+		page = ctx.pages[annot.Key("P").GetPtr()]
+	}
+
+	subtype := annot.Key("Subtype").Name()
+	if subtype == "Widget" {
+		return fieldModification(annot, verb, page)
+	}
+
+	description := fmt.Sprintf("%s annotation %s", subtype, verb)
+	if page > 0 {
+		description += fmt.Sprintf(" on page %d", page)
+	}
+	return &types.DocumentModification{Kind: ModificationAnnotation, Description: description, Page: page}
+}
+
+// classifyPage reports a page whose own entries changed. Changes to its annotation list are
+// covered by the annotation objects, unless annotations were removed.
+func (ctx *documentContext) classifyPage(current, previous pdf.Value, verb string) *types.DocumentModification {
+	page := ctx.pages[current.GetPtr()]
+	if previous.IsNull() {
+		return &types.DocumentModification{Kind: ModificationPageContent, Description: fmt.Sprintf("Page %d added", page), Page: page}
+	}
+
+	for _, key := range unionKeys(current, previous) {
+		if key != "Annots" && current.Key(key).String() != previous.Key(key).String() {
+			return &types.DocumentModification{Kind: ModificationPageContent, Description: fmt.Sprintf("Page %d %s", page, verb), Page: page}
+		}
+	}
+
+	remaining := map[any]bool{}
+	annots := current.Key("Annots")
+	for i := 0; i < annots.Len(); i++ {
+		remaining[annots.Index(i).GetPtr()] = true
+	}
+	annots = previous.Key("Annots")
+	for i := 0; i < annots.Len(); i++ {
+		if !remaining[annots.Index(i).GetPtr()] {
+			return &types.DocumentModification{Kind: ModificationAnnotation, Description: fmt.Sprintf("Annotation removed from page %d", page), Page: page}
+		}
+	}
+	return nil
+}
+
+// classifyCatalog describes a changed catalog. Updates of the AcroForm and DSS entries support
+// other changes, and a catalog whose DSS alone changed is left to the validation data entry.
+func classifyCatalog(current, previous pdf.Value) (*types.DocumentModification, string, bool) {
+	if previous.IsNull() {
+		return &types.DocumentModification{Kind: ModificationDocument, Description: "Document catalog replaced"}, "", true
+	}
+
+	var changed, supporting []string
+	for _, key := range unionKeys(current, previous) {
+		if current.Key(key).String() == previous.Key(key).String() {
+			continue
+		}
+		if slices.Contains(catalogSupportKeys, key) {
+			supporting = append(supporting, key)
+		} else {
+			changed = append(changed, key)
+		}
+	}
+
+	switch {
+	case len(changed) > 0:
+		return &types.DocumentModification{
+			Kind:        ModificationDocument,
+			Description: "Document catalog entries changed (" + strings.Join(changed, ", ") + ")",
+		}, "", true
+	case slices.Equal(supporting, []string{"DSS"}):
+		return nil, "", true
+	}
+	return nil, "Catalog", true
+}
+
+// fieldModification describes a change to a form field or its widget.
+func fieldModification(field pdf.Value, verb string, page int) *types.DocumentModification {
+	name := fieldName(field)
+	m := &types.DocumentModification{Field: name, Page: page}
+
+	if inheritedKey(field, "FT").Name() == "Sig" {
+		m.Kind = ModificationSignature
+		m.Description = fmt.Sprintf("Signature field '%s' %s", name, verb)
+		if v := inheritedKey(field, "V"); v.Key("SubFilter").Name() == SubFilterDocTimeStamp {
+			m.Description = fmt.Sprintf("Document timestamp %s in field '%s'", verb, name)
+		} else if !v.IsNull() {
+			m.Description = fmt.Sprintf("Signature %s in field '%s'", verb, name)
+		}
+		return m
+	}
+
+	m.Kind = ModificationFormFill
+	m.Description = fmt.Sprintf("Form field '%s' %s", name, verb)
+	return m
+}
+
+// fieldName returns the full name of a field from its partial names up the field tree.
+func fieldName(field pdf.Value) string {
+	var parts []string
+	for depth := 0; depth < 32 && !field.IsNull(); depth++ {
+		if t := field.Key("T").Text(); t != "" {
+			parts = append([]string{t}, parts...)
+		}
+		field = field.Key("Parent")
+	}
+	return strings.Join(parts, ".")
+}
+
+// inheritedKey looks up an inheritable field entry on a field or its ancestors.
+func inheritedKey(field pdf.Value, key string) pdf.Value {
+	for depth := 0; depth < 32 && !field.IsNull(); depth++ {
+		if v := field.Key(key); !v.IsNull() {
+			return v
+		}
+		field = field.Key("Parent")
+	}
+	return pdf.Value{}
+}
+
+func newDocumentContext(rdr *pdf.Reader) *documentContext {
+	ctx := &documentContext{
+		pages:       map[any]int{},
+		contents:    map[any]int{},
+		resources:   map[any]int{},
+		annotations: map[any]int{},
+		signatures:  map[any]string{},
+		dss:         map[any]bool{},
+		supporting:  map[any]string{},
+		reachable:   map[any]bool{},
+	}
+
+	root := rdr.Trailer().Key("Root")
+	ctx.root = root.GetPtr()
+	ctx.reachable[ctx.root] = true
+	walkReferences(rdr.Trailer(), func(ptr any) bool {
+		if ctx.reachable[ptr] {
+			return false
+		}
+		ctx.reachable[ptr] = true
+		return true
+	})
+	var pages []pdf.Value
+	collectPages(root.Key("Pages"), &pages, 0)
+	for i, page := range pages {
+		number := i + 1
+		ctx.pages[page.GetPtr()] = number
+
+		contents := page.Key("Contents")
+		if contents.Kind() == pdf.Array {
+			for j := 0; j < contents.Len(); j++ {
+				ctx.contents[contents.Index(j).GetPtr()] = number
+			}
+		} else if !contents.IsNull() {
+			ctx.contents[contents.GetPtr()] = number
+		}
+
+		// Fonts, images and form XObjects drawn by the page are part of its content
+		walkReferences(inheritedKey(page, "Resources"), func(ptr any) bool {
+			if _, ok := ctx.resources[ptr]; ok {
+				return false
+			}
+			ctx.resources[ptr] = number
+			return true
+		})
+
+		annots := page.Key("Annots")
+		for j := 0; j < annots.Len(); j++ {
+			annot := annots.Index(j)
+			ctx.annotations[annot.GetPtr()] = number
+			ctx.addSupporting(annot.Key("AP"), "Appearance")
+			ctx.addSupporting(annot.Key("MK"), "Appearance")
+		}
+	}
+
+	acroForm := root.Key("AcroForm")
+	if acroForm.GetPtr() != root.GetPtr() {
+		ctx.supporting[acroForm.GetPtr()] = "AcroForm"
+	}
+	ctx.addSupporting(acroForm.Key("DR"), "AcroForm")
+	ctx.addSupporting(rdr.Trailer().Key("Info"), "Info")
+	ctx.addSupporting(root.Key("Metadata"), "Metadata")
+
+	for _, f := range signatureFields(root.Key("AcroForm").Key("Fields"), "") {
+		ctx.signatures[f.value.GetPtr()] = f.name
+	}
+
+	dss := root.Key("DSS")
+	for _, key := range []string{"Certs", "OCSPs", "CRLs"} {
+		items := dss.Key(key)
+		for i := 0; i < items.Len(); i++ {
+			ctx.dss[items.Index(i).GetPtr()] = true
+		}
+	}
+	vri := dss.Key("VRI")
+	ctx.dss[vri.GetPtr()] = !vri.IsNull()
+	for _, key := range vri.Keys() {
+		ctx.dss[vri.Key(key).GetPtr()] = true
+	}
+
+	return ctx
+}
+
+// addSupporting labels the objects v refers to, and v itself if it is an indirect object.
+func (ctx *documentContext) addSupporting(v pdf.Value, label string) {
+	if v.IsNull() {
+		return
+	}
+	ctx.supporting[v.GetPtr()] = label
+	walkReferences(v, func(ptr any) bool {
+		if _, ok := ctx.supporting[ptr]; ok {
+			return false
+		}
+		ctx.supporting[ptr] = label
+		return true
+	})
+}
+
+// walkReferences calls visit for each indirect object reachable from v, descending into an object
+// only when visit returns true. Parent links are not followed.
+func walkReferences(v pdf.Value, visit func(ptr any) bool) {
+	pending := []pdf.Value{v}
+	for len(pending) > 0 {
+		v := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		follow := func(child pdf.Value) {
+			if child.IsNull() {
+				return
+			}
+			// Direct objects share the pointer of the object containing them
+			if child.GetPtr() == v.GetPtr() || visit(child.GetPtr()) {
+				pending = append(pending, child)
+			}
+		}
+		switch v.Kind() {
+		case pdf.Dict, pdf.Stream:
+			for _, key := range v.Keys() {
+				if key != "Parent" && key != "P" {
+					follow(v.Key(key))
+				}
+			}
+		case pdf.Array:
+			for i := 0; i < v.Len(); i++ {
+				follow(v.Index(i))
+			}
+		}
+	}
+}
+
+// collectPages appends the pages of a page tree in document order.
+func collectPages(node pdf.Value, pages *[]pdf.Value, depth int) {
+	if depth > 32 {
+		return
+	}
+	if node.Key("Type").Name() == "Page" {
+		*pages = append(*pages, node)
+		return
+	}
+	kids := node.Key("Kids")
+	for i := 0; i < kids.Len(); i++ {
+		collectPages(kids.Index(i), pages, depth+1)
+	}
+}
+
+// sameObject reports whether an object is unchanged between two revisions. Streams are compared
+// by their decoded data, since an identical stream may be written at a different offset.
+func sameObject(a, b pdf.Value) bool {
+	if a.Kind() != pdf.Stream || b.Kind() != pdf.Stream {
+		return a.String() == b.String()
+	}
+
+	if streamHeader(a) != streamHeader(b) {
+		return false
+	}
+	dataA, okA := streamData(a)
+	dataB, okB := streamData(b)
+	return okA && okB && bytes.Equal(dataA, dataB)
+}
+
+func streamHeader(v pdf.Value) string {
+	s := v.String()
+	if i := strings.LastIndex(s, "@"); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+func streamData(v pdf.Value) (data []byte, ok bool) {
+	// Unsupported filters panic in the reader
+	defer func() {
+		if recover() != nil {
+			data, ok = nil, false
+		}
+	}()
+
+	data, err := io.ReadAll(v.Reader())
+	return data, err == nil
+}
+
+func unionKeys(a, b pdf.Value) []string {
+	keys := a.Keys()
+	for _, key := range b.Keys() {
+		if !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,204 @@
+package signature
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Matbe34/lankir/internal/signature/revocation"
+	"github.com/Matbe34/lankir/internal/signature/types"
+)
+
+// TestGetVerificationReport_NoModifications tests that an untouched signed PDF reports no changes
+func TestGetVerificationReport_NoModifications(t *testing.T) {
+	storeDir := t.TempDir()
+	service, _ := NewTestServiceWithStore(t, storeDir)
+	service.revocationChecker = revocation.NewChecker(t.TempDir(), nil)
+
+	signedPath, _ := SignTestPDF(t, service, storeDir)
+
+	report, err := service.GetVerificationReport(signedPath)
+	if err != nil {
+		t.Fatalf("GetVerificationReport failed: %v", err)
+	}
+
+	summary := report.Signatures[0].Summary
+	if summary.ModificationStatus != ModificationStatusNone {
+		t.Errorf("Expected status %q, got %q", ModificationStatusNone, summary.ModificationStatus)
+	}
+	if len(summary.Modifications) != 0 {
+		t.Errorf("Expected no modifications, got %+v", summary.Modifications)
+	}
+}
+
+// TestGetVerificationReport_DisallowedModifications tests that content and annotation changes
+// after a certification signature permitting only form filling are classified and disallowed
+func TestGetVerificationReport_DisallowedModifications(t *testing.T) {
+	storeDir := t.TempDir()
+	service, _ := NewTestServiceWithStore(t, storeDir)
+	service.revocationChecker = revocation.NewChecker(t.TempDir(), nil)
+
+	signedPath, _ := SignTestPDF(t, service, storeDir)
+	AppendIncrementalUpdate(t, signedPath, map[int]string{
+		4:  "<< /Length 44 >>\nstream\nBT /F1 24 Tf 100 700 Td (Changed!) Tj ET\nendstream",
+		20: "<< /Type /Annot /Subtype /Text /Rect [10 10 30 30] /P 3 0 R /Contents (Note) >>",
+	})
+
+	report, err := service.GetVerificationReport(signedPath)
+	if err != nil {
+		t.Fatalf("GetVerificationReport failed: %v", err)
+	}
+
+	summary := report.Signatures[0].Summary
+	if summary.ModificationStatus != ModificationStatusDisallowed {
+		t.Errorf("Expected status %q, got %q", ModificationStatusDisallowed, summary.ModificationStatus)
+	}
+
+	kinds := map[string]types.DocumentModification{}
+	for _, m := range summary.Modifications {
+		kinds[m.Kind] = m
+	}
+
+	content, ok := kinds[ModificationPageContent]
+	if !ok {
+		t.Fatalf("Expected a page content change, got %+v", summary.Modifications)
+	}
+	if content.Allowed || content.Page != 1 {
+		t.Errorf("Expected a disallowed change on page 1, got %+v", content)
+	}
+
+	annotation, ok := kinds[ModificationAnnotation]
+	if !ok {
+		t.Fatalf("Expected an annotation change, got %+v", summary.Modifications)
+	}
+	if annotation.Allowed || annotation.Page != 1 {
+		t.Errorf("Expected a disallowed annotation on page 1, got %+v", annotation)
+	}
+}
+
+// TestGetVerificationReport_ResourceModification tests that swapping a form XObject drawn by a
+// signed page is reported as a page content change
+func TestGetVerificationReport_ResourceModification(t *testing.T) {
+	storeDir := t.TempDir()
+	service, _ := NewTestServiceWithStore(t, storeDir)
+	service.revocationChecker = revocation.NewChecker(t.TempDir(), nil)
+
+	now := time.Now()
+	cert, key := CreateTestCertificate(t, "Report Signer", now.Add(-time.Hour), now.Add(24*time.Hour))
+	WriteTestPKCS12(t, storeDir, "signer.p12", cert, key)
+
+	pdfPath := filepath.Join(t.TempDir(), "doc.pdf")
+	WriteTestPDF(t, pdfPath, []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R /Resources << /XObject << /X1 5 0 R >> >> >>",
+		"<< /Length 8 >>\nstream\n/X1 Do\nendstream",
+		"<< /Type /XObject /Subtype /Form /BBox [0 0 100 100] /Length 16 >>\nstream\n0 0 100 100 re f\nendstream",
+	})
+	signedPath, err := service.SignPDF(pdfPath, CertificateFingerprint(cert), "")
+	if err != nil {
+		t.Fatalf("SignPDF failed: %v", err)
+	}
+
+	AppendIncrementalUpdate(t, signedPath, map[int]string{
+		5: "<< /Type /XObject /Subtype /Form /BBox [0 0 100 100] /Length 14 >>\nstream\n0 0 50 50 re f\nendstream",
+	})
+
+	report, err := service.GetVerificationReport(signedPath)
+	if err != nil {
+		t.Fatalf("GetVerificationReport failed: %v", err)
+	}
+
+	summary := report.Signatures[0].Summary
+	if summary.ModificationStatus != ModificationStatusDisallowed {
+		t.Errorf("Expected status %q, got %q", ModificationStatusDisallowed, summary.ModificationStatus)
+	}
+	if len(summary.Modifications) != 1 || summary.Modifications[0].Kind != ModificationPageContent || summary.Modifications[0].Page != 1 {
+		t.Errorf("Expected a content change on page 1, got %+v", summary.Modifications)
+	}
+}
+
+// TestGetVerificationReport_CatalogModification tests that adding an open action after signing
+// is reported as a disallowed document change
+func TestGetVerificationReport_CatalogModification(t *testing.T) {
+	storeDir := t.TempDir()
+	service, _ := NewTestServiceWithStore(t, storeDir)
+	service.revocationChecker = revocation.NewChecker(t.TempDir(), nil)
+
+	signedPath, _ := SignTestPDF(t, service, storeDir)
+
+	// Rewrite the catalog of the signed revision with an open action
+	data, err := os.ReadFile(signedPath)
+	if err != nil {
+		t.Fatalf("Failed to read PDF: %v", err)
+	}
+	roots := regexp.MustCompile(`/Root (\d+) 0 R`).FindAllSubmatch(data, -1)
+	root, _ := strconv.Atoi(string(roots[len(roots)-1][1]))
+	objects := regexp.MustCompile(fmt.Sprintf(`(?s)\n%d 0 obj\s*<<(.*?)>>\s*endobj`, root)).FindAllSubmatch(data, -1)
+	if len(objects) == 0 {
+		t.Fatalf("Catalog object %d not found", root)
+	}
+	catalog := string(objects[len(objects)-1][1])
+
+	AppendIncrementalUpdate(t, signedPath, map[int]string{
+		root: "<<" + catalog + " /OpenAction 40 0 R >>",
+		40:   "<< /S /JavaScript /JS (app.alert\\(1\\)) >>",
+	})
+
+	report, err := service.GetVerificationReport(signedPath)
+	if err != nil {
+		t.Fatalf("GetVerificationReport failed: %v", err)
+	}
+
+	summary := report.Signatures[0].Summary
+	if summary.ModificationStatus != ModificationStatusDisallowed {
+		t.Errorf("Expected status %q, got %q", ModificationStatusDisallowed, summary.ModificationStatus)
+	}
+	kinds := map[string]types.DocumentModification{}
+	for _, m := range summary.Modifications {
+		kinds[m.Kind] = m
+	}
+	if m, ok := kinds[ModificationDocument]; !ok || m.Allowed || !strings.Contains(m.Description, "OpenAction") {
+		t.Errorf("Expected a disallowed catalog change naming OpenAction, got %+v", summary.Modifications)
+	}
+}
+
+// TestChangePermissions_Judge tests how DocMDP levels and FieldMDP locks judge each kind of change
+func TestChangePermissions_Judge(t *testing.T) {
+	lock := &types.FieldMDPInfo{Action: "Include", Fields: []string{"name"}}
+
+	tests := []struct {
+		name    string
+		perms   changePermissions
+		change  types.DocumentModification
+		allowed bool
+	}{
+		{"form fill under level 1", changePermissions{docMDP: 1}, types.DocumentModification{Kind: ModificationFormFill, Field: "city"}, false},
+		{"form fill under level 2", changePermissions{docMDP: 2}, types.DocumentModification{Kind: ModificationFormFill, Field: "city"}, true},
+		{"annotation under level 2", changePermissions{docMDP: 2}, types.DocumentModification{Kind: ModificationAnnotation}, false},
+		{"annotation under level 3", changePermissions{docMDP: 3}, types.DocumentModification{Kind: ModificationAnnotation}, true},
+		{"annotation without certification", changePermissions{}, types.DocumentModification{Kind: ModificationAnnotation}, true},
+		{"page content without certification", changePermissions{}, types.DocumentModification{Kind: ModificationPageContent}, false},
+		{"document under level 3", changePermissions{docMDP: 3}, types.DocumentModification{Kind: ModificationDocument}, false},
+		{"unrecognized without certification", changePermissions{}, types.DocumentModification{Kind: ModificationUnrecognized}, false},
+		{"supporting objects under level 2", changePermissions{docMDP: 2}, types.DocumentModification{Kind: ModificationOther}, true},
+		{"signature under level 2", changePermissions{docMDP: 2}, types.DocumentModification{Kind: ModificationSignature, Field: "Signature 2"}, true},
+		{"locked field", changePermissions{locks: []*types.FieldMDPInfo{lock}}, types.DocumentModification{Kind: ModificationFormFill, Field: "name"}, false},
+		{"unlocked field", changePermissions{locks: []*types.FieldMDPInfo{lock}}, types.DocumentModification{Kind: ModificationFormFill, Field: "city"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes := []types.DocumentModification{tt.change}
+			tt.perms.judge(changes)
+			if changes[0].Allowed != tt.allowed {
+				t.Errorf("Expected allowed=%v, got %v (%s)", tt.allowed, changes[0].Allowed, changes[0].Reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package signature
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/digitorus/pdf"
+)
+
+var startXrefPattern = regexp.MustCompile(`startxref\s+(\d+)`)
+
+// recoverPDFPanic turns a panic of the PDF reader, which panics on malformed input, into an
+// error describing the failed action. It must be deferred directly by the reading function.
+func recoverPDFPanic(err *error, action string) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("failed to %s (%v)", action, r)
+	}
+}
+
+// incrementalUpdate appends new and replaced objects to a PDF without touching its existing
+// bytes, so signatures covering earlier revisions stay intact. The cross-reference section is
+// written in the same form, table or stream, as the one it extends.
+type incrementalUpdate struct {
+	data       []byte
+	trailer    pdf.Value
+	prev       int64
+	xrefStream bool
+	size       uint32
+	objects    map[uint32]updatedObject
+}
+
+type updatedObject struct {
+	gen  uint16
+	body []byte
+}
+
+func newIncrementalUpdate(data []byte, rdr *pdf.Reader) (*incrementalUpdate, error) {
+	trailer := rdr.Trailer()
+	if !trailer.Key("Encrypt").IsNull() {
+		return nil, fmt.Errorf("encrypted PDFs are not supported")
+	}
+
+	matches := startXrefPattern.FindAllSubmatch(data, -1)
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("startxref not found")
+	}
+	prev, err := strconv.ParseInt(string(matches[len(matches)-1][1]), 10, 64)
+	if err != nil || prev <= 0 || prev >= int64(len(data)) {
+		return nil, fmt.Errorf("invalid startxref offset")
+	}
+
+	size := trailer.Key("Size").Int64()
+	if size <= 0 {
+		return nil, fmt.Errorf("trailer has no valid /Size")
+	}
+
+	return &incrementalUpdate{
+		data:       data,
+		trailer:    trailer,
+		prev:       prev,
+		xrefStream: !bytes.HasPrefix(bytes.TrimLeft(data[prev:], " \t\r\n"), []byte("xref")),
+		size:       uint32(size),
+		objects:    map[uint32]updatedObject{},
+	}, nil
+}
+
+// add appends a new object and returns its number.
+func (u *incrementalUpdate) add(body []byte) uint32 {
+	id := u.size
+	u.size++
+	u.objects[id] = updatedObject{body: body}
+	return id
+}
+
+// addStream appends a Flate-compressed stream object and returns its number.
+func (u *incrementalUpdate) addStream(data []byte) uint32 {
+	var compressed bytes.Buffer
+	w := zlib.NewWriter(&compressed)
+	w.Write(data)
+	w.Close()
+
+	var body bytes.Buffer
+	fmt.Fprintf(&body, "<< /Length %d /Filter /FlateDecode >>\nstream\n", compressed.Len())
+	body.Write(compressed.Bytes())
+	body.WriteString("\nendstream")
+	return u.add(body.Bytes())
+}
+
+// replace writes a new version of an existing object.
+func (u *incrementalUpdate) replace(ptr pdf.Value, body []byte) {
+	p := ptr.GetPtr()
+	u.objects[p.GetID()] = updatedObject{gen: p.GetGen(), body: body}
+}
+
+// bytes returns the original document followed by the update.
+func (u *incrementalUpdate) bytes() []byte {
+	var out bytes.Buffer
+	out.Write(u.data)
+	if !bytes.HasSuffix(u.data, []byte("\n")) {
+		out.WriteString("\n")
+	}
+
+	ids := make([]uint32, 0, len(u.objects)+1)
+	for id := range u.objects {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	offsets := map[uint32]int64{}
+	for _, id := range ids {
+		obj := u.objects[id]
+		offsets[id] = int64(out.Len())
+		fmt.Fprintf(&out, "%d %d obj\n", id, obj.gen)
+		out.Write(obj.body)
+		out.WriteString("\nendobj\n")
+	}
+
+	var trailer bytes.Buffer
+	trailer.WriteString("/Root ")
+	writeValue(&trailer, u.trailer.Key("Root"), u.trailer)
+	fmt.Fprintf(&trailer, " /Prev %d", u.prev)
+	for _, key := range []string{"Info", "ID"} {
+		if v := u.trailer.Key(key); !v.IsNull() {
+			trailer.WriteString(" /" + key + " ")
+			writeValue(&trailer, v, u.trailer)
+		}
+	}
+
+	xrefOffset := int64(out.Len())
+	if u.xrefStream {
+		xrefID := u.size
+		ids = append(ids, xrefID)
+		offsets[xrefID] = xrefOffset
+
+		var entries bytes.Buffer
+		for _, id := range ids {
+			entries.WriteByte(1)
+			binary.Write(&entries, binary.BigEndian, uint32(offsets[id]))
+			binary.Write(&entries, binary.BigEndian, u.objects[id].gen)
+		}
+
+		fmt.Fprintf(&out, "%d 0 obj\n<< /Type /XRef /Size %d /W [1 4 2] /Index [%s] %s /Length %d >>\nstream\n",
+			xrefID, xrefID+1, xrefSubsections(ids), trailer.String(), entries.Len())
+		out.Write(entries.Bytes())
+		out.WriteString("\nendstream\nendobj\n")
+	} else {
+		out.WriteString("xref\n")
+		for start := 0; start < len(ids); {
+			end := start + 1
+			for end < len(ids) && ids[end] == ids[end-1]+1 {
+				end++
+			}
+			fmt.Fprintf(&out, "%d %d\n", ids[start], end-start)
+			for _, id := range ids[start:end] {
+				fmt.Fprintf(&out, "%010d %05d n\r\n", offsets[id], u.objects[id].gen)
+			}
+			start = end
+		}
+		fmt.Fprintf(&out, "trailer\n<< /Size %d %s >>\n", u.size, trailer.String())
+	}
+
+	fmt.Fprintf(&out, "startxref\n%d\n%%%%EOF\n", xrefOffset)
+	return out.Bytes()
+}
+
+// xrefSubsections formats sorted object numbers as the start and count pairs of an /Index array.
+func xrefSubsections(ids []uint32) string {
+	var buf bytes.Buffer
+	for start := 0; start < len(ids); {
+		end := start + 1
+		for end < len(ids) && ids[end] == ids[end-1]+1 {
+			end++
+		}
+		if buf.Len() > 0 {
+			buf.WriteByte(' ')
+		}
+		fmt.Fprintf(&buf, "%d %d", ids[start], end-start)
+		start = end
+	}
+	return buf.String()
+}
+
+// writeValue serializes a value read from a document. Values stored in other objects than
+// owner, the object being rewritten, are written as indirect references.
+func writeValue(buf *bytes.Buffer, v, owner pdf.Value) {
+	if v.IsNull() {
+		buf.WriteString("null")
+		return
+	}
+	if ptr, ownerPtr := v.GetPtr(), owner.GetPtr(); ptr != ownerPtr {
+		fmt.Fprintf(buf, "%d %d R", ptr.GetID(), ptr.GetGen())
+		return
+	}
+
+	switch v.Kind() {
+	case pdf.Bool:
+		buf.WriteString(strconv.FormatBool(v.Bool()))
+	case pdf.Integer:
+		buf.WriteString(strconv.FormatInt(v.Int64(), 10))
+	case pdf.Real:
+		buf.WriteString(strconv.FormatFloat(v.Float64(), 'f', -1, 64))
+	case pdf.String:
+		fmt.Fprintf(buf, "<%X>", v.RawString())
+	case pdf.Name:
+		buf.WriteString(pdfName(v.Name()))
+	case pdf.Array:
+		buf.WriteByte('[')
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				buf.WriteByte(' ')
+			}
+			writeValue(buf, v.Index(i), owner)
+		}
+		buf.WriteByte(']')
+	case pdf.Dict:
+		buf.WriteString("<<")
+		for _, key := range v.Keys() {
+			buf.WriteString(" " + pdfName(key) + " ")
+			writeValue(buf, v.Key(key), owner)
+		}
+		buf.WriteString(" >>")
+	default:
+		// Streams are always indirect objects and never reach this point
+		buf.WriteString("null")
+	}
+}
+
+// pdfName writes a name object, escaping delimiters and characters outside printable ASCII.
+func pdfName(name string) string {
+	var buf bytes.Buffer
+	buf.WriteByte('/')
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c < '!' || c > '~' || bytes.IndexByte([]byte("#()<>[]{}/%"), c) >= 0 {
+			fmt.Fprintf(&buf, "#%02X", c)
+			continue
+		}
+		buf.WriteByte(c)
+	}
+	return buf.String()
+}
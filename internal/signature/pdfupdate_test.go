@@ -0,0 +1,122 @@
+package signature
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/digitorus/pdf"
+)
+
+// createTestPDFWithXrefStream writes a minimal one-page PDF indexed by a cross-reference stream
+func createTestPDFWithXrefStream(t *testing.T) []byte {
+	t.Helper()
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] >>",
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.7\n")
+	var entries bytes.Buffer
+	entries.Write([]byte{0, 0, 0, 0, 0, 0xff, 0xff})
+	for i, obj := range objects {
+		entries.WriteByte(1)
+		binary.Write(&entries, binary.BigEndian, uint32(buf.Len()))
+		entries.Write([]byte{0, 0})
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+
+	xref := buf.Len()
+	id := len(objects) + 1
+	entries.WriteByte(1)
+	binary.Write(&entries, binary.BigEndian, uint32(xref))
+	entries.Write([]byte{0, 0})
+	fmt.Fprintf(&buf, "%d 0 obj\n<< /Type /XRef /Size %d /W [1 4 2] /Root 1 0 R /ID [<0102> <0102>] /Length %d >>\nstream\n",
+		id, id+1, entries.Len())
+	buf.Write(entries.Bytes())
+	fmt.Fprintf(&buf, "\nendstream\nendobj\nstartxref\n%d\n%%%%EOF\n", xref)
+	return buf.Bytes()
+}
+
+// TestIncrementalUpdate tests that updates are readable after both xref tables and xref streams
+func TestIncrementalUpdate(t *testing.T) {
+	tablePath := filepath.Join(t.TempDir(), "table.pdf")
+	CreateTestPDF(t, tablePath)
+	table, err := os.ReadFile(tablePath)
+	if err != nil {
+		t.Fatalf("Failed to read PDF: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		data       []byte
+		xrefStream bool
+	}{
+		{"xref table", table, false},
+		{"xref stream", createTestPDFWithXrefStream(t), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rdr, err := pdf.NewReader(bytes.NewReader(tt.data), int64(len(tt.data)))
+			if err != nil {
+				t.Fatalf("Failed to read PDF: %v", err)
+			}
+
+			update, err := newIncrementalUpdate(tt.data, rdr)
+			if err != nil {
+				t.Fatalf("newIncrementalUpdate failed: %v", err)
+			}
+			if update.xrefStream != tt.xrefStream {
+				t.Errorf("Expected xrefStream %v", tt.xrefStream)
+			}
+
+			root := rdr.Trailer().Key("Root")
+			streamID := update.addStream([]byte("validation data"))
+			var catalog bytes.Buffer
+			writeValue(&catalog, root, root)
+			update.replace(root, bytes.Replace(catalog.Bytes(), []byte("<<"), []byte(fmt.Sprintf("<< /Extra %d 0 R", streamID)), 1))
+
+			updated := update.bytes()
+			if !bytes.HasPrefix(updated, tt.data) {
+				t.Fatal("Update changed the original bytes")
+			}
+
+			rdr, err = pdf.NewReader(bytes.NewReader(updated), int64(len(updated)))
+			if err != nil {
+				t.Fatalf("Failed to read updated PDF: %v", err)
+			}
+			root = rdr.Trailer().Key("Root")
+			if root.Key("Pages").Key("Count").Int64() != 1 {
+				t.Error("Catalog lost its page tree")
+			}
+
+			var extra bytes.Buffer
+			extra.ReadFrom(root.Key("Extra").Reader())
+			if extra.String() != "validation data" {
+				t.Errorf("Unexpected stream content %q", extra.String())
+			}
+		})
+	}
+}
+
+// TestPDFName tests the escaping of name objects
+func TestPDFName(t *testing.T) {
+	tests := map[string]string{
+		"DSS":         "/DSS",
+		"A B":         "/A#20B",
+		"Name#(1)":    "/Name#23#281#29",
+		"Caf\xc3\xa9": "/Caf#C3#A9",
+	}
+	for name, expected := range tests {
+		if got := pdfName(name); got != expected {
+			t.Errorf("pdfName(%q) = %s, expected %s", name, got, expected)
+		}
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"crypto/x509"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/Matbe34/lankir/internal/signature/certutil"
 	"github.com/Matbe34/lankir/internal/signature/types"
@@ -16,6 +18,27 @@ var DefaultModules = []string{
 	"/usr/lib/x86_64-linux-gnu/opensc-pkcs11.so",
 }
 
+var (
+	moduleLocksMu sync.Mutex
+	moduleLocks   = make(map[string]*sync.Mutex)
+)
+
+// moduleLock returns the lock serializing C_Initialize/C_Finalize cycles of
+// short-lived contexts and open signers for one module, which would otherwise
+// finalize each other.
+// Different modules are independent and can be loaded concurrently.
+func moduleLock(modulePath string) *sync.Mutex {
+	moduleLocksMu.Lock()
+	defer moduleLocksMu.Unlock()
+
+	mu, ok := moduleLocks[modulePath]
+	if !ok {
+		mu = &sync.Mutex{}
+		moduleLocks[modulePath] = mu
+	}
+	return mu
+}
+
 // LoadCertificatesFromModules loads certificates from a list of PKCS#11 module paths.
 func LoadCertificatesFromModules(modulePaths []string) ([]types.Certificate, error) {
 	var certs []types.Certificate
@@ -34,12 +57,22 @@ func LoadCertificatesFromModules(modulePaths []string) ([]types.Certificate, err
 	return certs, nil
 }
 
+// LoadCertificatesFromModule validates and loads certificates from a single PKCS#11 module.
+// Unlike LoadCertificatesFromModules it reports why a module could not be used.
+func LoadCertificatesFromModule(modulePath string) ([]types.Certificate, error) {
+	if err := validatePKCS11Module(modulePath); err != nil {
+		return nil, err
+	}
+
+	return loadCertificatesFromModule(modulePath)
+}
+
 // validatePKCS11Module checks if a module file is safe to load.
 func validatePKCS11Module(modulePath string) error {
 	fileInfo, err := os.Stat(modulePath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return fmt.Errorf("module does not exist")
+			return fmt.Errorf("module does not exist: %w", err)
 		}
 		return fmt.Errorf("failed to stat module: %w", err)
 	}
@@ -74,6 +107,10 @@ func validatePKCS11Module(modulePath string) error {
 func loadCertificatesFromModule(modulePath string) ([]types.Certificate, error) {
 	var certs []types.Certificate
 
+	mu := moduleLock(modulePath)
+	mu.Lock()
+	defer mu.Unlock()
+
 	p := pkcs11.New(modulePath)
 	if p == nil {
 		return certs, fmt.Errorf("failed to load PKCS#11 module: %s", modulePath)
@@ -120,6 +157,7 @@ func loadCertificatesFromModule(modulePath string) ([]types.Certificate, error)
 			attrs, err := p.GetAttributeValue(session, obj, []*pkcs11.Attribute{
 				pkcs11.NewAttribute(pkcs11.CKA_VALUE, nil),
 				pkcs11.NewAttribute(pkcs11.CKA_LABEL, nil),
+				pkcs11.NewAttribute(pkcs11.CKA_ID, nil),
 			})
 			if err != nil {
 				continue
@@ -127,12 +165,16 @@ func loadCertificatesFromModule(modulePath string) ([]types.Certificate, error)
 
 			var certDER []byte
 			var labelBytes []byte
+			var id []byte
 
 			for _, attr := range attrs {
-				if attr.Type == pkcs11.CKA_VALUE {
+				switch attr.Type {
+				case pkcs11.CKA_VALUE:
 					certDER = attr.Value
-				} else if attr.Type == pkcs11.CKA_LABEL {
+				case pkcs11.CKA_LABEL:
 					labelBytes = attr.Value
+				case pkcs11.CKA_ID:
+					id = attr.Value
 				}
 			}
 
@@ -156,6 +198,7 @@ func loadCertificatesFromModule(modulePath string) ([]types.Certificate, error)
 				// PKCS#11 tokens usually require a PIN
 				c.RequiresPin = true
 				c.PinOptional = false
+				c.HasPrivateKey = hasPrivateKey(p, session, id, tokenInfo.Flags&pkcs11.CKF_LOGIN_REQUIRED != 0)
 
 				certs = append(certs, c)
 			}
@@ -166,3 +209,79 @@ func loadCertificatesFromModule(modulePath string) ([]types.Certificate, error)
 
 	return certs, nil
 }
+
+// TokenSnapshot returns a stable description of the tokens currently present in
+// the given modules. Two snapshots differ when a token was inserted or removed.
+func TokenSnapshot(modulePaths []string) string {
+	var entries []string
+
+	for _, modulePath := range modulePaths {
+		if err := validatePKCS11Module(modulePath); err != nil {
+			continue
+		}
+
+		entries = append(entries, moduleTokenEntries(modulePath)...)
+	}
+
+	sort.Strings(entries)
+	return strings.Join(entries, "\n")
+}
+
+// moduleTokenEntries lists "module|slot|label|serial" for each token present in a module
+func moduleTokenEntries(modulePath string) []string {
+	mu := moduleLock(modulePath)
+	mu.Lock()
+	defer mu.Unlock()
+
+	p := pkcs11.New(modulePath)
+	if p == nil {
+		return nil
+	}
+	defer p.Destroy()
+
+	if err := p.Initialize(); err != nil {
+		return nil
+	}
+	defer p.Finalize()
+
+	slots, err := p.GetSlotList(true)
+	if err != nil {
+		return nil
+	}
+
+	var entries []string
+	for _, slot := range slots {
+		tokenInfo, err := p.GetTokenInfo(slot)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, fmt.Sprintf("%s|%d|%s|%s", modulePath, slot,
+			strings.TrimSpace(tokenInfo.Label), strings.TrimSpace(tokenInfo.SerialNumber)))
+	}
+
+	return entries
+}
+
+// hasPrivateKey reports whether the token holds a private key with the given CKA_ID.
+// Private keys are usually only visible after login, so on tokens that require one
+// a certificate is assumed to have its key unless the key is found publicly.
+func hasPrivateKey(p *pkcs11.Ctx, session pkcs11.SessionHandle, id []byte, loginRequired bool) bool {
+	if len(id) == 0 {
+		return loginRequired
+	}
+
+	if err := p.FindObjectsInit(session, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PRIVATE_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_ID, id),
+	}); err != nil {
+		return loginRequired
+	}
+	defer p.FindObjectsFinal(session)
+
+	objs, _, err := p.FindObjects(session, 1)
+	if err != nil {
+		return loginRequired
+	}
+
+	return len(objs) > 0 || loginRequired
+}
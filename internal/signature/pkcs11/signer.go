@@ -22,6 +22,9 @@ type Signer struct {
 	modulePath string
 	mu         sync.Mutex
 	closed     bool
+	// moduleMu is the module lock held from GetSignerFromCertificate until Close,
+	// so that certificate loads and token polls cannot finalize the open context.
+	moduleMu *sync.Mutex
 }
 
 func (ps *Signer) Public() crypto.PublicKey {
@@ -106,16 +109,25 @@ func (ps *Signer) Close() error {
 		ps.p.Destroy()
 	}
 
+	if ps.moduleMu != nil {
+		ps.moduleMu.Unlock()
+	}
+
 	if len(errs) > 0 {
 		return fmt.Errorf("cleanup errors: %v", errs)
 	}
 	return nil
 }
 
-// GetSignerFromCertificate retrieves a PKCS#11 signer for the given certificate
+// GetSignerFromCertificate retrieves a PKCS#11 signer for the given certificate.
+// The module stays locked until the signer is closed.
 func GetSignerFromCertificate(modulePath, fingerprint string, pin string) (*Signer, error) {
+	mu := moduleLock(modulePath)
+	mu.Lock()
+
 	p := pkcs11.New(modulePath)
 	if p == nil {
+		mu.Unlock()
 		return nil, fmt.Errorf("failed to load PKCS#11 module: %s", modulePath)
 	}
 
@@ -130,6 +142,7 @@ func GetSignerFromCertificate(modulePath, fingerprint string, pin string) (*Sign
 			p.Finalize()
 		}
 		p.Destroy()
+		mu.Unlock()
 	}()
 
 	if err := p.Initialize(); err != nil {
@@ -160,6 +173,7 @@ func GetSignerFromCertificate(modulePath, fingerprint string, pin string) (*Sign
 
 		if signer != nil {
 			signer.modulePath = modulePath
+			signer.moduleMu = mu
 			returnedSigner = signer
 			return signer, nil
 		}
@@ -0,0 +1,55 @@
+package pkcs11
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSigner_HoldsModuleLock(t *testing.T) {
+	modulePath := filepath.Join(t.TempDir(), "missing-pkcs11.so")
+
+	mu := moduleLock(modulePath)
+	mu.Lock()
+	signer := &Signer{modulePath: modulePath, moduleMu: mu}
+
+	done := make(chan struct{})
+	go func() {
+		moduleTokenEntries(modulePath)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("token snapshot ran while a signer was open on the same module")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := signer.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("token snapshot still blocked after the signer was closed")
+	}
+
+	if err := signer.Close(); err != nil {
+		t.Fatalf("second Close() error = %v", err)
+	}
+}
+
+func TestGetSignerFromCertificate_ReleasesModuleLockOnError(t *testing.T) {
+	modulePath := filepath.Join(t.TempDir(), "missing-pkcs11.so")
+
+	if _, err := GetSignerFromCertificate(modulePath, "00", ""); err == nil {
+		t.Fatal("GetSignerFromCertificate() succeeded for a missing module")
+	}
+
+	mu := moduleLock(modulePath)
+	if !mu.TryLock() {
+		t.Fatal("module lock still held after a failed GetSignerFromCertificate")
+	}
+	mu.Unlock()
+}
@@ -18,6 +18,7 @@ import (
 // Signer implements crypto.Signer for PKCS#12 files
 type Signer struct {
 	cert       *x509.Certificate
+	caCerts    []*x509.Certificate
 	privateKey crypto.PrivateKey
 }
 
@@ -39,6 +40,11 @@ func (ps *Signer) Certificate() *x509.Certificate {
 	return ps.cert
 }
 
+// CertificateChain returns the CA certificates bundled in the PKCS#12 file
+func (ps *Signer) CertificateChain() []*x509.Certificate {
+	return ps.caCerts
+}
+
 // DefaultSystemCertDirs contains common system certificate directories on Linux
 var DefaultSystemCertDirs = []string{
 	"/etc/ssl/certs",
@@ -53,7 +59,7 @@ func LoadCertificatesFromSystemStore() ([]types.Certificate, error) {
 			continue
 		}
 
-		dirCerts, err := loadCertificatesFromDirectory(dir, "system")
+		dirCerts, err := loadCertificatesFromDirectory(dir, "system", ScanOptions{})
 		if err == nil {
 			certs = append(certs, dirCerts...)
 		}
@@ -82,7 +88,7 @@ func LoadCertificatesFromUserStore() ([]types.Certificate, error) {
 			continue
 		}
 
-		dirCerts, err := loadCertificatesFromDirectory(dir, "user")
+		dirCerts, err := loadCertificatesFromDirectory(dir, "user", ScanOptions{})
 		if err == nil {
 			certs = append(certs, dirCerts...)
 		}
@@ -91,57 +97,6 @@ func LoadCertificatesFromUserStore() ([]types.Certificate, error) {
 	return certs, nil
 }
 
-// loadCertificatesFromDirectory loads certificates from a directory
-func loadCertificatesFromDirectory(dir string, source string) ([]types.Certificate, error) {
-	var certs []types.Certificate
-
-	entries, err := os.ReadDir(dir)
-	if err != nil {
-		return certs, err
-	}
-
-	for _, entry := range entries {
-		if entry.IsDir() {
-			continue
-		}
-
-		filePath := filepath.Join(dir, entry.Name())
-		fileName := entry.Name()
-
-		// Check for certificate file extensions
-		ext := strings.ToLower(filepath.Ext(fileName))
-		if ext == ".crt" || ext == ".cer" || ext == ".pem" || ext == ".p12" || ext == ".pfx" {
-			// Handle PKCS#12 files differently
-			if ext == ".p12" || ext == ".pfx" {
-				cert, err := LoadCertificateFromPKCS12File(filePath)
-				if err == nil && cert != nil {
-					certs = append(certs, *cert)
-				}
-				continue
-			}
-
-			// Read and parse the certificate
-			data, err := os.ReadFile(filePath)
-			if err != nil {
-				continue
-			}
-
-			cert, err := parseCertificate(data)
-			if err != nil {
-				continue
-			}
-
-			if certutil.IsCertificateValidForSigning(cert) {
-				c := certutil.ConvertX509Certificate(cert, source, fileName)
-				c.FilePath = filePath
-				certs = append(certs, c)
-			}
-		}
-	}
-
-	return certs, nil
-}
-
 // parseCertificate attempts to parse a certificate from various formats
 func parseCertificate(data []byte) (*x509.Certificate, error) {
 	// Try DER format first
@@ -150,10 +105,16 @@ func parseCertificate(data []byte) (*x509.Certificate, error) {
 		return cert, nil
 	}
 
-	// Try PEM format
-	block, _ := pem.Decode(data)
-	if block != nil {
-		return x509.ParseCertificate(block.Bytes)
+	// Try PEM format, skipping blocks such as private keys that precede the certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type == "CERTIFICATE" {
+			return x509.ParseCertificate(block.Bytes)
+		}
 	}
 
 	return nil, fmt.Errorf("failed to parse certificate")
@@ -220,7 +181,7 @@ func GetSignerFromPKCS12File(filePath string, password string) (*Signer, error)
 		return nil, fmt.Errorf("failed to read PKCS#12 file: %w", err)
 	}
 
-	privateKey, cert, _, err := goPkcs12.DecodeChain(data, password)
+	privateKey, cert, caCerts, err := goPkcs12.DecodeChain(data, password)
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode PKCS#12 file: %w", err)
 	}
@@ -235,6 +196,7 @@ func GetSignerFromPKCS12File(filePath string, password string) (*Signer, error)
 
 	return &Signer{
 		cert:       cert,
+		caCerts:    caCerts,
 		privateKey: privateKey,
 	}, nil
 }
@@ -262,6 +224,7 @@ func LoadCertificateFromPKCS12File(filePath string) (*types.Certificate, error)
 		c := certutil.ConvertX509Certificate(x509Cert, "User File", name)
 		c.FilePath = filePath
 		// If we have private key, it can sign
+		c.HasPrivateKey = privateKey != nil
 		if privateKey != nil {
 			// It technically doesn't require PIN if we opened it with empty string,
 			// but usually we treat empty password as "no PIN".
@@ -275,26 +238,37 @@ func LoadCertificateFromPKCS12File(filePath string) (*types.Certificate, error)
 	// If we can't open it, assume it requires PIN
 	// We can't get details, but we return the file info
 	cert.RequiresPin = true
+	// PKCS#12 files are bundles of a certificate and its key
+	cert.HasPrivateKey = true
 	// We mark it as valid for listing purposes, but it can't be used without PIN
 	cert.IsValid = true
 
 	return cert, nil
 }
 
-// LoadCertificatesFromPath loads certificates from a file or directory
+// LoadCertificatesFromPath loads certificates from a file or the top level of a directory
 func LoadCertificatesFromPath(path string) ([]types.Certificate, error) {
+	return LoadCertificatesFromPathWithOptions(path, ScanOptions{})
+}
+
+// LoadCertificatesFromPathWithOptions loads certificates from a file or a directory scanned with opts
+func LoadCertificatesFromPathWithOptions(path string, opts ScanOptions) ([]types.Certificate, error) {
 	info, err := os.Stat(path)
 	if err != nil {
 		return nil, err
 	}
 
 	if info.IsDir() {
-		return loadCertificatesFromDirectory(path, "User Store")
+		return loadCertificatesFromDirectory(path, "User Store", opts)
 	}
 
 	// It's a file
-	ext := strings.ToLower(filepath.Ext(path))
-	if ext == ".p12" || ext == ".pfx" {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if sniffCertificateFile(data) == fileKindPKCS12 {
 		cert, err := LoadCertificateFromPKCS12File(path)
 		if err != nil {
 			return nil, err
@@ -305,12 +279,6 @@ func LoadCertificatesFromPath(path string) ([]types.Certificate, error) {
 		return nil, nil
 	}
 
-	// Try loading as normal cert
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return nil, err
-	}
-
 	cert, err := parseCertificate(data)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,284 @@
+package pkcs12
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/Matbe34/lankir/internal/signature/certutil"
+	"github.com/Matbe34/lankir/internal/signature/types"
+)
+
+// DefaultMaxScanDepth limits recursion when a recursive store does not set MaxDepth.
+const DefaultMaxScanDepth = 8
+
+// maxCertificateFileSize is the largest file that is read when sniffing store contents.
+const maxCertificateFileSize = 1 << 20
+
+// ScanOptions controls how a certificate store directory is walked.
+type ScanOptions struct {
+	Recursive bool     // Descend into subdirectories
+	MaxDepth  int      // Maximum subdirectory depth when recursive (0 = DefaultMaxScanDepth)
+	Include   []string // Glob patterns a file must match to be loaded (empty = all files)
+	Exclude   []string // Glob patterns for files and directories to skip
+}
+
+// Validate checks that all include and exclude patterns are well-formed.
+func (o ScanOptions) Validate() error {
+	for _, pattern := range append(append([]string{}, o.Include...), o.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid store pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+func (o ScanOptions) maxDepth() int {
+	if !o.Recursive {
+		return 0
+	}
+	if o.MaxDepth <= 0 {
+		return DefaultMaxScanDepth
+	}
+	return o.MaxDepth
+}
+
+// fileKind is the certificate format detected from a file's content.
+type fileKind int
+
+const (
+	fileKindUnknown fileKind = iota
+	fileKindCertificate
+	fileKindPKCS12
+)
+
+// sniffCertificateFile detects certificates and PKCS#12 containers by content rather than extension.
+func sniffCertificateFile(data []byte) fileKind {
+	if bytes.Contains(data, []byte("-----BEGIN CERTIFICATE-----")) {
+		return fileKindCertificate
+	}
+
+	if len(data) == 0 || data[0] != 0x30 {
+		return fileKindUnknown
+	}
+
+	if _, err := x509.ParseCertificate(data); err == nil {
+		return fileKindCertificate
+	}
+
+	if isPKCS12(data) {
+		return fileKindPKCS12
+	}
+
+	return fileKindUnknown
+}
+
+// pfxHeader matches the outer structure of a PKCS#12 PFX (RFC 7292 section 4).
+type pfxHeader struct {
+	Version  int
+	AuthSafe struct {
+		ContentType asn1.ObjectIdentifier
+		Content     asn1.RawValue `asn1:"tag:0,explicit,optional"`
+	}
+	MacData asn1.RawValue `asn1:"optional"`
+}
+
+var (
+	oidDataContentType       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidSignedDataContentType = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	pkcs12SupportedVersion   = 3
+)
+
+func isPKCS12(data []byte) bool {
+	var pfx pfxHeader
+	if _, err := asn1.Unmarshal(data, &pfx); err != nil {
+		return false
+	}
+
+	if pfx.Version != pkcs12SupportedVersion {
+		return false
+	}
+
+	return pfx.AuthSafe.ContentType.Equal(oidDataContentType) ||
+		pfx.AuthSafe.ContentType.Equal(oidSignedDataContentType)
+}
+
+// matchesPattern reports whether the slash-separated path rel matches a glob pattern.
+// Patterns without a slash match the base name; "**" matches any number of directories.
+func matchesPattern(pattern, rel string) bool {
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(rel))
+		return ok
+	}
+
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(rel, "/"))
+}
+
+func matchSegments(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(parts); i++ {
+				if matchSegments(pattern[1:], parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], parts[0]); !ok {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+
+	return len(parts) == 0
+}
+
+func matchesAnyPattern(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		if matchesPattern(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// storeWalker walks a store directory, following symlinks while guarding against loops.
+type storeWalker struct {
+	opts    ScanOptions
+	visited map[string]bool // resolved paths of directories and files already seen
+	visit   func(filePath string, info os.FileInfo)
+	dirs    []string
+}
+
+func newStoreWalker(opts ScanOptions, visit func(filePath string, info os.FileInfo)) *storeWalker {
+	return &storeWalker{
+		opts:    opts,
+		visited: make(map[string]bool),
+		visit:   visit,
+	}
+}
+
+// seen marks a path as visited by its resolved location and reports whether it was visited before.
+func (w *storeWalker) seen(p string) bool {
+	resolved, err := filepath.EvalSymlinks(p)
+	if err != nil {
+		resolved = p
+	}
+	if w.visited[resolved] {
+		return true
+	}
+	w.visited[resolved] = true
+	return false
+}
+
+func (w *storeWalker) walk(dir, rel string, depth int) error {
+	if w.seen(dir) {
+		return nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	w.dirs = append(w.dirs, dir)
+
+	for _, entry := range entries {
+		entryPath := filepath.Join(dir, entry.Name())
+		entryRel := path.Join(rel, entry.Name())
+
+		// Stat follows symlinks so linked files and directories are treated like their targets
+		info, err := os.Stat(entryPath)
+		if err != nil {
+			continue
+		}
+
+		if matchesAnyPattern(w.opts.Exclude, entryRel) {
+			continue
+		}
+
+		if info.IsDir() {
+			if depth < w.opts.maxDepth() {
+				// Unreadable subdirectories are skipped so one bad folder does not hide the store
+				_ = w.walk(entryPath, entryRel, depth+1)
+			}
+			continue
+		}
+
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		if len(w.opts.Include) > 0 && !matchesAnyPattern(w.opts.Include, entryRel) {
+			continue
+		}
+		if w.seen(entryPath) {
+			continue
+		}
+
+		w.visit(entryPath, info)
+	}
+
+	return nil
+}
+
+// ScanDirectories returns every directory that a scan of root with opts would read.
+func ScanDirectories(root string, opts ScanOptions) ([]string, error) {
+	w := newStoreWalker(opts, func(string, os.FileInfo) {})
+	if err := w.walk(root, "", 0); err != nil {
+		return nil, err
+	}
+	return w.dirs, nil
+}
+
+// loadCertificatesFromDirectory loads certificates from a directory, detecting formats by content
+func loadCertificatesFromDirectory(dir string, source string, opts ScanOptions) ([]types.Certificate, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	var certs []types.Certificate
+
+	w := newStoreWalker(opts, func(filePath string, info os.FileInfo) {
+		if info.Size() > maxCertificateFileSize {
+			return
+		}
+
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return
+		}
+
+		switch sniffCertificateFile(data) {
+		case fileKindPKCS12:
+			cert, err := LoadCertificateFromPKCS12File(filePath)
+			if err == nil && cert != nil {
+				certs = append(certs, *cert)
+			}
+		case fileKindCertificate:
+			cert, err := parseCertificate(data)
+			if err != nil {
+				return
+			}
+
+			if certutil.IsCertificateValidForSigning(cert) {
+				c := certutil.ConvertX509Certificate(cert, source, filepath.Base(filePath))
+				c.FilePath = filePath
+				certs = append(certs, c)
+			}
+		}
+	})
+
+	if err := w.walk(dir, "", 0); err != nil {
+		return certs, err
+	}
+
+	return certs, nil
+}
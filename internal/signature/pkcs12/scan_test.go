@@ -0,0 +1,214 @@
+package pkcs12
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	goPkcs12 "software.sslmate.com/src/go-pkcs12"
+)
+
+// createScanCertificate creates a self-signed signing certificate and its key
+func createScanCertificate(t *testing.T, commonName string) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	return cert, key
+}
+
+// writeScanPEM writes a PEM certificate named name below dir, creating parent directories
+func writeScanPEM(t *testing.T, dir, name string) {
+	t.Helper()
+
+	cert, _ := createScanCertificate(t, name)
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0644); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+}
+
+// scannedFiles returns the store-relative paths of the certificates a scan of dir loads
+func scannedFiles(t *testing.T, dir string, opts ScanOptions) []string {
+	t.Helper()
+
+	certs, err := LoadCertificatesFromPathWithOptions(dir, opts)
+	if err != nil {
+		t.Fatalf("LoadCertificatesFromPathWithOptions failed: %v", err)
+	}
+
+	var files []string
+	for _, cert := range certs {
+		rel, err := filepath.Rel(dir, cert.FilePath)
+		if err != nil {
+			t.Fatalf("Certificate outside the store: %s", cert.FilePath)
+		}
+		files = append(files, filepath.ToSlash(rel))
+	}
+	sort.Strings(files)
+	return files
+}
+
+func equalFiles(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// TestScan_DepthLimit tests that recursion stops at the configured depth
+func TestScan_DepthLimit(t *testing.T) {
+	dir := t.TempDir()
+	writeScanPEM(t, dir, "top.pem")
+	writeScanPEM(t, dir, "a/one.pem")
+	writeScanPEM(t, dir, "a/b/two.pem")
+	writeScanPEM(t, dir, "a/b/c/three.pem")
+
+	tests := []struct {
+		name string
+		opts ScanOptions
+		want []string
+	}{
+		{"not recursive", ScanOptions{MaxDepth: 5}, []string{"top.pem"}},
+		{"depth 1", ScanOptions{Recursive: true, MaxDepth: 1}, []string{"a/one.pem", "top.pem"}},
+		{"depth 2", ScanOptions{Recursive: true, MaxDepth: 2}, []string{"a/b/two.pem", "a/one.pem", "top.pem"}},
+		{"default depth", ScanOptions{Recursive: true}, []string{"a/b/c/three.pem", "a/b/two.pem", "a/one.pem", "top.pem"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scannedFiles(t, dir, tt.opts); !equalFiles(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+// TestScan_Patterns tests include and exclude globs on names and relative paths
+func TestScan_Patterns(t *testing.T) {
+	dir := t.TempDir()
+	writeScanPEM(t, dir, "signing.pem")
+	writeScanPEM(t, dir, "signing.crt")
+	writeScanPEM(t, dir, "archive/old.pem")
+	writeScanPEM(t, dir, "2024/q1/deep.pem")
+
+	tests := []struct {
+		name string
+		opts ScanOptions
+		want []string
+	}{
+		{"include by name", ScanOptions{Recursive: true, Include: []string{"*.pem"}}, []string{"2024/q1/deep.pem", "archive/old.pem", "signing.pem"}},
+		{"exclude directory", ScanOptions{Recursive: true, Exclude: []string{"archive"}}, []string{"2024/q1/deep.pem", "signing.crt", "signing.pem"}},
+		{"include path with double star", ScanOptions{Recursive: true, Include: []string{"20*/**/*.pem"}}, []string{"2024/q1/deep.pem"}},
+		{"exclude wins over include", ScanOptions{Recursive: true, Include: []string{"*.pem"}, Exclude: []string{"old.*"}}, []string{"2024/q1/deep.pem", "signing.pem"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scannedFiles(t, dir, tt.opts); !equalFiles(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+// TestScan_InvalidPattern tests that malformed globs are rejected
+func TestScan_InvalidPattern(t *testing.T) {
+	opts := ScanOptions{Include: []string{"[*.pem"}}
+	if err := opts.Validate(); err == nil {
+		t.Error("Expected an error for a malformed pattern")
+	}
+	if _, err := LoadCertificatesFromPathWithOptions(t.TempDir(), opts); err == nil {
+		t.Error("Expected the scan to fail with a malformed pattern")
+	}
+}
+
+// TestScan_ContentSniffing tests that certificates and PKCS#12 files are found whatever their extension
+func TestScan_ContentSniffing(t *testing.T) {
+	dir := t.TempDir()
+	writeScanPEM(t, dir, "backup.cert-old")
+
+	cert, key := createScanCertificate(t, "Odd PKCS12")
+	p12, err := goPkcs12.Modern.Encode(key, cert, nil, "")
+	if err != nil {
+		t.Fatalf("Failed to encode PKCS#12: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "KEYSTORE.bin"), p12, 0600); err != nil {
+		t.Fatalf("Failed to write PKCS#12: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "der.txt"), cert.Raw, 0644); err != nil {
+		t.Fatalf("Failed to write DER certificate: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.pem"), []byte("not a certificate"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	want := []string{"KEYSTORE.bin", "backup.cert-old", "der.txt"}
+	if got := scannedFiles(t, dir, ScanOptions{}); !equalFiles(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	if kind := sniffCertificateFile(p12); kind != fileKindPKCS12 {
+		t.Errorf("Expected PKCS#12 to be sniffed as %d, got %d", fileKindPKCS12, kind)
+	}
+}
+
+// TestScan_SymlinkLoop tests that a symlink back to a parent directory neither loops nor loads twice
+func TestScan_SymlinkLoop(t *testing.T) {
+	dir := t.TempDir()
+	writeScanPEM(t, dir, "nested/cert.pem")
+	if err := os.Symlink(dir, filepath.Join(dir, "nested", "loop")); err != nil {
+		t.Skipf("Symlinks not supported: %v", err)
+	}
+
+	opts := ScanOptions{Recursive: true, MaxDepth: 50}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = ScanDirectories(dir, opts)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Scan did not terminate on a symlink loop")
+	}
+
+	if got, want := scannedFiles(t, dir, opts), []string{"nested/cert.pem"}; !equalFiles(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
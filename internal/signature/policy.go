@@ -0,0 +1,123 @@
+package signature
+
+import (
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/Matbe34/lankir/internal/config"
+	"github.com/Matbe34/lankir/internal/signature/certutil"
+	"github.com/Matbe34/lankir/internal/signature/types"
+)
+
+// Severities of a types.PolicyViolation
+const (
+	PolicySeverityWarning = "warning"
+	PolicySeverityError   = "error"
+)
+
+// signingDigestName is the digest algorithm used for new signatures
+const signingDigestName = "SHA-256"
+
+// certificateDigests names the digest of the certificate signature algorithms a policy may reject
+var certificateDigests = map[x509.SignatureAlgorithm]string{
+	x509.MD2WithRSA:    "MD2",
+	x509.MD5WithRSA:    "MD5",
+	x509.SHA1WithRSA:   "SHA-1",
+	x509.DSAWithSHA1:   "SHA-1",
+	x509.ECDSAWithSHA1: "SHA-1",
+}
+
+// algorithmPolicy returns the configured algorithm policy, or the default one without a config.
+func (s *SignatureService) algorithmPolicy() config.AlgorithmPolicy {
+	if s.configService == nil {
+		return config.DefaultAlgorithmPolicy()
+	}
+	return s.configService.Get().AlgorithmPolicy
+}
+
+// checkSigningAlgorithms refuses to sign with a key or digest the algorithm policy forbids, and
+// warns about a deprecated digest.
+func (s *SignatureService) checkSigningAlgorithms(cert *types.Certificate) error {
+	policy := s.algorithmPolicy()
+
+	switch {
+	case listsDigest(policy.ForbiddenDigests, signingDigestName):
+		return fmt.Errorf("digest algorithm %s is forbidden by the algorithm policy", signingDigestName)
+	case listsDigest(policy.DeprecatedDigests, signingDigestName):
+		slog.Warn("signing with a digest algorithm deprecated by the algorithm policy", "digest", signingDigestName)
+	}
+	if msg := keySizeViolation(policy, cert.KeyAlgorithm, cert.KeySize); msg != "" {
+		return fmt.Errorf("certificate '%s' cannot be used for signing: %s", cert.Name, msg)
+	}
+	return nil
+}
+
+// policyViolations checks the digest algorithm of a signature and its embedded certificates
+// against the algorithm policy. The signatures of self-signed roots are not checked, since
+// trust in them does not rest on their signature.
+func policyViolations(policy config.AlgorithmPolicy, digest string, certs []*x509.Certificate) []types.PolicyViolation {
+	var violations []types.PolicyViolation
+	add := func(severity, msg string) {
+		violations = append(violations, types.PolicyViolation{Severity: severity, Message: msg})
+	}
+
+	switch {
+	case listsDigest(policy.ForbiddenDigests, digest):
+		add(PolicySeverityError, fmt.Sprintf("Digest algorithm %s is forbidden", digest))
+	case listsDigest(policy.DeprecatedDigests, digest):
+		add(PolicySeverityWarning, fmt.Sprintf("Digest algorithm %s is deprecated", digest))
+	}
+
+	for _, cert := range certs {
+		name := cert.Subject.CommonName
+		if name == "" {
+			name = cert.Subject.String()
+		}
+
+		algorithm, size := certutil.PublicKeyInfo(cert)
+		if msg := keySizeViolation(policy, algorithm, size); msg != "" {
+			add(PolicySeverityError, fmt.Sprintf("Certificate '%s': %s", name, msg))
+		}
+
+		certDigest, ok := certificateDigests[cert.SignatureAlgorithm]
+		if !ok || certutil.IsSelfSigned(cert) {
+			continue
+		}
+		switch {
+		case listsDigest(policy.ForbiddenDigests, certDigest):
+			add(PolicySeverityError, fmt.Sprintf("Certificate '%s' is signed with forbidden digest %s", name, certDigest))
+		case listsDigest(policy.DeprecatedDigests, certDigest):
+			add(PolicySeverityWarning, fmt.Sprintf("Certificate '%s' is signed with deprecated digest %s", name, certDigest))
+		}
+	}
+
+	return violations
+}
+
+// keySizeViolation describes a key smaller than the policy minimum, or returns "".
+func keySizeViolation(policy config.AlgorithmPolicy, algorithm string, size int) string {
+	var minimum int
+	switch algorithm {
+	case "RSA":
+		minimum = policy.MinRSAKeySize
+	case "ECDSA":
+		minimum = policy.MinECKeySize
+	}
+	if size > 0 && size < minimum {
+		return fmt.Sprintf("%s key of %d bits is below the policy minimum of %d bits", algorithm, size, minimum)
+	}
+	return ""
+}
+
+// listsDigest reports whether a digest is in a policy list, ignoring case and dashes.
+func listsDigest(list []string, digest string) bool {
+	normalize := func(name string) string {
+		return strings.ReplaceAll(strings.ToUpper(name), "-", "")
+	}
+	return digest != "" && slices.ContainsFunc(list, func(name string) bool {
+		return normalize(name) == normalize(digest)
+	})
+}
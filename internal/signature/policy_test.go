@@ -0,0 +1,167 @@
+package signature
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Matbe34/lankir/internal/config"
+	"github.com/Matbe34/lankir/internal/signature/revocation"
+)
+
+// createTestRSACertificate creates a self-signed signing certificate with an RSA key of the given size
+func createTestRSACertificate(t *testing.T, commonName string, bits int) (*x509.Certificate, *rsa.PrivateKey) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+
+	now := time.Now()
+	cert := CreateTestCertificateFromTemplate(t, &x509.Certificate{
+		Subject:   pkix.Name{CommonName: commonName},
+		NotBefore: now.Add(-time.Hour),
+		NotAfter:  now.Add(24 * time.Hour),
+		KeyUsage:  x509.KeyUsageDigitalSignature | x509.KeyUsageContentCommitment,
+	}, key)
+	return cert, key
+}
+
+// TestPolicyViolations tests the default policy against digests and key sizes
+func TestPolicyViolations(t *testing.T) {
+	policy := config.DefaultAlgorithmPolicy()
+	weak, _ := createTestRSACertificate(t, "Weak Key", 1024)
+	strong, _ := CreateTestCertificate(t, "Strong Key", time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+
+	tests := []struct {
+		name     string
+		digest   string
+		cert     *x509.Certificate
+		expected []string
+	}{
+		{"SHA-256 with P-256 key", "SHA-256", strong, nil},
+		{"SHA-1 digest", "SHA-1", strong, []string{PolicySeverityWarning}},
+		{"MD5 digest", "MD5", strong, []string{PolicySeverityError}},
+		{"RSA 1024 key", "SHA-256", weak, []string{PolicySeverityError}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations := policyViolations(policy, tt.digest, []*x509.Certificate{tt.cert})
+			if len(violations) != len(tt.expected) {
+				t.Fatalf("Expected %d violations, got %+v", len(tt.expected), violations)
+			}
+			for i, v := range violations {
+				if v.Severity != tt.expected[i] {
+					t.Errorf("Expected severity %s, got %s (%s)", tt.expected[i], v.Severity, v.Message)
+				}
+			}
+		})
+	}
+}
+
+// TestListsDigest tests that digest names match regardless of case and dashes
+func TestListsDigest(t *testing.T) {
+	if !listsDigest([]string{"sha1"}, "SHA-1") {
+		t.Error("Expected sha1 to match SHA-1")
+	}
+	if listsDigest([]string{"SHA-1"}, "SHA-256") {
+		t.Error("Expected SHA-1 not to match SHA-256")
+	}
+}
+
+// TestSignPDF_PolicyRejectsSmallKey tests that signing refuses keys below the policy minimum
+func TestSignPDF_PolicyRejectsSmallKey(t *testing.T) {
+	storeDir := t.TempDir()
+	service, _ := NewTestServiceWithStore(t, storeDir)
+	service.revocationChecker = revocation.NewChecker(t.TempDir(), nil)
+
+	cert, key := createTestRSACertificate(t, "Weak Signer", 1024)
+	WriteTestPKCS12(t, storeDir, "weak.p12", cert, key)
+
+	pdfPath := filepath.Join(t.TempDir(), "doc.pdf")
+	CreateTestPDF(t, pdfPath)
+
+	_, err := service.SignPDF(pdfPath, CertificateFingerprint(cert), "")
+	if err == nil {
+		t.Fatal("Expected signing with a 1024-bit RSA key to fail")
+	}
+	if !strings.Contains(err.Error(), "policy minimum") {
+		t.Errorf("Expected a policy error, got: %v", err)
+	}
+}
+
+// TestSignPDF_PolicyDigests tests that signing refuses a forbidden digest but only warns about a
+// deprecated one
+func TestSignPDF_PolicyDigests(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  config.AlgorithmPolicy
+		wantErr bool
+	}{
+		{"deprecated digest", config.AlgorithmPolicy{DeprecatedDigests: []string{signingDigestName}}, false},
+		{"forbidden digest", config.AlgorithmPolicy{ForbiddenDigests: []string{signingDigestName}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storeDir := t.TempDir()
+			service, cfgService := NewTestServiceWithStore(t, storeDir)
+			service.revocationChecker = revocation.NewChecker(t.TempDir(), nil)
+
+			cfg := cfgService.Get()
+			cfg.AlgorithmPolicy = tt.policy
+			if err := cfgService.Update(cfg); err != nil {
+				t.Fatalf("Update failed: %v", err)
+			}
+
+			now := time.Now()
+			cert, key := CreateTestCertificate(t, "Policy Signer", now.Add(-time.Hour), now.Add(24*time.Hour))
+			WriteTestPKCS12(t, storeDir, "signer.p12", cert, key)
+			pdfPath := filepath.Join(t.TempDir(), "doc.pdf")
+			CreateTestPDF(t, pdfPath)
+
+			_, err := service.SignPDF(pdfPath, CertificateFingerprint(cert), "")
+			if tt.wantErr && err == nil {
+				t.Error("Expected signing to be refused")
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("Expected signing to succeed, got: %v", err)
+			}
+		})
+	}
+}
+
+// TestGetVerificationReport_PolicyViolation tests that a configured policy invalidates signatures it rejects
+func TestGetVerificationReport_PolicyViolation(t *testing.T) {
+	storeDir := t.TempDir()
+	service, cfgService := NewTestServiceWithStore(t, storeDir)
+	service.revocationChecker = revocation.NewChecker(t.TempDir(), nil)
+
+	signedPath, _ := SignTestPDF(t, service, storeDir)
+
+	cfg := cfgService.Get()
+	cfg.AlgorithmPolicy.MinECKeySize = 384
+	if err := cfgService.Update(cfg); err != nil {
+		t.Fatalf("Failed to update config: %v", err)
+	}
+
+	report, err := service.GetVerificationReport(signedPath)
+	if err != nil {
+		t.Fatalf("GetVerificationReport failed: %v", err)
+	}
+
+	summary := report.Signatures[0].Summary
+	if summary.IsValid {
+		t.Error("Expected the signature to be rejected by the policy")
+	}
+	if len(summary.PolicyViolations) != 1 || summary.PolicyViolations[0].Severity != PolicySeverityError {
+		t.Errorf("Expected one policy error, got %+v", summary.PolicyViolations)
+	}
+}
@@ -1,13 +1,23 @@
 package signature
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"image"
+	_ "image/gif"  // logo formats
+	_ "image/jpeg" // logo formats
 	"log/slog"
+	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/Matbe34/lankir/internal/signature/revocation"
 	"github.com/google/uuid"
+	"golang.org/x/image/font/opentype"
 )
 
 // SignatureVisibility defines whether a signature is visible or invisible
@@ -36,8 +46,9 @@ type SignatureAppearance struct {
 	ShowLogo        bool   `json:"showLogo"`                  // Show custom logo
 	LogoPath        string `json:"logoPath,omitempty"`        // Base64 data URL of logo image
 	LogoPosition    string `json:"logoPosition,omitempty"`    // Position of logo: "left" or "top"
-	CustomText      string `json:"customText,omitempty"`      // Additional custom text
+	CustomText      string `json:"customText,omitempty"`      // Additional text, a template of AppearanceData
 	FontSize        int    `json:"fontSize"`                  // Font size for text
+	FontPath        string `json:"fontPath,omitempty"`        // Base64 data URL of a TrueType/OpenType font
 	BackgroundColor string `json:"backgroundColor,omitempty"` // Hex color (future)
 	TextColor       string `json:"textColor,omitempty"`       // Hex color (future)
 }
@@ -52,6 +63,42 @@ type SignatureProfile struct {
 	Position    SignaturePosition   `json:"position"`    // Where to place signature (if visible)
 	Appearance  SignatureAppearance `json:"appearance"`  // What to show (if visible)
 	IsDefault   bool                `json:"isDefault"`   // Whether this is the default profile
+
+	// Reason and Location are stored in the signature; Location also replaces the looked-up
+	// location of the appearance
+	Reason   string `json:"reason,omitempty"`
+	Location string `json:"location,omitempty"`
+
+	// RevocationPolicy overrides the configured pre-signing revocation check
+	// ("off", "soft-fail" or "hard-fail"); empty uses the config setting
+	RevocationPolicy string `json:"revocationPolicy,omitempty"`
+
+	// CertificateFingerprint or CertificateSelector bind the profile to the certificate used when
+	// signing without one; the selector picks the best certificate matching it as automatic
+	// selection does. CertificateSource, such as "pkcs11", restricts the bound certificate to
+	// one source and signs with its location there.
+	CertificateFingerprint string `json:"certificateFingerprint,omitempty"`
+	CertificateSelector    string `json:"certificateSelector,omitempty"`
+	CertificateSource      string `json:"certificateSource,omitempty"`
+
+	// PINPolicy is PINPolicyPrompt (the default) or PINPolicyCache, which keeps the PIN in
+	// memory for PINCacheMinutes after signing
+	PINPolicy       string `json:"pinPolicy,omitempty"`
+	PINCacheMinutes int    `json:"pinCacheMinutes,omitempty"`
+}
+
+// PIN policies of a signature profile
+const (
+	PINPolicyPrompt = "prompt" // ask for the PIN on every signature
+	PINPolicyCache  = "cache"  // reuse the PIN for PINCacheMinutes
+)
+
+// MaxPINCacheMinutes limits how long a PIN is kept in memory
+const MaxPINCacheMinutes = 8 * 60
+
+// BindsCertificate reports whether the profile selects its own signing certificate.
+func (p *SignatureProfile) BindsCertificate() bool {
+	return p.CertificateFingerprint != "" || p.CertificateSelector != "" || p.CertificateSource != ""
 }
 
 // DefaultInvisibleProfile returns the built-in invisible signature profile.
@@ -182,7 +229,8 @@ func (pm *ProfileManager) GetDefaultProfile() (*SignatureProfile, error) {
 	return DefaultInvisibleProfile(), nil
 }
 
-// SaveProfile validates and persists a profile to disk.
+// SaveProfile validates and persists a profile to disk. Saving a default profile clears
+// IsDefault on the other profiles, so there is only ever one.
 func (pm *ProfileManager) SaveProfile(profile *SignatureProfile) error {
 	if err := pm.ValidateProfile(profile); err != nil {
 		return fmt.Errorf("profile validation failed: %w", err)
@@ -192,6 +240,73 @@ func (pm *ProfileManager) SaveProfile(profile *SignatureProfile) error {
 		return fmt.Errorf("failed to create config directory: %w", err)
 	}
 
+	if err := pm.writeProfile(profile); err != nil {
+		return err
+	}
+
+	if profile.IsDefault {
+		return pm.clearOtherDefaults(profile.ID)
+	}
+	return nil
+}
+
+// SetDefaultProfile marks a profile as the default and clears the flag on all others.
+func (pm *ProfileManager) SetDefaultProfile(id uuid.UUID) (*SignatureProfile, error) {
+	profile, err := pm.GetProfile(id)
+	if err != nil {
+		return nil, err
+	}
+
+	profile.IsDefault = true
+	if err := pm.SaveProfile(profile); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+// DuplicateProfile saves a copy of a profile under a new ID. The copy is never the default and
+// is named name, or "<original> (copy)" if name is empty.
+func (pm *ProfileManager) DuplicateProfile(id uuid.UUID, name string) (*SignatureProfile, error) {
+	original, err := pm.GetProfile(id)
+	if err != nil {
+		return nil, err
+	}
+
+	duplicate := *original
+	duplicate.ID = uuid.New()
+	duplicate.IsDefault = false
+	duplicate.Name = name
+	if duplicate.Name == "" {
+		duplicate.Name = original.Name + " (copy)"
+	}
+
+	if err := pm.SaveProfile(&duplicate); err != nil {
+		return nil, err
+	}
+	return &duplicate, nil
+}
+
+// clearOtherDefaults rewrites every stored profile other than id that is marked as default.
+func (pm *ProfileManager) clearOtherDefaults(id uuid.UUID) error {
+	var profiles []*SignatureProfile
+	if err := pm.loadCustomProfiles(&profiles); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to load profiles: %w", err)
+	}
+
+	for _, other := range profiles {
+		if other.ID == id || !other.IsDefault {
+			continue
+		}
+		other.IsDefault = false
+		if err := pm.writeProfile(other); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeProfile stores a profile in its file without validation.
+func (pm *ProfileManager) writeProfile(profile *SignatureProfile) error {
 	profilePath := filepath.Join(pm.configDir, profile.ID.String()+".json")
 	data, err := json.MarshalIndent(profile, "", "  ")
 	if err != nil {
@@ -236,6 +351,50 @@ func (pm *ProfileManager) ValidateProfile(profile *SignatureProfile) error {
 		}
 	}
 
+	if profile.Position.Page < -1 {
+		return fmt.Errorf("invalid page: %d (use 0 for the last page or -1 for the first)", profile.Position.Page)
+	}
+	if profile.Appearance.FontSize < 0 {
+		return fmt.Errorf("font size must not be negative (got %d)", profile.Appearance.FontSize)
+	}
+	switch profile.Appearance.LogoPosition {
+	case "", "left", "top":
+	default:
+		return fmt.Errorf("invalid logo position: %s (must be left or top)", profile.Appearance.LogoPosition)
+	}
+
+	if err := validateAppearanceText(profile); err != nil {
+		return err
+	}
+
+	if profile.RevocationPolicy != "" {
+		if _, err := revocation.ParsePolicy(profile.RevocationPolicy); err != nil {
+			return err
+		}
+	}
+
+	if profile.CertificateFingerprint != "" {
+		if profile.CertificateSelector != "" {
+			return fmt.Errorf("a profile binds a certificate by fingerprint or by selector, not both")
+		}
+		if _, err := hex.DecodeString(profile.CertificateFingerprint); err != nil || len(profile.CertificateFingerprint) != 64 {
+			return fmt.Errorf("invalid certificate fingerprint: %s (must be a SHA-256 fingerprint of 64 hex digits)", profile.CertificateFingerprint)
+		}
+	}
+
+	switch profile.PINPolicy {
+	case "", PINPolicyPrompt:
+		if profile.PINCacheMinutes != 0 {
+			return fmt.Errorf("PIN cache minutes require the %s PIN policy", PINPolicyCache)
+		}
+	case PINPolicyCache:
+		if profile.PINCacheMinutes < 1 || profile.PINCacheMinutes > MaxPINCacheMinutes {
+			return fmt.Errorf("PIN cache minutes must be 1-%d (got %d)", MaxPINCacheMinutes, profile.PINCacheMinutes)
+		}
+	default:
+		return fmt.Errorf("invalid PIN policy: %s (must be %s or %s)", profile.PINPolicy, PINPolicyPrompt, PINPolicyCache)
+	}
+
 	return nil
 }
 
@@ -277,3 +436,57 @@ func (pm *ProfileManager) loadCustomProfiles(profiles *[]*SignatureProfile) erro
 
 	return nil
 }
+
+// LoadLogoFile reads a PNG, JPEG or GIF image and returns it as the base64 data URL stored in
+// SignatureAppearance.LogoPath.
+func LoadLogoFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read logo: %w", err)
+	}
+	return logoDataURL(data, filepath.Base(path))
+}
+
+// logoDataURL checks that data is a supported image and encodes it as a data URL.
+func logoDataURL(data []byte, name string) (string, error) {
+	if _, _, err := image.DecodeConfig(bytes.NewReader(data)); err != nil {
+		return "", fmt.Errorf("unsupported logo image %s: %w", name, err)
+	}
+	return encodeDataURL(data), nil
+}
+
+// LoadFontFile reads a TrueType or OpenType font and returns it as the base64 data URL stored in
+// SignatureAppearance.FontPath.
+func LoadFontFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read font: %w", err)
+	}
+	return fontDataURL(data, filepath.Base(path))
+}
+
+// fontDataURL checks that data is a supported font and encodes it as a data URL.
+func fontDataURL(data []byte, name string) (string, error) {
+	if _, err := opentype.Parse(data); err != nil {
+		return "", fmt.Errorf("unsupported font %s: %w", name, err)
+	}
+	return encodeDataURL(data), nil
+}
+
+// encodeDataURL encodes data as a base64 data URL of its detected media type.
+func encodeDataURL(data []byte) string {
+	return "data:" + http.DetectContentType(data) + ";base64," + base64.StdEncoding.EncodeToString(data)
+}
+
+// decodeDataURL returns the media type and contents of a base64 data URL.
+func decodeDataURL(dataURL string) (string, []byte, error) {
+	mediaType, encoded, ok := strings.Cut(strings.TrimPrefix(dataURL, "data:"), ";base64,")
+	if !ok || !strings.HasPrefix(dataURL, "data:") {
+		return "", nil, fmt.Errorf("not a base64 data URL")
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid base64 data: %w", err)
+	}
+	return mediaType, data, nil
+}
@@ -0,0 +1,371 @@
+package signature
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/Matbe34/lankir/internal/signature/types"
+	"github.com/google/uuid"
+)
+
+// ProfileBundleFormat identifies signature profile bundles in their manifest
+const ProfileBundleFormat = "lankir-signature-profiles"
+
+// profileBundleVersion is the version of the bundle format
+const profileBundleVersion = 1
+
+// profileBundleManifest is the manifest file inside a bundle
+const profileBundleManifest = "manifest.json"
+
+// maxBundleFileSize limits the size of every file read from a bundle
+const maxBundleFileSize = 16 << 20
+
+// What ImportProfileBundle does with a profile whose ID already exists
+const (
+	ProfileConflictKeep    = "keep"    // keep the existing profile and skip the imported one
+	ProfileConflictReplace = "replace" // overwrite the existing profile
+	ProfileConflictRename  = "rename"  // import the profile under a new ID
+)
+
+// Outcomes of importing a profile
+const (
+	ProfileImportImported = "imported"
+	ProfileImportReplaced = "replaced"
+	ProfileImportRenamed  = "renamed"
+	ProfileImportSkipped  = "skipped"
+)
+
+// ExportProfileBundle writes the given profiles, or all profiles if ids is empty, to a
+// self-contained zip bundle. The logo and font of each profile are stored as separate files next
+// to its settings, and the manifest records the bundle format version. Exported profiles are
+// never marked as default and keep no certificate fingerprint, which is personal to the signer.
+func (pm *ProfileManager) ExportProfileBundle(ids []uuid.UUID, outputPath string) (*types.ProfileBundleManifest, error) {
+	var profiles []*SignatureProfile
+	if len(ids) == 0 {
+		all, err := pm.ListProfiles()
+		if err != nil {
+			return nil, err
+		}
+		profiles = all
+	}
+	for _, id := range ids {
+		profile, err := pm.GetProfile(id)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, profile)
+	}
+
+	manifest := &types.ProfileBundleManifest{
+		Format:     ProfileBundleFormat,
+		Version:    profileBundleVersion,
+		ExportedAt: time.Now(),
+		Profiles:   []types.ProfileBundleEntry{},
+	}
+	files := map[string][]byte{}
+
+	for _, original := range profiles {
+		profile := *original
+		profile.IsDefault = false
+		profile.CertificateFingerprint = ""
+		dir := "profiles/" + profile.ID.String()
+		entry := types.ProfileBundleEntry{
+			ID:   profile.ID.String(),
+			Name: profile.Name,
+			File: dir + "/profile.json",
+		}
+
+		if profile.Appearance.LogoPath != "" {
+			mediaType, data, err := decodeDataURL(profile.Appearance.LogoPath)
+			if err != nil {
+				return nil, fmt.Errorf("invalid logo in profile %q: %w", profile.Name, err)
+			}
+			entry.Logo = dir + "/logo" + bundleFileExtension(mediaType)
+			files[entry.Logo] = data
+			profile.Appearance.LogoPath = ""
+		}
+		if profile.Appearance.FontPath != "" {
+			mediaType, data, err := decodeDataURL(profile.Appearance.FontPath)
+			if err != nil {
+				return nil, fmt.Errorf("invalid font in profile %q: %w", profile.Name, err)
+			}
+			entry.Font = dir + "/font" + bundleFileExtension(mediaType)
+			files[entry.Font] = data
+			profile.Appearance.FontPath = ""
+		}
+
+		data, err := json.MarshalIndent(&profile, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal profile: %w", err)
+		}
+		files[entry.File] = data
+		manifest.Profiles = append(manifest.Profiles, entry)
+	}
+
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal bundle manifest: %w", err)
+	}
+
+	if err := writeProfileBundle(outputPath, manifest, manifestData, files); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// writeProfileBundle writes the manifest and the files of each profile to a zip file atomically.
+func writeProfileBundle(outputPath string, manifest *types.ProfileBundleManifest, manifestData []byte, files map[string][]byte) error {
+	tmpPath := outputPath + ".tmp"
+	out, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create profile bundle: %w", err)
+	}
+	defer os.Remove(tmpPath)
+
+	zw := zip.NewWriter(out)
+	write := func(name string, data []byte) error {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: manifest.ExportedAt})
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+
+	err = write(profileBundleManifest, manifestData)
+	for _, entry := range manifest.Profiles {
+		for _, name := range []string{entry.File, entry.Logo, entry.Font} {
+			if name != "" && err == nil {
+				err = write(name, files[name])
+			}
+		}
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write profile bundle: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, outputPath); err != nil {
+		return fmt.Errorf("failed to save profile bundle: %w", err)
+	}
+	return nil
+}
+
+// bundleFileExtension returns the file extension for an embedded logo or font.
+func bundleFileExtension(mediaType string) string {
+	switch mediaType {
+	case "image/png":
+		return ".png"
+	case "image/jpeg":
+		return ".jpg"
+	case "image/gif":
+		return ".gif"
+	case "font/ttf":
+		return ".ttf"
+	case "font/otf":
+		return ".otf"
+	}
+	return ".bin"
+}
+
+// ImportProfileBundle adds the profiles of a bundle written by ExportProfileBundle. onConflict
+// decides what happens to a profile whose ID already exists: keep (the default) skips it, replace
+// overwrites the existing profile, and rename imports it under a new ID, adding " (imported)" to
+// its name if the name is taken. The IDs of the built-in profiles always count as existing. Imported profiles never become the default. All profiles are
+// checked before any is saved, so an invalid bundle changes nothing.
+func (pm *ProfileManager) ImportProfileBundle(bundlePath string, onConflict string) (*types.ProfileImportResult, error) {
+	switch onConflict {
+	case "":
+		onConflict = ProfileConflictKeep
+	case ProfileConflictKeep, ProfileConflictReplace, ProfileConflictRename:
+	default:
+		return nil, fmt.Errorf("invalid conflict mode: %s (must be keep, replace or rename)", onConflict)
+	}
+
+	zr, err := zip.OpenReader(bundlePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open profile bundle: %w", err)
+	}
+	defer zr.Close()
+
+	var manifest types.ProfileBundleManifest
+	manifestData, err := readBundleFile(&zr.Reader, profileBundleManifest)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(manifestData, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse bundle manifest: %w", err)
+	}
+	if manifest.Format != ProfileBundleFormat {
+		return nil, fmt.Errorf("not a signature profile bundle (format %q)", manifest.Format)
+	}
+	if manifest.Version < 1 || manifest.Version > profileBundleVersion {
+		return nil, fmt.Errorf("profile bundle version %d is not supported (supported: %d)", manifest.Version, profileBundleVersion)
+	}
+
+	imported := make([]*SignatureProfile, 0, len(manifest.Profiles))
+	seen := map[uuid.UUID]bool{}
+	for _, entry := range manifest.Profiles {
+		profile, err := readBundleProfile(&zr.Reader, entry)
+		if err != nil {
+			return nil, fmt.Errorf("profile %q: %w", entry.Name, err)
+		}
+		if err := pm.ValidateProfile(profile); err != nil {
+			return nil, fmt.Errorf("profile %q: %w", entry.Name, err)
+		}
+		if seen[profile.ID] {
+			return nil, fmt.Errorf("profile %q: ID %s appears more than once in the bundle", entry.Name, profile.ID)
+		}
+		seen[profile.ID] = true
+		imported = append(imported, profile)
+	}
+
+	existing, err := pm.ListProfiles()
+	if err != nil {
+		return nil, err
+	}
+	// The built-in IDs always conflict, even after the built-in profiles were deleted
+	byID := map[uuid.UUID]*SignatureProfile{
+		DefaultInvisibleProfile().ID: nil,
+		DefaultVisibleProfile().ID:   nil,
+	}
+	names := map[string]bool{}
+	for _, profile := range existing {
+		byID[profile.ID] = profile
+		names[strings.ToLower(profile.Name)] = true
+	}
+
+	result := &types.ProfileImportResult{
+		Version:    manifest.Version,
+		ExportedAt: manifest.ExportedAt,
+		Profiles:   []types.ProfileImportEntry{},
+	}
+	var toSave []*SignatureProfile
+	added := 0
+	for _, profile := range imported {
+		entry := types.ProfileImportEntry{OriginalID: profile.ID.String(), Action: ProfileImportImported}
+
+		if current, ok := byID[profile.ID]; ok {
+			switch onConflict {
+			case ProfileConflictKeep:
+				entry.Action = ProfileImportSkipped
+			case ProfileConflictReplace:
+				entry.Action = ProfileImportReplaced
+				profile.IsDefault = current != nil && current.IsDefault
+			case ProfileConflictRename:
+				entry.Action = ProfileImportRenamed
+				profile.ID = uuid.New()
+				profile.Name = uniqueImportedName(profile.Name, names)
+			}
+		}
+
+		entry.ID = profile.ID.String()
+		entry.Name = profile.Name
+		result.Profiles = append(result.Profiles, entry)
+
+		if entry.Action == ProfileImportSkipped {
+			continue
+		}
+		if entry.Action != ProfileImportReplaced || byID[profile.ID] == nil {
+			added++
+		}
+		byID[profile.ID] = profile
+		names[strings.ToLower(profile.Name)] = true
+		toSave = append(toSave, profile)
+	}
+
+	if len(existing)+added > MaxProfileFiles {
+		return nil, fmt.Errorf("importing %d profile(s) would exceed the maximum of %d profiles", added, MaxProfileFiles)
+	}
+
+	for _, profile := range toSave {
+		if err := pm.SaveProfile(profile); err != nil {
+			return nil, fmt.Errorf("failed to save profile %q: %w", profile.Name, err)
+		}
+	}
+
+	return result, nil
+}
+
+// uniqueImportedName returns name if it is not taken, or else the first of "<name> (imported)",
+// "<name> (imported 2)", ... that is not. taken holds lowercase names.
+func uniqueImportedName(name string, taken map[string]bool) string {
+	if !taken[strings.ToLower(name)] {
+		return name
+	}
+	candidate := name + " (imported)"
+	for i := 2; taken[strings.ToLower(candidate)]; i++ {
+		candidate = fmt.Sprintf("%s (imported %d)", name, i)
+	}
+	return candidate
+}
+
+// readBundleProfile reads the settings of one bundled profile and restores its logo and font.
+func readBundleProfile(zr *zip.Reader, entry types.ProfileBundleEntry) (*SignatureProfile, error) {
+	data, err := readBundleFile(zr, entry.File)
+	if err != nil {
+		return nil, err
+	}
+
+	var profile SignatureProfile
+	if err := json.Unmarshal(data, &profile); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", entry.File, err)
+	}
+	profile.IsDefault = false
+	profile.Appearance.LogoPath = ""
+	profile.Appearance.FontPath = ""
+
+	if entry.Logo != "" {
+		data, err := readBundleFile(zr, entry.Logo)
+		if err != nil {
+			return nil, err
+		}
+		if profile.Appearance.LogoPath, err = logoDataURL(data, path.Base(entry.Logo)); err != nil {
+			return nil, err
+		}
+	}
+	if entry.Font != "" {
+		data, err := readBundleFile(zr, entry.Font)
+		if err != nil {
+			return nil, err
+		}
+		if profile.Appearance.FontPath, err = fontDataURL(data, path.Base(entry.Font)); err != nil {
+			return nil, err
+		}
+	}
+
+	return &profile, nil
+}
+
+// readBundleFile reads a file of a bundle, refusing files larger than maxBundleFileSize.
+func readBundleFile(zr *zip.Reader, name string) ([]byte, error) {
+	f, err := zr.Open(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("profile bundle is missing %s", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s in profile bundle: %w", name, err)
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, maxBundleFileSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s from profile bundle: %w", name, err)
+	}
+	if len(data) > maxBundleFileSize {
+		return nil, fmt.Errorf("%s in profile bundle is larger than %d bytes", name, maxBundleFileSize)
+	}
+	return data, nil
+}
@@ -0,0 +1,326 @@
+package signature
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Matbe34/lankir/internal/signature/types"
+	"github.com/google/uuid"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+// newBundleTestProfile returns a visible profile with a logo and a font
+func newBundleTestProfile(t *testing.T) *SignatureProfile {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatalf("failed to encode logo: %v", err)
+	}
+
+	profile := DefaultVisibleProfile()
+	profile.ID = uuid.New()
+	profile.Name = "Corporate"
+	profile.IsDefault = true
+	profile.Appearance.ShowLogo = true
+	profile.Appearance.LogoPath = encodeDataURL(buf.Bytes())
+	profile.Appearance.FontPath = encodeDataURL(goregular.TTF)
+	profile.Appearance.CustomText = "ACME Corp."
+	return profile
+}
+
+// TestExportImportProfileBundle tests that a bundle carries profiles with their logo and font
+func TestExportImportProfileBundle(t *testing.T) {
+	source := NewProfileManagerWithDir(t.TempDir())
+	profile := newBundleTestProfile(t)
+	if err := source.SaveProfile(profile); err != nil {
+		t.Fatalf("SaveProfile failed: %v", err)
+	}
+
+	bundlePath := filepath.Join(t.TempDir(), "corporate.zip")
+	manifest, err := source.ExportProfileBundle([]uuid.UUID{profile.ID}, bundlePath)
+	if err != nil {
+		t.Fatalf("ExportProfileBundle failed: %v", err)
+	}
+	if manifest.Format != ProfileBundleFormat || manifest.Version != profileBundleVersion {
+		t.Errorf("Unexpected manifest metadata: %+v", manifest)
+	}
+	if len(manifest.Profiles) != 1 {
+		t.Fatalf("Expected 1 profile in manifest, got %d", len(manifest.Profiles))
+	}
+	entry := manifest.Profiles[0]
+	if !strings.HasSuffix(entry.Logo, "logo.png") || !strings.HasSuffix(entry.Font, "font.ttf") {
+		t.Errorf("Expected logo and font files, got %+v", entry)
+	}
+
+	// The settings in the bundle reference no embedded data
+	zr, err := zip.OpenReader(bundlePath)
+	if err != nil {
+		t.Fatalf("failed to open bundle: %v", err)
+	}
+	data, err := readBundleFile(&zr.Reader, entry.File)
+	zr.Close()
+	if err != nil {
+		t.Fatalf("failed to read bundled profile: %v", err)
+	}
+	if strings.Contains(string(data), "base64") {
+		t.Error("Bundled profile should not embed the logo or font")
+	}
+
+	target := NewProfileManagerWithDir(t.TempDir())
+	result, err := target.ImportProfileBundle(bundlePath, "")
+	if err != nil {
+		t.Fatalf("ImportProfileBundle failed: %v", err)
+	}
+	if len(result.Profiles) != 1 || result.Profiles[0].Action != ProfileImportImported {
+		t.Fatalf("Expected the profile to be imported, got %+v", result.Profiles)
+	}
+
+	imported, err := target.GetProfile(profile.ID)
+	if err != nil {
+		t.Fatalf("GetProfile failed: %v", err)
+	}
+	if imported.Appearance.LogoPath != profile.Appearance.LogoPath {
+		t.Error("Logo not restored")
+	}
+	if imported.Appearance.FontPath != profile.Appearance.FontPath {
+		t.Error("Font not restored")
+	}
+	if imported.Appearance.CustomText != profile.Appearance.CustomText || imported.Position != profile.Position {
+		t.Error("Settings not restored")
+	}
+	if imported.IsDefault {
+		t.Error("Imported profile should not become the default")
+	}
+	if decodeFont(imported.Appearance.FontPath) == nil {
+		t.Error("Imported font should be usable")
+	}
+}
+
+// TestImportProfileBundle_Conflicts tests the keep, replace and rename conflict modes
+func TestImportProfileBundle_Conflicts(t *testing.T) {
+	source := NewProfileManagerWithDir(t.TempDir())
+	profile := newBundleTestProfile(t)
+	source.SaveProfile(profile)
+
+	bundlePath := filepath.Join(t.TempDir(), "corporate.zip")
+	if _, err := source.ExportProfileBundle(nil, bundlePath); err != nil {
+		t.Fatalf("ExportProfileBundle failed: %v", err)
+	}
+
+	target := NewProfileManagerWithDir(t.TempDir())
+	local := *profile
+	local.Appearance = SignatureAppearance{CustomText: "Local", FontSize: 10}
+	local.IsDefault = true
+	if err := target.SaveProfile(&local); err != nil {
+		t.Fatalf("SaveProfile failed: %v", err)
+	}
+
+	actions := func(result *types.ProfileImportResult) map[string]types.ProfileImportEntry {
+		entries := map[string]types.ProfileImportEntry{}
+		for _, entry := range result.Profiles {
+			entries[entry.OriginalID] = entry
+		}
+		return entries
+	}
+
+	// keep leaves the local profile alone
+	result, err := target.ImportProfileBundle(bundlePath, ProfileConflictKeep)
+	if err != nil {
+		t.Fatalf("ImportProfileBundle keep failed: %v", err)
+	}
+	if entry := actions(result)[profile.ID.String()]; entry.Action != ProfileImportSkipped {
+		t.Errorf("Expected skipped, got %+v", entry)
+	}
+	if stored, _ := target.GetProfile(profile.ID); stored.Appearance.CustomText != "Local" {
+		t.Error("keep should not change the existing profile")
+	}
+
+	// rename adds a copy under a new ID and name
+	result, err = target.ImportProfileBundle(bundlePath, ProfileConflictRename)
+	if err != nil {
+		t.Fatalf("ImportProfileBundle rename failed: %v", err)
+	}
+	renamed := actions(result)[profile.ID.String()]
+	if renamed.Action != ProfileImportRenamed || renamed.ID == renamed.OriginalID {
+		t.Fatalf("Expected renamed under a new ID, got %+v", renamed)
+	}
+	if renamed.Name != "Corporate (imported)" {
+		t.Errorf("Expected renamed profile name, got %q", renamed.Name)
+	}
+	copied, err := target.GetProfile(uuid.MustParse(renamed.ID))
+	if err != nil {
+		t.Fatalf("GetProfile failed: %v", err)
+	}
+	if copied.Appearance.CustomText != "ACME Corp." {
+		t.Error("Renamed profile should have the bundled settings")
+	}
+
+	// renaming again picks the next free name
+	result, err = target.ImportProfileBundle(bundlePath, ProfileConflictRename)
+	if err != nil {
+		t.Fatalf("ImportProfileBundle rename failed: %v", err)
+	}
+	if entry := actions(result)[profile.ID.String()]; entry.Name != "Corporate (imported 2)" {
+		t.Errorf("Expected a unique renamed profile name, got %q", entry.Name)
+	}
+
+	// replace overwrites the local profile but keeps it the default
+	result, err = target.ImportProfileBundle(bundlePath, ProfileConflictReplace)
+	if err != nil {
+		t.Fatalf("ImportProfileBundle replace failed: %v", err)
+	}
+	if entry := actions(result)[profile.ID.String()]; entry.Action != ProfileImportReplaced {
+		t.Errorf("Expected replaced, got %+v", entry)
+	}
+	stored, _ := target.GetProfile(profile.ID)
+	if stored.Appearance.CustomText != "ACME Corp." {
+		t.Error("replace should overwrite the existing profile")
+	}
+	if !stored.IsDefault {
+		t.Error("replace should keep the existing default")
+	}
+
+	if _, err := target.ImportProfileBundle(bundlePath, "merge"); err == nil {
+		t.Error("Expected error for an invalid conflict mode")
+	}
+}
+
+// TestImportProfileBundle_BuiltInIDs tests that bundled built-in profiles conflict even after
+// the local built-in profiles were deleted
+func TestImportProfileBundle_BuiltInIDs(t *testing.T) {
+	source := NewProfileManagerWithDir(t.TempDir())
+	bundlePath := filepath.Join(t.TempDir(), "builtin.zip")
+	if _, err := source.ExportProfileBundle(nil, bundlePath); err != nil {
+		t.Fatalf("ExportProfileBundle failed: %v", err)
+	}
+
+	target := NewProfileManagerWithDir(t.TempDir())
+	if err := target.SaveProfile(newBundleTestProfile(t)); err != nil {
+		t.Fatalf("SaveProfile failed: %v", err)
+	}
+
+	result, err := target.ImportProfileBundle(bundlePath, ProfileConflictKeep)
+	if err != nil {
+		t.Fatalf("ImportProfileBundle failed: %v", err)
+	}
+	for _, entry := range result.Profiles {
+		if entry.Action != ProfileImportSkipped {
+			t.Errorf("Expected built-in profile %s to be skipped, got %s", entry.OriginalID, entry.Action)
+		}
+	}
+	if _, err := target.GetProfile(DefaultVisibleProfile().ID); err == nil {
+		t.Error("keep should not import a built-in profile")
+	}
+}
+
+// TestImportProfileBundle_Invalid tests that invalid bundles are rejected without importing anything
+func TestImportProfileBundle_Invalid(t *testing.T) {
+	writeBundle := func(t *testing.T, files map[string]string) string {
+		t.Helper()
+		bundlePath := filepath.Join(t.TempDir(), "bundle.zip")
+		f, err := os.Create(bundlePath)
+		if err != nil {
+			t.Fatalf("failed to create bundle: %v", err)
+		}
+		zw := zip.NewWriter(f)
+		for name, content := range files {
+			w, _ := zw.Create(name)
+			w.Write([]byte(content))
+		}
+		zw.Close()
+		f.Close()
+		return bundlePath
+	}
+	manifest := func(version int, entries ...types.ProfileBundleEntry) string {
+		data, _ := json.Marshal(types.ProfileBundleManifest{Format: ProfileBundleFormat, Version: version, Profiles: entries})
+		return string(data)
+	}
+
+	validID := uuid.New().String()
+	invalidID := uuid.New().String()
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name:  "newer version",
+			files: map[string]string{profileBundleManifest: manifest(profileBundleVersion + 1)},
+			want:  "not supported",
+		},
+		{
+			name:  "other format",
+			files: map[string]string{profileBundleManifest: `{"format":"other","version":1}`},
+			want:  "not a signature profile bundle",
+		},
+		{
+			name:  "missing manifest",
+			files: map[string]string{"readme.txt": "hello"},
+			want:  "missing manifest.json",
+		},
+		{
+			name: "invalid profile",
+			files: map[string]string{
+				profileBundleManifest: manifest(profileBundleVersion,
+					types.ProfileBundleEntry{ID: validID, Name: "Valid", File: "valid.json"},
+					types.ProfileBundleEntry{ID: invalidID, Name: "Invalid", File: "invalid.json"}),
+				"valid.json":   `{"id":"` + validID + `","name":"Valid","visibility":"invisible"}`,
+				"invalid.json": `{"id":"` + invalidID + `","name":"Invalid","visibility":"sideways"}`,
+			},
+			want: "invalid visibility",
+		},
+		{
+			name: "nil ID",
+			files: map[string]string{
+				profileBundleManifest: manifest(profileBundleVersion,
+					types.ProfileBundleEntry{ID: validID, Name: "Valid", File: "valid.json"},
+					types.ProfileBundleEntry{ID: uuid.Nil.String(), Name: "Nil", File: "nil.json"}),
+				"valid.json": `{"id":"` + validID + `","name":"Valid","visibility":"invisible"}`,
+				"nil.json":   `{"id":"` + uuid.Nil.String() + `","name":"Nil","visibility":"invisible"}`,
+			},
+			want: "profile ID is required",
+		},
+		{
+			name: "duplicate ID",
+			files: map[string]string{
+				profileBundleManifest: manifest(profileBundleVersion,
+					types.ProfileBundleEntry{ID: validID, Name: "Valid", File: "valid.json"},
+					types.ProfileBundleEntry{ID: validID, Name: "Again", File: "again.json"}),
+				"valid.json": `{"id":"` + validID + `","name":"Valid","visibility":"invisible"}`,
+				"again.json": `{"id":"` + validID + `","name":"Again","visibility":"invisible"}`,
+			},
+			want: "appears more than once",
+		},
+		{
+			name: "invalid logo",
+			files: map[string]string{
+				profileBundleManifest: manifest(profileBundleVersion,
+					types.ProfileBundleEntry{ID: validID, Name: "Valid", File: "valid.json", Logo: "logo.png"}),
+				"valid.json": `{"id":"` + validID + `","name":"Valid","visibility":"invisible"}`,
+				"logo.png":   "not an image",
+			},
+			want: "unsupported logo image",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pm := NewProfileManagerWithDir(t.TempDir())
+			_, err := pm.ImportProfileBundle(writeBundle(t, tt.files), ProfileConflictKeep)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Expected error containing %q, got %v", tt.want, err)
+			}
+			if _, err := pm.GetProfile(uuid.MustParse(validID)); err == nil {
+				t.Error("No profile should be imported from an invalid bundle")
+			}
+		})
+	}
+}
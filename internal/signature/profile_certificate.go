@@ -0,0 +1,166 @@
+package signature
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Matbe34/lankir/internal/signature/types"
+)
+
+// ResolveProfileCertificate returns the certificate a profile is bound to, with the location of
+// its certificate source as backend. It fails when the bound certificate is not available, for
+// example because its token is not connected.
+func (s *SignatureService) ResolveProfileCertificate(profile *SignatureProfile) (*types.Certificate, error) {
+	if profile == nil {
+		return nil, fmt.Errorf("signature profile is required")
+	}
+	if !profile.BindsCertificate() {
+		return nil, fmt.Errorf("signature profile %q has no bound certificate; select a certificate", profile.Name)
+	}
+
+	if profile.CertificateFingerprint != "" {
+		cert, err := s.GetCertificateByFingerprint(strings.ToLower(profile.CertificateFingerprint))
+		if err != nil {
+			return nil, fmt.Errorf("certificate %s bound to profile %q is not available: connect its token or add its store, or bind another certificate",
+				profile.CertificateFingerprint, profile.Name)
+		}
+		if profile.CertificateSource != "" && !cert.HasSource(profile.CertificateSource) {
+			return nil, fmt.Errorf("certificate '%s' bound to profile %q is not available from source '%s' (found in: %s)",
+				cert.Name, profile.Name, profile.CertificateSource, strings.Join(certificateSources(cert), ", "))
+		}
+		useSourceLocation(cert, profile.CertificateSource)
+		return cert, nil
+	}
+
+	selection, err := s.selectSigningCertificate(CertificateFilter{
+		Search: profile.CertificateSelector,
+		Source: profile.CertificateSource,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("no certificate for profile %q: %w", profile.Name, err)
+	}
+	cert := selection.Certificate
+	useSourceLocation(&cert, profile.CertificateSource)
+	return &cert, nil
+}
+
+// GetProfileCertificate returns the certificate the signature profile with the given ID is
+// bound to and whether its PIN is cached, so the PIN need not be asked for.
+func (s *SignatureService) GetProfileCertificate(profileIDStr string) (*types.ProfileCertificate, error) {
+	profile, err := s.GetSignatureProfile(profileIDStr)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := s.ResolveProfileCertificate(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &types.ProfileCertificate{Certificate: *cert}
+	if profile.PINPolicy == PINPolicyCache {
+		_, result.PINCached = s.pinCache.get(pinCacheKey(cert))
+	}
+	return result, nil
+}
+
+// ClearPINCache forgets all cached PINs.
+func (s *SignatureService) ClearPINCache() {
+	s.pinCache.clear()
+}
+
+// useSourceLocation makes the location of cert in source its backend, preferring one with a
+// private key. It does nothing when source is empty or already the backend.
+func useSourceLocation(cert *types.Certificate, source string) {
+	if source == "" || strings.EqualFold(cert.Source, source) {
+		return
+	}
+	var match *types.CertificateLocation
+	for i, loc := range cert.Locations {
+		if !strings.EqualFold(loc.Source, source) {
+			continue
+		}
+		if match == nil || (loc.HasPrivateKey && !match.HasPrivateKey) {
+			match = &cert.Locations[i]
+		}
+	}
+	if match != nil {
+		cert.UseLocation(*match)
+	}
+}
+
+// certificateSources lists the sources of every location of cert.
+func certificateSources(cert *types.Certificate) []string {
+	if len(cert.Locations) == 0 {
+		return []string{cert.Source}
+	}
+	sources := make([]string, len(cert.Locations))
+	for i, loc := range cert.Locations {
+		sources[i] = loc.Source
+	}
+	return sources
+}
+
+// pinCache keeps the PINs of profiles with the cache PIN policy in memory until they expire.
+// Entries are keyed by certificate and backend, as the same certificate may be protected by a
+// different PIN in each of its locations.
+type pinCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedPIN
+	now     func() time.Time
+}
+
+type cachedPIN struct {
+	pin     string
+	expires time.Time
+}
+
+func newPINCache() *pinCache {
+	return &pinCache{
+		entries: make(map[string]cachedPIN),
+		now:     time.Now,
+	}
+}
+
+// pinCacheKey identifies the backend of a certificate in the PIN cache.
+func pinCacheKey(cert *types.Certificate) string {
+	return strings.Join([]string{cert.Fingerprint, cert.Source, cert.FilePath, cert.PKCS11Module, cert.PKCS11URL, cert.NSSNickname}, "\x00")
+}
+
+// get returns the cached PIN for key unless it has expired.
+func (c *pinCache) get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return "", false
+	}
+	if !c.now().Before(entry.expires) {
+		delete(c.entries, key)
+		return "", false
+	}
+	return entry.pin, true
+}
+
+// put caches pin for key for ttl.
+func (c *pinCache) put(key, pin string, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = cachedPIN{pin: pin, expires: c.now().Add(ttl)}
+}
+
+// remove forgets the PIN for key.
+func (c *pinCache) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+// clear forgets all PINs.
+func (c *pinCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]cachedPIN)
+}
@@ -0,0 +1,228 @@
+package signature
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// newBoundTestProfile returns an invisible profile bound to the certificate with fingerprint
+func newBoundTestProfile(fingerprint string) *SignatureProfile {
+	return &SignatureProfile{
+		ID:                     uuid.New(),
+		Name:                   "Bound",
+		Visibility:             VisibilityInvisible,
+		CertificateFingerprint: fingerprint,
+	}
+}
+
+// TestSignPDF_BoundCertificate tests signing with only a profile bound to a certificate
+func TestSignPDF_BoundCertificate(t *testing.T) {
+	storeDir := t.TempDir()
+	service, _ := NewTestServiceWithStore(t, storeDir)
+
+	now := time.Now()
+	cert, key := CreateTestCertificate(t, "Bound Signer", now.Add(-time.Hour), now.Add(24*time.Hour))
+	WriteTestPKCS12(t, storeDir, "signer.p12", cert, key)
+
+	pdfPath := filepath.Join(t.TempDir(), "doc.pdf")
+	CreateTestPDF(t, pdfPath)
+
+	// The built-in default profile binds no certificate
+	if _, err := service.SignPDF(pdfPath, "", ""); err == nil || !strings.Contains(err.Error(), "has no bound certificate") {
+		t.Fatalf("Expected error for a profile without certificate, got %v", err)
+	}
+
+	profile := newBoundTestProfile(strings.ToUpper(CertificateFingerprint(cert)))
+	profile.IsDefault = true
+	if err := service.SaveSignatureProfile(profile); err != nil {
+		t.Fatalf("SaveSignatureProfile failed: %v", err)
+	}
+
+	signedPath, err := service.SignPDF(pdfPath, "", "")
+	if err != nil {
+		t.Fatalf("SignPDF failed: %v", err)
+	}
+	signatures, err := service.VerifySignatures(signedPath)
+	if err != nil {
+		t.Fatalf("VerifySignatures failed: %v", err)
+	}
+	if len(signatures) != 1 || signatures[0].SignerName != "Bound Signer" {
+		t.Fatalf("Expected a signature by the bound certificate, got %+v", signatures)
+	}
+
+	// A certificate resolved by the caller is signed with as is
+	resolved, err := service.ResolveProfileCertificate(profile)
+	if err != nil {
+		t.Fatalf("ResolveProfileCertificate failed: %v", err)
+	}
+	if _, err := service.SignPDFWithCertificate(pdfPath, resolved, "", profile); err != nil {
+		t.Fatalf("SignPDFWithCertificate failed: %v", err)
+	}
+	if _, err := service.SignPDFWithCertificate(pdfPath, nil, "", profile); err == nil {
+		t.Error("Expected error for a missing signing certificate")
+	}
+
+	// A missing certificate fails clearly instead of falling back to another one
+	other, _ := CreateTestCertificate(t, "Absent Signer", now.Add(-time.Hour), now.Add(24*time.Hour))
+	missing := newBoundTestProfile(CertificateFingerprint(other))
+	_, err = service.SignPDFWithCustomProfile(pdfPath, "", "", missing)
+	if err == nil || !strings.Contains(err.Error(), "is not available") {
+		t.Fatalf("Expected error for a missing bound certificate, got %v", err)
+	}
+}
+
+// TestResolveProfileCertificate tests binding by selector and restricting the source
+func TestResolveProfileCertificate(t *testing.T) {
+	storeDir := t.TempDir()
+	service, _ := NewTestServiceWithStore(t, storeDir)
+
+	now := time.Now()
+	alice, aliceKey := CreateTestCertificate(t, "Alice Signer", now.Add(-time.Hour), now.Add(24*time.Hour))
+	WriteTestPKCS12(t, storeDir, "alice.p12", alice, aliceKey)
+	bob, bobKey := CreateTestCertificate(t, "Bob Signer", now.Add(-time.Hour), now.Add(24*time.Hour))
+	WriteTestPKCS12(t, storeDir, "bob.p12", bob, bobKey)
+
+	tests := []struct {
+		name    string
+		profile *SignatureProfile
+		want    string
+		wantErr string
+	}{
+		{
+			name:    "fingerprint",
+			profile: &SignatureProfile{Name: "P", CertificateFingerprint: CertificateFingerprint(alice)},
+			want:    CertificateFingerprint(alice),
+		},
+		{
+			name:    "selector",
+			profile: &SignatureProfile{Name: "P", CertificateSelector: "bob"},
+			want:    CertificateFingerprint(bob),
+		},
+		{
+			name:    "selector and source",
+			profile: &SignatureProfile{Name: "P", CertificateSelector: "Bob", CertificateSource: "user file"},
+			want:    CertificateFingerprint(bob),
+		},
+		{
+			name:    "selector from other source",
+			profile: &SignatureProfile{Name: "P", CertificateSelector: "Bob", CertificateSource: "pkcs11"},
+			wantErr: "from source 'pkcs11'",
+		},
+		{
+			name:    "fingerprint from other source",
+			profile: &SignatureProfile{Name: "P", CertificateFingerprint: CertificateFingerprint(alice), CertificateSource: "pkcs11"},
+			wantErr: "is not available from source 'pkcs11'",
+		},
+		{
+			name:    "selector without match",
+			profile: &SignatureProfile{Name: "P", CertificateSelector: "Carol"},
+			wantErr: "no certificate matching 'Carol'",
+		},
+		{
+			name:    "unbound",
+			profile: &SignatureProfile{Name: "P"},
+			wantErr: "has no bound certificate",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cert, err := service.ResolveProfileCertificate(tt.profile)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ResolveProfileCertificate failed: %v", err)
+			}
+			if cert.Fingerprint != tt.want {
+				t.Errorf("Expected certificate %s, got %s (%s)", tt.want, cert.Fingerprint, cert.Name)
+			}
+		})
+	}
+}
+
+// TestSignPDF_PINPolicy tests that only profiles with the cache PIN policy use cached PINs
+func TestSignPDF_PINPolicy(t *testing.T) {
+	storeDir := t.TempDir()
+	service, _ := NewTestServiceWithStore(t, storeDir)
+
+	now := time.Now()
+	cert, key := CreateTestCertificate(t, "PIN Signer", now.Add(-time.Hour), now.Add(24*time.Hour))
+	WriteTestPKCS12(t, storeDir, "signer.p12", cert, key)
+
+	pdfPath := filepath.Join(t.TempDir(), "doc.pdf")
+	CreateTestPDF(t, pdfPath)
+
+	cached := newBoundTestProfile(CertificateFingerprint(cert))
+	cached.PINPolicy = PINPolicyCache
+	cached.PINCacheMinutes = 10
+	if err := service.SaveSignatureProfile(cached); err != nil {
+		t.Fatalf("SaveSignatureProfile failed: %v", err)
+	}
+
+	resolved, err := service.ResolveProfileCertificate(cached)
+	if err != nil {
+		t.Fatalf("ResolveProfileCertificate failed: %v", err)
+	}
+	key2 := pinCacheKey(resolved)
+
+	// The test file has no password, so a cached wrong PIN makes signing fail
+	service.pinCache.put(key2, "wrong", time.Minute)
+
+	bound, err := service.GetProfileCertificate(cached.ID.String())
+	if err != nil {
+		t.Fatalf("GetProfileCertificate failed: %v", err)
+	}
+	if !bound.PINCached || bound.Certificate.Fingerprint != CertificateFingerprint(cert) {
+		t.Errorf("Expected the bound certificate with a cached PIN, got %+v", bound)
+	}
+
+	// The prompt policy ignores the cache
+	prompt := newBoundTestProfile(CertificateFingerprint(cert))
+	if _, err := service.SignPDFWithCustomProfile(pdfPath, "", "", prompt); err != nil {
+		t.Fatalf("Signing with the prompt policy should not use the cached PIN: %v", err)
+	}
+
+	if _, err := service.SignPDFWithProfile(pdfPath, "", "", cached.ID.String()); err == nil {
+		t.Fatal("Expected signing with the cached wrong PIN to fail")
+	}
+	if _, ok := service.pinCache.get(key2); ok {
+		t.Error("A cached PIN that failed should be forgotten")
+	}
+	if _, err := service.SignPDFWithProfile(pdfPath, "", "", cached.ID.String()); err != nil {
+		t.Fatalf("SignPDFWithProfile failed: %v", err)
+	}
+
+	service.pinCache.put(key2, "wrong", time.Minute)
+	service.ClearPINCache()
+	if _, ok := service.pinCache.get(key2); ok {
+		t.Error("ClearPINCache should forget all PINs")
+	}
+}
+
+// TestPINCache tests that cached PINs expire
+func TestPINCache(t *testing.T) {
+	now := time.Now()
+	cache := newPINCache()
+	cache.now = func() time.Time { return now }
+
+	cache.put("token", "1234", 15*time.Minute)
+	if pin, ok := cache.get("token"); !ok || pin != "1234" {
+		t.Fatalf("Expected cached PIN, got %q, %v", pin, ok)
+	}
+
+	now = now.Add(15 * time.Minute)
+	if _, ok := cache.get("token"); ok {
+		t.Error("PIN should expire after its time to live")
+	}
+	if _, ok := cache.get("other"); ok {
+		t.Error("Unknown key should not be cached")
+	}
+}
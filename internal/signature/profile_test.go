@@ -1,8 +1,12 @@
 package signature
 
 import (
+	"bytes"
+	"image"
+	"image/png"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -361,6 +365,100 @@ func TestValidateProfile(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "invalid page",
+			profile: &SignatureProfile{
+				ID:         uuid.New(),
+				Name:       "Test",
+				Visibility: VisibilityVisible,
+				Position:   SignaturePosition{Page: -2, Width: 200, Height: 80},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative font size",
+			profile: &SignatureProfile{
+				ID:         uuid.New(),
+				Name:       "Test",
+				Visibility: VisibilityInvisible,
+				Appearance: SignatureAppearance{FontSize: -1},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid logo position",
+			profile: &SignatureProfile{
+				ID:         uuid.New(),
+				Name:       "Test",
+				Visibility: VisibilityInvisible,
+				Appearance: SignatureAppearance{LogoPosition: "middle"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "bound certificate with cached PIN",
+			profile: &SignatureProfile{
+				ID:                     uuid.New(),
+				Name:                   "Test",
+				Visibility:             VisibilityInvisible,
+				CertificateFingerprint: "abababababababababababababababababababababababababababababababab",
+				CertificateSource:      "pkcs11",
+				PINPolicy:              PINPolicyCache,
+				PINCacheMinutes:        15,
+			},
+			wantErr: false,
+		},
+		{
+			name: "fingerprint and selector",
+			profile: &SignatureProfile{
+				ID:                     uuid.New(),
+				Name:                   "Test",
+				Visibility:             VisibilityInvisible,
+				CertificateFingerprint: "abababababababababababababababababababababababababababababababab",
+				CertificateSelector:    "Jane",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid fingerprint",
+			profile: &SignatureProfile{
+				ID:                     uuid.New(),
+				Name:                   "Test",
+				Visibility:             VisibilityInvisible,
+				CertificateFingerprint: "ab:cd",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid PIN policy",
+			profile: &SignatureProfile{
+				ID:         uuid.New(),
+				Name:       "Test",
+				Visibility: VisibilityInvisible,
+				PINPolicy:  "never",
+			},
+			wantErr: true,
+		},
+		{
+			name: "PIN cache without minutes",
+			profile: &SignatureProfile{
+				ID:         uuid.New(),
+				Name:       "Test",
+				Visibility: VisibilityInvisible,
+				PINPolicy:  PINPolicyCache,
+			},
+			wantErr: true,
+		},
+		{
+			name: "PIN cache minutes with prompt policy",
+			profile: &SignatureProfile{
+				ID:              uuid.New(),
+				Name:            "Test",
+				Visibility:      VisibilityInvisible,
+				PINCacheMinutes: 5,
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -437,7 +535,10 @@ func TestCreateSignatureAppearance(t *testing.T) {
 
 	t.Run("invisible", func(t *testing.T) {
 		profile := DefaultInvisibleProfile()
-		appearance := CreateSignatureAppearance(profile, cert, signingTime)
+		appearance, err := CreateSignatureAppearance(profile, newAppearanceData(profile, cert, nil, signingTime, ""))
+		if err != nil {
+			t.Fatalf("CreateSignatureAppearance failed: %v", err)
+		}
 
 		if appearance.Visible {
 			t.Error("Invisible profile should create non-visible appearance")
@@ -446,7 +547,10 @@ func TestCreateSignatureAppearance(t *testing.T) {
 
 	t.Run("visible", func(t *testing.T) {
 		profile := DefaultVisibleProfile()
-		appearance := CreateSignatureAppearance(profile, cert, signingTime)
+		appearance, err := CreateSignatureAppearance(profile, newAppearanceData(profile, cert, nil, signingTime, ""))
+		if err != nil {
+			t.Fatalf("CreateSignatureAppearance failed: %v", err)
+		}
 
 		if !appearance.Visible {
 			t.Error("Visible profile should create visible appearance")
@@ -471,11 +575,50 @@ func TestCreateSignatureAppearance(t *testing.T) {
 		}
 	})
 
+	t.Run("page selection", func(t *testing.T) {
+		tests := []struct {
+			page    int
+			pages   int
+			want    uint32
+			wantErr bool
+		}{
+			{page: 0, pages: 3, want: 3},
+			{page: -1, pages: 3, want: 1},
+			{page: 2, pages: 3, want: 2},
+			{page: 0, pages: 0, want: 1},
+			{page: 4, pages: 3, wantErr: true},
+		}
+
+		for _, tt := range tests {
+			profile := DefaultVisibleProfile()
+			profile.Position.Page = tt.page
+			data := newAppearanceData(profile, cert, nil, signingTime, "")
+			data.Document.Pages = tt.pages
+
+			appearance, err := CreateSignatureAppearance(profile, data)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("page %d of %d: expected an error", tt.page, tt.pages)
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatalf("page %d of %d: CreateSignatureAppearance failed: %v", tt.page, tt.pages, err)
+			}
+			if appearance.Page != tt.want {
+				t.Errorf("page %d of %d: expected page %d, got %d", tt.page, tt.pages, tt.want, appearance.Page)
+			}
+		}
+	})
+
 	t.Run("visible with signer name", func(t *testing.T) {
 		profile := DefaultVisibleProfile()
 		profile.Appearance.ShowSignerName = true
 
-		appearance := CreateSignatureAppearance(profile, cert, signingTime)
+		appearance, err := CreateSignatureAppearance(profile, newAppearanceData(profile, cert, nil, signingTime, ""))
+		if err != nil {
+			t.Fatalf("CreateSignatureAppearance failed: %v", err)
+		}
 
 		// The appearance content should be generated
 		// We can't easily test the exact content without knowing the implementation
@@ -641,3 +784,226 @@ func TestProfileManagerWithManyProfiles(t *testing.T) {
 		}
 	}
 }
+
+// TestSaveProfile_SingleDefault tests that saving a default profile clears the other defaults
+func TestSaveProfile_SingleDefault(t *testing.T) {
+	tmpDir := t.TempDir()
+	pm := NewProfileManagerWithDir(tmpDir)
+
+	if _, err := pm.ListProfiles(); err != nil {
+		t.Fatalf("ListProfiles failed: %v", err)
+	}
+
+	profile := &SignatureProfile{
+		ID:         uuid.New(),
+		Name:       "New Default",
+		Visibility: VisibilityInvisible,
+		IsDefault:  true,
+	}
+	if err := pm.SaveProfile(profile); err != nil {
+		t.Fatalf("SaveProfile failed: %v", err)
+	}
+
+	profiles, err := pm.ListProfiles()
+	if err != nil {
+		t.Fatalf("ListProfiles failed: %v", err)
+	}
+	for _, p := range profiles {
+		if p.IsDefault != (p.ID == profile.ID) {
+			t.Errorf("Profile %q: IsDefault = %v", p.Name, p.IsDefault)
+		}
+	}
+
+	defaultProfile, err := pm.GetDefaultProfile()
+	if err != nil {
+		t.Fatalf("GetDefaultProfile failed: %v", err)
+	}
+	if defaultProfile.ID != profile.ID {
+		t.Errorf("Expected default %s, got %s", profile.ID, defaultProfile.ID)
+	}
+}
+
+// TestSetDefaultProfile tests switching the default profile
+func TestSetDefaultProfile(t *testing.T) {
+	tmpDir := t.TempDir()
+	pm := NewProfileManagerWithDir(tmpDir)
+
+	visibleID := DefaultVisibleProfile().ID
+	profile, err := pm.SetDefaultProfile(visibleID)
+	if err != nil {
+		t.Fatalf("SetDefaultProfile failed: %v", err)
+	}
+	if !profile.IsDefault {
+		t.Error("Returned profile should be the default")
+	}
+
+	invisible, err := pm.GetProfile(DefaultInvisibleProfile().ID)
+	if err != nil {
+		t.Fatalf("GetProfile failed: %v", err)
+	}
+	if invisible.IsDefault {
+		t.Error("Previous default should have been cleared")
+	}
+
+	defaultProfile, _ := pm.GetDefaultProfile()
+	if defaultProfile.ID != visibleID {
+		t.Errorf("Expected default %s, got %s", visibleID, defaultProfile.ID)
+	}
+
+	if _, err := pm.SetDefaultProfile(uuid.New()); err == nil {
+		t.Error("Expected error for nonexistent profile")
+	}
+}
+
+// TestDuplicateProfile tests copying a profile under a new ID
+func TestDuplicateProfile(t *testing.T) {
+	tmpDir := t.TempDir()
+	pm := NewProfileManagerWithDir(tmpDir)
+
+	original := DefaultInvisibleProfile()
+	duplicate, err := pm.DuplicateProfile(original.ID, "")
+	if err != nil {
+		t.Fatalf("DuplicateProfile failed: %v", err)
+	}
+
+	if duplicate.ID == original.ID {
+		t.Error("Duplicate should have a new ID")
+	}
+	if duplicate.Name != original.Name+" (copy)" {
+		t.Errorf("Unexpected name %q", duplicate.Name)
+	}
+	if duplicate.IsDefault {
+		t.Error("Duplicate should not be the default")
+	}
+	if duplicate.Visibility != original.Visibility {
+		t.Error("Duplicate should keep the settings")
+	}
+
+	stored, err := pm.GetProfile(original.ID)
+	if err != nil {
+		t.Fatalf("GetProfile failed: %v", err)
+	}
+	if !stored.IsDefault {
+		t.Error("Original should stay the default")
+	}
+
+	named, err := pm.DuplicateProfile(original.ID, "Named Copy")
+	if err != nil {
+		t.Fatalf("DuplicateProfile failed: %v", err)
+	}
+	if named.Name != "Named Copy" {
+		t.Errorf("Expected name %q, got %q", "Named Copy", named.Name)
+	}
+}
+
+// TestLoadLogoFile tests converting a logo image to a data URL
+func TestLoadLogoFile(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatalf("failed to encode logo: %v", err)
+	}
+	logoPath := filepath.Join(tmpDir, "logo.png")
+	if err := os.WriteFile(logoPath, buf.Bytes(), 0600); err != nil {
+		t.Fatalf("failed to write logo: %v", err)
+	}
+
+	dataURL, err := LoadLogoFile(logoPath)
+	if err != nil {
+		t.Fatalf("LoadLogoFile failed: %v", err)
+	}
+	if !strings.HasPrefix(dataURL, "data:image/png;base64,") {
+		t.Errorf("Unexpected data URL prefix: %.30s", dataURL)
+	}
+	if decodeLogoImage(dataURL) == nil {
+		t.Error("Data URL should decode to an image")
+	}
+
+	textPath := filepath.Join(tmpDir, "logo.txt")
+	os.WriteFile(textPath, []byte("not an image"), 0600)
+	if _, err := LoadLogoFile(textPath); err == nil {
+		t.Error("Expected error for a file that is not an image")
+	}
+}
+
+// TestFindSignatureProfile tests looking up profiles by ID or name
+func TestFindSignatureProfile(t *testing.T) {
+	service, _ := NewTestServiceWithStore(t, t.TempDir())
+
+	visible := DefaultVisibleProfile()
+	byID, err := service.FindSignatureProfile(visible.ID.String())
+	if err != nil {
+		t.Fatalf("FindSignatureProfile by ID failed: %v", err)
+	}
+	if byID.ID != visible.ID {
+		t.Errorf("Expected %s, got %s", visible.ID, byID.ID)
+	}
+
+	byName, err := service.FindSignatureProfile("visible signature")
+	if err != nil {
+		t.Fatalf("FindSignatureProfile by name failed: %v", err)
+	}
+	if byName.ID != visible.ID {
+		t.Errorf("Expected %s, got %s", visible.ID, byName.ID)
+	}
+
+	if _, err := service.FindSignatureProfile("Missing"); err == nil {
+		t.Error("Expected error for unknown profile")
+	}
+
+	if _, err := service.DuplicateSignatureProfile(visible.ID.String(), "Visible Signature"); err != nil {
+		t.Fatalf("DuplicateSignatureProfile failed: %v", err)
+	}
+	if _, err := service.FindSignatureProfile("Visible Signature"); err == nil || !strings.Contains(err.Error(), "several profiles") {
+		t.Errorf("Expected ambiguous name error, got %v", err)
+	}
+}
+
+// TestSignPDFWithCustomProfile tests signing with profile settings that are not saved
+func TestSignPDFWithCustomProfile(t *testing.T) {
+	storeDir := t.TempDir()
+	service, _ := NewTestServiceWithStore(t, storeDir)
+
+	now := time.Now()
+	cert, key := CreateTestCertificate(t, "Profile Signer", now.Add(-time.Hour), now.Add(24*time.Hour))
+	WriteTestPKCS12(t, storeDir, "signer.p12", cert, key)
+
+	pdfPath := filepath.Join(t.TempDir(), "doc.pdf")
+	CreateTestPDF(t, pdfPath)
+
+	profile, err := service.FindSignatureProfile("Visible Signature")
+	if err != nil {
+		t.Fatalf("FindSignatureProfile failed: %v", err)
+	}
+	profile.Position.Page = 1
+	profile.Position.X = 72
+	profile.Appearance.CustomText = "Approved"
+
+	signedPath, err := service.SignPDFWithCustomProfile(pdfPath, CertificateFingerprint(cert), "", profile)
+	if err != nil {
+		t.Fatalf("SignPDFWithCustomProfile failed: %v", err)
+	}
+
+	signatures, err := service.VerifySignatures(signedPath)
+	if err != nil {
+		t.Fatalf("VerifySignatures failed: %v", err)
+	}
+	if len(signatures) != 1 || !signatures[0].IsValid {
+		t.Fatalf("Expected 1 valid signature, got %+v", signatures)
+	}
+
+	// The overrides are not saved
+	stored, err := service.GetSignatureProfile(profile.ID.String())
+	if err != nil {
+		t.Fatalf("GetSignatureProfile failed: %v", err)
+	}
+	if stored.Appearance.CustomText != "" || stored.Position.X == 72 {
+		t.Error("Signing with a custom profile should not change the saved profile")
+	}
+
+	profile.Position.X = -5
+	if _, err := service.SignPDFWithCustomProfile(pdfPath, CertificateFingerprint(cert), "", profile); err == nil {
+		t.Error("Expected error for a position out of bounds")
+	}
+}
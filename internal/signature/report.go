@@ -0,0 +1,484 @@
+package signature
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Matbe34/lankir/internal/config"
+	"github.com/Matbe34/lankir/internal/signature/certutil"
+	"github.com/Matbe34/lankir/internal/signature/types"
+	"github.com/digitorus/pdf"
+	"github.com/digitorus/pdfsign/verify"
+	"github.com/digitorus/pkcs7"
+)
+
+// Certificate statuses reported in types.SignerCertificateStatus
+const (
+	CertificateStatusValid   = "valid"
+	CertificateStatusInvalid = "invalid"
+	CertificateStatusRevoked = "revoked"
+)
+
+// docMDPDescriptions describes the DocMDP permission levels (ISO 32000-1, table 254)
+var docMDPDescriptions = map[int]string{
+	1: "No changes permitted",
+	2: "Form filling and signing permitted",
+	3: "Form filling, signing and annotations permitted",
+}
+
+// signatureDictionary holds the values of a signature dictionary that the verifier does not report.
+type signatureDictionary struct {
+	fieldName string
+	subFilter string
+	byteRange []int64
+	contents  []byte
+	p7        *pkcs7.PKCS7
+	docMDP    int
+	fieldMDP  *types.FieldMDPInfo
+}
+
+// GetVerificationReport verifies every signature in a PDF and returns a detailed report covering
+// byte range coverage, algorithms, the signer chain with revocation sources, timestamps and
+// DocMDP permissions.
+func (s *SignatureService) GetVerificationReport(pdfPath string) (*types.VerificationReport, error) {
+	return s.GetVerificationReportWithOptions(pdfPath, VerifyOptions{})
+}
+
+// GetVerificationReportWithOptions is GetVerificationReport at a chosen validation time, with
+// supplied validation data, or fetching missing validation data from the network.
+func (s *SignatureService) GetVerificationReportWithOptions(pdfPath string, opts VerifyOptions) (*types.VerificationReport, error) {
+	data, err := os.ReadFile(pdfPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open PDF: %w", err)
+	}
+
+	evidence, err := loadValidationFiles(opts.CertificateFiles, opts.OCSPFiles, opts.CRLFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	size := int64(len(data))
+	digest := sha256.Sum256(data)
+	report := &types.VerificationReport{
+		File:           pdfPath,
+		FileSize:       size,
+		SHA256:         hex.EncodeToString(digest[:]),
+		VerifiedAt:     time.Now(),
+		Signatures:     []types.SignatureReport{},
+		ValidationMode: ValidationModeOffline,
+	}
+	if s.fetchesValidationData(opts) {
+		report.ValidationMode = ValidationModeOnline
+	}
+	if !opts.ValidationTime.IsZero() {
+		report.ValidationTime = &opts.ValidationTime
+	}
+
+	response, err := verify.Verify(bytes.NewReader(data), size)
+	if err != nil {
+		if strings.Contains(err.Error(), "no digital signature in document") {
+			return report, nil
+		}
+		return nil, fmt.Errorf("verification failed: %w", err)
+	}
+
+	dictionaries, err := readSignatureDictionaries(bytes.NewReader(data), size)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := analyzeModifications(data, dictionaries)
+
+	// Validation data stored in the DSS is ranked below the supplied files when both cover a certificate
+	if dss, err := readDSS(data); err == nil {
+		evidence.certs = append(dss.certs, evidence.certs...)
+		evidence.items = append(dss.items, evidence.items...)
+	}
+
+	for i, signer := range response.Signers {
+		var dict *signatureDictionary
+		var changed revisionChanges
+		// The verifier walks the same objects in the same order, so signers and dictionaries align
+		if i < len(dictionaries) {
+			dict = &dictionaries[i]
+			changed = changes[i]
+		}
+		responseError := response.Error
+		if dict != nil && dict.subFilter == SubFilterDocTimeStamp {
+			signer, responseError = verifyDocumentTimestamp(dict, data)
+		}
+		report.Signatures = append(report.Signatures, s.newSignatureReport(i+1, signer, dict, changed, responseError, data, evidence, opts))
+	}
+
+	return report, nil
+}
+
+func (s *SignatureService) newSignatureReport(index int, signer verify.Signer, dict *signatureDictionary, changes revisionChanges, responseError string, data []byte, evidence *validationEvidence, opts VerifyOptions) types.SignatureReport {
+	at, timeSource, requested := validationTime(signer, opts)
+	if requested {
+		// Trust and revocation are decided again below as of the requested time
+		signer.VerificationTime = &at
+		signer.TimeSource = timeSource
+		signer.TrustedIssuer = false
+		signer.RevokedCertificate = false
+		for i := range signer.Certificates {
+			signer.Certificates[i].VerifyError = ""
+			signer.Certificates[i].RevocationTime = nil
+			signer.Certificates[i].RevokedBeforeSigning = false
+		}
+	}
+
+	documentTimestamp := dict != nil && dict.subFilter == SubFilterDocTimeStamp
+
+	report := types.SignatureReport{
+		Index:             index,
+		Chain:             []types.SignerCertificateStatus{},
+		RevocationSources: []string{},
+		TimeSource:        signer.TimeSource,
+		VerificationTime:  signer.VerificationTime,
+		Warnings:          []string{},
+	}
+
+	if dict != nil {
+		report.FieldName = dict.fieldName
+		report.SubFilter = dict.subFilter
+		report.ByteRange = byteRangeCoverage(dict.byteRange, data)
+
+		if len(dict.p7.Signers) > 0 {
+			report.DigestAlgorithm = certutil.DigestAlgorithmName(dict.p7.Signers[0].DigestAlgorithm.Algorithm)
+			report.SignatureAlgorithm = certutil.SignatureAlgorithmName(dict.p7.Signers[0].DigestEncryptionAlgorithm.Algorithm)
+		}
+
+		if dict.docMDP > 0 {
+			report.DocMDP = &types.DocMDPInfo{Level: dict.docMDP, Description: docMDPDescriptions[dict.docMDP]}
+		}
+		report.FieldMDP = dict.fieldMDP
+
+		signer.Certificates = orderSignerCertificates(dict.p7.GetOnlySigner(), signer.Certificates)
+		if documentTimestamp {
+			// The verifier did not check the TSA chain, so its errors are always recorded
+			report.TrustAnchor = s.applyTrustAnchors(&signer, config.TrustPurposeTimestamping, true)
+			signer.TimestampTrusted = signer.TrustedIssuer
+		} else {
+			report.TrustAnchor = s.applyTrustAnchors(&signer, config.TrustPurposeSigning, requested)
+		}
+	}
+	revocationEvidence := s.checkRevocationEvidence(&signer, dict, evidence, opts, at)
+
+	report.Summary = s.convertSignerToInfo(signer, responseError)
+	report.Summary.FieldName = report.FieldName
+	report.Summary.DocumentTimestamp = documentTimestamp
+	if documentTimestamp && report.Summary.IsValid {
+		report.Summary.ValidationMessage = "Document timestamp matches the document"
+	}
+	report.Summary.ValidationTime = at.Format(time.RFC3339)
+	report.Summary.Evidence = revocationEvidence
+	if report.TrustAnchor != nil {
+		report.Summary.TrustAnchor = fmt.Sprintf("%s (%s)", report.TrustAnchor.SubjectDN, report.TrustAnchor.Source)
+	}
+	report.Summary.SigningHashAlgorithm = report.DigestAlgorithm
+	report.Summary.SignatureType = report.SignatureAlgorithm
+	report.Summary.ModificationStatus = modificationStatus(changes)
+	report.Summary.Modifications = changes.modifications
+
+	if report.ByteRange.Error != "" {
+		report.Warnings = append(report.Warnings, "Invalid byte range: "+report.ByteRange.Error)
+	} else if report.ByteRange.BytesAfterSignature > 0 {
+		report.ModifiedAfterSigning = true
+		report.Warnings = append(report.Warnings, fmt.Sprintf("Document was modified after signing (%d bytes added)", report.ByteRange.BytesAfterSignature))
+	}
+	if changes.err != nil {
+		report.Warnings = append(report.Warnings, "Could not analyse changes after signing: "+changes.err.Error())
+	}
+	for _, m := range changes.modifications {
+		if !m.Allowed {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("Disallowed change after signing: %s (%s)", m.Description, m.Reason))
+		}
+	}
+	report.Warnings = append(report.Warnings, signer.TimeWarnings...)
+
+	var certs []*x509.Certificate
+	for i, c := range signer.Certificates {
+		if c.Certificate == nil {
+			continue
+		}
+		certs = append(certs, c.Certificate)
+		status := signerCertificateStatus(c, i == 0)
+		report.Chain = append(report.Chain, status)
+		for _, source := range status.RevocationSources {
+			if !slices.Contains(report.RevocationSources, source) {
+				report.RevocationSources = append(report.RevocationSources, source)
+			}
+		}
+	}
+
+	report.Summary.PolicyViolations = policyViolations(s.algorithmPolicy(), report.DigestAlgorithm, certs)
+	for _, v := range report.Summary.PolicyViolations {
+		if v.Severity == PolicySeverityError && report.Summary.IsValid {
+			report.Summary.IsValid = false
+			report.Summary.ValidationMessage = "Signature rejected by algorithm policy: " + v.Message
+		}
+		report.Warnings = append(report.Warnings, "Algorithm policy: "+v.Message)
+	}
+
+	if signer.TimeStamp != nil {
+		report.Timestamp = timestampInfo(signer)
+	}
+
+	return report
+}
+
+// readSignatureDictionaries returns the signature dictionaries of the document in the order the
+// verifier processes them.
+func readSignatureDictionaries(file io.ReaderAt, size int64) (dictionaries []signatureDictionary, err error) {
+	defer recoverPDFPanic(&err, "read signature dictionaries")
+
+	rdr, err := pdf.NewReader(file, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+
+	fields := signatureFields(rdr.Trailer().Key("Root").Key("AcroForm").Key("Fields"), "")
+
+	for _, x := range rdr.Xref() {
+		v := rdr.Resolve(x.Ptr(), x.Ptr())
+		if v.Key("Filter").Name() != "Adobe.PPKLite" {
+			continue
+		}
+
+		contents := []byte(v.Key("Contents").RawString())
+		p7, err := pkcs7.Parse(contents)
+		if err != nil {
+			// The verifier skips signatures it cannot parse as well
+			continue
+		}
+
+		dict := signatureDictionary{
+			subFilter: v.Key("SubFilter").Name(),
+			contents:  contents,
+			p7:        p7,
+		}
+
+		byteRange := v.Key("ByteRange")
+		for i := 0; i < byteRange.Len(); i++ {
+			dict.byteRange = append(dict.byteRange, byteRange.Index(i).Int64())
+		}
+
+		references := v.Key("Reference")
+		for i := 0; i < references.Len(); i++ {
+			ref := references.Index(i)
+			params := ref.Key("TransformParams")
+			switch ref.Key("TransformMethod").Name() {
+			case "DocMDP":
+				dict.docMDP = 2
+				if p := params.Key("P"); !p.IsNull() {
+					dict.docMDP = int(p.Int64())
+				}
+			case "FieldMDP":
+				dict.fieldMDP = &types.FieldMDPInfo{Action: params.Key("Action").Name()}
+				fields := params.Key("Fields")
+				for j := 0; j < fields.Len(); j++ {
+					dict.fieldMDP.Fields = append(dict.fieldMDP.Fields, fields.Index(j).Text())
+				}
+			}
+		}
+
+		for _, f := range fields {
+			if f.value.GetPtr() == v.GetPtr() {
+				dict.fieldName = f.name
+				break
+			}
+		}
+
+		dictionaries = append(dictionaries, dict)
+	}
+
+	return dictionaries, nil
+}
+
+type signatureField struct {
+	name  string
+	value pdf.Value
+}
+
+// signatureFields returns the signature fields of an AcroForm field tree with their full names.
+func signatureFields(fields pdf.Value, parent string) []signatureField {
+	var result []signatureField
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Index(i)
+
+		name := field.Key("T").Text()
+		if parent != "" && name != "" {
+			name = parent + "." + name
+		} else if name == "" {
+			name = parent
+		}
+
+		if field.Key("FT").Name() == "Sig" && !field.Key("V").IsNull() {
+			result = append(result, signatureField{name: name, value: field.Key("V")})
+		}
+		result = append(result, signatureFields(field.Key("Kids"), name)...)
+	}
+	return result
+}
+
+// byteRangeCoverage checks that a byte range is well formed and reports how much of the file it covers.
+func byteRangeCoverage(ranges []int64, data []byte) types.ByteRangeCoverage {
+	size := int64(len(data))
+	coverage := types.ByteRangeCoverage{Ranges: ranges, FileSize: size}
+	if coverage.Ranges == nil {
+		coverage.Ranges = []int64{}
+	}
+
+	if len(ranges) != 4 {
+		coverage.Error = fmt.Sprintf("expected 4 values, found %d", len(ranges))
+		return coverage
+	}
+
+	start1, len1, start2, len2 := ranges[0], ranges[1], ranges[2], ranges[3]
+	end := start2 + len2
+	switch {
+	case start1 != 0:
+		coverage.Error = "does not start at the beginning of the file"
+	case len1 < 0 || len2 < 0 || start2 < start1+len1:
+		coverage.Error = "ranges overlap or are negative"
+	case end > size:
+		coverage.Error = "extends beyond the end of the file"
+	case start2 == start1+len1 || data[len1] != '<' || data[start2-1] != '>':
+		coverage.Error = "the excluded bytes are not the signature value"
+	}
+	if coverage.Error != "" {
+		return coverage
+	}
+
+	coverage.CoveredBytes = len1 + len2
+	coverage.BytesAfterSignature = size - end
+	coverage.CoversWholeFile = coverage.BytesAfterSignature == 0
+	return coverage
+}
+
+// orderSignerCertificates puts the signer certificate first, followed by its issuers.
+func orderSignerCertificates(signerCert *x509.Certificate, certs []verify.Certificate) []verify.Certificate {
+	if signerCert == nil {
+		return certs
+	}
+
+	remaining := append([]verify.Certificate(nil), certs...)
+	var ordered []verify.Certificate
+
+	take := func(match func(*x509.Certificate) bool) bool {
+		for i, c := range remaining {
+			if c.Certificate != nil && match(c.Certificate) {
+				ordered = append(ordered, c)
+				remaining = append(remaining[:i], remaining[i+1:]...)
+				return true
+			}
+		}
+		return false
+	}
+
+	if !take(signerCert.Equal) {
+		return certs
+	}
+	for {
+		current := ordered[len(ordered)-1].Certificate
+		if certutil.IsSelfSigned(current) {
+			break
+		}
+		if !take(func(c *x509.Certificate) bool { return bytes.Equal(c.RawSubject, current.RawIssuer) }) {
+			break
+		}
+	}
+
+	return append(ordered, remaining...)
+}
+
+// signerCertificateStatus converts a certificate verified by pdfsign. Key usage errors only
+// concern the signer certificate.
+func signerCertificateStatus(c verify.Certificate, isSigner bool) types.SignerCertificateStatus {
+	_, sha256Hex := certutil.Fingerprints(c.Certificate)
+	status := types.SignerCertificateStatus{
+		SubjectDN:         c.Certificate.Subject.String(),
+		IssuerDN:          c.Certificate.Issuer.String(),
+		SerialNumberHex:   strings.ToUpper(c.Certificate.SerialNumber.Text(16)),
+		FingerprintSHA256: sha256Hex,
+		NotBefore:         c.Certificate.NotBefore,
+		NotAfter:          c.Certificate.NotAfter,
+		Status:            CertificateStatusValid,
+		Errors:            []string{},
+		Warnings:          []string{},
+		RevocationSources: revocationSources(c),
+		RevocationTime:    c.RevocationTime,
+	}
+
+	if c.VerifyError != "" {
+		status.Errors = append(status.Errors, c.VerifyError)
+	}
+	if isSigner && c.KeyUsageError != "" {
+		status.Errors = append(status.Errors, "Key usage: "+c.KeyUsageError)
+	}
+	if isSigner && c.ExtKeyUsageError != "" {
+		status.Warnings = append(status.Warnings, "Extended key usage: "+c.ExtKeyUsageError)
+	}
+	if c.RevocationWarning != "" {
+		status.Warnings = append(status.Warnings, c.RevocationWarning)
+	}
+
+	switch {
+	case c.RevocationTime != nil:
+		status.Status = CertificateStatusRevoked
+	case len(status.Errors) > 0:
+		status.Status = CertificateStatusInvalid
+	}
+
+	return status
+}
+
+// revocationSources names where the revocation status of a certificate came from.
+func revocationSources(c verify.Certificate) []string {
+	sources := []string{}
+	if c.OCSPEmbedded {
+		sources = append(sources, "OCSP (embedded)")
+	}
+	if c.OCSPExternal {
+		sources = append(sources, "OCSP (online)")
+	}
+	if c.CRLEmbedded {
+		sources = append(sources, "CRL (embedded)")
+	}
+	if c.CRLExternal {
+		sources = append(sources, "CRL (online)")
+	}
+	return sources
+}
+
+func timestampInfo(signer verify.Signer) *types.TimestampInfo {
+	ts := signer.TimeStamp
+	info := &types.TimestampInfo{
+		Time:          ts.Time,
+		Status:        signer.TimestampStatus,
+		Trusted:       signer.TimestampTrusted,
+		HashAlgorithm: ts.HashAlgorithm.String(),
+	}
+	if ts.SerialNumber != nil {
+		info.SerialNumber = strings.ToUpper(ts.SerialNumber.Text(16))
+	}
+	if len(ts.Policy) > 0 {
+		info.Policy = ts.Policy.String()
+	}
+	if ts.Accuracy > 0 {
+		info.Accuracy = ts.Accuracy.String()
+	}
+	if len(ts.Certificates) > 0 {
+		info.Authority = ts.Certificates[0].Subject.String()
+	}
+	return info
+}
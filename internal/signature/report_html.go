@@ -0,0 +1,152 @@
+package signature
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/Matbe34/lankir/internal/signature/types"
+)
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"formatTime": func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.UTC().Format("2006-01-02 15:04:05 UTC")
+	},
+	"join": strings.Join,
+	"yesNo": func(b bool) string {
+		if b {
+			return "Yes"
+		}
+		return "No"
+	},
+	"ranges": func(r []int64) string {
+		return strings.Trim(fmt.Sprint(r), "[]")
+	},
+}).Parse(reportHTML))
+
+// WriteVerificationReportHTML renders a verification report as a standalone HTML document.
+func WriteVerificationReportHTML(w io.Writer, report *types.VerificationReport) error {
+	if err := reportTemplate.Execute(w, report); err != nil {
+		return fmt.Errorf("failed to render report: %w", err)
+	}
+	return nil
+}
+
+const reportHTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Signature verification report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+h1 { font-size: 1.5em; }
+h2 { font-size: 1.2em; margin-top: 2em; border-bottom: 1px solid #ccc; }
+h3 { font-size: 1em; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { text-align: left; vertical-align: top; padding: 0.25em 1em 0.25em 0; }
+th { font-weight: 600; white-space: nowrap; }
+.chain td, .chain th { border-bottom: 1px solid #eee; }
+.valid, .good { color: #1a7f37; }
+.invalid, .revoked, .disallowed { color: #cf222e; }
+.warning, .unknown { color: #9a6700; }
+code { font-size: 0.9em; word-break: break-all; }
+</style>
+</head>
+<body>
+<h1>Signature verification report</h1>
+<table>
+<tr><th>File</th><td>{{.File}}</td></tr>
+<tr><th>Size</th><td>{{.FileSize}} bytes</td></tr>
+<tr><th>SHA-256</th><td><code>{{.SHA256}}</code></td></tr>
+<tr><th>Verified at</th><td>{{formatTime .VerifiedAt}}</td></tr>
+<tr><th>Validation mode</th><td>{{.ValidationMode}}{{with .ValidationTime}}, as of {{formatTime .}}{{end}}</td></tr>
+<tr><th>Signatures</th><td>{{len .Signatures}}</td></tr>
+</table>
+{{range .Signatures}}
+<h2>Signature {{.Index}}{{if .FieldName}} ({{.FieldName}}){{end}}{{if .Summary.DocumentTimestamp}}, document timestamp{{end}}</h2>
+<table>
+<tr><th>Signer</th><td>{{.Summary.SignerName}}</td></tr>
+<tr><th>Signer DN</th><td>{{.Summary.SignerDN}}</td></tr>
+<tr><th>Signing time</th><td>{{.Summary.SigningTime}}</td></tr>
+<tr><th>Signature</th><td class="{{if .Summary.IsValid}}valid{{else}}invalid{{end}}">{{.Summary.ValidationMessage}}</td></tr>
+<tr><th>Certificate</th><td class="{{if .Summary.CertificateValid}}valid{{else}}invalid{{end}}">{{.Summary.CertificateValidationMessage}}</td></tr>
+<tr><th>Trust anchor</th><td>{{with .TrustAnchor}}{{.SubjectDN}} ({{.Source}}){{else}}None{{end}}</td></tr>
+{{if .Summary.Reason}}<tr><th>Reason</th><td>{{.Summary.Reason}}</td></tr>{{end}}
+{{if .Summary.Location}}<tr><th>Location</th><td>{{.Summary.Location}}</td></tr>{{end}}
+{{if .Summary.ContactInfo}}<tr><th>Contact</th><td>{{.Summary.ContactInfo}}</td></tr>{{end}}
+<tr><th>Format</th><td>{{.SubFilter}}</td></tr>
+<tr><th>Digest algorithm</th><td>{{.DigestAlgorithm}}</td></tr>
+<tr><th>Signature algorithm</th><td>{{.SignatureAlgorithm}}</td></tr>
+<tr><th>Byte range</th><td>{{ranges .ByteRange.Ranges}}{{if .ByteRange.Error}} <span class="invalid">({{.ByteRange.Error}})</span>{{else}} ({{.ByteRange.CoveredBytes}} of {{.ByteRange.FileSize}} bytes){{end}}</td></tr>
+<tr><th>Covers whole file</th><td>{{yesNo .ByteRange.CoversWholeFile}}</td></tr>
+<tr><th>Modified after signing</th><td{{if .ModifiedAfterSigning}} class="warning"{{end}}>{{yesNo .ModifiedAfterSigning}}</td></tr>
+<tr><th>Permissions (DocMDP)</th><td>{{if .DocMDP}}Level {{.DocMDP.Level}}: {{.DocMDP.Description}}{{else}}Approval signature{{end}}</td></tr>
+{{with .FieldMDP}}<tr><th>Locked fields (FieldMDP)</th><td>{{.Action}}{{if .Fields}}: {{join .Fields ", "}}{{end}}</td></tr>{{end}}
+<tr><th>Later changes</th><td class="{{.Summary.ModificationStatus}}">{{.Summary.ModificationStatus}}</td></tr>
+<tr><th>Time source</th><td>{{.TimeSource}}{{if .VerificationTime}} ({{formatTime .VerificationTime}}){{end}}</td></tr>
+<tr><th>Revocation sources</th><td>{{if .RevocationSources}}{{join .RevocationSources ", "}}{{else}}None{{end}}</td></tr>
+</table>
+{{with .Timestamp}}
+<h3>Timestamp</h3>
+<table>
+<tr><th>Time</th><td>{{formatTime .Time}}</td></tr>
+<tr><th>Status</th><td>{{.Status}}{{if .Trusted}} (trusted){{else}} (untrusted){{end}}</td></tr>
+<tr><th>Hash algorithm</th><td>{{.HashAlgorithm}}</td></tr>
+<tr><th>Serial number</th><td><code>{{.SerialNumber}}</code></td></tr>
+{{if .Policy}}<tr><th>Policy</th><td>{{.Policy}}</td></tr>{{end}}
+{{if .Accuracy}}<tr><th>Accuracy</th><td>{{.Accuracy}}</td></tr>{{end}}
+{{if .Authority}}<tr><th>Authority</th><td>{{.Authority}}</td></tr>{{end}}
+</table>
+{{end}}
+<h3>Certificate chain</h3>
+<table class="chain">
+<tr><th>Subject</th><th>Issuer</th><th>Valid</th><th>Status</th><th>Revocation</th></tr>
+{{range .Chain}}
+<tr>
+<td>{{.SubjectDN}}<br><code>{{.FingerprintSHA256}}</code></td>
+<td>{{.IssuerDN}}</td>
+<td>{{formatTime .NotBefore}} &ndash; {{formatTime .NotAfter}}</td>
+<td class="{{.Status}}">{{.Status}}{{if .RevocationTime}} on {{formatTime .RevocationTime}}{{end}}{{range .Errors}}<br>{{.}}{{end}}{{range .Warnings}}<br><span class="warning">{{.}}</span>{{end}}</td>
+<td>{{if .RevocationSources}}{{join .RevocationSources ", "}}{{else}}None{{end}}</td>
+</tr>
+{{end}}
+</table>
+{{if .Summary.Evidence}}
+<h3>Revocation evidence</h3>
+<table class="chain">
+<tr><th>Certificate</th><th>Status</th><th>Method</th><th>Source</th><th>Published</th></tr>
+{{range .Summary.Evidence}}
+<tr>
+<td>{{.Certificate}}</td>
+<td class="{{.Status}}">{{.Status}}{{if .RevokedAt}} (revoked on {{formatTime .RevokedAt}}){{end}}{{if .Message}}<br>{{.Message}}{{end}}</td>
+<td>{{.Method}}</td>
+<td>{{.Source}}{{if .Location}} ({{.Location}}){{end}}</td>
+<td>{{with .ThisUpdate}}{{formatTime .}}{{end}}{{with .NextUpdate}} &ndash; {{formatTime .}}{{end}}</td>
+</tr>
+{{end}}
+</table>
+{{end}}
+{{if .Summary.Modifications}}
+<h3>Changes after signing</h3>
+<table class="chain">
+<tr><th>Change</th><th>Kind</th><th>Verdict</th></tr>
+{{range .Summary.Modifications}}
+<tr><td>{{.Description}}</td><td>{{.Kind}}</td><td class="{{if .Allowed}}valid{{else}}invalid{{end}}">{{if .Allowed}}Allowed{{else}}Disallowed: {{.Reason}}{{end}}</td></tr>
+{{end}}
+</table>
+{{end}}
+{{if .Warnings}}
+<h3>Warnings</h3>
+<ul>
+{{range .Warnings}}<li class="warning">{{.}}</li>{{end}}
+</ul>
+{{end}}
+{{end}}
+</body>
+</html>
+`
@@ -0,0 +1,128 @@
+package signature
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Matbe34/lankir/internal/signature/revocation"
+)
+
+// TestGetVerificationReport tests the structured report of a freshly signed PDF
+func TestGetVerificationReport(t *testing.T) {
+	storeDir := t.TempDir()
+	service, _ := NewTestServiceWithStore(t, storeDir)
+	service.revocationChecker = revocation.NewChecker(t.TempDir(), nil)
+
+	signedPath, cert := SignTestPDF(t, service, storeDir)
+
+	report, err := service.GetVerificationReport(signedPath)
+	if err != nil {
+		t.Fatalf("GetVerificationReport failed: %v", err)
+	}
+	if len(report.Signatures) != 1 {
+		t.Fatalf("Expected 1 signature, got %d", len(report.Signatures))
+	}
+
+	sig := report.Signatures[0]
+	if sig.DigestAlgorithm != "SHA-256" {
+		t.Errorf("Expected SHA-256 digest, got %q", sig.DigestAlgorithm)
+	}
+	if sig.Summary.SigningHashAlgorithm != "SHA-256" {
+		t.Errorf("Expected summary hash algorithm SHA-256, got %q", sig.Summary.SigningHashAlgorithm)
+	}
+	if !strings.HasPrefix(sig.SignatureAlgorithm, "ECDSA") {
+		t.Errorf("Expected an ECDSA signature algorithm, got %q", sig.SignatureAlgorithm)
+	}
+	if !sig.ByteRange.CoversWholeFile || sig.ModifiedAfterSigning || sig.ByteRange.Error != "" {
+		t.Errorf("Expected the signature to cover the whole file, got %+v", sig.ByteRange)
+	}
+	if sig.ByteRange.FileSize != report.FileSize {
+		t.Errorf("Expected file size %d, got %d", report.FileSize, sig.ByteRange.FileSize)
+	}
+	if sig.DocMDP == nil || sig.DocMDP.Level != 2 {
+		t.Errorf("Expected DocMDP level 2 for an invisible certification signature, got %+v", sig.DocMDP)
+	}
+	if len(sig.Chain) == 0 || sig.Chain[0].SubjectDN != cert.Subject.String() {
+		t.Fatalf("Expected the signer certificate first in the chain, got %+v", sig.Chain)
+	}
+	if sig.Chain[0].FingerprintSHA256 != CertificateFingerprint(cert) {
+		t.Errorf("Expected signer fingerprint %s, got %s", CertificateFingerprint(cert), sig.Chain[0].FingerprintSHA256)
+	}
+	if sig.Chain[0].Status != CertificateStatusInvalid {
+		t.Errorf("Expected the self-signed signer to be untrusted, got status %s", sig.Chain[0].Status)
+	}
+	if sig.Timestamp != nil {
+		t.Errorf("Expected no timestamp, got %+v", sig.Timestamp)
+	}
+
+	var html bytes.Buffer
+	if err := WriteVerificationReportHTML(&html, report); err != nil {
+		t.Fatalf("WriteVerificationReportHTML failed: %v", err)
+	}
+	if !strings.Contains(html.String(), "SHA-256") || !strings.Contains(html.String(), "Report Signer") {
+		t.Error("Expected the HTML report to include the digest algorithm and signer")
+	}
+}
+
+// TestGetVerificationReport_ModifiedAfterSigning tests that appended bytes are reported
+func TestGetVerificationReport_ModifiedAfterSigning(t *testing.T) {
+	storeDir := t.TempDir()
+	service, _ := NewTestServiceWithStore(t, storeDir)
+	service.revocationChecker = revocation.NewChecker(t.TempDir(), nil)
+
+	signedPath, _ := SignTestPDF(t, service, storeDir)
+
+	f, err := os.OpenFile(signedPath, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("Failed to open signed PDF: %v", err)
+	}
+	f.WriteString("% appended comment\n")
+	f.Close()
+
+	report, err := service.GetVerificationReport(signedPath)
+	if err != nil {
+		t.Fatalf("GetVerificationReport failed: %v", err)
+	}
+	if len(report.Signatures) != 1 {
+		t.Fatalf("Expected 1 signature, got %d", len(report.Signatures))
+	}
+
+	sig := report.Signatures[0]
+	if !sig.ModifiedAfterSigning || sig.ByteRange.CoversWholeFile {
+		t.Errorf("Expected the signature to be reported as modified, got %+v", sig.ByteRange)
+	}
+	if sig.ByteRange.BytesAfterSignature != int64(len("% appended comment\n")) {
+		t.Errorf("Expected %d bytes after signature, got %d", len("% appended comment\n"), sig.ByteRange.BytesAfterSignature)
+	}
+}
+
+// TestByteRangeCoverage_Invalid tests that malformed byte ranges are rejected
+func TestByteRangeCoverage_Invalid(t *testing.T) {
+	data := []byte("0123<abcd>6789")
+
+	tests := []struct {
+		name   string
+		ranges []int64
+	}{
+		{"wrong length", []int64{0, 4}},
+		{"not at start", []int64{1, 3, 10, 4}},
+		{"beyond end", []int64{0, 4, 10, 10}},
+		{"gap is not the signature", []int64{0, 3, 10, 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			coverage := byteRangeCoverage(tt.ranges, data)
+			if coverage.Error == "" {
+				t.Errorf("Expected an error for %v", tt.ranges)
+			}
+		})
+	}
+
+	coverage := byteRangeCoverage([]int64{0, 4, 10, 4}, data)
+	if coverage.Error != "" || !coverage.CoversWholeFile || coverage.CoveredBytes != 8 {
+		t.Errorf("Expected full coverage, got %+v", coverage)
+	}
+}
@@ -0,0 +1,82 @@
+package signature
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+
+	"github.com/Matbe34/lankir/internal/signature/revocation"
+)
+
+// newRevocationChecker creates the checker used for pre-signing revocation checks.
+func newRevocationChecker() *revocation.Checker {
+	cacheDir, err := revocation.DefaultCacheDir()
+	if err != nil {
+		slog.Warn("revocation responses will not be cached", "error", err)
+		cacheDir = ""
+	}
+	return revocation.NewChecker(cacheDir, nil)
+}
+
+// revocationPolicy returns the revocation policy for a signing operation.
+// A policy set on the profile takes precedence over the configured default.
+func (s *SignatureService) revocationPolicy(profile *SignatureProfile) (revocation.Policy, error) {
+	if profile != nil && profile.RevocationPolicy != "" {
+		return revocation.ParsePolicy(profile.RevocationPolicy)
+	}
+
+	if s.configService != nil {
+		return revocation.ParsePolicy(s.configService.Get().RevocationPolicy)
+	}
+
+	return revocation.PolicyOff, nil
+}
+
+// checkSigningRevocation checks that the signing certificate is not revoked before signing.
+// It returns the issuer certificate when found and the revocation evidence, which
+// callers embed in the signature for long-term validation.
+func (s *SignatureService) checkSigningRevocation(signer CertificateSigner, policy revocation.Policy) (*x509.Certificate, *revocation.Result, error) {
+	ctx := s.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	cert := signer.Certificate()
+	name := cert.Subject.CommonName
+
+	var candidates []*x509.Certificate
+	if provider, ok := signer.(CertificateChainProvider); ok {
+		candidates = provider.CertificateChain()
+	}
+
+	issuer, err := s.revocationChecker.ResolveIssuer(ctx, cert, candidates)
+	if err != nil {
+		slog.Debug("issuer of signing certificate not found", "certificate", name, "error", err)
+		issuer = nil
+	}
+
+	result, err := s.revocationChecker.Check(ctx, cert, issuer)
+	if err != nil {
+		if policy == revocation.PolicyHardFail {
+			return issuer, nil, fmt.Errorf("revocation check failed for certificate '%s': %w", name, err)
+		}
+		slog.Warn("revocation status of signing certificate unavailable, continuing (soft-fail)",
+			"certificate", name,
+			"error", err)
+		return issuer, nil, nil
+	}
+
+	if result.Status == revocation.StatusRevoked {
+		return issuer, result, fmt.Errorf("certificate '%s' was revoked on %s (checked via %s)",
+			name, result.RevokedAt.Format("2006-01-02 15:04:05"), result.Method)
+	}
+
+	slog.Debug("signing certificate revocation status",
+		"certificate", name,
+		"status", result.Status,
+		"method", result.Method,
+		"cached", result.FromCache)
+
+	return issuer, result, nil
+}
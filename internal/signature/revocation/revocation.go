@@ -0,0 +1,496 @@
+package revocation
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Matbe34/lankir/internal/signature/certutil"
+	pdfrevocation "github.com/digitorus/pdfsign/revocation"
+	"golang.org/x/crypto/ocsp"
+)
+
+// Policy controls how the pre-signing revocation check reacts to its outcome.
+type Policy string
+
+const (
+	// PolicyOff skips the revocation check.
+	PolicyOff Policy = "off"
+	// PolicySoftFail refuses revoked certificates but signs when the status cannot be determined.
+	PolicySoftFail Policy = "soft-fail"
+	// PolicyHardFail only signs when the certificate is confirmed not revoked.
+	PolicyHardFail Policy = "hard-fail"
+)
+
+// ParsePolicy validates a policy name. An empty string yields PolicyOff.
+func ParsePolicy(s string) (Policy, error) {
+	switch Policy(strings.ToLower(strings.TrimSpace(s))) {
+	case "", PolicyOff:
+		return PolicyOff, nil
+	case PolicySoftFail:
+		return PolicySoftFail, nil
+	case PolicyHardFail:
+		return PolicyHardFail, nil
+	default:
+		return "", fmt.Errorf("invalid revocation policy %q (expected off, soft-fail or hard-fail)", s)
+	}
+}
+
+// Status is the revocation state of a certificate.
+type Status string
+
+const (
+	StatusGood    Status = "good"
+	StatusRevoked Status = "revoked"
+	StatusUnknown Status = "unknown"
+)
+
+// Revocation methods reported in Result.Method.
+const (
+	MethodOCSP = "ocsp"
+	MethodCRL  = "crl"
+)
+
+const (
+	// DefaultOCSPCacheTTL is how long an OCSP response without NextUpdate is reused.
+	DefaultOCSPCacheTTL = time.Hour
+	// DefaultHTTPTimeout bounds each request to an OCSP responder, CRL or issuer URL.
+	DefaultHTTPTimeout = 10 * time.Second
+
+	maxResponseSize = 20 << 20
+
+	// maxClockSkew tolerates OCSP responses produced slightly ahead of the local clock.
+	maxClockSkew = 5 * time.Minute
+)
+
+var (
+	// ErrNoRevocationSources is returned when a certificate lists no OCSP responder or CRL.
+	ErrNoRevocationSources = errors.New("certificate has no OCSP responder or CRL distribution point")
+	// ErrIssuerNotFound is returned when the issuing certificate cannot be located.
+	ErrIssuerNotFound = errors.New("issuer certificate not found")
+	// ErrUnauthorizedResponder is returned for OCSP responses signed by a certificate the issuer
+	// did not delegate OCSP signing to.
+	ErrUnauthorizedResponder = errors.New("OCSP response not signed by the issuer or an authorized responder")
+)
+
+// Result describes the revocation status of a certificate and the evidence it is based on.
+type Result struct {
+	Status           Status    `json:"status"`
+	Method           string    `json:"method"`
+	URL              string    `json:"url"`
+	ThisUpdate       time.Time `json:"thisUpdate"`
+	NextUpdate       time.Time `json:"nextUpdate,omitempty"`
+	RevokedAt        time.Time `json:"revokedAt,omitempty"`
+	RevocationReason int       `json:"revocationReason,omitempty"`
+	FromCache        bool      `json:"fromCache"`
+
+	// Raw is the DER-encoded OCSP response or CRL, kept for long-term validation embedding.
+	Raw []byte `json:"-"`
+}
+
+// AddTo embeds the raw OCSP response or CRL into a revocation archival structure.
+func (r *Result) AddTo(archival *pdfrevocation.InfoArchival) error {
+	if r == nil || len(r.Raw) == 0 {
+		return nil
+	}
+
+	switch r.Method {
+	case MethodOCSP:
+		return archival.AddOCSP(r.Raw)
+	case MethodCRL:
+		return archival.AddCRL(r.Raw)
+	default:
+		return fmt.Errorf("unknown revocation method: %s", r.Method)
+	}
+}
+
+// Checker queries OCSP responders and CRL distribution points, caching responses on disk.
+type Checker struct {
+	cacheDir string
+	client   *http.Client
+	now      func() time.Time
+}
+
+// NewChecker creates a checker caching responses in cacheDir. A nil client uses a default with DefaultHTTPTimeout.
+func NewChecker(cacheDir string, client *http.Client) *Checker {
+	if client == nil {
+		client = &http.Client{Timeout: DefaultHTTPTimeout}
+	}
+
+	return &Checker{
+		cacheDir: cacheDir,
+		client:   client,
+		now:      time.Now,
+	}
+}
+
+// DefaultCacheDir returns the directory used to cache revocation responses.
+func DefaultCacheDir() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user cache directory: %w", err)
+	}
+	return filepath.Join(cacheDir, "lankir", "revocation"), nil
+}
+
+// Check determines the revocation status of cert, trying OCSP before CRLs.
+// An error means no source could provide a status.
+func (c *Checker) Check(ctx context.Context, cert, issuer *x509.Certificate) (*Result, error) {
+	if len(cert.OCSPServer) == 0 && len(cert.CRLDistributionPoints) == 0 {
+		return nil, ErrNoRevocationSources
+	}
+
+	var errs []error
+
+	if issuer != nil {
+		for _, url := range cert.OCSPServer {
+			result, err := c.checkOCSP(ctx, url, cert, issuer)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("OCSP %s: %w", url, err))
+				continue
+			}
+			if result.Status != StatusUnknown {
+				return result, nil
+			}
+			errs = append(errs, fmt.Errorf("OCSP %s: responder does not know the certificate", url))
+		}
+	} else if len(cert.OCSPServer) > 0 {
+		errs = append(errs, fmt.Errorf("OCSP: %w", ErrIssuerNotFound))
+	}
+
+	for _, url := range cert.CRLDistributionPoints {
+		result, err := c.checkCRL(ctx, url, cert, issuer)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("CRL %s: %w", url, err))
+			continue
+		}
+		return result, nil
+	}
+
+	return nil, fmt.Errorf("revocation status could not be determined: %w", errors.Join(errs...))
+}
+
+// ResolveIssuer finds the certificate that issued cert among candidates, falling
+// back to the Authority Information Access CA issuers URLs.
+func (c *Checker) ResolveIssuer(ctx context.Context, cert *x509.Certificate, candidates []*x509.Certificate) (*x509.Certificate, error) {
+	for _, candidate := range candidates {
+		if candidate != nil && !candidate.Equal(cert) && cert.CheckSignatureFrom(candidate) == nil {
+			return candidate, nil
+		}
+	}
+
+	for _, url := range cert.IssuingCertificateURL {
+		data, _, err := c.fetchCached(ctx, "issuer", url, func(data []byte) (time.Time, error) {
+			_, err := certutil.ParseCertificates(data)
+			return time.Time{}, err
+		})
+		if err != nil {
+			continue
+		}
+
+		issuers, err := certutil.ParseCertificates(data)
+		if err != nil {
+			continue
+		}
+		for _, candidate := range issuers {
+			if cert.CheckSignatureFrom(candidate) == nil {
+				return candidate, nil
+			}
+		}
+	}
+
+	return nil, ErrIssuerNotFound
+}
+
+func (c *Checker) checkOCSP(ctx context.Context, url string, cert, issuer *x509.Certificate) (*Result, error) {
+	key := url + "|" + hex.EncodeToString(issuer.RawSubjectPublicKeyInfo) + "|" + cert.SerialNumber.String()
+
+	var resp *ocsp.Response
+	raw, fromCache, err := c.cached("ocsp", key, func(data []byte) (time.Time, error) {
+		parsed, err := ocsp.ParseResponseForCert(data, cert, issuer)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if err := CheckOCSPResponder(parsed, issuer); err != nil {
+			return time.Time{}, err
+		}
+		resp = parsed
+		return ocspExpiry(parsed), nil
+	}, func() ([]byte, error) {
+		req, err := ocsp.CreateRequest(cert, issuer, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OCSP request: %w", err)
+		}
+		return c.post(ctx, url, "application/ocsp-request", req)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := CheckOCSPFreshness(resp, c.now()); err != nil {
+		return nil, err
+	}
+
+	result := &Result{
+		Method:     MethodOCSP,
+		URL:        url,
+		ThisUpdate: resp.ThisUpdate,
+		NextUpdate: resp.NextUpdate,
+		FromCache:  fromCache,
+		Raw:        raw,
+	}
+
+	switch resp.Status {
+	case ocsp.Good:
+		result.Status = StatusGood
+	case ocsp.Revoked:
+		result.Status = StatusRevoked
+		result.RevokedAt = resp.RevokedAt
+		result.RevocationReason = resp.RevocationReason
+	default:
+		result.Status = StatusUnknown
+	}
+
+	return result, nil
+}
+
+func (c *Checker) checkCRL(ctx context.Context, url string, cert, issuer *x509.Certificate) (*Result, error) {
+	if issuer == nil {
+		return nil, ErrIssuerNotFound
+	}
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return nil, fmt.Errorf("unsupported CRL URL scheme")
+	}
+
+	var crl *x509.RevocationList
+	raw, fromCache, err := c.cached("crl", url, func(data []byte) (time.Time, error) {
+		parsed, err := ParseCRL(data)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if err := parsed.CheckSignatureFrom(issuer); err != nil {
+			return time.Time{}, fmt.Errorf("CRL signature invalid: %w", err)
+		}
+		crl = parsed
+		return parsed.NextUpdate, nil
+	}, func() ([]byte, error) {
+		return c.get(ctx, url)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !crl.NextUpdate.IsZero() && c.now().After(crl.NextUpdate) {
+		return nil, fmt.Errorf("CRL expired at %s", crl.NextUpdate.Format(time.RFC3339))
+	}
+
+	result := &Result{
+		Status:     StatusGood,
+		Method:     MethodCRL,
+		URL:        url,
+		ThisUpdate: crl.ThisUpdate,
+		NextUpdate: crl.NextUpdate,
+		FromCache:  fromCache,
+		Raw:        raw,
+	}
+
+	for _, entry := range crl.RevokedCertificateEntries {
+		if entry.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+			result.Status = StatusRevoked
+			result.RevokedAt = entry.RevocationTime
+			result.RevocationReason = entry.ReasonCode
+			break
+		}
+	}
+
+	return result, nil
+}
+
+// cached returns a fresh cached response for key, or fetches, validates and caches a new one.
+// parse validates a response and returns the time until which it may be reused.
+func (c *Checker) cached(kind, key string, parse func([]byte) (time.Time, error), fetch func() ([]byte, error)) ([]byte, bool, error) {
+	path := c.cachePath(kind, key)
+
+	if path != "" {
+		if data, err := os.ReadFile(path); err == nil {
+			if expiry, err := parse(data); err == nil && c.now().Before(expiry) {
+				return data, true, nil
+			}
+		}
+	}
+
+	data, err := fetch()
+	if err != nil {
+		return nil, false, err
+	}
+
+	expiry, err := parse(data)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if path != "" && c.now().Before(expiry) {
+		c.writeCache(path, data)
+	}
+
+	return data, false, nil
+}
+
+// fetchCached downloads url once and then serves the cached copy, for immutable content such as issuer certificates.
+func (c *Checker) fetchCached(ctx context.Context, kind, url string, parse func([]byte) (time.Time, error)) ([]byte, bool, error) {
+	path := c.cachePath(kind, url)
+	if path != "" {
+		if data, err := os.ReadFile(path); err == nil {
+			return data, true, nil
+		}
+	}
+
+	data, err := c.get(ctx, url)
+	if err != nil {
+		return nil, false, err
+	}
+	if _, err := parse(data); err != nil {
+		return nil, false, err
+	}
+
+	if path != "" {
+		c.writeCache(path, data)
+	}
+	return data, false, nil
+}
+
+func (c *Checker) cachePath(kind, key string) string {
+	if c.cacheDir == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.cacheDir, kind+"-"+hex.EncodeToString(sum[:])+".der")
+}
+
+func (c *Checker) writeCache(path string, data []byte) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return
+	}
+
+	// Each write gets its own temporary file so concurrent fetches of the same
+	// response never rename a file another writer is still filling.
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return
+	}
+	tmpPath := tmp.Name()
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+	}
+}
+
+func (c *Checker) get(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.do(req)
+}
+
+func (c *Checker) post(ctx context.Context, url, contentType string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	return c.do(req)
+}
+
+func (c *Checker) do(req *http.Request) ([]byte, error) {
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected HTTP status %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxResponseSize {
+		return nil, fmt.Errorf("response exceeds %d bytes", maxResponseSize)
+	}
+	return data, nil
+}
+
+// CheckOCSPResponder checks that a response parsed for a certificate of issuer was signed by the
+// issuer itself or by a delegated responder certified for OCSP signing. ocsp.ParseResponseForCert
+// only checks that the issuer signed the responder certificate, which any certificate of the same
+// CA satisfies.
+func CheckOCSPResponder(resp *ocsp.Response, issuer *x509.Certificate) error {
+	responder := resp.Certificate
+	if responder == nil || responder.Equal(issuer) {
+		return nil
+	}
+
+	authorized := false
+	for _, usage := range responder.ExtKeyUsage {
+		if usage == x509.ExtKeyUsageOCSPSigning {
+			authorized = true
+			break
+		}
+	}
+	if !authorized {
+		return fmt.Errorf("%w: responder %q lacks the OCSP signing extended key usage", ErrUnauthorizedResponder, responder.Subject.CommonName)
+	}
+	if resp.ThisUpdate.Before(responder.NotBefore) || resp.ThisUpdate.After(responder.NotAfter) {
+		return fmt.Errorf("%w: responder certificate not valid when the response was produced", ErrUnauthorizedResponder)
+	}
+	return nil
+}
+
+// CheckOCSPFreshness checks that a response is current at the given time, allowing for small
+// clock differences on its start.
+func CheckOCSPFreshness(resp *ocsp.Response, at time.Time) error {
+	if resp.ThisUpdate.After(at.Add(maxClockSkew)) {
+		return fmt.Errorf("OCSP response not valid before %s", resp.ThisUpdate.Format(time.RFC3339))
+	}
+	if !resp.NextUpdate.IsZero() && !at.Before(resp.NextUpdate) {
+		return fmt.Errorf("OCSP response expired at %s", resp.NextUpdate.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// ocspExpiry returns when a response stops being reusable.
+func ocspExpiry(resp *ocsp.Response) time.Time {
+	if !resp.NextUpdate.IsZero() {
+		return resp.NextUpdate
+	}
+	return resp.ThisUpdate.Add(DefaultOCSPCacheTTL)
+}
+
+// ParseCRL parses a DER or PEM encoded CRL.
+func ParseCRL(data []byte) (*x509.RevocationList, error) {
+	if block, _ := pem.Decode(data); block != nil && block.Type == "X509 CRL" {
+		data = block.Bytes
+	}
+	return x509.ParseRevocationList(data)
+}
@@ -0,0 +1,395 @@
+package revocation
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pdfrevocation "github.com/digitorus/pdfsign/revocation"
+	"golang.org/x/crypto/ocsp"
+)
+
+// testPKI is a CA with a local OCSP responder and CRL endpoint
+type testPKI struct {
+	ca        *x509.Certificate
+	caKey     crypto.Signer
+	server    *httptest.Server
+	revoked   map[string]bool
+	ocspHits  int32
+	crlHits   int32
+	ocspDown  bool
+	issuerDER []byte
+
+	// responder and responderKey sign OCSP responses instead of the CA when set
+	responder    *x509.Certificate
+	responderKey crypto.Signer
+	// ocspAge shifts the validity window of OCSP responses into the past
+	ocspAge time.Duration
+}
+
+func newTestPKI(t *testing.T) *testPKI {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate CA key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	ca, _ := x509.ParseCertificate(der)
+
+	pki := &testPKI{ca: ca, caKey: key, revoked: make(map[string]bool), issuerDER: der}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ocsp", pki.handleOCSP(t))
+	mux.HandleFunc("/crl", pki.handleCRL(t))
+	mux.HandleFunc("/ca.crt", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(pki.issuerDER)
+	})
+	pki.server = httptest.NewServer(mux)
+	t.Cleanup(pki.server.Close)
+
+	return pki
+}
+
+func (p *testPKI) handleOCSP(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&p.ocspHits, 1)
+		if p.ocspDown {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		req, err := ocsp.ParseRequest(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		template := ocsp.Response{
+			Status:       ocsp.Good,
+			SerialNumber: req.SerialNumber,
+			ThisUpdate:   time.Now().Add(-time.Minute - p.ocspAge),
+			NextUpdate:   time.Now().Add(time.Hour - p.ocspAge),
+		}
+		if p.revoked[req.SerialNumber.String()] {
+			template.Status = ocsp.Revoked
+			template.RevokedAt = time.Now().Add(-30 * time.Minute)
+			template.RevocationReason = ocsp.KeyCompromise
+		}
+
+		responder, key := p.ca, p.caKey
+		if p.responder != nil {
+			responder, key = p.responder, p.responderKey
+			template.Certificate = p.responder
+		}
+		resp, err := ocsp.CreateResponse(p.ca, responder, template, key)
+		if err != nil {
+			t.Errorf("Failed to create OCSP response: %v", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/ocsp-response")
+		w.Write(resp)
+	}
+}
+
+func (p *testPKI) handleCRL(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&p.crlHits, 1)
+
+		var entries []x509.RevocationListEntry
+		for serial := range p.revoked {
+			n, _ := new(big.Int).SetString(serial, 10)
+			entries = append(entries, x509.RevocationListEntry{SerialNumber: n, RevocationTime: time.Now().Add(-time.Hour)})
+		}
+
+		crl, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+			Number:                    big.NewInt(1),
+			ThisUpdate:                time.Now().Add(-time.Minute),
+			NextUpdate:                time.Now().Add(time.Hour),
+			RevokedCertificateEntries: entries,
+		}, p.ca, p.caKey)
+		if err != nil {
+			t.Errorf("Failed to create CRL: %v", err)
+			return
+		}
+		w.Write(crl)
+	}
+}
+
+// issue creates a leaf certificate pointing at the test responder
+func (p *testPKI) issue(t *testing.T, serial int64, withOCSP, withCRL bool) *x509.Certificate {
+	t.Helper()
+
+	cert, _ := p.issueWithKey(t, serial, withOCSP, withCRL, nil)
+	return cert
+}
+
+// issueWithKey creates a leaf certificate with the given extended key usages and returns its key
+func (p *testPKI) issueWithKey(t *testing.T, serial int64, withOCSP, withCRL bool, extKeyUsage []x509.ExtKeyUsage) (*x509.Certificate, crypto.Signer) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: "Signer"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		IssuingCertificateURL: []string{p.server.URL + "/ca.crt"},
+		ExtKeyUsage:           extKeyUsage,
+	}
+	if withOCSP {
+		template.OCSPServer = []string{p.server.URL + "/ocsp"}
+	}
+	if withCRL {
+		template.CRLDistributionPoints = []string{p.server.URL + "/crl"}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, p.ca, &key.PublicKey, p.caKey)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return cert, key
+}
+
+// TestParsePolicy tests policy name parsing
+func TestParsePolicy(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Policy
+		wantErr bool
+	}{
+		{"", PolicyOff, false},
+		{"off", PolicyOff, false},
+		{"Soft-Fail", PolicySoftFail, false},
+		{"hard-fail", PolicyHardFail, false},
+		{"strict", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParsePolicy(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParsePolicy(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("ParsePolicy(%q) = %s, want %s", tt.input, got, tt.want)
+		}
+	}
+}
+
+// TestCheck_OCSPGoodAndCached tests an OCSP check against a local responder and its disk cache
+func TestCheck_OCSPGoodAndCached(t *testing.T) {
+	pki := newTestPKI(t)
+	cert := pki.issue(t, 100, true, false)
+	checker := NewChecker(t.TempDir(), nil)
+
+	result, err := checker.Check(context.Background(), cert, pki.ca)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if result.Status != StatusGood || result.Method != MethodOCSP {
+		t.Errorf("Expected good OCSP result, got %s via %s", result.Status, result.Method)
+	}
+	if result.FromCache {
+		t.Error("First result should not come from cache")
+	}
+
+	again, err := checker.Check(context.Background(), cert, pki.ca)
+	if err != nil {
+		t.Fatalf("Second check failed: %v", err)
+	}
+	if !again.FromCache {
+		t.Error("Second result should come from cache")
+	}
+	if hits := atomic.LoadInt32(&pki.ocspHits); hits != 1 {
+		t.Errorf("Expected 1 OCSP request, got %d", hits)
+	}
+
+	var archival pdfrevocation.InfoArchival
+	if err := again.AddTo(&archival); err != nil {
+		t.Fatalf("AddTo failed: %v", err)
+	}
+	if len(archival.OCSP) != 1 {
+		t.Errorf("Expected OCSP response to be embeddable, got %d entries", len(archival.OCSP))
+	}
+}
+
+// TestCheck_OCSPRevoked tests that revoked certificates are reported
+func TestCheck_OCSPRevoked(t *testing.T) {
+	pki := newTestPKI(t)
+	pki.revoked["101"] = true
+	cert := pki.issue(t, 101, true, false)
+
+	result, err := NewChecker(t.TempDir(), nil).Check(context.Background(), cert, pki.ca)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if result.Status != StatusRevoked {
+		t.Errorf("Expected revoked, got %s", result.Status)
+	}
+	if result.RevocationReason != ocsp.KeyCompromise {
+		t.Errorf("Expected reason %d, got %d", ocsp.KeyCompromise, result.RevocationReason)
+	}
+}
+
+// TestCheck_OCSPStale tests that a response past its NextUpdate is not accepted as good
+func TestCheck_OCSPStale(t *testing.T) {
+	pki := newTestPKI(t)
+	pki.ocspAge = 2 * time.Hour
+	cert := pki.issue(t, 105, true, false)
+
+	result, err := NewChecker(t.TempDir(), nil).Check(context.Background(), cert, pki.ca)
+	if err == nil {
+		t.Fatalf("Expected stale OCSP response to be rejected, got %s", result.Status)
+	}
+}
+
+// TestCheck_OCSPDelegatedResponder tests that delegated responders need the OCSP signing usage
+func TestCheck_OCSPDelegatedResponder(t *testing.T) {
+	tests := []struct {
+		name        string
+		extKeyUsage []x509.ExtKeyUsage
+		wantErr     bool
+	}{
+		{"OCSP signing responder", []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning}, false},
+		{"sibling certificate", nil, true},
+		{"client authentication only", []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}, true},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pki := newTestPKI(t)
+			pki.revoked["110"] = true
+			pki.responder, pki.responderKey = pki.issueWithKey(t, int64(200+i), false, false, tt.extKeyUsage)
+			cert := pki.issue(t, 110, true, false)
+
+			result, err := NewChecker(t.TempDir(), nil).Check(context.Background(), cert, pki.ca)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Expected response from unauthorized responder to be rejected, got %s", result.Status)
+				}
+				if !errors.Is(err, ErrUnauthorizedResponder) {
+					t.Errorf("Expected ErrUnauthorizedResponder, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Check failed: %v", err)
+			}
+			if result.Status != StatusRevoked {
+				t.Errorf("Expected revoked, got %s", result.Status)
+			}
+		})
+	}
+}
+
+// TestCheck_FallsBackToCRL tests that the CRL is used when the OCSP responder fails
+func TestCheck_FallsBackToCRL(t *testing.T) {
+	pki := newTestPKI(t)
+	pki.ocspDown = true
+	pki.revoked["102"] = true
+	cert := pki.issue(t, 102, true, true)
+
+	result, err := NewChecker(t.TempDir(), nil).Check(context.Background(), cert, pki.ca)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if result.Method != MethodCRL || result.Status != StatusRevoked {
+		t.Errorf("Expected revoked via CRL, got %s via %s", result.Status, result.Method)
+	}
+}
+
+// TestCheck_NoSources tests certificates without revocation information
+func TestCheck_NoSources(t *testing.T) {
+	pki := newTestPKI(t)
+	cert := pki.issue(t, 103, false, false)
+
+	_, err := NewChecker(t.TempDir(), nil).Check(context.Background(), cert, pki.ca)
+	if !errors.Is(err, ErrNoRevocationSources) {
+		t.Errorf("Expected ErrNoRevocationSources, got %v", err)
+	}
+}
+
+// TestWriteCache_Concurrent tests that concurrent writers of one cache entry leave a complete file
+func TestWriteCache_Concurrent(t *testing.T) {
+	dir := t.TempDir()
+	checker := NewChecker(dir, nil)
+	path := checker.cachePath("ocsp", "concurrent")
+
+	var wg sync.WaitGroup
+	for i := range 16 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checker.writeCache(path, bytes.Repeat([]byte{byte(i)}, 64<<10))
+		}()
+	}
+	wg.Wait()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if len(data) != 64<<10 || !bytes.Equal(data, bytes.Repeat(data[:1], len(data))) {
+		t.Error("Cache entry mixes the data of several writers")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected only the cache entry, found %d files", len(entries))
+	}
+}
+
+// TestResolveIssuer tests issuer lookup from candidates and the AIA URL
+func TestResolveIssuer(t *testing.T) {
+	pki := newTestPKI(t)
+	cert := pki.issue(t, 104, true, false)
+	checker := NewChecker(t.TempDir(), nil)
+
+	issuer, err := checker.ResolveIssuer(context.Background(), cert, []*x509.Certificate{pki.ca})
+	if err != nil || !issuer.Equal(pki.ca) {
+		t.Errorf("Expected issuer from candidates, got %v", err)
+	}
+
+	issuer, err = checker.ResolveIssuer(context.Background(), cert, nil)
+	if err != nil || !issuer.Equal(pki.ca) {
+		t.Errorf("Expected issuer from AIA URL, got %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package signature
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/Matbe34/lankir/internal/signature/revocation"
+)
+
+// testSigner is an in-memory CertificateSigner
+type testSigner struct {
+	*ecdsa.PrivateKey
+	cert *x509.Certificate
+}
+
+func (s *testSigner) Certificate() *x509.Certificate {
+	return s.cert
+}
+
+// TestRevocationPolicy tests that the profile policy overrides the config default
+func TestRevocationPolicy(t *testing.T) {
+	service, cfgService := NewTestServiceWithStore(t, t.TempDir())
+
+	cfg := cfgService.Get()
+	cfg.RevocationPolicy = "soft-fail"
+	if err := cfgService.Update(cfg); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	profile := DefaultInvisibleProfile()
+	policy, err := service.revocationPolicy(profile)
+	if err != nil || policy != revocation.PolicySoftFail {
+		t.Errorf("Expected config policy soft-fail, got %s (%v)", policy, err)
+	}
+
+	profile.RevocationPolicy = "hard-fail"
+	policy, err = service.revocationPolicy(profile)
+	if err != nil || policy != revocation.PolicyHardFail {
+		t.Errorf("Expected profile policy hard-fail, got %s (%v)", policy, err)
+	}
+
+	profile.RevocationPolicy = "sometimes"
+	if err := service.profileManager.ValidateProfile(profile); err == nil {
+		t.Error("Expected invalid revocation policy to fail profile validation")
+	}
+}
+
+// TestCheckSigningRevocation_UnknownStatus tests hard-fail and soft-fail when no status is available
+func TestCheckSigningRevocation_UnknownStatus(t *testing.T) {
+	service, _ := NewTestServiceWithStore(t, t.TempDir())
+	service.revocationChecker = revocation.NewChecker(t.TempDir(), nil)
+
+	now := time.Now()
+	cert, key := CreateTestCertificate(t, "No Revocation Info", now.Add(-time.Hour), now.Add(24*time.Hour))
+	signer := &testSigner{PrivateKey: key, cert: cert}
+
+	if _, _, err := service.checkSigningRevocation(signer, revocation.PolicyHardFail); err == nil {
+		t.Error("Expected hard-fail to refuse a certificate without revocation information")
+	}
+
+	if _, _, err := service.checkSigningRevocation(signer, revocation.PolicySoftFail); err != nil {
+		t.Errorf("Expected soft-fail to allow signing, got %v", err)
+	}
+}
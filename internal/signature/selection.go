@@ -0,0 +1,195 @@
+package signature
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Matbe34/lankir/internal/config"
+	"github.com/Matbe34/lankir/internal/signature/types"
+)
+
+// CertificateSelection is the outcome of automatic signing certificate selection.
+type CertificateSelection struct {
+	Certificate types.Certificate    `json:"certificate"`
+	Reasons     []string             `json:"reasons"`
+	Candidates  []CertificateRanking `json:"candidates"`
+}
+
+// CertificateRanking explains how one candidate was ranked. Rank is 1-based and 0 for
+// candidates that are not eligible for signing.
+type CertificateRanking struct {
+	Fingerprint string   `json:"fingerprint"`
+	Name        string   `json:"name"`
+	Source      string   `json:"source"`
+	Rank        int      `json:"rank"`
+	Eligible    bool     `json:"eligible"`
+	Reasons     []string `json:"reasons"`
+}
+
+// rankedCertificate holds the comparison keys of an eligible candidate.
+type rankedCertificate struct {
+	cert           types.Certificate
+	nonRepudiation bool
+	qualified      bool
+	issuerRank     int
+	sourceRank     int
+	reasons        []string
+}
+
+// SelectSigningCertificate chooses the best certificate for signing among those matching search
+// (all certificates when empty). Candidates must be able to sign, be currently valid and have a
+// private key. They are then ranked by non-repudiation, qualified status, preferred issuer,
+// preferred source and latest expiry; the fingerprint breaks remaining ties so the choice is
+// deterministic.
+func (s *SignatureService) SelectSigningCertificate(search string) (*CertificateSelection, error) {
+	return s.selectSigningCertificate(CertificateFilter{Search: search})
+}
+
+// selectSigningCertificate chooses the best certificate for signing among those matching filter.
+func (s *SignatureService) selectSigningCertificate(filter CertificateFilter) (*CertificateSelection, error) {
+	certs, err := s.ListCertificatesFiltered(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var prefs config.CertificatePreferences
+	if s.configService != nil {
+		prefs = s.configService.Get().CertificatePreferences
+	}
+
+	var eligible []rankedCertificate
+	var rejected []CertificateRanking
+	for _, cert := range certs {
+		if reason := ineligibleReason(cert, prefs); reason != "" {
+			rejected = append(rejected, CertificateRanking{
+				Fingerprint: cert.Fingerprint,
+				Name:        cert.Name,
+				Source:      cert.Source,
+				Reasons:     []string{reason},
+			})
+			continue
+		}
+		eligible = append(eligible, rankCertificate(cert, prefs))
+	}
+
+	if len(eligible) == 0 {
+		var scope string
+		if filter.Search != "" {
+			scope += fmt.Sprintf(" matching '%s'", filter.Search)
+		}
+		if filter.Source != "" {
+			scope += fmt.Sprintf(" from source '%s'", filter.Source)
+		}
+		return nil, fmt.Errorf("no certificate%s can be used for signing (%d considered)", scope, len(certs))
+	}
+
+	sort.SliceStable(eligible, func(i, j int) bool {
+		return betterCandidate(eligible[i], eligible[j])
+	})
+
+	selection := &CertificateSelection{
+		Certificate: eligible[0].cert,
+		Reasons:     eligible[0].reasons,
+	}
+	for i, r := range eligible {
+		selection.Candidates = append(selection.Candidates, CertificateRanking{
+			Fingerprint: r.cert.Fingerprint,
+			Name:        r.cert.Name,
+			Source:      r.cert.Source,
+			Rank:        i + 1,
+			Eligible:    true,
+			Reasons:     r.reasons,
+		})
+	}
+	selection.Candidates = append(selection.Candidates, rejected...)
+
+	return selection, nil
+}
+
+// ineligibleReason returns why cert cannot be selected automatically, or "" if it can.
+func ineligibleReason(cert types.Certificate, prefs config.CertificatePreferences) string {
+	for _, fp := range prefs.ExcludedCertificates {
+		if strings.EqualFold(fp, cert.Fingerprint) {
+			return "excluded by preferences"
+		}
+	}
+	if !cert.HasSigningCapability() {
+		return "no digital signature key usage"
+	}
+	if !cert.IsValid {
+		return "expired or not yet valid"
+	}
+	if !cert.HasPrivateKey {
+		return "no private key"
+	}
+	if prefs.RequireQualified && !cert.IsQualified {
+		return "not qualified (qualified certificate required by preferences)"
+	}
+	return ""
+}
+
+// rankCertificate computes the ranking keys of an eligible certificate and describes them.
+func rankCertificate(cert types.Certificate, prefs config.CertificatePreferences) rankedCertificate {
+	r := rankedCertificate{
+		cert:           cert,
+		nonRepudiation: cert.HasKeyUsage("Non Repudiation"),
+		qualified:      cert.IsQualified,
+		issuerRank:     preferenceRank(prefs.PreferredIssuers, func(p string) bool { return issuerMatches(cert, p) }),
+		sourceRank:     preferenceRank(prefs.PreferredSources, func(p string) bool { return strings.EqualFold(cert.Source, p) }),
+	}
+
+	r.reasons = append(r.reasons, "can sign, valid and has a private key")
+	if r.nonRepudiation {
+		r.reasons = append(r.reasons, "has non-repudiation key usage")
+	}
+	if r.qualified {
+		r.reasons = append(r.reasons, "qualified certificate")
+	}
+	if r.issuerRank < len(prefs.PreferredIssuers) {
+		r.reasons = append(r.reasons, fmt.Sprintf("preferred issuer #%d (%s)", r.issuerRank+1, prefs.PreferredIssuers[r.issuerRank]))
+	}
+	if r.sourceRank < len(prefs.PreferredSources) {
+		r.reasons = append(r.reasons, fmt.Sprintf("preferred source #%d (%s)", r.sourceRank+1, prefs.PreferredSources[r.sourceRank]))
+	}
+	r.reasons = append(r.reasons, fmt.Sprintf("expires %s", cert.ValidTo))
+
+	return r
+}
+
+// betterCandidate reports whether a ranks before b.
+func betterCandidate(a, b rankedCertificate) bool {
+	if a.nonRepudiation != b.nonRepudiation {
+		return a.nonRepudiation
+	}
+	if a.qualified != b.qualified {
+		return a.qualified
+	}
+	if a.issuerRank != b.issuerRank {
+		return a.issuerRank < b.issuerRank
+	}
+	if a.sourceRank != b.sourceRank {
+		return a.sourceRank < b.sourceRank
+	}
+	// ValidTo uses a fixed-width layout, so later expiry sorts lexically greater
+	if a.cert.ValidTo != b.cert.ValidTo {
+		return a.cert.ValidTo > b.cert.ValidTo
+	}
+	return a.cert.Fingerprint < b.cert.Fingerprint
+}
+
+// preferenceRank returns the index of the first preference matched, or len(prefs) if none is.
+func preferenceRank(prefs []string, matches func(string) bool) int {
+	for i, p := range prefs {
+		if matches(p) {
+			return i
+		}
+	}
+	return len(prefs)
+}
+
+func issuerMatches(cert types.Certificate, pattern string) bool {
+	pattern = strings.ToLower(pattern)
+	return strings.Contains(strings.ToLower(cert.Issuer), pattern) ||
+		strings.Contains(strings.ToLower(cert.IssuerDN), pattern)
+}
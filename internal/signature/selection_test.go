@@ -0,0 +1,94 @@
+package signature
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+	"time"
+)
+
+// TestSelectSigningCertificate tests ranking, eligibility and preference rules
+func TestSelectSigningCertificate(t *testing.T) {
+	storeDir := t.TempDir()
+	service, cfgService := NewTestServiceWithStore(t, storeDir)
+	now := time.Now()
+
+	alpha, alphaKey := CreateTestCertificate(t, "Alpha Signer", now.Add(-time.Hour), now.Add(100*24*time.Hour))
+	WriteTestPKCS12(t, storeDir, "alpha.p12", alpha, alphaKey)
+
+	gamma, gammaKey := CreateTestCertificate(t, "Gamma Signer", now.Add(-time.Hour), now.Add(200*24*time.Hour))
+	WriteTestPKCS12(t, storeDir, "gamma.p12", gamma, gammaKey)
+
+	// Longest validity, but without non-repudiation
+	betaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	beta := CreateTestCertificateFromTemplate(t, &x509.Certificate{
+		Subject:   pkix.Name{CommonName: "Beta Signer"},
+		NotBefore: now.Add(-time.Hour),
+		NotAfter:  now.Add(300 * 24 * time.Hour),
+		KeyUsage:  x509.KeyUsageDigitalSignature,
+	}, betaKey)
+	WriteTestPKCS12(t, storeDir, "beta.p12", beta, betaKey)
+
+	// Not eligible: no private key, and expired
+	noKey, _ := CreateTestCertificate(t, "Delta Signer", now.Add(-time.Hour), now.Add(400*24*time.Hour))
+	WriteTestCertificatePEM(t, storeDir, "delta.pem", noKey)
+	expired, expiredKey := CreateTestCertificate(t, "Epsilon Signer", now.Add(-48*time.Hour), now.Add(-24*time.Hour))
+	WriteTestPKCS12(t, storeDir, "epsilon.p12", expired, expiredKey)
+
+	selection, err := service.SelectSigningCertificate("signer")
+	if err != nil {
+		t.Fatalf("SelectSigningCertificate failed: %v", err)
+	}
+	if selection.Certificate.Fingerprint != CertificateFingerprint(gamma) {
+		t.Errorf("Expected Gamma (non-repudiation, latest expiry), got %s", selection.Certificate.Name)
+	}
+	if len(selection.Reasons) == 0 {
+		t.Error("Expected the selection to be explained")
+	}
+
+	ranks := make(map[string]int)
+	for _, c := range selection.Candidates {
+		ranks[c.Fingerprint] = c.Rank
+	}
+	if ranks[CertificateFingerprint(alpha)] != 2 || ranks[CertificateFingerprint(beta)] != 3 {
+		t.Errorf("Unexpected ranking: %+v", selection.Candidates)
+	}
+	if ranks[CertificateFingerprint(noKey)] != 0 || ranks[CertificateFingerprint(expired)] != 0 {
+		t.Error("Certificates without a key or expired must not be ranked")
+	}
+
+	cfg := cfgService.Get()
+	cfg.CertificatePreferences.PreferredIssuers = []string{"alpha"}
+	if err := cfgService.Update(cfg); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	selection, err = service.SelectSigningCertificate("signer")
+	if err != nil || selection.Certificate.Fingerprint != CertificateFingerprint(alpha) {
+		t.Errorf("Expected the preferred issuer to win, got %v (%v)", selection, err)
+	}
+
+	cfg = cfgService.Get()
+	cfg.CertificatePreferences.ExcludedCertificates = []string{CertificateFingerprint(alpha)}
+	if err := cfgService.Update(cfg); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	selection, err = service.SelectSigningCertificate("signer")
+	if err != nil || selection.Certificate.Fingerprint != CertificateFingerprint(gamma) {
+		t.Errorf("Expected excluded certificate to be skipped, got %v (%v)", selection, err)
+	}
+
+	cfg = cfgService.Get()
+	cfg.CertificatePreferences.RequireQualified = true
+	if err := cfgService.Update(cfg); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if _, err := service.SelectSigningCertificate("signer"); err == nil {
+		t.Error("Expected no selection when a qualified certificate is required")
+	}
+}
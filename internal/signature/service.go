@@ -3,6 +3,7 @@ package signature
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"slices"
@@ -11,6 +12,8 @@ import (
 	"github.com/Matbe34/lankir/internal/config"
 	"github.com/Matbe34/lankir/internal/signature/pkcs11"
 	"github.com/Matbe34/lankir/internal/signature/pkcs12"
+	"github.com/Matbe34/lankir/internal/signature/revocation"
+	"github.com/Matbe34/lankir/internal/signature/types"
 	"github.com/google/uuid"
 )
 
@@ -19,14 +22,38 @@ type SignatureService struct {
 	ctx            context.Context
 	profileManager *ProfileManager
 	configService  *config.Service
+	certIndex      *certificateIndex
+	certWatcher    *certificateWatcher
+	sourceLoader   *sourceLoader
+	pinCache       *pinCache
+	extractionDirs *extractionDirs
+
+	revocationChecker *revocation.Checker
 }
 
 // NewSignatureService creates a new signature service instance with the given configuration service.
 func NewSignatureService(cfgService *config.Service) *SignatureService {
-	return &SignatureService{
+	certIndex := newCertificateIndex()
+	s := &SignatureService{
 		profileManager: NewProfileManager(),
 		configService:  cfgService,
+		certIndex:      certIndex,
+		sourceLoader:   newSourceLoader(certIndex.invalidate),
+		pinCache:       newPINCache(),
+		extractionDirs: newExtractionDirs(),
+
+		revocationChecker: newRevocationChecker(),
+	}
+	s.certWatcher = newCertificateWatcher(s.certIndex.invalidate)
+
+	if cfgService != nil {
+		cfgService.OnChange(func(cfg *config.Config) {
+			s.certIndex.invalidate()
+			s.certWatcher.setPaths(watchedCertificatePaths(cfg))
+		})
 	}
+
+	return s
 }
 
 // Startup stores the app context. Called by Wails on app start.
@@ -34,6 +61,54 @@ func (s *SignatureService) Startup(ctx context.Context) {
 	s.ctx = ctx
 }
 
+// StartWatching watches certificate stores and PKCS#11 tokens until ctx is cancelled or
+// Shutdown is called, invalidating the certificate index on changes. Only the GUI calls it;
+// one-shot CLI commands read the stores once and have nothing to keep fresh.
+func (s *SignatureService) StartWatching(ctx context.Context) {
+	if err := s.certWatcher.start(ctx, s.watchedPaths, s.tokenLibraries); err != nil {
+		slog.Warn("failed to watch certificate sources", "error", err)
+	}
+}
+
+// DomReady notifies the frontend about certificates nearing expiry. Called by Wails once the UI has loaded.
+func (s *SignatureService) DomReady(ctx context.Context) {
+	go func() {
+		expiring, err := s.GetExpiringCertificates(0)
+		if err != nil {
+			slog.Warn("failed to check certificate expiry", "error", err)
+			return
+		}
+		if len(expiring) > 0 {
+			s.emitEvent(EventCertificatesExpiring, expiring)
+		}
+	}()
+}
+
+// Shutdown stops watching certificate sources, forgets cached PINs and removes extracted
+// signatures. Called by Wails on app shutdown.
+func (s *SignatureService) Shutdown(ctx context.Context) {
+	s.certWatcher.stop()
+	s.pinCache.clear()
+	s.extractionDirs.removeAll()
+}
+
+// watchedPaths returns the certificate directories to watch for the current configuration.
+func (s *SignatureService) watchedPaths() []string {
+	var cfg *config.Config
+	if s.configService != nil {
+		cfg = s.configService.Get()
+	}
+	return watchedCertificatePaths(cfg)
+}
+
+// tokenLibraries returns the currently configured PKCS#11 module paths.
+func (s *SignatureService) tokenLibraries() []string {
+	if s.configService == nil {
+		return nil
+	}
+	return s.configService.Get().TokenLibraries
+}
+
 // ListSignatureProfiles returns all saved signature profiles.
 func (s *SignatureService) ListSignatureProfiles() ([]*SignatureProfile, error) {
 	return s.profileManager.ListProfiles()
@@ -48,6 +123,34 @@ func (s *SignatureService) GetSignatureProfile(profileIDStr string) (*SignatureP
 	return s.profileManager.GetProfile(profileID)
 }
 
+// FindSignatureProfile retrieves a signature profile by its UUID string or, failing that, by its
+// name, ignoring case. A name shared by several profiles is rejected as ambiguous.
+func (s *SignatureService) FindSignatureProfile(idOrName string) (*SignatureProfile, error) {
+	if profileID, err := uuid.Parse(idOrName); err == nil {
+		return s.profileManager.GetProfile(profileID)
+	}
+
+	profiles, err := s.profileManager.ListProfiles()
+	if err != nil {
+		return nil, err
+	}
+
+	var found *SignatureProfile
+	for _, profile := range profiles {
+		if !strings.EqualFold(profile.Name, idOrName) {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("several profiles are named %q, use the profile ID", idOrName)
+		}
+		found = profile
+	}
+	if found == nil {
+		return nil, fmt.Errorf("profile not found: %s", idOrName)
+	}
+	return found, nil
+}
+
 // GetDefaultSignatureProfile returns the profile marked as default.
 func (s *SignatureService) GetDefaultSignatureProfile() (*SignatureProfile, error) {
 	return s.profileManager.GetDefaultProfile()
@@ -58,6 +161,44 @@ func (s *SignatureService) SaveSignatureProfile(profile *SignatureProfile) error
 	return s.profileManager.SaveProfile(profile)
 }
 
+// SetDefaultSignatureProfile makes a profile the default, clearing the flag on all others.
+func (s *SignatureService) SetDefaultSignatureProfile(profileIDStr string) (*SignatureProfile, error) {
+	profileID, err := uuid.Parse(profileIDStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid profile ID format: %w", err)
+	}
+	return s.profileManager.SetDefaultProfile(profileID)
+}
+
+// DuplicateSignatureProfile saves a copy of a profile under a new ID and the given name.
+func (s *SignatureService) DuplicateSignatureProfile(profileIDStr string, name string) (*SignatureProfile, error) {
+	profileID, err := uuid.Parse(profileIDStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid profile ID format: %w", err)
+	}
+	return s.profileManager.DuplicateProfile(profileID, name)
+}
+
+// ExportSignatureProfiles writes the given profiles, or all profiles if none are given, to a
+// self-contained bundle including their logos and fonts.
+func (s *SignatureService) ExportSignatureProfiles(profileIDs []string, outputPath string) (*types.ProfileBundleManifest, error) {
+	ids := make([]uuid.UUID, 0, len(profileIDs))
+	for _, idStr := range profileIDs {
+		id, err := uuid.Parse(idStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid profile ID format: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return s.profileManager.ExportProfileBundle(ids, outputPath)
+}
+
+// ImportSignatureProfiles adds the profiles of a bundle. onConflict is keep, replace or rename
+// and decides what happens to profiles whose ID already exists.
+func (s *SignatureService) ImportSignatureProfiles(bundlePath string, onConflict string) (*types.ProfileImportResult, error) {
+	return s.profileManager.ImportProfileBundle(bundlePath, onConflict)
+}
+
 // DeleteSignatureProfile removes a signature profile by its UUID string.
 func (s *SignatureService) DeleteSignatureProfile(profileIDStr string) error {
 	profileID, err := uuid.Parse(profileIDStr)
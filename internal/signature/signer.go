@@ -10,3 +10,9 @@ type CertificateSigner interface {
 	crypto.Signer
 	Certificate() *x509.Certificate
 }
+
+// CertificateChainProvider is implemented by signers that carry the CA certificates
+// of the signing certificate, such as PKCS#12 files.
+type CertificateChainProvider interface {
+	CertificateChain() []*x509.Certificate
+}
@@ -13,7 +13,9 @@ import (
 	"github.com/Matbe34/lankir/internal/signature/nss"
 	"github.com/Matbe34/lankir/internal/signature/pkcs11"
 	"github.com/Matbe34/lankir/internal/signature/pkcs12"
+	"github.com/Matbe34/lankir/internal/signature/revocation"
 	"github.com/Matbe34/lankir/internal/signature/types"
+	pdfrevocation "github.com/digitorus/pdfsign/revocation"
 	"github.com/digitorus/pdfsign/sign"
 	"github.com/google/uuid"
 )
@@ -26,7 +28,8 @@ func generateSignedPDFPath(pdfPath string) string {
 	return base + "_signed.pdf"
 }
 
-// SignPDF signs a PDF with the specified certificate using the default profile.
+// SignPDF signs a PDF with the specified certificate using the default profile. Without a
+// certificate fingerprint, the certificate bound to the profile is used.
 func (s *SignatureService) SignPDF(pdfPath string, certFingerprint string, pin string) (string, error) {
 	defaultProfile, err := s.profileManager.GetDefaultProfile()
 	if err != nil {
@@ -35,7 +38,8 @@ func (s *SignatureService) SignPDF(pdfPath string, certFingerprint string, pin s
 	return s.SignPDFWithProfile(pdfPath, certFingerprint, pin, defaultProfile.ID.String())
 }
 
-// SignPDFWithProfile signs a PDF using the specified certificate and signature profile.
+// SignPDFWithProfile signs a PDF using the specified certificate and signature profile. Without a
+// certificate fingerprint, the certificate bound to the profile is used.
 func (s *SignatureService) SignPDFWithProfile(pdfPath string, certFingerprint string, pin string, profileIDStr string) (string, error) {
 	return s.SignPDFWithProfileAndPosition(pdfPath, certFingerprint, pin, profileIDStr, nil)
 }
@@ -66,51 +70,104 @@ func (s *SignatureService) SignPDFWithProfileAndPosition(pdfPath string, certFin
 			positionOverride.Page = 1
 		}
 
-		const maxSignatureDimension = 2000.0
-		const maxCoordinate = 10000.0
-
-		if positionOverride.Width > maxSignatureDimension {
-			return "", fmt.Errorf("signature width too large: %.2f points (maximum %.2f)",
-				positionOverride.Width, maxSignatureDimension)
-		}
-		if positionOverride.Height > maxSignatureDimension {
-			return "", fmt.Errorf("signature height too large: %.2f points (maximum %.2f)",
-				positionOverride.Height, maxSignatureDimension)
-		}
-		if positionOverride.X < 0 || positionOverride.X > maxCoordinate {
-			return "", fmt.Errorf("signature X coordinate out of bounds: %.2f (must be 0-%.2f)",
-				positionOverride.X, maxCoordinate)
-		}
-		if positionOverride.Y < 0 || positionOverride.Y > maxCoordinate {
-			return "", fmt.Errorf("signature Y coordinate out of bounds: %.2f (must be 0-%.2f)",
-				positionOverride.Y, maxCoordinate)
+		if err := checkSignaturePosition(positionOverride); err != nil {
+			return "", err
 		}
 
 		profile.Position = *positionOverride
 	}
 
-	// Validate the profile
+	return s.signWithProfile(pdfPath, certFingerprint, pin, profile)
+}
+
+// SignPDFWithCustomProfile signs a PDF with profile settings that need not be saved, such as a
+// saved profile with some fields changed for a single signature.
+func (s *SignatureService) SignPDFWithCustomProfile(pdfPath string, certFingerprint string, pin string, profile *SignatureProfile) (string, error) {
+	if profile == nil {
+		return "", fmt.Errorf("signature profile is required")
+	}
+	if profile.Visibility == VisibilityVisible {
+		if err := checkSignaturePosition(&profile.Position); err != nil {
+			return "", err
+		}
+	}
+	return s.signWithProfile(pdfPath, certFingerprint, pin, profile)
+}
+
+// SignPDFWithCertificate signs a PDF like SignPDFWithCustomProfile, with a certificate the caller
+// has already resolved, for example with ResolveProfileCertificate. When the profile names a
+// certificate source, the location of the certificate in that source is used.
+func (s *SignatureService) SignPDFWithCertificate(pdfPath string, cert *types.Certificate, pin string, profile *SignatureProfile) (string, error) {
+	if profile == nil {
+		return "", fmt.Errorf("signature profile is required")
+	}
+	if cert == nil {
+		return "", fmt.Errorf("signing certificate is required")
+	}
+	if profile.Visibility == VisibilityVisible {
+		if err := checkSignaturePosition(&profile.Position); err != nil {
+			return "", err
+		}
+	}
 	if err := s.profileManager.ValidateProfile(profile); err != nil {
 		return "", fmt.Errorf("invalid signature profile: %w", err)
 	}
 
-	certs, err := s.ListCertificates()
-	if err != nil {
-		return "", fmt.Errorf("failed to list certificates: %w", err)
+	selectedCert := *cert
+	useSourceLocation(&selectedCert, profile.CertificateSource)
+	return s.signWithSelectedCertificate(pdfPath, &selectedCert, pin, profile)
+}
+
+// checkSignaturePosition rejects signature boxes too large or too far off the page to be sensible.
+func checkSignaturePosition(pos *SignaturePosition) error {
+	const maxSignatureDimension = 2000.0
+	const maxCoordinate = 10000.0
+
+	if pos.Width > maxSignatureDimension {
+		return fmt.Errorf("signature width too large: %.2f points (maximum %.2f)",
+			pos.Width, maxSignatureDimension)
+	}
+	if pos.Height > maxSignatureDimension {
+		return fmt.Errorf("signature height too large: %.2f points (maximum %.2f)",
+			pos.Height, maxSignatureDimension)
+	}
+	if pos.X < 0 || pos.X > maxCoordinate {
+		return fmt.Errorf("signature X coordinate out of bounds: %.2f (must be 0-%.2f)",
+			pos.X, maxCoordinate)
 	}
+	if pos.Y < 0 || pos.Y > maxCoordinate {
+		return fmt.Errorf("signature Y coordinate out of bounds: %.2f (must be 0-%.2f)",
+			pos.Y, maxCoordinate)
+	}
+	return nil
+}
 
-	var selectedCert *types.Certificate
-	for _, cert := range certs {
-		if cert.Fingerprint == certFingerprint {
-			selectedCert = &cert
-			break
-		}
+// signWithProfile validates the profile and signs the PDF with the selected certificate, or the
+// certificate bound to the profile when certFingerprint is empty.
+func (s *SignatureService) signWithProfile(pdfPath string, certFingerprint string, pin string, profile *SignatureProfile) (string, error) {
+	// Validate the profile
+	if err := s.profileManager.ValidateProfile(profile); err != nil {
+		return "", fmt.Errorf("invalid signature profile: %w", err)
 	}
 
-	if selectedCert == nil {
-		return "", fmt.Errorf("certificate with fingerprint %s not found", certFingerprint)
+	var selectedCert *types.Certificate
+	var err error
+	if certFingerprint == "" {
+		selectedCert, err = s.ResolveProfileCertificate(profile)
+	} else {
+		selectedCert, err = s.GetCertificateByFingerprint(certFingerprint)
+	}
+	if err != nil {
+		return "", err
 	}
 
+	return s.signWithSelectedCertificate(pdfPath, selectedCert, pin, profile)
+}
+
+// signWithSelectedCertificate checks that selectedCert can sign and signs the PDF with it. Under
+// the cache PIN policy of the profile, an empty PIN is taken from the PIN cache and a PIN that
+// signed is cached.
+func (s *SignatureService) signWithSelectedCertificate(pdfPath string, selectedCert *types.Certificate, pin string, profile *SignatureProfile) (string, error) {
 	if !selectedCert.IsValid {
 		return "", fmt.Errorf("certificate '%s' is not valid (expired or not yet valid)", selectedCert.Name)
 	}
@@ -119,12 +176,50 @@ func (s *SignatureService) SignPDFWithProfileAndPosition(pdfPath string, certFin
 		return "", fmt.Errorf("certificate '%s' does not have digital signature capability", selectedCert.Name)
 	}
 
+	if err := s.checkSigningAlgorithms(selectedCert); err != nil {
+		return "", err
+	}
+
+	s.warnIfExpiring(selectedCert)
+
+	// Merged certificates use a location with a private key as their backend when one exists
+	if len(selectedCert.Locations) > 1 && !selectedCert.HasPrivateKey {
+		sources := make([]string, len(selectedCert.Locations))
+		for i, loc := range selectedCert.Locations {
+			sources[i] = loc.Source
+		}
+		return "", fmt.Errorf("certificate '%s' has no private key in any of its locations (%s)",
+			selectedCert.Name, strings.Join(sources, ", "))
+	}
+
+	if profile.PINPolicy != PINPolicyCache {
+		return s.signWithCertificate(pdfPath, selectedCert, pin, profile)
+	}
+
+	key := pinCacheKey(selectedCert)
+	cached := false
+	if pin == "" {
+		pin, cached = s.pinCache.get(key)
+	}
+	signedPath, err := s.signWithCertificate(pdfPath, selectedCert, pin, profile)
+	switch {
+	case err != nil && cached:
+		// The PIN may have changed; ask for it again next time
+		s.pinCache.remove(key)
+	case err == nil && pin != "":
+		s.pinCache.put(key, pin, time.Duration(profile.PINCacheMinutes)*time.Minute)
+	}
+	return signedPath, err
+}
+
+// signWithCertificate signs the PDF with the backend of cert.
+func (s *SignatureService) signWithCertificate(pdfPath string, selectedCert *types.Certificate, pin string, profile *SignatureProfile) (string, error) {
 	switch selectedCert.Source {
 	case "pkcs11":
 		return s.signWithPKCS11(pdfPath, selectedCert, pin, profile)
 	case "User NSS DB", "NSS Database":
 		return s.signWithNSS(pdfPath, selectedCert, pin, profile)
-	case "user", "system":
+	case "user", "system", "User Store", "User File":
 		if selectedCert.FilePath == "" {
 			return "", fmt.Errorf("certificate does not have an associated file path")
 		}
@@ -261,10 +356,35 @@ func (s *SignatureService) signWithPKCS12(pdfPath string, cert *types.Certificat
 }
 
 func (s *SignatureService) signPDFWithSigner(inputPath, outputPath string, signer CertificateSigner, cert *types.Certificate, profile *SignatureProfile) error {
+	policy, err := s.revocationPolicy(profile)
+	if err != nil {
+		return err
+	}
+
+	chain := []*x509.Certificate{signer.Certificate()}
+	var revocationData pdfrevocation.InfoArchival
+
+	if policy != revocation.PolicyOff {
+		issuer, result, err := s.checkSigningRevocation(signer, policy)
+		if err != nil {
+			return err
+		}
+		if issuer != nil {
+			chain = append(chain, issuer)
+		}
+		if err := result.AddTo(&revocationData); err != nil {
+			return fmt.Errorf("failed to embed revocation data: %w", err)
+		}
+	}
+
 	signingTime := time.Now().Local()
 
 	// Create appearance based on profile
-	appearance := CreateSignatureAppearance(profile, cert, signingTime)
+	appearanceData := newAppearanceData(profile, cert, signer.Certificate(), signingTime, inputPath)
+	appearance, err := CreateSignatureAppearance(profile, appearanceData)
+	if err != nil {
+		return err
+	}
 
 	// Determine signature type based on visibility
 	certType := sign.CertificationSignature
@@ -275,8 +395,10 @@ func (s *SignatureService) signPDFWithSigner(inputPath, outputPath string, signe
 	signData := sign.SignData{
 		Signature: sign.SignDataSignature{
 			Info: sign.SignDataSignatureInfo{
-				Name: cert.Name,
-				Date: signingTime,
+				Name:     cert.Name,
+				Date:     signingTime,
+				Reason:   profile.Reason,
+				Location: profile.Location,
 			},
 			CertType:   certType,
 			DocMDPPerm: sign.AllowFillingExistingFormFieldsAndSignaturesPerms,
@@ -285,7 +407,8 @@ func (s *SignatureService) signPDFWithSigner(inputPath, outputPath string, signe
 		Signer:            signer,
 		DigestAlgorithm:   crypto.SHA256,
 		Certificate:       signer.Certificate(),
-		CertificateChains: [][]*x509.Certificate{{signer.Certificate()}},
+		CertificateChains: [][]*x509.Certificate{chain},
+		RevocationData:    revocationData,
 		TSA: sign.TSA{
 			URL:      "",
 			Username: "",
@@ -293,7 +416,7 @@ func (s *SignatureService) signPDFWithSigner(inputPath, outputPath string, signe
 		},
 	}
 
-	err := sign.SignFile(inputPath, outputPath, signData)
+	err = sign.SignFile(inputPath, outputPath, signData)
 	if err != nil {
 		if _, statErr := os.Stat(outputPath); statErr == nil {
 			os.Remove(outputPath)
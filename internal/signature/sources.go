@@ -0,0 +1,290 @@
+package signature
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Matbe34/lankir/internal/config"
+	"github.com/Matbe34/lankir/internal/signature/pkcs11"
+	"github.com/Matbe34/lankir/internal/signature/pkcs12"
+	"github.com/Matbe34/lankir/internal/signature/types"
+)
+
+// Certificate source kinds reported in CertificateSourceStatus.Kind.
+const (
+	SourceKindStore  = "store"
+	SourceKindPKCS11 = "pkcs11"
+	SourceKindNSS    = "nss"
+)
+
+// Certificate source load outcomes reported in CertificateSourceStatus.Status.
+const (
+	SourceStatusOK               = "ok"
+	SourceStatusTimeout          = "timeout"
+	SourceStatusPermissionDenied = "permission_denied"
+	SourceStatusNotFound         = "not_found"
+	SourceStatusModuleFailed     = "module_failed"
+	SourceStatusError            = "error"
+)
+
+// DefaultCertificateSourceTimeout is used when the config does not set a per-source timeout.
+const DefaultCertificateSourceTimeout = 10 * time.Second
+
+// CertificateListResult contains the certificate inventory and the load status of every source.
+type CertificateListResult struct {
+	Certificates []types.Certificate             `json:"certificates"`
+	Sources      []types.CertificateSourceStatus `json:"sources"`
+}
+
+// certificateSource is a single store, PKCS#11 module or NSS database to load from.
+type certificateSource struct {
+	kind string
+	name string
+	load func() ([]types.Certificate, error)
+}
+
+func (src certificateSource) key() string {
+	return src.kind + ":" + src.name
+}
+
+// sourceCall is a load in progress. A load that outlives its timeout keeps
+// running in the background and later callers wait on it instead of starting
+// another one against the same, possibly hung, source.
+type sourceCall struct {
+	done  chan struct{}
+	certs []types.Certificate
+	err   error
+	// timedOut is set once a caller gave up waiting, so the late result is announced
+	timedOut bool
+}
+
+// sourceLoader runs certificate source loads concurrently with per-source timeouts.
+type sourceLoader struct {
+	mu       sync.Mutex
+	inflight map[string]*sourceCall
+	// onLateResult is called when a load that timed out finishes, since the inventory
+	// cached without it is then incomplete
+	onLateResult func()
+}
+
+func newSourceLoader(onLateResult func()) *sourceLoader {
+	return &sourceLoader{
+		inflight:     make(map[string]*sourceCall),
+		onLateResult: onLateResult,
+	}
+}
+
+// call starts loading src unless a load of the same source is already running.
+func (l *sourceLoader) call(src certificateSource) *sourceCall {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if c, ok := l.inflight[src.key()]; ok {
+		return c
+	}
+
+	c := &sourceCall{done: make(chan struct{})}
+	l.inflight[src.key()] = c
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				c.err = fmt.Errorf("panic while loading source: %v", r)
+			}
+			l.mu.Lock()
+			delete(l.inflight, src.key())
+			close(c.done)
+			late := c.timedOut
+			l.mu.Unlock()
+
+			if late && l.onLateResult != nil {
+				slog.Info("certificate source finished after timing out", "kind", src.kind, "source", src.name)
+				l.onLateResult()
+			}
+		}()
+		c.certs, c.err = src.load()
+	}()
+
+	return c
+}
+
+// loadAll loads every source in parallel and returns their certificates in source order.
+func (l *sourceLoader) loadAll(sources []certificateSource, timeout time.Duration) ([][]types.Certificate, []types.CertificateSourceStatus) {
+	certs := make([][]types.Certificate, len(sources))
+	statuses := make([]types.CertificateSourceStatus, len(sources))
+
+	var wg sync.WaitGroup
+	for i, src := range sources {
+		wg.Add(1)
+		go func(i int, src certificateSource) {
+			defer wg.Done()
+			certs[i], statuses[i] = l.load(src, timeout)
+		}(i, src)
+	}
+	wg.Wait()
+
+	return certs, statuses
+}
+
+// load waits up to timeout for src to finish loading and reports the outcome.
+func (l *sourceLoader) load(src certificateSource, timeout time.Duration) ([]types.Certificate, types.CertificateSourceStatus) {
+	status := types.CertificateSourceStatus{
+		Source: src.name,
+		Kind:   src.kind,
+	}
+
+	start := time.Now()
+	c := l.call(src)
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-c.done:
+	case <-timer.C:
+		if !l.giveUp(c) {
+			break
+		}
+		status.Status = SourceStatusTimeout
+		status.Message = fmt.Sprintf("source did not respond within %s", timeout)
+		status.DurationMs = time.Since(start).Milliseconds()
+		slog.Warn("certificate source timed out", "kind", src.kind, "source", src.name, "timeout", timeout)
+		return nil, status
+	}
+
+	status.DurationMs = time.Since(start).Milliseconds()
+
+	if c.err != nil {
+		status.Status = classifySourceError(src.kind, c.err)
+		status.Message = c.err.Error()
+		if status.Status == SourceStatusNotFound {
+			slog.Debug("certificate source not found", "kind", src.kind, "source", src.name, "error", c.err)
+		} else {
+			slog.Warn("failed to load certificate source", "kind", src.kind, "source", src.name, "error", c.err)
+		}
+		return nil, status
+	}
+
+	status.Status = SourceStatusOK
+	status.Count = len(c.certs)
+	return c.certs, status
+}
+
+// giveUp marks c as timed out unless it finished meanwhile, and reports whether it did so.
+func (l *sourceLoader) giveUp(c *sourceCall) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return false
+	default:
+		c.timedOut = true
+		return true
+	}
+}
+
+// classifySourceError maps a source load error to a status constant.
+func classifySourceError(kind string, err error) string {
+	switch {
+	case errors.Is(err, fs.ErrPermission):
+		return SourceStatusPermissionDenied
+	case errors.Is(err, fs.ErrNotExist):
+		return SourceStatusNotFound
+	case kind == SourceKindPKCS11:
+		return SourceStatusModuleFailed
+	default:
+		return SourceStatusError
+	}
+}
+
+// certificateSources returns every configured source in the order used for deduplication.
+func (s *SignatureService) certificateSources() []certificateSource {
+	var sources []certificateSource
+
+	if s.configService != nil {
+		cfg := s.configService.Get()
+
+		for _, storePath := range cfg.CertificateStores {
+			path := storePath
+			opts := storeScanOptions(cfg, path)
+			sources = append(sources, certificateSource{
+				kind: SourceKindStore,
+				name: path,
+				load: func() ([]types.Certificate, error) { return loadStoreCertificates(path, opts) },
+			})
+		}
+
+		for _, modulePath := range cfg.TokenLibraries {
+			path := modulePath
+			sources = append(sources, certificateSource{
+				kind: SourceKindPKCS11,
+				name: path,
+				load: func() ([]types.Certificate, error) { return pkcs11.LoadCertificatesFromModule(path) },
+			})
+		}
+	}
+
+	sources = append(sources, certificateSource{
+		kind: SourceKindNSS,
+		name: "NSS Database",
+		load: LoadNSSCertificates,
+	})
+
+	return sources
+}
+
+// sourceTimeout returns the configured per-source load timeout.
+func (s *SignatureService) sourceTimeout() time.Duration {
+	if s.configService != nil {
+		if seconds := s.configService.Get().CertificateSourceTimeout; seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	return DefaultCertificateSourceTimeout
+}
+
+// storeScanOptions returns the configured scan options for a certificate store.
+func storeScanOptions(cfg *config.Config, storePath string) pkcs12.ScanOptions {
+	opts, ok := cfg.CertificateStoreOptions[storePath]
+	if !ok {
+		return pkcs12.ScanOptions{}
+	}
+
+	return pkcs12.ScanOptions{
+		Recursive: opts.Recursive,
+		MaxDepth:  opts.MaxDepth,
+		Include:   opts.Include,
+		Exclude:   opts.Exclude,
+	}
+}
+
+// loadStoreCertificates loads a certificate store, skipping plain certificate
+// files inside NSS databases since those are served by the NSS source.
+func loadStoreCertificates(storePath string, opts pkcs12.ScanOptions) ([]types.Certificate, error) {
+	storeCerts, err := pkcs12.LoadCertificatesFromPathWithOptions(storePath, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	certs := make([]types.Certificate, 0, len(storeCerts))
+	for _, sc := range storeCerts {
+		if sc.FilePath != "" {
+			ext := strings.ToLower(filepath.Ext(sc.FilePath))
+			inNSSDB := strings.Contains(sc.FilePath, ".pki/nssdb")
+
+			if ext != ".p12" && ext != ".pfx" && inNSSDB {
+				continue
+			}
+		}
+		certs = append(certs, sc)
+	}
+
+	return certs, nil
+}
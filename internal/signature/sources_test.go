@@ -0,0 +1,175 @@
+package signature
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Matbe34/lankir/internal/signature/types"
+)
+
+// findSourceStatus returns the status reported for the named source
+func findSourceStatus(t *testing.T, sources []types.CertificateSourceStatus, name string) types.CertificateSourceStatus {
+	t.Helper()
+
+	for _, src := range sources {
+		if src.Source == name {
+			return src
+		}
+	}
+	t.Fatalf("No status reported for source %s", name)
+	return types.CertificateSourceStatus{}
+}
+
+// TestListCertificatesWithStatus tests per-source status reporting for stores
+func TestListCertificatesWithStatus(t *testing.T) {
+	storeDir := t.TempDir()
+	missingDir := filepath.Join(t.TempDir(), "missing")
+	service, cfgService := NewTestServiceWithStore(t, storeDir)
+
+	cfg := cfgService.Get()
+	cfg.CertificateStores = append(cfg.CertificateStores, missingDir)
+	if err := cfgService.Update(cfg); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	now := time.Now()
+	cert, _ := CreateTestCertificate(t, "Status", now.Add(-time.Hour), now.Add(24*time.Hour))
+	WriteTestCertificatePEM(t, storeDir, "status.pem", cert)
+
+	result, err := service.ListCertificatesWithStatus()
+	if err != nil {
+		t.Fatalf("ListCertificatesWithStatus failed: %v", err)
+	}
+
+	ok := findSourceStatus(t, result.Sources, storeDir)
+	if ok.Status != SourceStatusOK {
+		t.Errorf("Expected status %s for store, got %s (%s)", SourceStatusOK, ok.Status, ok.Message)
+	}
+	if ok.Count != 1 {
+		t.Errorf("Expected 1 certificate from store, got %d", ok.Count)
+	}
+	if ok.Kind != SourceKindStore {
+		t.Errorf("Expected kind %s, got %s", SourceKindStore, ok.Kind)
+	}
+
+	missing := findSourceStatus(t, result.Sources, missingDir)
+	if missing.Status != SourceStatusNotFound {
+		t.Errorf("Expected status %s for missing store, got %s", SourceStatusNotFound, missing.Status)
+	}
+
+	found := false
+	for _, c := range result.Certificates {
+		if c.Fingerprint == CertificateFingerprint(cert) {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("Certificate from healthy store missing from result")
+	}
+}
+
+// TestSourceLoader_Timeout tests that a hung source times out without blocking other sources
+func TestSourceLoader_Timeout(t *testing.T) {
+	loader := newSourceLoader(nil)
+	release := make(chan struct{})
+	defer close(release)
+
+	var calls int32
+	hung := certificateSource{
+		kind: SourceKindPKCS11,
+		name: "hung-module",
+		load: func() ([]types.Certificate, error) {
+			atomic.AddInt32(&calls, 1)
+			<-release
+			return nil, nil
+		},
+	}
+	healthy := certificateSource{
+		kind: SourceKindStore,
+		name: "healthy-store",
+		load: func() ([]types.Certificate, error) {
+			return []types.Certificate{{Fingerprint: "aa"}}, nil
+		},
+	}
+
+	start := time.Now()
+	results, statuses := loader.loadAll([]certificateSource{hung, healthy}, 100*time.Millisecond)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("loadAll took %s, expected to be bounded by the timeout", elapsed)
+	}
+
+	if statuses[0].Status != SourceStatusTimeout {
+		t.Errorf("Expected status %s for hung source, got %s", SourceStatusTimeout, statuses[0].Status)
+	}
+	if statuses[1].Status != SourceStatusOK || len(results[1]) != 1 {
+		t.Errorf("Expected healthy source to load 1 certificate, got %s with %d", statuses[1].Status, len(results[1]))
+	}
+
+	_, status := loader.load(hung, 50*time.Millisecond)
+	if status.Status != SourceStatusTimeout {
+		t.Errorf("Expected repeated load to time out, got %s", status.Status)
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("Expected hung source to be started once, got %d", n)
+	}
+}
+
+// TestSourceLoader_LateResult tests that a load finishing after its timeout announces the result
+func TestSourceLoader_LateResult(t *testing.T) {
+	late := make(chan struct{}, 1)
+	loader := newSourceLoader(func() { late <- struct{}{} })
+	release := make(chan struct{})
+
+	slow := certificateSource{
+		kind: SourceKindPKCS11,
+		name: "slow-module",
+		load: func() ([]types.Certificate, error) {
+			<-release
+			return []types.Certificate{{Fingerprint: "bb"}}, nil
+		},
+	}
+
+	if _, status := loader.load(slow, 50*time.Millisecond); status.Status != SourceStatusTimeout {
+		t.Fatalf("Expected status %s, got %s", SourceStatusTimeout, status.Status)
+	}
+	close(release)
+
+	select {
+	case <-late:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Late result was not announced")
+	}
+
+	certs, status := loader.load(slow, time.Second)
+	if status.Status != SourceStatusOK || len(certs) != 1 {
+		t.Errorf("Expected reload to return 1 certificate, got %s with %d", status.Status, len(certs))
+	}
+}
+
+// TestClassifySourceError tests mapping of load errors to source statuses
+func TestClassifySourceError(t *testing.T) {
+	tests := []struct {
+		name string
+		kind string
+		err  error
+		want string
+	}{
+		{"permission", SourceKindStore, fmt.Errorf("open: %w", fs.ErrPermission), SourceStatusPermissionDenied},
+		{"not exist", SourceKindPKCS11, fmt.Errorf("module does not exist: %w", fs.ErrNotExist), SourceStatusNotFound},
+		{"module failure", SourceKindPKCS11, errors.New("failed to initialize PKCS#11 module"), SourceStatusModuleFailed},
+		{"store failure", SourceKindStore, errors.New("bad data"), SourceStatusError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifySourceError(tt.kind, tt.err); got != tt.want {
+				t.Errorf("classifySourceError() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
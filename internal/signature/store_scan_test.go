@@ -0,0 +1,145 @@
+package signature
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Matbe34/lankir/internal/config"
+	goPkcs12 "software.sslmate.com/src/go-pkcs12"
+)
+
+// setStoreOptions configures scan options for a single certificate store
+func setStoreOptions(t *testing.T, cfgService *config.Service, storeDir string, opts config.StoreScanOptions) {
+	t.Helper()
+
+	cfg := cfgService.Get()
+	cfg.CertificateStoreOptions = map[string]config.StoreScanOptions{storeDir: opts}
+	if err := cfgService.Update(cfg); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+}
+
+// TestStoreScan_RecursiveWithSniffing tests nested folders and files identified by content
+func TestStoreScan_RecursiveWithSniffing(t *testing.T) {
+	storeDir := t.TempDir()
+	service, cfgService := NewTestServiceWithStore(t, storeDir)
+
+	nested := filepath.Join(storeDir, "2024", "q3")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatalf("Failed to create nested store: %v", err)
+	}
+
+	now := time.Now()
+	pemCert, _ := CreateTestCertificate(t, "Nested PEM", now.Add(-time.Hour), now.Add(24*time.Hour))
+	WriteTestCertificatePEM(t, nested, "signing.cert-backup", pemCert)
+
+	p12Cert, p12Key := CreateTestCertificate(t, "Odd PKCS12", now.Add(-time.Hour), now.Add(24*time.Hour))
+	p12Data, err := goPkcs12.Modern.Encode(p12Key, p12Cert, nil, "")
+	if err != nil {
+		t.Fatalf("Failed to encode PKCS#12: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(nested, "KEYSTORE_final.bin"), p12Data, 0600); err != nil {
+		t.Fatalf("Failed to write PKCS#12: %v", err)
+	}
+
+	if containsFingerprint(t, service, CertificateFingerprint(pemCert)) {
+		t.Error("Nested certificate listed without recursive scanning")
+	}
+
+	setStoreOptions(t, cfgService, storeDir, config.StoreScanOptions{Recursive: true})
+
+	if !containsFingerprint(t, service, CertificateFingerprint(pemCert)) {
+		t.Error("Nested PEM certificate with unknown extension not found")
+	}
+	if !containsFingerprint(t, service, CertificateFingerprint(p12Cert)) {
+		t.Error("Nested PKCS#12 file with unknown extension not found")
+	}
+
+	setStoreOptions(t, cfgService, storeDir, config.StoreScanOptions{Recursive: true, MaxDepth: 1})
+
+	if containsFingerprint(t, service, CertificateFingerprint(pemCert)) {
+		t.Error("Certificate below the depth limit was listed")
+	}
+}
+
+// TestStoreScan_Patterns tests per-store include and exclude globs
+func TestStoreScan_Patterns(t *testing.T) {
+	storeDir := t.TempDir()
+	service, cfgService := NewTestServiceWithStore(t, storeDir)
+
+	archive := filepath.Join(storeDir, "archive")
+	if err := os.MkdirAll(archive, 0755); err != nil {
+		t.Fatalf("Failed to create archive dir: %v", err)
+	}
+
+	now := time.Now()
+	included, _ := CreateTestCertificate(t, "Included", now.Add(-time.Hour), now.Add(24*time.Hour))
+	WriteTestCertificatePEM(t, storeDir, "included.pem", included)
+	other, _ := CreateTestCertificate(t, "Other Extension", now.Add(-time.Hour), now.Add(24*time.Hour))
+	WriteTestCertificatePEM(t, storeDir, "other.crt", other)
+	archived, _ := CreateTestCertificate(t, "Archived", now.Add(-time.Hour), now.Add(24*time.Hour))
+	WriteTestCertificatePEM(t, archive, "archived.pem", archived)
+
+	setStoreOptions(t, cfgService, storeDir, config.StoreScanOptions{
+		Recursive: true,
+		Include:   []string{"*.pem"},
+		Exclude:   []string{"archive"},
+	})
+
+	if !containsFingerprint(t, service, CertificateFingerprint(included)) {
+		t.Error("Certificate matching include pattern not found")
+	}
+	if containsFingerprint(t, service, CertificateFingerprint(other)) {
+		t.Error("Certificate not matching include pattern was listed")
+	}
+	if containsFingerprint(t, service, CertificateFingerprint(archived)) {
+		t.Error("Certificate in excluded directory was listed")
+	}
+
+	setStoreOptions(t, cfgService, storeDir, config.StoreScanOptions{Include: []string{"[invalid"}})
+
+	result, err := service.ListCertificatesWithStatus()
+	if err != nil {
+		t.Fatalf("ListCertificatesWithStatus failed: %v", err)
+	}
+	if status := findSourceStatus(t, result.Sources, storeDir); status.Status != SourceStatusError {
+		t.Errorf("Expected invalid pattern to report status %s, got %s", SourceStatusError, status.Status)
+	}
+}
+
+// TestStoreScan_SymlinkLoop tests that symlink cycles do not cause endless scanning
+func TestStoreScan_SymlinkLoop(t *testing.T) {
+	storeDir := t.TempDir()
+	service, cfgService := NewTestServiceWithStore(t, storeDir)
+
+	sub := filepath.Join(storeDir, "sub")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatalf("Failed to create subdirectory: %v", err)
+	}
+	if err := os.Symlink(storeDir, filepath.Join(sub, "loop")); err != nil {
+		t.Skipf("Symlinks not supported: %v", err)
+	}
+
+	now := time.Now()
+	cert, _ := CreateTestCertificate(t, "Looped", now.Add(-time.Hour), now.Add(24*time.Hour))
+	WriteTestCertificatePEM(t, sub, "looped.pem", cert)
+
+	setStoreOptions(t, cfgService, storeDir, config.StoreScanOptions{Recursive: true, MaxDepth: 100})
+
+	certs, err := service.ListCertificates()
+	if err != nil {
+		t.Fatalf("ListCertificates failed: %v", err)
+	}
+
+	count := 0
+	for _, c := range certs {
+		if c.FilePath != "" && filepath.Base(c.FilePath) == "looped.pem" {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Errorf("Expected certificate to be loaded once, got %d", count)
+	}
+}
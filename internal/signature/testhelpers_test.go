@@ -0,0 +1,311 @@
+package signature
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Matbe34/lankir/internal/config"
+	"github.com/digitorus/timestamp"
+	goPkcs12 "software.sslmate.com/src/go-pkcs12"
+)
+
+// CreateTestCertificate creates a self-signed signing certificate valid between notBefore and notAfter
+func CreateTestCertificate(t *testing.T, commonName string, notBefore, notAfter time.Time) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatalf("Failed to generate serial: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageContentCommitment,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+
+	return cert, key
+}
+
+// CreateTestCertificateFromTemplate creates a self-signed certificate from template with the given key
+func CreateTestCertificateFromTemplate(t *testing.T, template *x509.Certificate, key crypto.Signer) *x509.Certificate {
+	t.Helper()
+
+	if template.SerialNumber == nil {
+		serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+		if err != nil {
+			t.Fatalf("Failed to generate serial: %v", err)
+		}
+		template.SerialNumber = serial
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+
+	return cert
+}
+
+// WriteTestCertificatePEM writes a certificate as PEM into dir and returns the file path
+func WriteTestCertificatePEM(t *testing.T, dir, name string, cert *x509.Certificate) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+	return path
+}
+
+// WriteTestPKCS12 writes a certificate and its key as an unencrypted PKCS#12 file into dir
+func WriteTestPKCS12(t *testing.T, dir, name string, cert *x509.Certificate, key crypto.PrivateKey) string {
+	t.Helper()
+
+	data, err := goPkcs12.Modern.Encode(key, cert, nil, "")
+	if err != nil {
+		t.Fatalf("Failed to encode PKCS#12: %v", err)
+	}
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("Failed to write PKCS#12: %v", err)
+	}
+	return path
+}
+
+// CreateTestPDF writes a minimal one-page PDF with a valid cross-reference table
+func CreateTestPDF(t *testing.T, path string) {
+	t.Helper()
+
+	WriteTestPDF(t, path, []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R /Resources << /Font << /F1 << /Type /Font /Subtype /Type1 /BaseFont /Helvetica >> >> >> >>",
+		"<< /Length 44 >>\nstream\nBT /F1 24 Tf 100 700 Td (Test PDF) Tj ET\nendstream",
+	})
+}
+
+// WriteTestPDF writes a PDF of the given objects, numbered from 1 with the catalog first
+func WriteTestPDF(t *testing.T, path string, objects []string) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.7\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write test PDF: %v", err)
+	}
+}
+
+// CertificateFingerprint returns the SHA-256 fingerprint used to identify certificates
+func CertificateFingerprint(cert *x509.Certificate) string {
+	hash := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(hash[:])
+}
+
+// NewTestServiceWithStore creates a signature service whose only configured source is storeDir
+func NewTestServiceWithStore(t *testing.T, storeDir string) (*SignatureService, *config.Service) {
+	t.Helper()
+
+	cfgService, err := config.NewServiceWithDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create config service: %v", err)
+	}
+
+	cfg := cfgService.Get()
+	cfg.CertificateStores = []string{storeDir}
+	cfg.TokenLibraries = []string{}
+	if err := cfgService.Update(cfg); err != nil {
+		t.Fatalf("Failed to update config: %v", err)
+	}
+
+	service := NewSignatureService(cfgService)
+	service.profileManager = NewProfileManagerWithDir(filepath.Join(t.TempDir(), "profiles"))
+	return service, cfgService
+}
+
+// SignTestPDF signs a fresh test PDF with a new certificate stored in storeDir and returns the
+// signed file and the signing certificate
+func SignTestPDF(t *testing.T, service *SignatureService, storeDir string) (string, *x509.Certificate) {
+	t.Helper()
+
+	now := time.Now()
+	cert, key := CreateTestCertificate(t, "Report Signer", now.Add(-time.Hour), now.Add(24*time.Hour))
+	WriteTestPKCS12(t, storeDir, "signer.p12", cert, key)
+
+	pdfPath := filepath.Join(t.TempDir(), "doc.pdf")
+	CreateTestPDF(t, pdfPath)
+
+	signedPath, err := service.SignPDF(pdfPath, CertificateFingerprint(cert), "")
+	if err != nil {
+		t.Fatalf("SignPDF failed: %v", err)
+	}
+	return signedPath, cert
+}
+
+// AppendIncrementalUpdate appends the given objects to a PDF as a new revision with an xref table
+func AppendIncrementalUpdate(t *testing.T, path string, objects map[int]string) {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read PDF: %v", err)
+	}
+
+	last := func(pattern string) []string {
+		matches := regexp.MustCompile(pattern).FindAllStringSubmatch(string(data), -1)
+		if len(matches) == 0 {
+			t.Fatalf("PDF has no %s", pattern)
+		}
+		return matches[len(matches)-1]
+	}
+	root := last(`/Root (\d+ \d+) R`)[1]
+	size, _ := strconv.Atoi(last(`/Size (\d+)`)[1])
+	prev := last(`startxref\s+(\d+)`)[1]
+
+	var ids []int
+	for id := range objects {
+		ids = append(ids, id)
+		size = max(size, id+1)
+	}
+	slices.Sort(ids)
+
+	buf := bytes.NewBuffer(data)
+	buf.WriteString("\n")
+	offsets := map[int]int{}
+	for _, id := range ids {
+		offsets[id] = buf.Len()
+		fmt.Fprintf(buf, "%d 0 obj\n%s\nendobj\n", id, objects[id])
+	}
+
+	xref := buf.Len()
+	buf.WriteString("xref\n")
+	for _, id := range ids {
+		fmt.Fprintf(buf, "%d 1\n%010d 00000 n \n", id, offsets[id])
+	}
+	fmt.Fprintf(buf, "trailer\n<< /Size %d /Root %s R /Prev %s >>\nstartxref\n%d\n%%%%EOF\n", size, root, prev, xref)
+
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write PDF: %v", err)
+	}
+}
+
+// NewTestTSA starts an RFC 3161 time-stamping server with a new self-signed TSA certificate and
+// returns its URL and certificate
+func NewTestTSA(t *testing.T) (string, *x509.Certificate) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	now := time.Now()
+	cert := CreateTestCertificateFromTemplate(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "Test TSA"},
+		NotBefore:   now.Add(-time.Hour),
+		NotAfter:    now.Add(24 * time.Hour),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+	}, key)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req, err := timestamp.ParseRequest(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ts := timestamp.Timestamp{
+			HashAlgorithm:     req.HashAlgorithm,
+			HashedMessage:     req.HashedMessage,
+			Time:              time.Now().UTC().Truncate(time.Second),
+			Policy:            []int{1, 2, 3, 4},
+			Nonce:             req.Nonce,
+			AddTSACertificate: req.Certificates,
+		}
+		resp, err := ts.CreateResponseWithOpts(cert, key, crypto.SHA256)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/timestamp-reply")
+		w.Write(resp)
+	}))
+	t.Cleanup(server.Close)
+
+	return server.URL, cert
+}
+
+// CopyTestFile copies src to dst
+func CopyTestFile(t *testing.T, src, dst string) {
+	t.Helper()
+
+	data, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", src, err)
+	}
+	if err := os.WriteFile(dst, data, 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", dst, err)
+	}
+}
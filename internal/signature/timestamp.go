@@ -0,0 +1,168 @@
+package signature
+
+import (
+	"bytes"
+	"crypto"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Matbe34/lankir/internal/signature/types"
+	"github.com/digitorus/pdfsign/sign"
+	"github.com/digitorus/pdfsign/verify"
+	"github.com/digitorus/timestamp"
+)
+
+// SubFilterDocTimeStamp is the SubFilter of document timestamp signatures (ETSI EN 319 142-1)
+const SubFilterDocTimeStamp = "ETSI.RFC3161"
+
+// TimestampOptions selects the output and the TSA of TimestampPDF.
+type TimestampOptions struct {
+	// OutputPath is the timestamped document; empty writes <name>_timestamped.pdf next to the input
+	OutputPath string `json:"outputPath"`
+	// URL overrides the configured time-stamping authority
+	URL string `json:"url"`
+}
+
+// TimestampPDF adds a document timestamp (SubFilter ETSI.RFC3161) from the configured TSA to a
+// PDF. The timestamp proves the document existed in its current form at the time it was issued,
+// without a personal signature. It is added as an incremental update, so existing signatures
+// stay valid.
+func (s *SignatureService) TimestampPDF(pdfPath string, opts TimestampOptions) (*types.DocumentTimestampResult, error) {
+	if _, err := os.Stat(pdfPath); err != nil {
+		return nil, fmt.Errorf("failed to open PDF: %w", err)
+	}
+
+	tsa := s.timestampAuthority(opts.URL)
+	if tsa.URL == "" {
+		return nil, fmt.Errorf("no timestamp authority configured (set timestampUrl in the configuration)")
+	}
+
+	outputPath := opts.OutputPath
+	if outputPath == "" {
+		outputPath = generateTimestampedPDFPath(pdfPath)
+	}
+
+	if err := writeDocumentTimestamp(pdfPath, outputPath, tsa); err != nil {
+		return nil, err
+	}
+
+	result := &types.DocumentTimestampResult{OutputPath: outputPath}
+
+	// Report the token the TSA issued, which is the last signature of the new revision
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read timestamped PDF: %w", err)
+	}
+	dictionaries, err := readSignatureDictionaries(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	for i := len(dictionaries) - 1; i >= 0; i-- {
+		if dictionaries[i].subFilter != SubFilterDocTimeStamp {
+			continue
+		}
+		if signer, _ := verifyDocumentTimestamp(&dictionaries[i], data); signer.TimeStamp != nil {
+			result.Timestamp = timestampInfo(signer)
+		}
+		break
+	}
+
+	return result, nil
+}
+
+// timestampAuthority returns the TSA to request timestamps from: url if given, otherwise the
+// configured one. The configured credentials are used when the URLs match.
+func (s *SignatureService) timestampAuthority(url string) sign.TSA {
+	var configured sign.TSA
+	if s.configService != nil {
+		cfg := s.configService.Get().TimestampAuthority
+		configured = sign.TSA{URL: cfg.URL, Username: cfg.Username, Password: cfg.Password}
+	}
+
+	if url == "" || url == configured.URL {
+		return configured
+	}
+	return sign.TSA{URL: url}
+}
+
+// generateTimestampedPDFPath returns the default output path of TimestampPDF.
+func generateTimestampedPDFPath(pdfPath string) string {
+	ext := filepath.Ext(pdfPath)
+	return strings.TrimSuffix(pdfPath, ext) + "_timestamped" + ext
+}
+
+// writeDocumentTimestamp writes inputPath with an RFC 3161 document timestamp covering the whole
+// document to outputPath, which may be the input itself.
+func writeDocumentTimestamp(inputPath, outputPath string, tsa sign.TSA) error {
+	tmpPath := outputPath + ".tmp"
+	err := sign.SignFile(inputPath, tmpPath, sign.SignData{
+		Signature: sign.SignDataSignature{
+			Info:     sign.SignDataSignatureInfo{Date: time.Now().Local()},
+			CertType: sign.TimeStampSignature,
+		},
+		DigestAlgorithm: crypto.SHA256,
+		TSA:             tsa,
+	})
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to add document timestamp: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, outputPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write timestamped PDF: %w", err)
+	}
+	return nil
+}
+
+// verifyDocumentTimestamp checks a document timestamp, which the PDF verifier reports as a broken
+// signature since its contents are a timestamp token rather than a signature over the document.
+// The token must carry the TSA certificate, which takes the place of the signer certificate, and
+// its message imprint must be the digest of the byte range. The returned message explains why
+// the timestamp is invalid.
+func verifyDocumentTimestamp(dict *signatureDictionary, data []byte) (verify.Signer, string) {
+	signer := verify.Signer{TimeSource: "current_time", TimestampStatus: "invalid"}
+
+	// Parse checks the token signature when it includes the TSA certificate
+	ts, err := timestamp.Parse(dict.contents)
+	if err != nil {
+		return signer, fmt.Sprintf("failed to parse timestamp token: %v", err)
+	}
+	signer.TimeStamp = ts
+	signer.SignatureTime = &ts.Time
+	for _, cert := range ts.Certificates {
+		signer.Certificates = append(signer.Certificates, verify.Certificate{Certificate: cert})
+	}
+	if tsaCert := dict.p7.GetOnlySigner(); tsaCert != nil {
+		signer.Name = tsaCert.Subject.CommonName
+	}
+
+	if len(ts.Certificates) == 0 {
+		return signer, "timestamp token does not include the TSA certificate"
+	}
+	if !ts.HashAlgorithm.Available() {
+		return signer, fmt.Sprintf("unsupported timestamp digest algorithm %v", ts.HashAlgorithm)
+	}
+
+	h := ts.HashAlgorithm.New()
+	if len(dict.byteRange) != 4 {
+		return signer, "invalid byte range"
+	}
+	for i := 0; i < len(dict.byteRange); i += 2 {
+		start, length := dict.byteRange[i], dict.byteRange[i+1]
+		if start < 0 || length < 0 || start+length > int64(len(data)) {
+			return signer, "byte range extends beyond the end of the file"
+		}
+		h.Write(data[start : start+length])
+	}
+	if !bytes.Equal(h.Sum(nil), ts.HashedMessage) {
+		return signer, "timestamp does not match the document"
+	}
+
+	signer.ValidSignature = true
+	signer.TimestampStatus = "valid"
+	return signer, ""
+}
@@ -0,0 +1,120 @@
+package signature
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Matbe34/lankir/internal/config"
+	"github.com/Matbe34/lankir/internal/signature/revocation"
+)
+
+// TestTimestampPDF tests that a document timestamp from the configured TSA is added and verified
+func TestTimestampPDF(t *testing.T) {
+	service, cfgService := NewTestServiceWithStore(t, t.TempDir())
+	service.revocationChecker = revocation.NewChecker(t.TempDir(), nil)
+
+	pdfPath := filepath.Join(t.TempDir(), "doc.pdf")
+	CreateTestPDF(t, pdfPath)
+
+	if _, err := service.TimestampPDF(pdfPath, TimestampOptions{}); err == nil || !strings.Contains(err.Error(), "no timestamp authority") {
+		t.Fatalf("Expected an error without a configured TSA, got %v", err)
+	}
+
+	tsaURL, tsaCert := NewTestTSA(t)
+	cfg := cfgService.Get()
+	cfg.TimestampAuthority = config.TimestampAuthority{URL: tsaURL}
+	if err := cfgService.Update(cfg); err != nil {
+		t.Fatalf("Failed to update config: %v", err)
+	}
+
+	result, err := service.TimestampPDF(pdfPath, TimestampOptions{})
+	if err != nil {
+		t.Fatalf("TimestampPDF failed: %v", err)
+	}
+	if result.OutputPath != filepath.Join(filepath.Dir(pdfPath), "doc_timestamped.pdf") {
+		t.Errorf("Unexpected output path %s", result.OutputPath)
+	}
+	if result.Timestamp == nil || !strings.Contains(result.Timestamp.Authority, "Test TSA") {
+		t.Fatalf("Expected the issued timestamp to be reported, got %+v", result.Timestamp)
+	}
+
+	signatures, err := service.VerifySignatures(result.OutputPath)
+	if err != nil {
+		t.Fatalf("VerifySignatures failed: %v", err)
+	}
+	if len(signatures) != 1 {
+		t.Fatalf("Expected 1 signature, got %d", len(signatures))
+	}
+	sig := signatures[0]
+	if !sig.DocumentTimestamp || !sig.IsValid || sig.SignerName != "Test TSA" {
+		t.Errorf("Expected a valid document timestamp by the TSA, got %+v", sig)
+	}
+	if sig.CertificateValid {
+		t.Error("Expected the self-signed TSA to be untrusted")
+	}
+
+	// A timestamping anchor makes the TSA trusted
+	bundle := WriteTestCertificatePEM(t, t.TempDir(), "tsa.pem", tsaCert)
+	if err := service.AddTrustAnchor(bundle, config.TrustPurposeTimestamping); err != nil {
+		t.Fatalf("AddTrustAnchor failed: %v", err)
+	}
+	report, err := service.GetVerificationReport(result.OutputPath)
+	if err != nil {
+		t.Fatalf("GetVerificationReport failed: %v", err)
+	}
+	tsReport := report.Signatures[0]
+	if tsReport.SubFilter != SubFilterDocTimeStamp || !tsReport.Summary.CertificateValid {
+		t.Errorf("Expected a trusted document timestamp, got %+v", tsReport.Summary)
+	}
+	if tsReport.Timestamp == nil || !tsReport.Timestamp.Trusted {
+		t.Errorf("Expected trusted timestamp details, got %+v", tsReport.Timestamp)
+	}
+
+	// Changing the timestamped bytes breaks the message imprint
+	data, err := os.ReadFile(result.OutputPath)
+	if err != nil {
+		t.Fatalf("Failed to read PDF: %v", err)
+	}
+	tampered := filepath.Join(t.TempDir(), "tampered.pdf")
+	if err := os.WriteFile(tampered, []byte(strings.Replace(string(data), "%PDF-1.7", "%PDF-1.6", 1)), 0644); err != nil {
+		t.Fatalf("Failed to write PDF: %v", err)
+	}
+	signatures, err = service.VerifySignatures(tampered)
+	if err != nil {
+		t.Fatalf("VerifySignatures failed: %v", err)
+	}
+	if len(signatures) != 1 || signatures[0].IsValid || !strings.Contains(signatures[0].ValidationMessage, "does not match") {
+		t.Errorf("Expected the tampered timestamp to be invalid, got %+v", signatures)
+	}
+}
+
+// TestTimestampPDF_SignedDocument tests that a document timestamp keeps earlier signatures valid
+func TestTimestampPDF_SignedDocument(t *testing.T) {
+	storeDir := t.TempDir()
+	service, _ := NewTestServiceWithStore(t, storeDir)
+	service.revocationChecker = revocation.NewChecker(t.TempDir(), nil)
+
+	signedPath, _ := SignTestPDF(t, service, storeDir)
+	tsaURL, _ := NewTestTSA(t)
+
+	outputPath := filepath.Join(t.TempDir(), "archived.pdf")
+	if _, err := service.TimestampPDF(signedPath, TimestampOptions{OutputPath: outputPath, URL: tsaURL}); err != nil {
+		t.Fatalf("TimestampPDF failed: %v", err)
+	}
+
+	signatures, err := service.VerifySignatures(outputPath)
+	if err != nil {
+		t.Fatalf("VerifySignatures failed: %v", err)
+	}
+	if len(signatures) != 2 {
+		t.Fatalf("Expected 2 signatures, got %d", len(signatures))
+	}
+	if signatures[0].DocumentTimestamp || !signatures[0].IsValid || signatures[0].ModificationStatus == "disallowed" {
+		t.Errorf("Expected the signature to stay valid, got %+v", signatures[0])
+	}
+	if !signatures[1].DocumentTimestamp || !signatures[1].IsValid {
+		t.Errorf("Expected a valid document timestamp, got %+v", signatures[1])
+	}
+}
@@ -0,0 +1,308 @@
+package signature
+
+import (
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/Matbe34/lankir/internal/config"
+	"github.com/Matbe34/lankir/internal/signature/certutil"
+	"github.com/Matbe34/lankir/internal/signature/types"
+	"github.com/digitorus/pdfsign/verify"
+	"github.com/digitorus/pkcs7"
+)
+
+// TrustAnchorSystem is the source reported for chains ending at an operating system root.
+const TrustAnchorSystem = "system"
+
+// trustAnchorExtensions are the file extensions loaded from trust anchor directories
+var trustAnchorExtensions = []string{".pem", ".crt", ".cer", ".der"}
+
+// TrustAnchorInfo describes a configured trust anchor and the certificates it provides.
+type TrustAnchorInfo struct {
+	Path         string                   `json:"path"`
+	Purpose      string                   `json:"purpose"`
+	Certificates []types.ChainCertificate `json:"certificates"`
+	Error        string                   `json:"error,omitempty"`
+}
+
+// anchorCertificate is a trusted root together with the configured anchor it was loaded from.
+type anchorCertificate struct {
+	cert   *x509.Certificate
+	source string
+}
+
+// ListTrustAnchors returns the configured trust anchors with the certificates each provides.
+func (s *SignatureService) ListTrustAnchors() ([]TrustAnchorInfo, error) {
+	anchors := []TrustAnchorInfo{}
+	for _, anchor := range s.configService.Get().TrustAnchors {
+		info := TrustAnchorInfo{
+			Path:         anchor.Path,
+			Purpose:      anchor.Purpose,
+			Certificates: []types.ChainCertificate{},
+		}
+
+		certs, err := loadTrustAnchor(anchor.Path)
+		if err != nil {
+			info.Error = err.Error()
+		}
+		for _, cert := range certs {
+			info.Certificates = append(info.Certificates, certutil.NewChainCertificate(cert))
+		}
+
+		anchors = append(anchors, info)
+	}
+	return anchors, nil
+}
+
+// AddTrustAnchor trusts the certificates in a PEM/DER bundle or a directory of certificates for
+// the given purpose ("all", "signing" or "timestamping"; empty means "all").
+func (s *SignatureService) AddTrustAnchor(path, purpose string) error {
+	purpose = strings.ToLower(strings.TrimSpace(purpose))
+	if purpose == "" {
+		purpose = config.TrustPurposeAll
+	}
+	if purpose != config.TrustPurposeAll && purpose != config.TrustPurposeSigning && purpose != config.TrustPurposeTimestamping {
+		return fmt.Errorf("invalid trust anchor purpose '%s' (use all, signing or timestamping)", purpose)
+	}
+
+	if path == "" {
+		return fmt.Errorf("path cannot be empty")
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("failed to resolve path: %w", err)
+	}
+
+	if _, err := loadTrustAnchor(absPath); err != nil {
+		return fmt.Errorf("invalid trust anchor: %w", err)
+	}
+
+	cfg := s.configService.Get()
+	for _, anchor := range cfg.TrustAnchors {
+		if anchor.Path == absPath {
+			return fmt.Errorf("trust anchor already exists")
+		}
+	}
+
+	cfg.TrustAnchors = append(cfg.TrustAnchors, config.TrustAnchor{Path: absPath, Purpose: purpose})
+	return s.configService.Update(cfg)
+}
+
+// RemoveTrustAnchor removes a trust anchor from the config.
+func (s *SignatureService) RemoveTrustAnchor(path string) error {
+	cfg := s.configService.Get()
+
+	if absPath, err := filepath.Abs(path); err == nil {
+		path = absPath
+	}
+
+	originalLen := len(cfg.TrustAnchors)
+	cfg.TrustAnchors = slices.DeleteFunc(cfg.TrustAnchors, func(anchor config.TrustAnchor) bool {
+		return anchor.Path == path
+	})
+
+	if len(cfg.TrustAnchors) == originalLen {
+		return fmt.Errorf("trust anchor %s not found", path)
+	}
+
+	return s.configService.Update(cfg)
+}
+
+// trustAnchors loads the certificates of the configured anchors that apply to purpose.
+// Anchors that cannot be read are skipped.
+func (s *SignatureService) trustAnchors(purpose string) []anchorCertificate {
+	if s.configService == nil {
+		return nil
+	}
+
+	var anchors []anchorCertificate
+	for _, anchor := range s.configService.Get().TrustAnchors {
+		if !anchor.AppliesTo(purpose) {
+			continue
+		}
+		certs, err := loadTrustAnchor(anchor.Path)
+		if err != nil {
+			slog.Warn("failed to load trust anchor", "path", anchor.Path, "error", err)
+		}
+		for _, cert := range certs {
+			anchors = append(anchors, anchorCertificate{cert: cert, source: anchor.Path})
+		}
+	}
+	return anchors
+}
+
+// trustRoots returns the system roots together with the given anchors.
+func trustRoots(anchors []anchorCertificate) *x509.CertPool {
+	roots, err := x509.SystemCertPool()
+	if err != nil {
+		roots = x509.NewCertPool()
+	}
+	for _, anchor := range anchors {
+		roots.AddCert(anchor.cert)
+	}
+	return roots
+}
+
+// trustAnchorMatch names the anchor a verified chain ended at.
+func trustAnchorMatch(root *x509.Certificate, anchors []anchorCertificate) *types.TrustAnchorMatch {
+	_, sha256Hex := certutil.Fingerprints(root)
+	match := &types.TrustAnchorMatch{
+		SubjectDN:         root.Subject.String(),
+		FingerprintSHA256: sha256Hex,
+		Source:            TrustAnchorSystem,
+	}
+	for _, anchor := range anchors {
+		if anchor.cert.Equal(root) {
+			match.Source = anchor.source
+			break
+		}
+	}
+	return match
+}
+
+// applyTrustAnchors verifies the embedded certificates of a signature against the system roots
+// and the anchors for purpose, clearing the chain errors an anchor resolves. The signer
+// certificate must come first and alone decides whether the signature is trusted, with the other
+// certificates only as intermediates; the anchor its chain ends at is returned, or nil if it is
+// not trusted. When strict, chain errors are recorded for certificates the verifier did not flag, as
+// happens when checking at a validation time other than its own. Timestamping chains must allow
+// time stamping.
+func (s *SignatureService) applyTrustAnchors(signer *verify.Signer, purpose string, strict bool) *types.TrustAnchorMatch {
+	anchors := s.trustAnchors(purpose)
+
+	opts := x509.VerifyOptions{
+		Roots:         trustRoots(anchors),
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+	if purpose == config.TrustPurposeTimestamping {
+		opts.KeyUsages = []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping}
+	}
+	for _, c := range signer.Certificates {
+		if c.Certificate != nil {
+			opts.Intermediates.AddCert(c.Certificate)
+		}
+	}
+	if signer.VerificationTime != nil {
+		opts.CurrentTime = *signer.VerificationTime
+	}
+
+	var match *types.TrustAnchorMatch
+	signer.TrustedIssuer = false
+	for i := range signer.Certificates {
+		c := &signer.Certificates[i]
+		if c.Certificate == nil {
+			continue
+		}
+
+		chains, err := c.Certificate.Verify(opts)
+		if err != nil {
+			if strict && c.VerifyError == "" {
+				c.VerifyError = err.Error()
+			}
+			continue
+		}
+
+		// Extended key usage problems are reported separately by the verifier
+		c.VerifyError = ""
+		if i == 0 {
+			chain := chains[0]
+			match = trustAnchorMatch(chain[len(chain)-1], anchors)
+			signer.TrustedIssuer = true
+		}
+	}
+
+	if signer.TimeStamp != nil && !signer.TimestampTrusted {
+		s.applyTimestampTrustAnchors(signer)
+	}
+
+	return match
+}
+
+// applyTimestampTrustAnchors trusts a timestamp whose authority chains to a timestamping anchor.
+// Only the certificate that signed the timestamp token is verified.
+func (s *SignatureService) applyTimestampTrustAnchors(signer *verify.Signer) {
+	anchors := s.trustAnchors(config.TrustPurposeTimestamping)
+	if len(anchors) == 0 {
+		return
+	}
+
+	ts := signer.TimeStamp
+	token, err := pkcs7.Parse(ts.RawToken)
+	if err != nil {
+		return
+	}
+	tsaCert := token.GetOnlySigner()
+	if tsaCert == nil {
+		return
+	}
+	opts := x509.VerifyOptions{
+		Roots:         trustRoots(anchors),
+		Intermediates: x509.NewCertPool(),
+		CurrentTime:   ts.Time,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+	}
+	for _, cert := range ts.Certificates {
+		opts.Intermediates.AddCert(cert)
+	}
+
+	if _, err := tsaCert.Verify(opts); err != nil {
+		return
+	}
+
+	signer.TimestampTrusted = true
+	signer.TimeWarnings = slices.DeleteFunc(signer.TimeWarnings, func(w string) bool {
+		return strings.HasPrefix(w, "Timestamp certificate chain validation failed")
+	})
+}
+
+// loadTrustAnchor reads the certificates of a PEM/DER bundle, or of the certificate files
+// directly inside a directory.
+func loadTrustAnchor(path string) ([]*x509.Certificate, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return certutil.ParseCertificates(data)
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var certs []*x509.Certificate
+	for _, entry := range entries {
+		if entry.IsDir() || !slices.Contains(trustAnchorExtensions, strings.ToLower(filepath.Ext(entry.Name()))) {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(path, entry.Name()))
+		if err != nil {
+			slog.Debug("failed to read trust anchor file", "file", entry.Name(), "error", err)
+			continue
+		}
+		parsed, err := certutil.ParseCertificates(data)
+		if err != nil {
+			slog.Debug("failed to parse trust anchor file", "file", entry.Name(), "error", err)
+			continue
+		}
+		certs = append(certs, parsed...)
+	}
+
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+	return certs, nil
+}
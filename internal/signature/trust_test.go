@@ -0,0 +1,175 @@
+package signature
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Matbe34/lankir/internal/config"
+	"github.com/Matbe34/lankir/internal/signature/revocation"
+	"github.com/digitorus/pdfsign/verify"
+)
+
+// TestAddTrustAnchor tests adding, listing and removing trust anchors
+func TestAddTrustAnchor(t *testing.T) {
+	service, cfgService := NewTestServiceWithStore(t, t.TempDir())
+
+	anchorDir := t.TempDir()
+	now := time.Now()
+	root, _ := CreateTestCertificate(t, "Internal Root CA", now.Add(-time.Hour), now.Add(24*time.Hour))
+	bundle := WriteTestCertificatePEM(t, anchorDir, "internal.pem", root)
+
+	if err := service.AddTrustAnchor(bundle, "Signing"); err != nil {
+		t.Fatalf("AddTrustAnchor failed: %v", err)
+	}
+	if err := service.AddTrustAnchor(bundle, ""); err == nil {
+		t.Error("Expected an error when adding the same anchor twice")
+	}
+	if err := service.AddTrustAnchor(anchorDir, "everything"); err == nil {
+		t.Error("Expected an error for an invalid purpose")
+	}
+	if err := service.AddTrustAnchor(filepath.Join(anchorDir, "missing.pem"), ""); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+
+	emptyDir := t.TempDir()
+	os.WriteFile(filepath.Join(emptyDir, "notes.txt"), []byte("not a certificate"), 0644)
+	if err := service.AddTrustAnchor(emptyDir, ""); err == nil {
+		t.Error("Expected an error for a directory without certificates")
+	}
+
+	cfg := cfgService.Get()
+	if len(cfg.TrustAnchors) != 1 || cfg.TrustAnchors[0].Purpose != config.TrustPurposeSigning {
+		t.Fatalf("Expected one signing anchor in config, got %+v", cfg.TrustAnchors)
+	}
+
+	anchors, err := service.ListTrustAnchors()
+	if err != nil {
+		t.Fatalf("ListTrustAnchors failed: %v", err)
+	}
+	if len(anchors) != 1 || len(anchors[0].Certificates) != 1 {
+		t.Fatalf("Expected one anchor with one certificate, got %+v", anchors)
+	}
+	if anchors[0].Certificates[0].SubjectDN != root.Subject.String() {
+		t.Errorf("Expected anchor certificate %s, got %s", root.Subject, anchors[0].Certificates[0].SubjectDN)
+	}
+
+	if err := service.RemoveTrustAnchor(bundle); err != nil {
+		t.Fatalf("RemoveTrustAnchor failed: %v", err)
+	}
+	if err := service.RemoveTrustAnchor(bundle); err == nil {
+		t.Error("Expected an error when removing a missing anchor")
+	}
+	if len(cfgService.Get().TrustAnchors) != 0 {
+		t.Error("Expected no trust anchors after removal")
+	}
+}
+
+// TestLoadTrustAnchor_Directory tests that only certificate files directly in a directory are loaded
+func TestLoadTrustAnchor_Directory(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	first, _ := CreateTestCertificate(t, "First Root", now.Add(-time.Hour), now.Add(24*time.Hour))
+	second, _ := CreateTestCertificate(t, "Second Root", now.Add(-time.Hour), now.Add(24*time.Hour))
+	WriteTestCertificatePEM(t, dir, "first.crt", first)
+	if err := os.WriteFile(filepath.Join(dir, "second.der"), second.Raw, 0644); err != nil {
+		t.Fatalf("Failed to write DER certificate: %v", err)
+	}
+	os.WriteFile(filepath.Join(dir, "readme.txt"), []byte("ignored"), 0644)
+	os.WriteFile(filepath.Join(dir, "broken.pem"), []byte("not a certificate"), 0644)
+
+	certs, err := loadTrustAnchor(dir)
+	if err != nil {
+		t.Fatalf("loadTrustAnchor failed: %v", err)
+	}
+	if len(certs) != 2 {
+		t.Errorf("Expected 2 certificates, got %d", len(certs))
+	}
+}
+
+// TestGetVerificationReport_TrustAnchor tests that a signing anchor makes the chain trusted and is reported
+func TestGetVerificationReport_TrustAnchor(t *testing.T) {
+	storeDir := t.TempDir()
+	service, _ := NewTestServiceWithStore(t, storeDir)
+	service.revocationChecker = revocation.NewChecker(t.TempDir(), nil)
+
+	signedPath, cert := SignTestPDF(t, service, storeDir)
+	bundle := WriteTestCertificatePEM(t, t.TempDir(), "anchor.pem", cert)
+
+	// An anchor for another purpose does not apply to signatures
+	if err := service.AddTrustAnchor(bundle, config.TrustPurposeTimestamping); err != nil {
+		t.Fatalf("AddTrustAnchor failed: %v", err)
+	}
+
+	report, err := service.GetVerificationReport(signedPath)
+	if err != nil {
+		t.Fatalf("GetVerificationReport failed: %v", err)
+	}
+	if report.Signatures[0].TrustAnchor != nil || report.Signatures[0].Summary.CertificateValid {
+		t.Errorf("Expected the timestamping anchor to be ignored, got %+v", report.Signatures[0].TrustAnchor)
+	}
+
+	if err := service.RemoveTrustAnchor(bundle); err != nil {
+		t.Fatalf("RemoveTrustAnchor failed: %v", err)
+	}
+	if err := service.AddTrustAnchor(bundle, config.TrustPurposeSigning); err != nil {
+		t.Fatalf("AddTrustAnchor failed: %v", err)
+	}
+
+	report, err = service.GetVerificationReport(signedPath)
+	if err != nil {
+		t.Fatalf("GetVerificationReport failed: %v", err)
+	}
+
+	sig := report.Signatures[0]
+	if sig.TrustAnchor == nil || sig.TrustAnchor.Source != bundle {
+		t.Fatalf("Expected the chain to end at anchor %s, got %+v", bundle, sig.TrustAnchor)
+	}
+	if sig.TrustAnchor.FingerprintSHA256 != CertificateFingerprint(cert) {
+		t.Errorf("Expected anchor fingerprint %s, got %s", CertificateFingerprint(cert), sig.TrustAnchor.FingerprintSHA256)
+	}
+	if !sig.Summary.CertificateValid || sig.Summary.TrustAnchor == "" {
+		t.Errorf("Expected a trusted certificate naming its anchor, got %+v", sig.Summary)
+	}
+	if sig.Chain[0].Status != CertificateStatusValid {
+		t.Errorf("Expected signer status valid, got %s (%v)", sig.Chain[0].Status, sig.Chain[0].Errors)
+	}
+
+	details, err := service.GetCertificateDetails(CertificateFingerprint(cert))
+	if err != nil {
+		t.Fatalf("GetCertificateDetails failed: %v", err)
+	}
+	if !details.ChainTrusted || details.TrustAnchor == nil || details.TrustAnchor.Source != bundle {
+		t.Errorf("Expected certificate details to be trusted via %s, got %+v", bundle, details.TrustAnchor)
+	}
+}
+
+// TestApplyTrustAnchors_EmbeddedAnchor tests that an anchored certificate embedded next to an
+// untrusted signer certificate does not make the signature trusted
+func TestApplyTrustAnchors_EmbeddedAnchor(t *testing.T) {
+	service, _ := NewTestServiceWithStore(t, t.TempDir())
+
+	now := time.Now()
+	root, _ := CreateTestCertificate(t, "Anchored Root CA", now.Add(-time.Hour), now.Add(24*time.Hour))
+	if err := service.AddTrustAnchor(WriteTestCertificatePEM(t, t.TempDir(), "root.pem", root), config.TrustPurposeSigning); err != nil {
+		t.Fatalf("AddTrustAnchor failed: %v", err)
+	}
+	attacker, _ := CreateTestCertificate(t, "Self-Signed Attacker", now.Add(-time.Hour), now.Add(24*time.Hour))
+
+	signer := verify.Signer{
+		TrustedIssuer: true,
+		Certificates: []verify.Certificate{
+			{Certificate: attacker, VerifyError: "x509: certificate signed by unknown authority"},
+			{Certificate: root},
+		},
+	}
+	match := service.applyTrustAnchors(&signer, config.TrustPurposeSigning, false)
+
+	if signer.TrustedIssuer || match != nil {
+		t.Errorf("Expected an untrusted signer, got trusted=%v anchor=%+v", signer.TrustedIssuer, match)
+	}
+	if signer.Certificates[0].VerifyError == "" {
+		t.Error("Expected the chain error of the signer certificate to be kept")
+	}
+}
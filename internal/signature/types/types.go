@@ -1,6 +1,9 @@
 package types
 
-import "strings"
+import (
+	"strings"
+	"time"
+)
 
 // Certificate represents an X.509 certificate available for PDF signing.
 type Certificate struct {
@@ -21,6 +24,85 @@ type Certificate struct {
 	CanSign      bool     `json:"canSign"`
 	RequiresPin  bool     `json:"requiresPin"`
 	PinOptional  bool     `json:"pinOptional"`
+
+	IssuerDN         string   `json:"issuerDN,omitempty"`
+	ExtendedKeyUsage []string `json:"extendedKeyUsage,omitempty"`
+	KeyAlgorithm     string   `json:"keyAlgorithm,omitempty"` // "RSA", "ECDSA" or "Ed25519"
+	KeySize          int      `json:"keySize,omitempty"`      // bits
+	IsQualified      bool     `json:"isQualified"`            // carries the ETSI QcCompliance statement
+	HasPrivateKey    bool     `json:"hasPrivateKey"`
+
+	// Locations lists every backend holding this certificate. The backend fields above
+	// (Source, FilePath, PKCS11Module, ...) describe the preferred one, which has a
+	// private key whenever any location has.
+	Locations []CertificateLocation `json:"locations,omitempty"`
+
+	// Raw is the DER encoding, used to build CertificateDetails. Empty for password-protected files.
+	Raw []byte `json:"-"`
+}
+
+// CertificateLocation is one backend holding a certificate.
+type CertificateLocation struct {
+	Source        string `json:"source"`
+	FilePath      string `json:"filePath,omitempty"`
+	PKCS11Module  string `json:"pkcs11Module,omitempty"`
+	PKCS11URL     string `json:"pkcs11Url,omitempty"`
+	NSSNickname   string `json:"nssNickname,omitempty"`
+	HasPrivateKey bool   `json:"hasPrivateKey"`
+	RequiresPin   bool   `json:"requiresPin"`
+	PinOptional   bool   `json:"pinOptional"`
+}
+
+// Location returns the backend fields of the certificate as a location.
+func (c *Certificate) Location() CertificateLocation {
+	return CertificateLocation{
+		Source:        c.Source,
+		FilePath:      c.FilePath,
+		PKCS11Module:  c.PKCS11Module,
+		PKCS11URL:     c.PKCS11URL,
+		NSSNickname:   c.NSSNickname,
+		HasPrivateKey: c.HasPrivateKey,
+		RequiresPin:   c.RequiresPin,
+		PinOptional:   c.PinOptional,
+	}
+}
+
+// UseLocation sets the backend fields of the certificate from loc.
+func (c *Certificate) UseLocation(loc CertificateLocation) {
+	c.Source = loc.Source
+	c.FilePath = loc.FilePath
+	c.PKCS11Module = loc.PKCS11Module
+	c.PKCS11URL = loc.PKCS11URL
+	c.NSSNickname = loc.NSSNickname
+	c.HasPrivateKey = loc.HasPrivateKey
+	c.RequiresPin = loc.RequiresPin
+	c.PinOptional = loc.PinOptional
+}
+
+// HasSource returns true if any location of the certificate has the given source.
+func (c *Certificate) HasSource(source string) bool {
+	if strings.EqualFold(c.Source, source) {
+		return true
+	}
+	for _, loc := range c.Locations {
+		if strings.EqualFold(loc.Source, source) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasFilePath returns true if any location of the certificate is the given file.
+func (c *Certificate) HasFilePath(path string) bool {
+	if c.FilePath == path {
+		return true
+	}
+	for _, loc := range c.Locations {
+		if loc.FilePath == path {
+			return true
+		}
+	}
+	return false
 }
 
 // HasKeyUsage returns true if the certificate has the specified key usage.
@@ -33,6 +115,27 @@ func (c *Certificate) HasKeyUsage(usage string) bool {
 	return false
 }
 
+// HasExtendedKeyUsage returns true if the certificate has the specified extended key usage.
+// Names are compared ignoring case, spaces and dashes, so "documentSigning" matches "Document Signing".
+func (c *Certificate) HasExtendedKeyUsage(usage string) bool {
+	want := normalizeUsage(usage)
+	for _, u := range c.ExtendedKeyUsage {
+		if normalizeUsage(u) == want {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeUsage(usage string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' || r == '_' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(usage))
+}
+
 // HasSigningCapability returns true if the certificate can sign documents.
 func (c *Certificate) HasSigningCapability() bool {
 	if c.CanSign {
@@ -44,6 +147,10 @@ func (c *Certificate) HasSigningCapability() bool {
 
 // SignatureInfo contains validation details for a signature embedded in a PDF.
 type SignatureInfo struct {
+	// FieldName is the signature field holding the signature, locating its widget on the page
+	FieldName string `json:"fieldName,omitempty"`
+	// DocumentTimestamp marks an RFC 3161 document timestamp; SignerName is then the TSA
+	DocumentTimestamp            bool   `json:"documentTimestamp,omitempty"`
 	SignerName                   string `json:"signerName"`
 	SignerDN                     string `json:"signerDN"`
 	SigningTime                  string `json:"signingTime"`
@@ -56,4 +163,352 @@ type SignatureInfo struct {
 	Reason                       string `json:"reason"`
 	Location                     string `json:"location"`
 	ContactInfo                  string `json:"contactInfo"`
+	TrustAnchor                  string `json:"trustAnchor,omitempty"`
+	// ModificationStatus summarizes the changes made after signing: "none", "allowed",
+	// "disallowed" or "unknown" when they could not be analysed.
+	ModificationStatus string                 `json:"modificationStatus"`
+	Modifications      []DocumentModification `json:"modifications,omitempty"`
+	PolicyViolations   []PolicyViolation      `json:"policyViolations,omitempty"`
+	// ValidationTime is the time the certificates and their revocation status were checked at
+	ValidationTime string               `json:"validationTime,omitempty"`
+	Evidence       []RevocationEvidence `json:"evidence,omitempty"`
+}
+
+// RevocationEvidence is the revocation status of one certificate in a signer chain and the OCSP
+// response or CRL it was taken from. Source is "embedded" (in the signature), "dss", "file",
+// "network", or "none" when no evidence was available.
+type RevocationEvidence struct {
+	Certificate string     `json:"certificate"`
+	Method      string     `json:"method,omitempty"`
+	Source      string     `json:"source"`
+	Location    string     `json:"location,omitempty"`
+	Status      string     `json:"status"`
+	ThisUpdate  *time.Time `json:"thisUpdate,omitempty"`
+	NextUpdate  *time.Time `json:"nextUpdate,omitempty"`
+	RevokedAt   *time.Time `json:"revokedAt,omitempty"`
+	Message     string     `json:"message,omitempty"`
+}
+
+// PolicyViolation is an algorithm or key size of a signature the algorithm policy does not accept.
+type PolicyViolation struct {
+	Severity string `json:"severity"` // "warning" or "error"
+	Message  string `json:"message"`
+}
+
+// DocumentModification is a change made to the document in a revision after a signature.
+type DocumentModification struct {
+	Kind        string `json:"kind"`
+	Description string `json:"description"`
+	Page        int    `json:"page,omitempty"`
+	Field       string `json:"field,omitempty"`
+	Allowed     bool   `json:"allowed"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+// CertificateSourceStatus reports the outcome of loading one certificate source.
+type CertificateSourceStatus struct {
+	Source     string `json:"source"`
+	Kind       string `json:"kind"`
+	Status     string `json:"status"`
+	Message    string `json:"message,omitempty"`
+	Count      int    `json:"count"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+// CertificateDetails is the full decoded content of a certificate and its resolved chain.
+type CertificateDetails struct {
+	Certificate        Certificate `json:"certificate"`
+	Version            int         `json:"version"`
+	SubjectDN          string      `json:"subjectDN"`
+	IssuerDN           string      `json:"issuerDN"`
+	SerialNumberHex    string      `json:"serialNumberHex"`
+	NotBefore          time.Time   `json:"notBefore"`
+	NotAfter           time.Time   `json:"notAfter"`
+	SignatureAlgorithm string      `json:"signatureAlgorithm"`
+	KeyAlgorithm       string      `json:"keyAlgorithm"`
+	KeySize            int         `json:"keySize"`
+	KeyCurve           string      `json:"keyCurve,omitempty"`
+	FingerprintSHA1    string      `json:"fingerprintSHA1"`
+	FingerprintSHA256  string      `json:"fingerprintSHA256"`
+	SubjectKeyID       string      `json:"subjectKeyId,omitempty"`
+	AuthorityKeyID     string      `json:"authorityKeyId,omitempty"`
+	IsCA               bool        `json:"isCA"`
+	SelfSigned         bool        `json:"selfSigned"`
+
+	KeyUsage              []string           `json:"keyUsage"`
+	ExtendedKeyUsage      []string           `json:"extendedKeyUsage"`
+	SubjectAltNames       SubjectAltNames    `json:"subjectAltNames"`
+	CertificatePolicies   []string           `json:"certificatePolicies"`
+	OCSPServers           []string           `json:"ocspServers"`
+	IssuingCertificateURL []string           `json:"issuingCertificateUrls"`
+	CRLDistributionPoints []string           `json:"crlDistributionPoints"`
+	QCStatements          []QCStatement      `json:"qcStatements"`
+	Extensions            []ExtensionInfo    `json:"extensions"`
+	Chain                 []ChainCertificate `json:"chain"`
+	ChainTrusted          bool               `json:"chainTrusted"`
+	ChainError            string             `json:"chainError,omitempty"`
+	TrustAnchor           *TrustAnchorMatch  `json:"trustAnchor,omitempty"`
+}
+
+// SubjectAltNames lists the subject alternative names of a certificate.
+type SubjectAltNames struct {
+	DNSNames       []string `json:"dnsNames,omitempty"`
+	EmailAddresses []string `json:"emailAddresses,omitempty"`
+	IPAddresses    []string `json:"ipAddresses,omitempty"`
+	URIs           []string `json:"uris,omitempty"`
+}
+
+// QCStatement is one entry of the qcStatements extension (ETSI EN 319 412-5).
+type QCStatement struct {
+	OID   string   `json:"oid"`
+	Name  string   `json:"name"`
+	Types []string `json:"types,omitempty"` // QcType values such as "esign" or "eseal"
+}
+
+// ExtensionInfo describes a certificate extension.
+type ExtensionInfo struct {
+	OID      string `json:"oid"`
+	Name     string `json:"name"`
+	Critical bool   `json:"critical"`
+}
+
+// ChainCertificate is one certificate in a resolved chain, starting with the leaf.
+type ChainCertificate struct {
+	SubjectDN         string    `json:"subjectDN"`
+	IssuerDN          string    `json:"issuerDN"`
+	FingerprintSHA256 string    `json:"fingerprintSHA256"`
+	NotAfter          time.Time `json:"notAfter"`
+	IsCA              bool      `json:"isCA"`
+	SelfSigned        bool      `json:"selfSigned"`
+}
+
+// VerificationReport is the structured result of verifying every signature in a PDF.
+type VerificationReport struct {
+	File       string    `json:"file"`
+	FileSize   int64     `json:"fileSize"`
+	SHA256     string    `json:"sha256"`
+	VerifiedAt time.Time `json:"verifiedAt"`
+	// ValidationMode is "online" when missing revocation data may be fetched, or "offline"
+	ValidationMode string `json:"validationMode"`
+	// ValidationTime is set when the verification was requested as of a given time
+	ValidationTime *time.Time        `json:"validationTime,omitempty"`
+	Signatures     []SignatureReport `json:"signatures"`
+}
+
+// SignatureReport details the verification of one signature. Summary holds the same
+// values VerifySignatures returns for it.
+type SignatureReport struct {
+	Index                int                       `json:"index"`
+	FieldName            string                    `json:"fieldName,omitempty"`
+	SubFilter            string                    `json:"subFilter"`
+	Summary              SignatureInfo             `json:"summary"`
+	ByteRange            ByteRangeCoverage         `json:"byteRange"`
+	DigestAlgorithm      string                    `json:"digestAlgorithm"`
+	SignatureAlgorithm   string                    `json:"signatureAlgorithm"`
+	Chain                []SignerCertificateStatus `json:"chain"`
+	RevocationSources    []string                  `json:"revocationSources"`
+	TrustAnchor          *TrustAnchorMatch         `json:"trustAnchor,omitempty"`
+	TimeSource           string                    `json:"timeSource"`
+	VerificationTime     *time.Time                `json:"verificationTime,omitempty"`
+	Timestamp            *TimestampInfo            `json:"timestamp,omitempty"`
+	DocMDP               *DocMDPInfo               `json:"docMDP,omitempty"`
+	FieldMDP             *FieldMDPInfo             `json:"fieldMDP,omitempty"`
+	ModifiedAfterSigning bool                      `json:"modifiedAfterSigning"`
+	Warnings             []string                  `json:"warnings"`
+}
+
+// ExtractedSignature lists the files written for one signature of a document: the revision as
+// it was signed, the raw CMS signature and the embedded certificates.
+type ExtractedSignature struct {
+	Index            int    `json:"index"`
+	FieldName        string `json:"fieldName,omitempty"`
+	SignerName       string `json:"signerName,omitempty"`
+	RevisionPath     string `json:"revisionPath,omitempty"`
+	RevisionSize     int64  `json:"revisionSize,omitempty"`
+	CMSPath          string `json:"cmsPath"`
+	CertificatesPath string `json:"certificatesPath,omitempty"`
+	CertificateCount int    `json:"certificateCount"`
+	Error            string `json:"error,omitempty"`
+}
+
+// ExtendResult describes the validation data added to a document by ExtendSignatures.
+type ExtendResult struct {
+	OutputPath        string              `json:"outputPath"`
+	Signatures        []ExtendedSignature `json:"signatures"`
+	Certificates      int                 `json:"certificates"`
+	OCSPResponses     int                 `json:"ocspResponses"`
+	CRLs              int                 `json:"crls"`
+	DocumentTimestamp bool                `json:"documentTimestamp"`
+}
+
+// DocumentTimestampResult describes the document timestamp added by TimestampPDF.
+type DocumentTimestampResult struct {
+	OutputPath string         `json:"outputPath"`
+	Timestamp  *TimestampInfo `json:"timestamp,omitempty"`
+}
+
+// ArchiveRenewResult lists the documents RenewArchive checked and what was done with each.
+type ArchiveRenewResult struct {
+	Directory    string              `json:"directory"`
+	ManifestPath string              `json:"manifestPath"`
+	DryRun       bool                `json:"dryRun"`
+	Files        []ArchiveFileResult `json:"files"`
+	Renewed      int                 `json:"renewed"`
+	Due          int                 `json:"due"`
+	Failed       int                 `json:"failed"`
+}
+
+// ArchiveFileResult is the state of one archived document. Status is "renewed", "due" (in a
+// dry run), "current", "unsigned" or "failed"; Reason says why a document needed renewal.
+type ArchiveFileResult struct {
+	File          string     `json:"file"` // relative to the archive directory
+	Status        string     `json:"status"`
+	Reason        string     `json:"reason,omitempty"`
+	TimestampTime *time.Time `json:"timestampTime,omitempty"` // latest document timestamp
+	ExpiresAt     *time.Time `json:"expiresAt,omitempty"`     // its TSA certificate expiry
+	RenewedUntil  *time.Time `json:"renewedUntil,omitempty"`  // expiry of the new timestamp
+	Warnings      []string   `json:"warnings,omitempty"`
+	Error         string     `json:"error,omitempty"`
+}
+
+// ArchiveManifest records every renewal made in an archive directory.
+type ArchiveManifest struct {
+	Version   int              `json:"version"`
+	UpdatedAt time.Time        `json:"updatedAt"`
+	Renewals  []ArchiveRenewal `json:"renewals"`
+}
+
+// ArchiveRenewal is one renewal of a document: the validation data and document timestamp added,
+// the expiry it replaced and the digest of the renewed file.
+type ArchiveRenewal struct {
+	File           string     `json:"file"`
+	RenewedAt      time.Time  `json:"renewedAt"`
+	Reason         string     `json:"reason"`
+	PreviousExpiry *time.Time `json:"previousExpiry,omitempty"`
+	TimestampTime  time.Time  `json:"timestampTime"`
+	ExpiresAt      time.Time  `json:"expiresAt"`
+	Authority      string     `json:"authority"`
+	SHA256         string     `json:"sha256"`
+}
+
+// ProfileCertificate is the certificate a signature profile is bound to. PINCached reports
+// whether its PIN is cached under the PIN policy of the profile.
+type ProfileCertificate struct {
+	Certificate Certificate `json:"certificate"`
+	PINCached   bool        `json:"pinCached"`
+}
+
+// AppearancePreview is the rendered appearance of a signature: its text lines, the custom text
+// alone, and for visible signatures the PNG image as a data URL.
+type AppearancePreview struct {
+	Lines      []string `json:"lines"`
+	CustomText string   `json:"customText"`
+	Image      string   `json:"image,omitempty"`
+}
+
+// ProfileBundleManifest describes a signature profile bundle: its format version, when it was
+// exported and the files of each profile.
+type ProfileBundleManifest struct {
+	Format     string               `json:"format"`
+	Version    int                  `json:"version"`
+	ExportedAt time.Time            `json:"exportedAt"`
+	Profiles   []ProfileBundleEntry `json:"profiles"`
+}
+
+// ProfileBundleEntry lists the files of one profile in a bundle. Logo and Font are empty when
+// the profile has none.
+type ProfileBundleEntry struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	File string `json:"file"`
+	Logo string `json:"logo,omitempty"`
+	Font string `json:"font,omitempty"`
+}
+
+// ProfileImportResult lists what importing a profile bundle did with each of its profiles.
+type ProfileImportResult struct {
+	Version    int                  `json:"version"`
+	ExportedAt time.Time            `json:"exportedAt"`
+	Profiles   []ProfileImportEntry `json:"profiles"`
+}
+
+// ProfileImportEntry is the outcome for one imported profile: "imported", "replaced", "renamed"
+// or "skipped". ID differs from OriginalID when the profile was renamed.
+type ProfileImportEntry struct {
+	ID         string `json:"id"`
+	OriginalID string `json:"originalId"`
+	Name       string `json:"name"`
+	Action     string `json:"action"`
+}
+
+// ExtendedSignature lists the validation data recorded in the VRI entry of one signature.
+// Warnings name the certificates whose chain or revocation status could not be completed.
+type ExtendedSignature struct {
+	Index         int      `json:"index"`
+	FieldName     string   `json:"fieldName,omitempty"`
+	SignerName    string   `json:"signerName,omitempty"`
+	VRIKey        string   `json:"vriKey"`
+	Certificates  int      `json:"certificates"`
+	OCSPResponses int      `json:"ocspResponses"`
+	CRLs          int      `json:"crls"`
+	Warnings      []string `json:"warnings,omitempty"`
+}
+
+// ByteRangeCoverage describes which bytes of the file a signature covers. A signature
+// covers the whole file when its two ranges span everything but its /Contents value.
+type ByteRangeCoverage struct {
+	Ranges              []int64 `json:"ranges"`
+	CoveredBytes        int64   `json:"coveredBytes"`
+	FileSize            int64   `json:"fileSize"`
+	CoversWholeFile     bool    `json:"coversWholeFile"`
+	BytesAfterSignature int64   `json:"bytesAfterSignature"`
+	Error               string  `json:"error,omitempty"`
+}
+
+// SignerCertificateStatus is one certificate embedded with a signature, starting with the signer.
+type SignerCertificateStatus struct {
+	SubjectDN         string     `json:"subjectDN"`
+	IssuerDN          string     `json:"issuerDN"`
+	SerialNumberHex   string     `json:"serialNumberHex"`
+	FingerprintSHA256 string     `json:"fingerprintSHA256"`
+	NotBefore         time.Time  `json:"notBefore"`
+	NotAfter          time.Time  `json:"notAfter"`
+	Status            string     `json:"status"` // "valid", "revoked" or "invalid"
+	Errors            []string   `json:"errors"`
+	Warnings          []string   `json:"warnings"`
+	RevocationSources []string   `json:"revocationSources"`
+	RevocationTime    *time.Time `json:"revocationTime,omitempty"`
+}
+
+// TrustAnchorMatch identifies the root a verified chain ended at. Source is "system" for the
+// operating system roots, or the path of the configured trust anchor.
+type TrustAnchorMatch struct {
+	SubjectDN         string `json:"subjectDN"`
+	FingerprintSHA256 string `json:"fingerprintSHA256"`
+	Source            string `json:"source"`
+}
+
+// TimestampInfo describes the RFC 3161 timestamp token of a signature.
+type TimestampInfo struct {
+	Time          time.Time `json:"time"`
+	Status        string    `json:"status"`
+	Trusted       bool      `json:"trusted"`
+	HashAlgorithm string    `json:"hashAlgorithm"`
+	SerialNumber  string    `json:"serialNumber"`
+	Policy        string    `json:"policy,omitempty"`
+	Accuracy      string    `json:"accuracy,omitempty"`
+	Authority     string    `json:"authority,omitempty"`
+}
+
+// DocMDPInfo is the modification permission level set by a certification signature.
+type DocMDPInfo struct {
+	Level       int    `json:"level"`
+	Description string `json:"description"`
+}
+
+// FieldMDPInfo lists the form fields a signature locks against later changes. Action is
+// "All", "Include" (only Fields are locked) or "Exclude" (all but Fields are locked).
+type FieldMDPInfo struct {
+	Action string   `json:"action"`
+	Fields []string `json:"fields,omitempty"`
 }
@@ -1,9 +1,6 @@
 package signature
 
 import (
-	"fmt"
-	"os"
-	"strings"
 	"time"
 
 	"github.com/Matbe34/lankir/internal/signature/types"
@@ -11,31 +8,22 @@ import (
 )
 
 // VerifySignatures validates all digital signatures in a PDF and returns their status.
+// GetVerificationReport returns the same signatures with full details.
 func (s *SignatureService) VerifySignatures(pdfPath string) ([]types.SignatureInfo, error) {
-	file, err := os.Open(pdfPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open PDF: %w", err)
-	}
-	defer file.Close()
+	return s.VerifySignaturesWithOptions(pdfPath, VerifyOptions{})
+}
 
-	response, err := verify.VerifyFile(file)
+// VerifySignaturesWithOptions is VerifySignatures at a chosen validation time, or offline with
+// only the validation data in the document and the supplied files.
+func (s *SignatureService) VerifySignaturesWithOptions(pdfPath string, opts VerifyOptions) ([]types.SignatureInfo, error) {
+	report, err := s.GetVerificationReportWithOptions(pdfPath, opts)
 	if err != nil {
-		errMsg := err.Error()
-
-		if strings.Contains(errMsg, "no digital signature in document") {
-			return []types.SignatureInfo{}, nil
-		}
-		return nil, fmt.Errorf("verification failed: %w", err)
+		return nil, err
 	}
 
-	if len(response.Signers) == 0 {
-		return []types.SignatureInfo{}, nil
-	}
-
-	var signatures []types.SignatureInfo
-	for _, signer := range response.Signers {
-		sigInfo := s.convertSignerToInfo(signer, response.Error)
-		signatures = append(signatures, sigInfo)
+	signatures := []types.SignatureInfo{}
+	for _, sig := range report.Signatures {
+		signatures = append(signatures, sig.Summary)
 	}
 
 	return signatures, nil
@@ -68,9 +56,6 @@ func (s *SignatureService) convertSignerToInfo(signer verify.Signer, responseErr
 					info.SignerName = cert.Subject.String()
 				}
 			}
-			info.SignatureType = cert.SignatureAlgorithm.String()
-			info.SigningHashAlgorithm = cert.PublicKeyAlgorithm.String()
-
 			if certWrapper.VerifyError != "" {
 				if info.CertificateValidationMessage != "" {
 					info.CertificateValidationMessage += "; " + certWrapper.VerifyError
@@ -114,10 +99,13 @@ func (s *SignatureService) convertSignerToInfo(signer verify.Signer, responseErr
 	} else if signer.TrustedIssuer {
 		info.CertificateValidationMessage = "Certificate is valid and trusted"
 	} else {
-		info.CertificateValidationMessage = "Certificate chain validation issue (not in system trust store)"
+		info.CertificateValidationMessage = "Certificate chain validation issue (not in system trust store or trust anchors)"
 
 		if len(signer.Certificates) > 0 {
 			certWrapper := signer.Certificates[0]
+			if certWrapper.VerifyError != "" {
+				info.CertificateValidationMessage += "; " + certWrapper.VerifyError
+			}
 			if certWrapper.KeyUsageError != "" {
 				info.CertificateValidationMessage += "; Key usage: " + certWrapper.KeyUsageError
 			}
@@ -0,0 +1,203 @@
+package signature
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Matbe34/lankir/internal/config"
+	"github.com/Matbe34/lankir/internal/signature/pkcs11"
+	"github.com/Matbe34/lankir/internal/signature/pkcs12"
+	"github.com/fsnotify/fsnotify"
+)
+
+// TokenPollInterval is how often PKCS#11 modules are polled for token insertion or removal.
+const TokenPollInterval = 10 * time.Second
+
+// certificateWatcher invalidates the certificate index when a certificate
+// store directory, the NSS database or the set of present tokens changes.
+type certificateWatcher struct {
+	mu         sync.Mutex
+	fsWatcher  *fsnotify.Watcher
+	watched    map[string]bool
+	invalidate func()
+	paths      func() []string
+	cancel     context.CancelFunc
+}
+
+func newCertificateWatcher(invalidate func()) *certificateWatcher {
+	return &certificateWatcher{
+		watched:    make(map[string]bool),
+		invalidate: invalidate,
+	}
+}
+
+// start begins watching the store paths and polling the token modules until ctx
+// is cancelled or stop is called. Paths are re-read when directories are created
+// or removed so new subdirectories of recursive stores are watched too.
+func (w *certificateWatcher) start(ctx context.Context, paths func() []string, tokenLibraries func() []string) error {
+	w.stop()
+
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	w.mu.Lock()
+	w.fsWatcher = fsWatcher
+	w.paths = paths
+	w.cancel = cancel
+	w.mu.Unlock()
+
+	w.setPaths(paths())
+
+	go w.watchFilesystem(ctx, fsWatcher)
+	go w.pollTokens(ctx, tokenLibraries)
+
+	return nil
+}
+
+// stop releases the filesystem watches and stops token polling.
+func (w *certificateWatcher) stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.cancel != nil {
+		w.cancel()
+		w.cancel = nil
+	}
+	if w.fsWatcher != nil {
+		w.fsWatcher.Close()
+		w.fsWatcher = nil
+	}
+	w.watched = make(map[string]bool)
+}
+
+// setPaths replaces the set of watched paths, adding new ones and removing stale ones.
+func (w *certificateWatcher) setPaths(paths []string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.fsWatcher == nil {
+		return
+	}
+
+	wanted := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		wanted[filepath.Clean(path)] = true
+	}
+
+	for path := range w.watched {
+		if !wanted[path] {
+			w.fsWatcher.Remove(path)
+			delete(w.watched, path)
+		}
+	}
+
+	for path := range wanted {
+		if w.watched[path] {
+			continue
+		}
+		if err := w.fsWatcher.Add(path); err != nil {
+			slog.Debug("failed to watch certificate path", "path", path, "error", err)
+			continue
+		}
+		w.watched[path] = true
+	}
+}
+
+// refreshPaths re-reads the set of paths to watch.
+func (w *certificateWatcher) refreshPaths() {
+	w.mu.Lock()
+	paths := w.paths
+	w.mu.Unlock()
+
+	if paths != nil {
+		w.setPaths(paths())
+	}
+}
+
+func (w *certificateWatcher) watchFilesystem(ctx context.Context, fsWatcher *fsnotify.Watcher) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-fsWatcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			slog.Debug("certificate store changed", "path", event.Name, "op", event.Op.String())
+			w.invalidate()
+			if event.Has(fsnotify.Create) || event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+				w.refreshPaths()
+			}
+		case err, ok := <-fsWatcher.Errors:
+			if !ok {
+				return
+			}
+			slog.Debug("certificate watcher error", "error", err)
+		}
+	}
+}
+
+func (w *certificateWatcher) pollTokens(ctx context.Context, tokenLibraries func() []string) {
+	ticker := time.NewTicker(TokenPollInterval)
+	defer ticker.Stop()
+
+	var last string
+	first := true
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			snapshot := pkcs11.TokenSnapshot(tokenLibraries())
+			if !first && snapshot != last {
+				slog.Debug("PKCS#11 token set changed")
+				w.invalidate()
+			}
+			last = snapshot
+			first = false
+		}
+	}
+}
+
+// watchedCertificatePaths returns the directories whose changes affect the certificate inventory,
+// including the subdirectories that recursive stores descend into.
+func watchedCertificatePaths(cfg *config.Config) []string {
+	var paths []string
+	if cfg != nil {
+		for _, storePath := range cfg.CertificateStores {
+			opts := storeScanOptions(cfg, storePath)
+			if !opts.Recursive {
+				paths = append(paths, storePath)
+				continue
+			}
+
+			dirs, err := pkcs12.ScanDirectories(storePath, opts)
+			if err != nil {
+				paths = append(paths, storePath)
+				continue
+			}
+			paths = append(paths, dirs...)
+		}
+	}
+
+	if homeDir, err := os.UserHomeDir(); err == nil {
+		paths = append(paths, filepath.Join(homeDir, ".pki", "nssdb"))
+	}
+
+	return paths
+}
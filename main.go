@@ -46,6 +46,15 @@ func runGUI() {
 		pdfService.Startup(ctx)
 		recentFilesService.Startup(ctx)
 		signatureService.Startup(ctx)
+		signatureService.StartWatching(ctx)
+	}
+
+	onDomReady := func(ctx context.Context) {
+		signatureService.DomReady(ctx)
+	}
+
+	onShutdown := func(ctx context.Context) {
+		signatureService.Shutdown(ctx)
 	}
 
 	err = wails.Run(&options.App{
@@ -57,6 +66,8 @@ func runGUI() {
 		},
 		BackgroundColour: &options.RGBA{R: 27, G: 38, B: 54, A: 1},
 		OnStartup:        onStartup,
+		OnDomReady:       onDomReady,
+		OnShutdown:       onShutdown,
 		Bind: []interface{}{
 			app,
 			pdfService,